@@ -17,9 +17,13 @@
 package abi
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/DATxChain-Protocol/DATx/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -137,3 +141,24 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// revertSelector is the selector of the Error(string) reason returned by reverted
+// executions, as emitted by the revert and require statements of Solidity.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// UnpackRevert resolves the reason string of the data returned by a reverted
+// execution.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errors.New("abi: revert data without an Error(string) reason")
+	}
+	typ, err := NewType("string")
+	if err != nil {
+		return "", err
+	}
+	reason, err := toGoType(0, typ, data[4:])
+	if err != nil {
+		return "", err
+	}
+	return reason.(string), nil
+}
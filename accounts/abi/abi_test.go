@@ -418,3 +418,30 @@ func TestBareEvents(t *testing.T) {
 		}
 	}
 }
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason string
+		err    bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000001", "", true},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", false},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000", "", false},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d7265766572", "", true},
+		{"08c379a0000000000000000000000000000000000000000000000000ffffffffffffffe0000000000000000000000000000000000000000000000000000000000000000d", "", true},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000ffffffffffffffff", "", true},
+	}
+	for i, tt := range tests {
+		reason, err := UnpackRevert(common.Hex2Bytes(tt.input))
+		if (err != nil) != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, tt.err)
+			continue
+		}
+		if reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, tt.reason)
+		}
+	}
+}
@@ -20,8 +20,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -36,11 +38,14 @@ import (
 	"github.com/DATxChain-Protocol/DATx/event"
 	"github.com/DATxChain-Protocol/DATx/log"
 	"github.com/DATxChain-Protocol/DATx/trie"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"gopkg.in/urfave/cli.v1"
 )
 
 var (
+	copydbRawFlag = cli.BoolFlag{
+		Name:  "raw",
+		Usage: "Copy the database entries verbatim instead of resyncing the chain from them",
+	}
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initGenesis),
 		Name:      "init",
@@ -48,6 +53,7 @@ var (
 		ArgsUsage: "<genesisPath>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.LightModeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -65,7 +71,10 @@ It expects the genesis file as argument.`,
 		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
+			utils.GCModeFlag,
+			utils.SnapshotFlag,
 			utils.LightModeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -83,6 +92,7 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 		},
@@ -92,6 +102,45 @@ Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing.`,
+	}
+	exportStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportState),
+		Name:      "export-state",
+		Usage:     "Export a state checkpoint into file",
+		ArgsUsage: "<filename> [<blockNum>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-state command writes a checkpoint of the canonical block of the given
+number, the current head by default: the block, the headers of its ancestors
+and the full account state and DPoS tries at it, in chunks with their hashes.
+The file is compressed if its name ends in .gz.`,
+	}
+	importStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(importState),
+		Name:      "import-state",
+		Usage:     "Start the chain from a state checkpoint file",
+		ArgsUsage: "<filename> <blockHash>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-state command makes the block of a state checkpoint the head of a
+chain initialised with the same genesis block and holding no other blocks, so
+the node goes on from it without syncing the state. Every entry of the file is
+checked against the block, whose hash has to be given as the second argument:
+the seals of the block aren't verified, so only the checkpoint of a block known
+to be canonical should be trusted.
+
+The blocks below the checkpoint have headers only, and the ancient store stays
+unused.`,
 	}
 	copydbCommand = cli.Command{
 		Action:    utils.MigrateFlags(copyDb),
@@ -100,12 +149,20 @@ if already existing.`,
 		ArgsUsage: "<sourceChaindataDir>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			copydbRawFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
-The first argument must be the directory containing the blockchain to download from`,
+The first argument must be the directory containing the blockchain to download from.
+
+The source database is opened with the engine it was created with, while the
+local one is created with --db.engine, so copying migrates a chain between
+database engines. By default the chain is resynced from the source, verifying
+it along the way. With --raw, the database entries are copied verbatim instead.`,
 	}
 	removedbCommand = cli.Command{
 		Action:    utils.MigrateFlags(removeDB),
@@ -114,6 +171,7 @@ The first argument must be the directory containing the blockchain to download f
 		ArgsUsage: " ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.LightModeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -127,6 +185,7 @@ Remove blockchain and state databases`,
 		ArgsUsage: "[<blockHash> | <blockNum>]...",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 		},
@@ -212,13 +271,11 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*datxdb.LDBDatabase)
-
-	stats, err := db.LDB().GetProperty("leveldb.stats")
-	if err != nil {
-		utils.Fatalf("Failed to read database stats: %v", err)
+	stats, err := chainDb.Stat("leveldb.stats")
+	isLDB := err == nil
+	if isLDB {
+		fmt.Println(stats)
 	}
-	fmt.Println(stats)
 	fmt.Printf("Trie cache misses:  %d\n", trie.CacheMisses())
 	fmt.Printf("Trie cache unloads: %d\n\n", trie.CacheUnloads())
 
@@ -231,19 +288,19 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Allocations:   %.3f million\n", float64(mem.Mallocs)/1000000)
 	fmt.Printf("GC pause:      %v\n\n", time.Duration(mem.PauseTotalNs))
 
-	if ctx.GlobalIsSet(utils.NoCompactionFlag.Name) {
+	if ctx.GlobalIsSet(utils.NoCompactionFlag.Name) || !isLDB {
 		return nil
 	}
 
 	// Compact the entire database to more accurately measure disk io and print the stats
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = db.LDB().CompactRange(util.Range{}); err != nil {
+	if err := chainDb.Compact(nil, nil); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
-	stats, err = db.LDB().GetProperty("leveldb.stats")
+	stats, err = chainDb.Stat("leveldb.stats")
 	if err != nil {
 		utils.Fatalf("Failed to read database stats: %v", err)
 	}
@@ -284,11 +341,80 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+func exportState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	number := chain.CurrentBlock().NumberU64()
+	if len(ctx.Args()) > 1 {
+		n, err := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if err != nil {
+			utils.Fatalf("Export error: invalid block number %q", ctx.Args().Get(1))
+		}
+		number = n
+	}
+	start := time.Now()
+	if err := utils.ExportCheckpoint(chain, ctx.Args().First(), number); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func importState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires the file and the hash of its block as arguments.")
+	}
+	if len(common.FromHex(ctx.Args().Get(1))) != common.HashLength {
+		utils.Fatalf("Import error: invalid block hash %q", ctx.Args().Get(1))
+	}
+	trusted := common.HexToHash(ctx.Args().Get(1))
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if core.GetCanonicalHash(chainDb, 0) == (common.Hash{}) {
+		utils.Fatalf("Import error: no genesis block, run init first")
+	}
+	start := time.Now()
+	block, err := utils.ImportCheckpoint(chainDb, ctx.Args().First(), trusted)
+	if err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	fmt.Printf("Imported checkpoint of block #%d [%x] in %v\n", block.NumberU64(), block.Hash(), time.Since(start))
+	return nil
+}
+
 func copyDb(ctx *cli.Context) error {
 	// Ensure we have a source chain directory to copy
 	if len(ctx.Args()) != 1 {
 		utils.Fatalf("Source chaindata directory path argument missing")
 	}
+	// Open the source database with whatever engine it was created with
+	source := ctx.Args().First()
+	if datxdb.DetectEngine(source) == "" {
+		utils.Fatalf("No database found in %s", source)
+	}
+	db, err := datxdb.Open("", source, ctx.GlobalInt(utils.CacheFlag.Name), 256)
+	if err != nil {
+		return err
+	}
+	// Read the blocks frozen in the source's ancient store too, if it has one
+	if ancient := filepath.Join(source, "ancient"); common.FileExist(ancient) {
+		if db, err = datxdb.NewDatabaseWithFreezer(db, ancient); err != nil {
+			return err
+		}
+	}
+	defer db.Close()
+
+	if ctx.Bool(copydbRawFlag.Name) {
+		return copyRawDb(ctx, db)
+	}
 	// Initialize a new chain for the running node to sync into
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
@@ -297,10 +423,6 @@ func copyDb(ctx *cli.Context) error {
 	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
-	db, err := datxdb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
-	if err != nil {
-		return err
-	}
 	hc, err := core.NewHeaderChain(db, chain.Config(), chain.Engine(), func() bool { return false })
 	if err != nil {
 		return err
@@ -321,25 +443,126 @@ func copyDb(ctx *cli.Context) error {
 	}
 	fmt.Printf("Database copy done in %v\n", time.Since(start))
 
-	// Compact the entire database to remove any sync overhead
-	start = time.Now()
+	compactDb(chainDb)
+	return nil
+}
+
+// copyRawDb copies every entry of the source database verbatim into the local
+// chain database, which must be empty.
+func copyRawDb(ctx *cli.Context, db datxdb.Database) error {
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	it := chainDb.NewIteratorWithPrefix(nil, nil)
+	nonEmpty := it.Next()
+	it.Release()
+	if nonEmpty {
+		utils.Fatalf("Local chain database is not empty, refusing to copy into it")
+	}
+	if err := copyAncients(db, chainDb); err != nil {
+		return err
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		count  int
+		size   common.StorageSize
+		batch  = chainDb.NewBatch()
+	)
+	it = db.NewIteratorWithPrefix(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		count++
+		size += common.StorageSize(len(it.Key()) + len(it.Value()))
+
+		if batch.ValueSize() >= datxdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Copying database entries", "entries", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	fmt.Printf("Database copy of %d entries (%v) done in %v\n", count, size, time.Since(start))
+
+	compactDb(chainDb)
+	return nil
+}
+
+// copyAncients copies the blocks of the ancient store of the source database,
+// if it has one, into the ancient store of the local chain database.
+func copyAncients(db datxdb.Database, chainDb datxdb.Database) error {
+	src, ok := db.(datxdb.AncientReader)
+	if !ok {
+		return nil
+	}
+	frozen, err := src.Ancients()
+	if err != nil || frozen == 0 {
+		return err
+	}
+	dst, ok := chainDb.(datxdb.AncientStore)
+	if !ok {
+		utils.Fatalf("Local chain database has no ancient store to copy %d frozen blocks into", frozen)
+	}
+	if items, _ := dst.Ancients(); items > 0 {
+		utils.Fatalf("Local ancient store is not empty, refusing to copy into it")
+	}
+	start := time.Now()
+	for number := uint64(0); number < frozen; number++ {
+		var items [][]byte
+		for _, kind := range []string{datxdb.FreezerHashTable, datxdb.FreezerHeaderTable, datxdb.FreezerBodiesTable, datxdb.FreezerReceiptTable, datxdb.FreezerDifficultyTable} {
+			item, err := src.Ancient(kind, number)
+			if err != nil {
+				return fmt.Errorf("failed to read ancient %s #%d: %v", kind, number, err)
+			}
+			items = append(items, item)
+		}
+		if err := dst.AppendAncient(number, items[0], items[1], items[2], items[3], items[4]); err != nil {
+			return err
+		}
+	}
+	if err := dst.Sync(); err != nil {
+		return err
+	}
+	fmt.Printf("Ancient store copy of %d blocks done in %v\n", frozen, time.Since(start))
+	return nil
+}
+
+// compactDb compacts the entire database to remove any copy overhead.
+func compactDb(db datxdb.Database) {
+	start := time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*datxdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
+	if err := db.Compact(nil, nil); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
-
-	return nil
 }
 
 func removeDB(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 
-	for _, name := range []string{"chaindata", "lightchaindata"} {
+	dbdirs := []string{stack.ResolvePath("chaindata"), stack.ResolvePath("lightchaindata")}
+	if ancient := stack.ResolveAncient("chaindata"); !strings.HasPrefix(ancient, dbdirs[0]+string(filepath.Separator)) {
+		dbdirs = append(dbdirs, ancient)
+	}
+	for _, dbdir := range dbdirs {
 		// Ensure the database exists in the first place
-		logger := log.New("database", name)
+		logger := log.New("database", filepath.Base(dbdir))
 
-		dbdir := stack.ResolvePath(name)
 		if !common.FileExist(dbdir) {
 			logger.Info("Database doesn't exist, skipping", "path", dbdir)
 			continue
@@ -32,6 +32,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/contracts/release"
 	"github.com/DATxChain-Protocol/DATx/dashboard"
 	"github.com/DATxChain-Protocol/DATx/datx"
+	"github.com/DATxChain-Protocol/DATx/graphql"
 	"github.com/DATxChain-Protocol/DATx/node"
 	"github.com/DATxChain-Protocol/DATx/params"
 	whisper "github.com/DATxChain-Protocol/DATx/whisper/whisperv5"
@@ -172,6 +173,13 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
+	// Add the GraphQL endpoint if requested.
+	if ctx.GlobalBool(utils.GraphQLEnabledFlag.Name) {
+		config := graphql.DefaultConfig
+		config.MaxCost = ctx.GlobalInt(utils.GraphQLMaxCostFlag.Name)
+		utils.RegisterGraphQLService(stack, config)
+	}
+
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
@@ -0,0 +1,124 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of go-DATx.
+//
+// go-DATx is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-DATx is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-DATx. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/DATxChain-Protocol/DATx/cmd/utils"
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	inspectJSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the breakdown as JSON",
+	}
+	inspectUnknownFlag = cli.IntFlag{
+		Name:  "unknown",
+		Value: 16,
+		Usage: "Maximum number of unknown keys to list",
+	}
+	inspectBloomSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Value: core.DefaultInspectBloomSize,
+		Usage: "Megabytes of memory allocated to the bloom filter of the head state",
+	}
+	dbCommand = cli.Command{
+		Name:     "db",
+		Usage:    "Low level database operations",
+		Category: "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Break down the chain database by the data stored",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectDb),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					inspectJSONFlag,
+					inspectUnknownFlag,
+					inspectBloomSizeFlag,
+				},
+				Description: `
+    gdatx db inspect [--json] [--unknown <n>] [--bloomfilter.size <MB>]
+
+iterates over the entire chain database and reports the number and size of
+the entries of every kind of data: headers, bodies, receipts, transaction
+lookups, bloombits, the account and storage tries, contract codes, the DPoS
+tries, preimages and so on.
+
+Trie nodes and codes are attributed to their tries by walking the state of
+the head block. Those only reachable from older blocks are reported as
+unreferenced state, which pruning the state would delete. The nodes of the
+head state are recorded in a bloom filter, so a few unreferenced ones may be
+attributed to it, less the larger the filter. Keys matching no
+known kind of data are reported as unknown and some of them listed.`,
+			},
+		},
+	}
+)
+
+// inspectDb iterates over the chain database and prints the number and size of
+// its entries per category.
+func inspectDb(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	result, err := core.InspectDatabase(chainDb, ctx.Int(inspectUnknownFlag.Name), ctx.Uint64(inspectBloomSizeFlag.Name))
+	if err != nil {
+		utils.Fatalf("Database inspection failed: %v", err)
+	}
+	if ctx.Bool(inspectJSONFlag.Name) {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Category", "Entries", "Size"})
+	table.SetFooter([]string{result.Total.Category, fmt.Sprint(result.Total.Count), common.StorageSize(result.Total.Size).String()})
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	for _, stat := range result.Stats {
+		table.Append([]string{stat.Category, fmt.Sprint(stat.Count), common.StorageSize(stat.Size).String()})
+	}
+	table.Render()
+
+	if !result.HeadState && result.Total.Count > 0 {
+		fmt.Printf("State of head block %d unavailable, all trie nodes and codes reported as unreferenced\n", result.Head)
+	}
+	if len(result.Unknown) > 0 {
+		fmt.Println("Unknown keys:")
+		for _, key := range result.Unknown {
+			fmt.Printf("  %v\n", key)
+		}
+	}
+	return nil
+}
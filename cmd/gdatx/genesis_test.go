@@ -21,6 +21,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/datxdb"
 )
 
 var customGenesisTests = []struct {
@@ -108,3 +110,26 @@ func TestCustomGenesis(t *testing.T) {
 		gdatx.ExpectExit()
 	}
 }
+
+// Tests that a data directory initialized on any database engine starts up and
+// serves its chain, the chain database being opened with the ancient store.
+func TestDatabaseEngines(t *testing.T) {
+	genesis := customGenesisTests[1]
+	for _, engine := range datxdb.Engines() {
+		datadir := tmpdir(t)
+		defer os.RemoveAll(datadir)
+
+		json := filepath.Join(datadir, "genesis.json")
+		if err := ioutil.WriteFile(json, []byte(genesis.genesis), 0600); err != nil {
+			t.Fatalf("%s: failed to write genesis file: %v", engine, err)
+		}
+		runGdatx(t, "--datadir", datadir, "--db.engine", engine, "init", json).WaitExit()
+
+		gdatx := runGdatx(t,
+			"--datadir", datadir, "--db.engine", engine, "--maxpeers", "0", "--port", "0",
+			"--nodiscover", "--nat", "none", "--ipcdisable",
+			"--exec", genesis.query, "console")
+		gdatx.ExpectRegexp(genesis.result)
+		gdatx.ExpectExit()
+	}
+}
@@ -59,6 +59,8 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.DBEngineFlag,
+		utils.AncientFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
@@ -67,6 +69,7 @@ var (
 		utils.DashboardRefreshFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
+		utils.TxPoolRemoteJournalFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
@@ -75,9 +78,15 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolReservedFlag,
+		utils.TxPoolSenderLimitFlag,
+		utils.TxPoolSenderWindowFlag,
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
+		utils.GCModeFlag,
+		utils.SnapshotFlag,
+		utils.AddressIndexFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
@@ -98,6 +107,7 @@ var (
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMRevertDataFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.EthStatsURLFlag,
@@ -119,6 +129,12 @@ var (
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.RPCSizeLimitFlag,
+		utils.RPCBatchLimitFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.GraphQLEnabledFlag,
+		utils.GraphQLMaxCostFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 	}
@@ -140,9 +156,15 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importStateCommand,
+		exportStateCommand,
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See snapshot.go:
+		snapshotCommand,
+		// See dbcmd.go:
+		dbCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
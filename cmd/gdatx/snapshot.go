@@ -0,0 +1,147 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of go-DATx.
+//
+// go-DATx is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-DATx is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-DATx. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/cmd/utils"
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state/pruner"
+	"github.com/DATxChain-Protocol/DATx/core/state/snapshot"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	pruneBlocksFlag = cli.Uint64Flag{
+		Name:  "blocks",
+		Value: pruner.DefaultBlocks,
+		Usage: "Number of recent blocks whose state is retained",
+	}
+	pruneBloomSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Value: pruner.DefaultBloomSize,
+		Usage: "Megabytes of memory allocated to the bloom filter of retained state",
+	}
+	pruneDryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Only report the space pruning would reclaim, without deleting anything",
+	}
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Manage the state of the chain database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+    gdatx snapshot prune-state
+    gdatx snapshot verify-state
+
+deletes the historical state from the chain database of a full node, or
+checks the flat state snapshot against the state trie.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Prune the state of all but the most recent blocks",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(pruneState),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+					pruneBlocksFlag,
+					pruneBloomSizeFlag,
+					pruneDryRunFlag,
+				},
+				Description: `
+    gdatx snapshot prune-state [--blocks <n>] [--dry-run]
+
+deletes every account trie node, storage trie node, DPoS trie node and
+contract code that is not reachable from the state of the genesis or of the
+last n blocks. The node must not be running while pruning.
+
+Reachable state is first marked in a bloom filter, which is saved to the data
+directory before anything is deleted. If pruning is interrupted, it's finished
+the next time the command or the node is started. With --dry-run, the space
+that would be reclaimed is only reported.`,
+			},
+			{
+				Name:      "verify-state",
+				Usage:     "Check the flat state snapshot against the state trie",
+				ArgsUsage: "[<root>]",
+				Action:    utils.MigrateFlags(verifyState),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+    gdatx snapshot verify-state [<root>]
+
+checks that the flat state snapshot kept with --snapshot holds exactly the
+accounts and storage slots of the state trie of the head block, or of the
+given state root. The snapshot is flattened to the head state whenever the
+node shuts down cleanly, otherwise it's rebuilt on the next start.`,
+			},
+		},
+	}
+)
+
+// pruneState deletes the historical state of the chain database.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+
+	p := pruner.NewPruner(chainDb, stack.ResolvePath(""), ctx.Uint64(pruneBloomSizeFlag.Name))
+	if err := p.Prune(ctx.Uint64(pruneBlocksFlag.Name), ctx.Bool(pruneDryRunFlag.Name)); err != nil {
+		utils.Fatalf("State pruning failed: %v", err)
+	}
+	log.Info("State pruning done", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyState checks the flat state snapshot against the state trie of the
+// head block or of the given root.
+func verifyState(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command requires at most one argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	var root common.Hash
+	if len(ctx.Args()) == 1 {
+		root = common.HexToHash(ctx.Args().First())
+	} else {
+		hash := core.GetHeadBlockHash(chainDb)
+		head := core.GetHeader(chainDb, hash, core.GetBlockNumber(chainDb, hash))
+		if head == nil {
+			utils.Fatalf("Head block missing")
+		}
+		root = head.Root
+	}
+	if err := snapshot.Verify(chainDb, chainDb, root); err != nil {
+		utils.Fatalf("State snapshot verification failed: %v", err)
+	}
+	return nil
+}
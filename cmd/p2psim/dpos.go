@@ -0,0 +1,252 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of go-DATx.
+//
+// go-DATx is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-DATx is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-DATx. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/node"
+	"github.com/DATxChain-Protocol/DATx/p2p/simulations"
+	"github.com/DATxChain-Protocol/DATx/p2p/simulations/adapters"
+	"github.com/DATxChain-Protocol/DATx/p2p/simulations/dpossim"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// dposConfigEnv is the environment variable pointing simulated validators to
+// the shared network configuration. It's inherited by exec adapter nodes.
+const dposConfigEnv = "P2PSIM_DPOS_CONFIG"
+
+func init() {
+	adapters.RegisterServices(adapters.Services{
+		dpossim.ServiceName: newDposService,
+	})
+}
+
+// newDposService boots a simulated validator sealing with the node key.
+func newDposService(ctx *adapters.ServiceContext) (node.Service, error) {
+	config, err := dpossim.LoadConfig(os.Getenv(dposConfigEnv))
+	if err != nil {
+		return nil, err
+	}
+	return dpossim.NewService(ctx.NodeContext, ctx.Config.PrivateKey, config)
+}
+
+var dposCommand = cli.Command{
+	Name:  "dpos",
+	Usage: "simulate networks of DPoS validators",
+	Subcommands: []cli.Command{
+		{
+			Name:   "serve",
+			Usage:  "boot a validator network and serve the simulation API",
+			Action: serveDpos,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "validators",
+					Value: 21,
+					Usage: "number of validators in the genesis",
+				},
+				cli.Uint64Flag{
+					Name:  "epoch",
+					Value: dpossim.DefaultEpoch,
+					Usage: "epoch length in seconds",
+				},
+				cli.StringFlag{
+					Name:  "adapter",
+					Value: "sim",
+					Usage: `node adapter to use (one of "sim" or "exec")`,
+				},
+				cli.StringFlag{
+					Name:  "addr",
+					Value: "localhost:8888",
+					Usage: "simulation API listening address",
+				},
+			},
+		},
+		{
+			Name:      "run",
+			ArgsUsage: "<scenario.json>",
+			Usage:     "run a fault injection scenario against a served network",
+			Action:    runDpos,
+			Flags: []cli.Flag{
+				cli.Uint64Flag{
+					Name:  "epoch",
+					Value: dpossim.DefaultEpoch,
+					Usage: "epoch length in seconds of the served network",
+				},
+			},
+		},
+	},
+}
+
+// serveDpos creates a fully connected network of validators sharing a DPoS
+// genesis and serves it over the simulation HTTP API.
+func serveDpos(ctx *cli.Context) error {
+	workdir, err := ioutil.TempDir("", "p2psim-dpos")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workdir)
+
+	var adapter adapters.NodeAdapter
+	switch ctx.String("adapter") {
+	case "sim":
+		adapter = adapters.NewSimAdapter(adapters.Services{dpossim.ServiceName: newDposService})
+	case "exec":
+		adapter = adapters.NewExecAdapter(workdir)
+	default:
+		return fmt.Errorf("unknown node adapter %q", ctx.String("adapter"))
+	}
+	// Create the node configs first, the genesis needs all their keys
+	var (
+		confs = make([]*adapters.NodeConfig, ctx.Int("validators"))
+		keys  = make([]*ecdsa.PrivateKey, len(confs))
+	)
+	for i := range confs {
+		confs[i] = adapters.RandomNodeConfig()
+		confs[i].Name = fmt.Sprintf("validator%02d", i)
+		confs[i].Services = []string{dpossim.ServiceName}
+		keys[i] = confs[i].PrivateKey
+	}
+	config := dpossim.NewConfig(keys, ctx.Uint64("epoch"))
+	path := filepath.Join(workdir, "dpos.json")
+	if err := config.Save(path); err != nil {
+		return err
+	}
+	os.Setenv(dposConfigEnv, path)
+
+	network := simulations.NewNetwork(adapter, &simulations.NetworkConfig{
+		DefaultService: dpossim.ServiceName,
+	})
+	defer network.Shutdown()
+
+	for _, conf := range confs {
+		if _, err := network.NewNodeWithConfig(conf); err != nil {
+			return err
+		}
+	}
+	if err := network.StartAll(); err != nil {
+		return err
+	}
+	for i, one := range confs {
+		for _, other := range confs[i+1:] {
+			if err := network.Connect(one.ID, other.ID); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("Serving DPoS simulation", "validators", len(confs), "epoch", ctx.Uint64("epoch"), "addr", ctx.String("addr"))
+	return http.ListenAndServe(ctx.String("addr"), simulations.NewServer(network))
+}
+
+// runDpos executes a scenario against the network served by "dpos serve" and
+// prints the resulting report.
+func runDpos(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	scenario, err := dpossim.LoadScenario(args[0])
+	if err != nil {
+		return err
+	}
+	runner := dpossim.NewRunner(&clientNetwork{clients: make(map[string]*rpc.Client)}, ctx.Uint64("epoch"))
+	report, err := runner.Run(context.Background(), scenario)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(ctx.App.Writer)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if report.Failed() {
+		return fmt.Errorf("scenario failed: %d invariant violations, %d failed expectations", len(report.Violations), len(report.Failures))
+	}
+	return nil
+}
+
+// clientNetwork implements dpossim.Network on top of the simulation HTTP API.
+type clientNetwork struct {
+	clients map[string]*rpc.Client
+	lock    sync.Mutex
+}
+
+func (n *clientNetwork) Nodes() ([]string, error) {
+	nodes, err := client.GetNodes()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name
+	}
+	return names, nil
+}
+
+func (n *clientNetwork) Start(node string) error {
+	n.drop(node)
+	return client.StartNode(node)
+}
+
+func (n *clientNetwork) Stop(node string) error {
+	n.drop(node)
+	return client.StopNode(node)
+}
+
+func (n *clientNetwork) Connect(one, other string) error {
+	return client.ConnectNode(one, other)
+}
+
+func (n *clientNetwork) Disconnect(one, other string) error {
+	return client.DisconnectNode(one, other)
+}
+
+func (n *clientNetwork) Client(node string) (*rpc.Client, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if c, ok := n.clients[node]; ok {
+		return c, nil
+	}
+	c, err := client.RPCClient(context.Background(), node)
+	if err != nil {
+		return nil, err
+	}
+	n.clients[node] = c
+	return c, nil
+}
+
+// drop closes the cached RPC client of a node being restarted or stopped.
+func (n *clientNetwork) drop(node string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if c, ok := n.clients[node]; ok {
+		c.Close()
+		delete(n.clients, node)
+	}
+}
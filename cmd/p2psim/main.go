@@ -18,6 +18,13 @@
 //     $ p2psim node connect node01 node02
 //     Connected node01 to node02
 //
+// It can also boot a network of DPoS validators sharing a genesis and run fault
+// injection scenarios against it:
+//
+//     $ p2psim dpos serve --validators 21 --epoch 420
+//
+//     $ p2psim dpos run scenario.json
+//
 package main
 
 import (
@@ -31,7 +38,7 @@ import (
 
 	"github.com/DATxChain-Protocol/DATx/crypto"
 	"github.com/DATxChain-Protocol/DATx/p2p"
-	"github.com/DATxChain-Protocol/DATx/p2p/discover"
+	"github.com/DATxChain-Protocol/DATx/p2p/enode"
 	"github.com/DATxChain-Protocol/DATx/p2p/simulations"
 	"github.com/DATxChain-Protocol/DATx/p2p/simulations/adapters"
 	"github.com/DATxChain-Protocol/DATx/rpc"
@@ -163,6 +170,7 @@ func main() {
 				},
 			},
 		},
+		dposCommand,
 	}
 	app.Run(os.Args)
 }
@@ -267,7 +275,7 @@ func createNode(ctx *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		config.ID = discover.PubkeyID(&privKey.PublicKey)
+		config.ID = enode.PubkeyToIDV4(&privKey.PublicKey)
 		config.PrivateKey = privKey
 	}
 	if services := ctx.String("services"); services != "" {
@@ -18,6 +18,7 @@
 package utils
 
 import (
+	"bufio"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -26,8 +27,10 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/DATxChain-Protocol/DATx/common"
 	"github.com/DATxChain-Protocol/DATx/core"
 	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
 	"github.com/DATxChain-Protocol/DATx/internal/debug"
 	"github.com/DATxChain-Protocol/DATx/log"
 	"github.com/DATxChain-Protocol/DATx/node"
@@ -214,3 +217,44 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	log.Info("Exported blockchain to", "file", fn)
 	return nil
 }
+
+// ExportCheckpoint writes a state checkpoint of the canonical block of the given
+// number to a file, compressed if its name ends in .gz.
+func ExportCheckpoint(blockchain *core.BlockChain, fn string, number uint64) error {
+	log.Info("Exporting state checkpoint", "file", fn)
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	if err := blockchain.ExportCheckpoint(writer, number); err != nil {
+		return err
+	}
+	log.Info("Exported state checkpoint", "file", fn)
+	return nil
+}
+
+// ImportCheckpoint reads a state checkpoint from a file into the database, see
+// core.ImportCheckpoint. Files with names ending in .gz are decompressed.
+func ImportCheckpoint(db datxdb.Database, fn string, trusted common.Hash) (*types.Block, error) {
+	log.Info("Importing state checkpoint", "file", fn)
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = bufio.NewReader(fh)
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	return core.ImportCheckpoint(db, reader, trusted)
+}
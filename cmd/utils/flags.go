@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -41,6 +42,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/datx/gasprice"
 	"github.com/DATxChain-Protocol/DATx/datxdb"
 	"github.com/DATxChain-Protocol/DATx/datxstats"
+	"github.com/DATxChain-Protocol/DATx/graphql"
 	"github.com/DATxChain-Protocol/DATx/les"
 	"github.com/DATxChain-Protocol/DATx/log"
 	"github.com/DATxChain-Protocol/DATx/metrics"
@@ -51,6 +53,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/p2p/nat"
 	"github.com/DATxChain-Protocol/DATx/p2p/netutil"
 	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rpc"
 	whisper "github.com/DATxChain-Protocol/DATx/whisper/whisperv5"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -114,6 +117,14 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Database engine of new databases (" + strings.Join(datxdb.Engines(), ", ") + "), existing ones keep theirs",
+	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for the ancient chain data (default = inside chaindata)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -151,6 +162,19 @@ var (
 		Value: &defaultSyncMode,
 	}
 
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: "full",
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Serve state reads from a flat snapshot of the state (built in the background on first start)",
+	}
+	AddressIndexFlag = cli.BoolFlag{
+		Name:  "addressindex",
+		Usage: "Index the transactions sent from and to each address (built in the background)",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -195,6 +219,10 @@ var (
 		Usage: "Disk journal for local transaction to survive node restarts",
 		Value: core.DefaultTxPoolConfig.Journal,
 	}
+	TxPoolRemoteJournalFlag = cli.StringFlag{
+		Name:  "txpool.remotejournal",
+		Usage: "Disk journal for remote transactions to survive node restarts (disabled if empty)",
+	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
 		Usage: "Time interval to regenerate the local transaction journal",
@@ -235,6 +263,21 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: datx.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolReservedFlag = cli.Uint64Flag{
+		Name:  "txpool.reserved",
+		Usage: "Transaction slots reserved for DPoS candidate and delegation transactions",
+		Value: datx.DefaultConfig.TxPool.Policy.ReservedSlots,
+	}
+	TxPoolSenderLimitFlag = cli.Uint64Flag{
+		Name:  "txpool.senderlimit",
+		Usage: "Maximum number of remote transactions accepted per sender and window (0 = no limit)",
+		Value: datx.DefaultConfig.TxPool.Policy.SenderLimit,
+	}
+	TxPoolSenderWindowFlag = cli.DurationFlag{
+		Name:  "txpool.senderwindow",
+		Usage: "Time window of the sender limit",
+		Value: datx.DefaultConfig.TxPool.Policy.SenderWindow,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -291,6 +334,10 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMRevertDataFlag = cli.BoolFlag{
+		Name:  "vmrevertdata",
+		Usage: "Store the data returned by reverted transactions in their receipts",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "datxstats",
@@ -361,6 +408,32 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	RPCSizeLimitFlag = cli.Int64Flag{
+		Name:  "rpcsizelimit",
+		Usage: "Maximum size in bytes of a HTTP-RPC or WS-RPC request (0 = default)",
+	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpcbatchlimit",
+		Usage: "Maximum number of calls in a HTTP-RPC or WS-RPC batch (0 = no limit)",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpcratelimit",
+		Usage: "Calls per second allowed to a HTTP-RPC or WS-RPC client (0 = no limit)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpcrateburst",
+		Usage: "Calls a HTTP-RPC or WS-RPC client may make at once when rate limited",
+		Value: 100,
+	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable GraphQL queries on the HTTP-RPC server under /graphql",
+	}
+	GraphQLMaxCostFlag = cli.IntFlag{
+		Name:  "graphqlmaxcost",
+		Usage: "Maximum cost of a GraphQL query, in fields resolved",
+		Value: graphql.DefaultConfig.MaxCost,
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -634,6 +707,27 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setRPCPolicy applies the limits of the HTTP and WebSocket RPC servers from the
+// set command line flags. Credentials are only read from the config file.
+func setRPCPolicy(ctx *cli.Context, cfg *node.Config) {
+	if !ctx.GlobalIsSet(RPCSizeLimitFlag.Name) && !ctx.GlobalIsSet(RPCBatchLimitFlag.Name) && !ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		return
+	}
+	if cfg.RPCPolicy == nil {
+		cfg.RPCPolicy = new(rpc.Policy)
+	}
+	if ctx.GlobalIsSet(RPCSizeLimitFlag.Name) {
+		cfg.RPCPolicy.MaxRequestSize = ctx.GlobalInt64(RPCSizeLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+		cfg.RPCPolicy.MaxBatchSize = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCPolicy.RateLimit = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+		cfg.RPCPolicy.RateBurst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -784,6 +878,7 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCPolicy(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 
 	switch {
@@ -791,6 +886,12 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 		cfg.DataDir = ctx.GlobalString(DataDirFlag.Name)
 	}
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		cfg.DBEngine = ctx.GlobalString(DBEngineFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.AncientDir = ctx.GlobalString(AncientFlag.Name)
+	}
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
@@ -821,6 +922,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolRemoteJournalFlag.Name) {
+		cfg.RemoteJournal = ctx.GlobalString(TxPoolRemoteJournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -842,6 +946,15 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolReservedFlag.Name) {
+		cfg.Policy.ReservedSlots = ctx.GlobalUint64(TxPoolReservedFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSenderLimitFlag.Name) {
+		cfg.Policy.SenderLimit = ctx.GlobalUint64(TxPoolSenderLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSenderWindowFlag.Name) {
+		cfg.Policy.SenderWindow = ctx.GlobalDuration(TxPoolSenderWindowFlag.Name)
+	}
 }
 
 func checkExclusive(ctx *cli.Context, flags ...cli.Flag) {
@@ -885,6 +998,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *datx.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
+		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+	}
+	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.Snapshot = ctx.GlobalBool(SnapshotFlag.Name)
+	cfg.AddressIndex = ctx.GlobalBool(AddressIndexFlag.Name)
+
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -913,6 +1033,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *datx.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMRevertDataFlag.Name) {
+		cfg.RecordRevertData = ctx.GlobalBool(VMRevertDataFlag.Name)
+	}
 }
 
 // SetDashboardConfig applies dashboard related command line flags to the config.
@@ -977,6 +1100,24 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterGraphQLService configures the GraphQL endpoint and adds it to the given
+// node, serving the chain of its full or light Ethereum service.
+func RegisterGraphQLService(stack *node.Node, cfg graphql.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *datx.Ethereum
+		if err := ctx.Service(&ethServ); err == nil {
+			return graphql.New(ethServ.ApiBackend, cfg)
+		}
+		var lesServ *les.LightEthereum
+		if err := ctx.Service(&lesServ); err == nil {
+			return graphql.New(lesServ.ApiBackend, cfg)
+		}
+		return nil, errors.New("no Ethereum service to serve GraphQL queries from")
+	}); err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
@@ -989,11 +1130,18 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) datxdb.Database {
 		cache   = ctx.GlobalInt(CacheFlag.Name)
 		handles = makeDatabaseHandles()
 	)
-	name := "chaindata"
+	var (
+		chainDb datxdb.Database
+		err     error
+	)
 	if ctx.GlobalBool(LightModeFlag.Name) {
-		name = "lightchaindata"
+		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles)
+	} else {
+		chainDb, err = stack.OpenDatabase("chaindata", cache, handles)
+		if err == nil && stack.DataDir() != "" {
+			chainDb, err = datxdb.NewDatabaseWithFreezer(chainDb, stack.ResolveAncient("chaindata"))
+		}
 	}
-	chainDb, err := stack.OpenDatabase(name, cache, handles)
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
@@ -1009,9 +1157,18 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("%v", err)
 	}
+	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
+		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+	}
+	cache := &core.CacheConfig{
+		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
+		TrieNodeLimit: datx.DefaultConfig.TrieCache,
+		TrieTimeLimit: datx.DefaultConfig.TrieTimeout,
+		Snapshot:      ctx.GlobalBool(SnapshotFlag.Name),
+	}
 	engine := dpos.New(config.Dpos, chainDb)
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
-	chain, err = core.NewBlockChain(chainDb, config, engine, vmcfg)
+	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name), RecordRevertData: ctx.GlobalBool(VMRevertDataFlag.Name)}
+	chain, err = core.NewBlockChainWithCache(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
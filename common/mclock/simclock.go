@@ -0,0 +1,151 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package mclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Simulated implements a virtual Clock for reproducible time-sensitive tests. It
+// simulates a scheduler on a virtual timescale where actual processing takes zero
+// time.
+//
+// The virtual clock doesn't advance on its own, call Run to advance it and execute
+// timers. Since there is no way to influence the Go scheduler, testing timeout
+// behaviour involving goroutines needs special care: perform the action that is
+// supposed to time out, wait for its timer to be created (WaitForTimers), then
+// run the clock past the timeout and observe the effect.
+type Simulated struct {
+	now       AbsTime
+	scheduled []*simTimer
+	mu        sync.Mutex
+	cond      *sync.Cond
+}
+
+// simTimer is a timer scheduled on a simulated clock.
+type simTimer struct {
+	at AbsTime
+	do func()
+	s  *Simulated
+}
+
+// Run moves the clock by the given duration, executing all timers before that
+// duration.
+func (s *Simulated) Run(d time.Duration) {
+	s.mu.Lock()
+	s.init()
+
+	end := s.now + AbsTime(d)
+	for len(s.scheduled) > 0 && s.scheduled[0].at <= end {
+		timer := s.scheduled[0]
+		s.scheduled = s.scheduled[1:]
+		s.now = timer.at
+
+		// Fire the timer unlocked, it may well call back into the clock
+		s.mu.Unlock()
+		timer.do()
+		s.mu.Lock()
+	}
+	s.now = end
+	s.mu.Unlock()
+}
+
+// ActiveTimers returns the number of timers that haven't fired.
+func (s *Simulated) ActiveTimers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.scheduled)
+}
+
+// WaitForTimers waits until the clock has at least n scheduled timers.
+func (s *Simulated) WaitForTimers(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+
+	for len(s.scheduled) < n {
+		s.cond.Wait()
+	}
+}
+
+// Now implements Clock.
+func (s *Simulated) Now() AbsTime {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now
+}
+
+// Sleep implements Clock.
+func (s *Simulated) Sleep(d time.Duration) {
+	<-s.After(d)
+}
+
+// After implements Clock.
+func (s *Simulated) After(d time.Duration) <-chan time.Time {
+	after := make(chan time.Time, 1)
+	s.insert(d, func() {
+		after <- (time.Time{}).Add(time.Duration(s.Now()))
+	})
+	return after
+}
+
+// AfterFunc implements Clock.
+func (s *Simulated) AfterFunc(d time.Duration, f func()) Event {
+	return s.insert(d, f)
+}
+
+// insert schedules a new timer d after the current virtual time.
+func (s *Simulated) insert(d time.Duration, do func()) *simTimer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+
+	timer := &simTimer{at: s.now + AbsTime(d), do: do, s: s}
+	pos := sort.Search(len(s.scheduled), func(i int) bool {
+		return s.scheduled[i].at > timer.at
+	})
+	s.scheduled = append(s.scheduled, nil)
+	copy(s.scheduled[pos+1:], s.scheduled[pos:])
+	s.scheduled[pos] = timer
+	s.cond.Broadcast()
+
+	return timer
+}
+
+func (s *Simulated) init() {
+	if s.cond == nil {
+		s.cond = sync.NewCond(&s.mu)
+	}
+}
+
+// Cancel implements Event, removing the timer if it hasn't fired yet.
+func (t *simTimer) Cancel() bool {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+
+	for i, timer := range t.s.scheduled {
+		if timer == t {
+			t.s.scheduled = append(t.s.scheduled[:i], t.s.scheduled[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/rpc"
 
 	"math/big"
+	"time"
 )
 
 // API is a user facing RPC API to allow controlling the delegate and voting
@@ -44,7 +45,7 @@ func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error)
 		return nil, errUnknownBlock
 	}
 
-	epochTrie, err := types.NewEpochTrie(header.DposContext.EpochHash, api.dpos.db)
+	epochTrie, err := types.NewEpochTrie(header.DposContext.EpochHash, api.dpos.trieDB())
 	if err != nil {
 		return nil, err
 	}
@@ -57,15 +58,29 @@ func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error)
 	return validators, nil
 }
 
+// ClockOffset is the estimated offset of the local clock from the validators'.
+type ClockOffset struct {
+	Estimate int64 `json:"estimate"` // Estimated offset in milliseconds, positive if ahead
+	Applied  int64 `json:"applied"`  // Correction applied to slot timing in milliseconds
+	Samples  int   `json:"samples"`  // Number of validators the estimate is based on
+}
+
+// GetClockOffset retrieves the estimated offset of the local clock from the
+// validators' clocks, as measured from the arrival times of their blocks.
+func (api *API) GetClockOffset() *ClockOffset {
+	estimate, samples := api.dpos.ClockOffset()
+	return &ClockOffset{
+		Estimate: int64(estimate / time.Millisecond),
+		Applied:  int64(api.dpos.drift.offset() / time.Millisecond),
+		Samples:  samples,
+	}
+}
+
 // GetConfirmedBlockNumber retrieves the latest irreversible block
 func (api *API) GetConfirmedBlockNumber() (*big.Int, error) {
-	var err error
-	header := api.dpos.confirmedBlockHeader
+	header := api.dpos.ConfirmedHeader(api.chain)
 	if header == nil {
-		header, err = api.dpos.loadConfirmedBlockHeader(api.chain)
-		if err != nil {
-			return nil, err
-		}
+		return nil, ErrNilBlockHeader
 	}
 	return header.Number, nil
 }
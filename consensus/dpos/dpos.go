@@ -11,6 +11,7 @@ import (
 
 	"github.com/DATxChain-Protocol/DATx/accounts"
 	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/mclock"
 	"github.com/DATxChain-Protocol/DATx/consensus"
 	"github.com/DATxChain-Protocol/DATx/consensus/misc"
 	"github.com/DATxChain-Protocol/DATx/core/state"
@@ -30,6 +31,7 @@ const (
 	extraVanity        = 32   // Fixed number of extra-data prefix bytes reserved for signer vanity
 	extraSeal          = 65   // Fixed number of extra-data suffix bytes reserved for signer seal
 	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+	inmemorySchedules  = 4096 // Number of recent blocks to remember whether they were sealed in schedule
 
 	blockInterval    = int64(10)
 	epochInterval    = int64(86400)
@@ -46,8 +48,6 @@ var (
 	frontierBlockReward  *big.Int = big.NewInt(5e+18) // Block reward in uno for successfully mining a block
 	byzantiumBlockReward *big.Int = big.NewInt(3e+18) // Block reward in uno for successfully mining a block upward from Byzantium
 
-	timeOfFirstBlock = int64(0)
-
 	confirmedBlockHead = []byte("confirmed-block-head")
 )
 
@@ -84,11 +84,22 @@ var (
 type Dpos struct {
 	config *params.DposConfig // Consensus engine configuration parameters
 	db     datxdb.Database    // Database to store and retrieve snapshot checkpoints
+	triedb trie.Database      // Database to read the DPoS tries of the chain from
 
 	signer               common.Address
 	signFn               SignerFn
-	signatures           *lru.ARCCache // Signatures of recent blocks to speed up mining
-	confirmedBlockHeader *types.Header
+	signatures           *lru.ARCCache    // Signatures of recent blocks to speed up mining
+	schedules            *lru.ARCCache    // Whether recent blocks were sealed by the validator of their slot
+	confirmedBlockHeader *types.Header    // Latest block confirmed by the validators, protected by mu
+	timeOfFirstBlock     int64            // Timestamp of block #1, zero until known, protected by mu
+	epochLength          int64            // Epoch length in seconds to re-elect validators
+	timeSource           func() time.Time // Wall clock used for slot scheduling
+	drift                *clockDrift      // Offset of the wall clock from the validators'
+	arrivals             chan *arrival    // Propagated blocks waiting to be sampled by the drift estimator
+	sampling             sync.Once        // Starts the loop sampling the arrivals
+	sampled              sync.WaitGroup   // Tracks the loop sampling the arrivals
+	quit                 chan struct{}    // Closed when the engine is closed
+	closing              sync.Once        // Closes the quit channel
 
 	mu   sync.RWMutex
 	stop chan bool
@@ -130,13 +141,154 @@ func sigHash(header *types.Header) (hash common.Hash) {
 	return hash
 }
 
+// SigHash returns the hash a validator signs to seal the given header.
+func SigHash(header *types.Header) common.Hash {
+	return sigHash(header)
+}
+
 func New(config *params.DposConfig, db datxdb.Database) *Dpos {
 	signatures, _ := lru.NewARC(inmemorySignatures)
+	schedules, _ := lru.NewARC(inmemorySchedules)
+	epochLength := epochInterval
+	if config != nil && config.Epoch != 0 {
+		epochLength = int64(config.Epoch)
+	}
 	return &Dpos{
-		config:     config,
-		db:         db,
-		signatures: signatures,
+		config:      config,
+		db:          db,
+		triedb:      db,
+		signatures:  signatures,
+		schedules:   schedules,
+		epochLength: epochLength,
+		timeSource:  time.Now,
+		drift:       newClockDrift(mclock.System{}),
+		arrivals:    make(chan *arrival, driftQueueSize),
+		quit:        make(chan struct{}),
+	}
+}
+
+// Close stops the background sampling of block arrivals, waiting for it to
+// return, so the engine doesn't access the chain any more.
+func (d *Dpos) Close() error {
+	d.closing.Do(func() { close(d.quit) })
+
+	// Ensure the sampling loop is never started once closed
+	d.sampling.Do(func() {})
+	d.sampled.Wait()
+	return nil
+}
+
+// Now returns the current time as seen by the engine: the wall clock corrected
+// by its estimated offset from the other validators' clocks.
+func (d *Dpos) Now() time.Time {
+	return d.wallTime().Add(-d.drift.offset())
+}
+
+// wallTime returns the uncorrected time of the local wall clock.
+func (d *Dpos) wallTime() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.timeSource()
+}
+
+// ClockOffset returns the estimated offset of the local clock from the clocks
+// of the validators, along with the number of validators it's estimated from.
+func (d *Dpos) ClockOffset() (time.Duration, int) {
+	return d.drift.status()
+}
+
+// arrival is a propagated block along with the local time it arrived at.
+type arrival struct {
+	chain  consensus.ChainReader
+	header *types.Header
+	time   time.Time
+}
+
+// ObserveBlock feeds the arrival of a freshly propagated block into the clock
+// drift estimator. Looking up the validator of the block needs its parent's DPoS
+// context, so the block is only timestamped here and sampled in the background,
+// keeping the caller (the p2p message handler) off the tries. Arrivals beyond the
+// capacity of the queue are dropped.
+func (d *Dpos) ObserveBlock(chain consensus.ChainReader, header *types.Header) {
+	select {
+	case <-d.quit:
+		return
+	default:
+	}
+	select {
+	case d.arrivals <- &arrival{chain: chain, header: header, time: d.wallTime()}:
+		d.sampling.Do(func() {
+			d.sampled.Add(1)
+			go d.sampleArrivals()
+		})
+	default:
+	}
+}
+
+// sampleArrivals feeds the queued block arrivals sealed by other validators into
+// the clock drift estimator, until the engine is closed.
+func (d *Dpos) sampleArrivals() {
+	defer d.sampled.Done()
+
+	for {
+		select {
+		case arrival := <-d.arrivals:
+			d.sampleArrival(arrival.chain, arrival.header, arrival.time)
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// sampleArrival feeds the arrival of a block into the clock drift estimator if
+// it was sealed by the validator of its slot, other than the local one.
+func (d *Dpos) sampleArrival(chain consensus.ChainReader, header *types.Header, arrival time.Time) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return
+	}
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return
+	}
+	validator, err := d.LookupValidator(parent, header.Time.Int64())
+	if err != nil {
+		return
+	}
+	d.mu.RLock()
+	signer := d.signer
+	d.mu.RUnlock()
+	if validator == signer {
+		return
 	}
+	if err := d.verifyBlockSigner(validator, header); err != nil {
+		return
+	}
+	d.drift.add(validator, header.Hash(), header.Time.Int64(), arrival)
+}
+
+// SetTimeSource replaces the wall clock the engine uses to schedule and verify
+// slots. It's meant for simulations running several validators in-process.
+func (d *Dpos) SetTimeSource(now func() time.Time) {
+	d.mu.Lock()
+	d.timeSource = now
+	d.mu.Unlock()
+}
+
+// SetTrieDB replaces the database the DPoS tries are read from, so that the
+// tries cached in memory by the blockchain are seen before they reach disk.
+func (d *Dpos) SetTrieDB(triedb trie.Database) {
+	d.mu.Lock()
+	d.triedb = triedb
+	d.mu.Unlock()
+}
+
+// trieDB returns the database the DPoS tries are read from.
+func (d *Dpos) trieDB() trie.Database {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.triedb
 }
 
 func (d *Dpos) Author(header *types.Header) (common.Address, error) {
@@ -157,7 +309,7 @@ func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 	}
 	number := header.Number.Uint64()
 	// Unnecssary to verify the block from feature
-	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+	if header.Time.Cmp(big.NewInt(d.Now().Unix())) > 0 {
 		return consensus.ErrFutureBlock
 	}
 	// Check that the extra-data contains both the vanity and signature
@@ -243,12 +395,7 @@ func (d *Dpos) verifySeal(chain consensus.ChainReader, header *types.Header, par
 	} else {
 		parent = chain.GetHeader(header.ParentHash, number-1)
 	}
-	dposContext, err := types.NewDposContextFromProto(d.db, parent.DposContext)
-	if err != nil {
-		return err
-	}
-	epochContext := &EpochContext{DposContext: dposContext}
-	validator, err := epochContext.lookupValidator(header.Time.Int64())
+	validator, err := d.LookupValidator(parent, header.Time.Int64())
 	if err != nil {
 		return err
 	}
@@ -273,23 +420,22 @@ func (d *Dpos) verifyBlockSigner(validator common.Address, header *types.Header)
 }
 
 func (d *Dpos) updateConfirmedBlockHeader(chain consensus.ChainReader) error {
-	if d.confirmedBlockHeader == nil {
-		header, err := d.loadConfirmedBlockHeader(chain)
-		if err != nil {
-			header = chain.GetHeaderByNumber(0)
-			if header == nil {
-				return err
-			}
+	confirmed := d.ConfirmedHeader(chain)
+	if confirmed == nil {
+		if confirmed = chain.GetHeaderByNumber(0); confirmed == nil {
+			return ErrNilBlockHeader
 		}
-		d.confirmedBlockHeader = header
+		d.mu.Lock()
+		d.confirmedBlockHeader = confirmed
+		d.mu.Unlock()
 	}
 
 	curHeader := chain.CurrentHeader()
 	epoch := int64(-1)
 	validatorMap := make(map[common.Address]bool)
-	for d.confirmedBlockHeader.Hash() != curHeader.Hash() &&
-		d.confirmedBlockHeader.Number.Uint64() < curHeader.Number.Uint64() {
-		curEpoch := curHeader.Time.Int64() / epochInterval
+	for confirmed.Hash() != curHeader.Hash() &&
+		confirmed.Number.Uint64() < curHeader.Number.Uint64() {
+		curEpoch := curHeader.Time.Int64() / d.epochLength
 		if curEpoch != epoch {
 			epoch = curEpoch
 			validatorMap = make(map[common.Address]bool)
@@ -297,14 +443,16 @@ func (d *Dpos) updateConfirmedBlockHeader(chain consensus.ChainReader) error {
 		// fast return
 		// if block number difference less consensusSize-witnessNum
 		// there is no need to check block is confirmed
-		if curHeader.Number.Int64()-d.confirmedBlockHeader.Number.Int64() < int64(consensusSize-len(validatorMap)) {
-			log.Debug("Dpos fast return", "current", curHeader.Number.String(), "confirmed", d.confirmedBlockHeader.Number.String(), "witnessCount", len(validatorMap))
+		if curHeader.Number.Int64()-confirmed.Number.Int64() < int64(consensusSize-len(validatorMap)) {
+			log.Debug("Dpos fast return", "current", curHeader.Number.String(), "confirmed", confirmed.Number.String(), "witnessCount", len(validatorMap))
 			return nil
 		}
 		validatorMap[curHeader.Validator] = true
 		if len(validatorMap) >= consensusSize {
+			d.mu.Lock()
 			d.confirmedBlockHeader = curHeader
-			if err := d.storeConfirmedBlockHeader(d.db); err != nil {
+			d.mu.Unlock()
+			if err := d.storeConfirmedBlockHeader(d.db, curHeader); err != nil {
 				return err
 			}
 			log.Debug("dpos set confirmed block header success", "currentHeader", curHeader.Number.String())
@@ -331,8 +479,8 @@ func (s *Dpos) loadConfirmedBlockHeader(chain consensus.ChainReader) (*types.Hea
 }
 
 // store inserts the snapshot into the database.
-func (s *Dpos) storeConfirmedBlockHeader(db datxdb.Database) error {
-	return db.Put(confirmedBlockHead, s.confirmedBlockHeader.Hash().Bytes())
+func (s *Dpos) storeConfirmedBlockHeader(db datxdb.Database, header *types.Header) error {
+	return db.Put(confirmedBlockHead, header.Hash().Bytes())
 }
 
 func (d *Dpos) Prepare(chain consensus.ChainReader, header *types.Header) error {
@@ -374,11 +522,9 @@ func (d *Dpos) Finalize(chain consensus.ChainReader, header *types.Header, state
 		statedb:     state,
 		DposContext: dposContext,
 		TimeStamp:   header.Time.Int64(),
-	}
-	if timeOfFirstBlock == 0 {
-		if firstBlockHeader := chain.GetHeaderByNumber(1); firstBlockHeader != nil {
-			timeOfFirstBlock = firstBlockHeader.Time.Int64()
-		}
+		Interval:    d.epochLength,
+
+		timeOfFirstBlock: d.firstBlockTime(chain),
 	}
 	genesis := chain.GetHeaderByNumber(0)
 	err := epochContext.tryElect(genesis, parent)
@@ -387,11 +533,30 @@ func (d *Dpos) Finalize(chain consensus.ChainReader, header *types.Header, state
 	}
 
 	//update mint count trie
-	updateMintCnt(parent.Time.Int64(), header.Time.Int64(), header.Validator, dposContext)
+	updateMintCnt(d.epochLength, parent.Time.Int64(), header.Time.Int64(), header.Validator, dposContext)
 	header.DposContext = dposContext.ToProto()
 	return types.NewBlock(header, txs, uncles, receipts), nil
 }
 
+// firstBlockTime returns the timestamp of block #1 of the chain, or zero if the
+// chain has no blocks yet.
+func (d *Dpos) firstBlockTime(chain consensus.ChainReader) int64 {
+	d.mu.RLock()
+	stamp := d.timeOfFirstBlock
+	d.mu.RUnlock()
+
+	if stamp == 0 {
+		if header := chain.GetHeaderByNumber(1); header != nil {
+			stamp = header.Time.Int64()
+
+			d.mu.Lock()
+			d.timeOfFirstBlock = stamp
+			d.mu.Unlock()
+		}
+	}
+	return stamp
+}
+
 func (d *Dpos) checkDeadline(lastBlock *types.Block, now int64) error {
 	prevSlot := PrevSlot(now)
 	nextSlot := NextSlot(now)
@@ -409,12 +574,7 @@ func (d *Dpos) CheckValidator(lastBlock *types.Block, now int64) error {
 	if err := d.checkDeadline(lastBlock, now); err != nil {
 		return err
 	}
-	dposContext, err := types.NewDposContextFromProto(d.db, lastBlock.Header().DposContext)
-	if err != nil {
-		return err
-	}
-	epochContext := &EpochContext{DposContext: dposContext}
-	validator, err := epochContext.lookupValidator(now)
+	validator, err := d.LookupValidator(lastBlock.Header(), now)
 	if err != nil {
 		return err
 	}
@@ -424,6 +584,17 @@ func (d *Dpos) CheckValidator(lastBlock *types.Block, now int64) error {
 	return nil
 }
 
+// LookupValidator returns the validator scheduled to seal the block with the
+// given timestamp on top of parent.
+func (d *Dpos) LookupValidator(parent *types.Header, timestamp int64) (common.Address, error) {
+	dposContext, err := types.NewDposContextFromProto(d.trieDB(), parent.DposContext)
+	if err != nil {
+		return common.Address{}, err
+	}
+	epochContext := &EpochContext{Interval: d.epochLength, DposContext: dposContext}
+	return epochContext.lookupValidator(timestamp)
+}
+
 // Seal generates a new block for the given input block with the local miner's
 // seal place on top.
 func (d *Dpos) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
@@ -433,7 +604,7 @@ func (d *Dpos) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	if number == 0 {
 		return nil, errUnknownBlock
 	}
-	now := time.Now().Unix()
+	now := d.Now().Unix()
 	delay := NextSlot(now) - now
 	if delay > 0 {
 		select {
@@ -442,7 +613,7 @@ func (d *Dpos) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 		case <-time.After(time.Duration(delay) * time.Second):
 		}
 	}
-	block.Header().Time.SetInt64(time.Now().Unix())
+	block.Header().Time.SetInt64(d.Now().Unix())
 
 	// time's up, sign the block
 	sighash, err := d.signFn(accounts.Account{Address: d.signer}, sigHash(header).Bytes())
@@ -505,14 +676,14 @@ func NextSlot(now int64) int64 {
 }
 
 // update counts in MintCntTrie for the miner of newBlock
-func updateMintCnt(parentBlockTime, currentBlockTime int64, validator common.Address, dposContext *types.DposContext) {
+func updateMintCnt(epochLength, parentBlockTime, currentBlockTime int64, validator common.Address, dposContext *types.DposContext) {
 	currentMintCntTrie := dposContext.MintCntTrie()
-	currentEpoch := parentBlockTime / epochInterval
+	currentEpoch := parentBlockTime / epochLength
 	currentEpochBytes := make([]byte, 8)
 	binary.BigEndian.PutUint64(currentEpochBytes, uint64(currentEpoch))
 
 	cnt := int64(1)
-	newEpoch := currentBlockTime / epochInterval
+	newEpoch := currentBlockTime / epochLength
 	// still during the currentEpochID
 	if currentEpoch == newEpoch {
 		iter := trie.NewIterator(currentMintCntTrie.NodeIterator(currentEpochBytes))
@@ -0,0 +1,140 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/mclock"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/metrics"
+)
+
+const (
+	driftMinSamples    = 3                      // Number of validators needed before trusting the estimate
+	driftSampleTTL     = 10 * time.Minute       // Age after which the sample of a validator is discarded
+	driftMaxSample     = 30 * time.Second       // Arrival delays beyond this come from stale blocks, not clocks
+	driftLatency       = 250 * time.Millisecond // Expected propagation latency, included in every arrival delay
+	driftTolerance     = 500 * time.Millisecond // Offsets within the estimation error, left uncorrected
+	driftQueueSize     = 64                     // Number of block arrivals waiting to be sampled
+	driftWarnThreshold = 2 * time.Second        // Offsets large enough to make validators miss their slots
+	driftWarnInterval  = time.Minute            // Time between two clock drift warnings
+)
+
+// clockOffsetGauge tracks the estimated offset of the local clock in milliseconds.
+var clockOffsetGauge = metrics.NewGauge("dpos/clock/offset")
+
+// driftSample is the arrival delay of the latest block sealed by a validator.
+type driftSample struct {
+	hash  common.Hash    // Hash of the sampled block, to skip re-propagations
+	delay time.Duration  // Local arrival time minus the header timestamp
+	time  mclock.AbsTime // Time the sample was taken, for expiration
+}
+
+// clockDrift estimates the offset of the local clock from the validators' by
+// comparing the arrival time of freshly sealed blocks with their timestamps.
+// Validators stamp blocks with the start of their slot, so a block arriving well
+// before or after its timestamp means that the local clock is off.
+//
+// Only the latest block of each validator is sampled and the estimate is the
+// median of the samples, so a minority of skewed validators cannot drag the
+// local clock along with them. Blocks always arrive some time after they were
+// sealed, so the expected propagation latency is taken off the median.
+type clockDrift struct {
+	clock   mclock.Clock
+	samples map[common.Address]*driftSample
+
+	estimate time.Duration  // Median arrival delay across validators, less the latency
+	warned   mclock.AbsTime // Time of the last clock drift warning
+	lock     sync.RWMutex
+}
+
+func newClockDrift(clock mclock.Clock) *clockDrift {
+	return &clockDrift{
+		clock:   clock,
+		samples: make(map[common.Address]*driftSample),
+		warned:  clock.Now() - mclock.AbsTime(driftWarnInterval),
+	}
+}
+
+// add records the arrival of a block sealed by validator with the given header
+// timestamp, and updates the offset estimate.
+func (c *clockDrift) add(validator common.Address, hash common.Hash, timestamp int64, arrival time.Time) {
+	delay := arrival.Sub(time.Unix(timestamp, 0))
+	if delay < -driftMaxSample || delay > driftMaxSample {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if sample, ok := c.samples[validator]; ok && sample.hash == hash {
+		return
+	}
+	now := c.clock.Now()
+	c.samples[validator] = &driftSample{hash: hash, delay: delay, time: now}
+
+	// Drop expired samples and recalculate the estimate from the remaining ones
+	delays := make([]time.Duration, 0, len(c.samples))
+	for addr, sample := range c.samples {
+		if time.Duration(now-sample.time) > driftSampleTTL {
+			delete(c.samples, addr)
+			continue
+		}
+		delays = append(delays, sample.delay)
+	}
+	if len(delays) < driftMinSamples {
+		c.estimate = 0
+		return
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	if mid := len(delays) / 2; len(delays)%2 == 1 {
+		c.estimate = delays[mid] - driftLatency
+	} else {
+		c.estimate = (delays[mid-1]+delays[mid])/2 - driftLatency
+	}
+	clockOffsetGauge.Update(int64(c.estimate / time.Millisecond))
+
+	if (c.estimate < -driftWarnThreshold || c.estimate > driftWarnThreshold) && time.Duration(now-c.warned) >= driftWarnInterval {
+		log.Warn(fmt.Sprintf("System clock seems off by %v from the validators, which makes blocks miss their slots", c.estimate))
+		log.Warn("Slot timing is corrected, but please enable network time synchronisation in system settings.")
+		c.warned = now
+	}
+}
+
+// offset returns the correction to subtract from the local clock to get the
+// validators' time. Offsets within the tolerance are not corrected.
+func (c *clockDrift) offset() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.estimate > -driftTolerance && c.estimate < driftTolerance {
+		return 0
+	}
+	return c.estimate
+}
+
+// status returns the current estimate and the number of validators sampled.
+func (c *clockDrift) status() (time.Duration, int) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.estimate, len(c.samples)
+}
@@ -0,0 +1,197 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/mclock"
+	"github.com/DATxChain-Protocol/DATx/consensus"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulatedWallClock derives a wall clock from a simulated monotonic clock,
+// running skew ahead of the validators' time.
+func simulatedWallClock(clock *mclock.Simulated, skew time.Duration) func() time.Time {
+	return func() time.Time {
+		return time.Unix(1500000000, 0).Add(time.Duration(clock.Now())).Add(skew)
+	}
+}
+
+// sealSlots simulates validators sealing blocks in consecutive slots, reporting
+// the arrival of each block (after the given latency) to the engine's estimator.
+func sealSlots(engine *Dpos, clock *mclock.Simulated, validators int, latency time.Duration) {
+	for i := 0; i < validators; i++ {
+		// Advance the validators' time to the next slot and stamp the block
+		network := time.Unix(1500000000, 0).Add(time.Duration(clock.Now()))
+		slot := NextSlot(network.Unix())
+		if network.Unix() == slot {
+			slot += blockInterval
+		}
+		clock.Run(time.Unix(slot, 0).Sub(network))
+
+		clock.Run(latency)
+		engine.drift.add(common.Address{byte(i + 1)}, common.Hash{byte(i + 1)}, slot, engine.wallTime())
+	}
+}
+
+func TestClockDriftEstimate(t *testing.T) {
+	var (
+		clock  = new(mclock.Simulated)
+		engine = New(&params.DposConfig{}, nil)
+	)
+	engine.drift = newClockDrift(clock)
+	engine.SetTimeSource(simulatedWallClock(clock, 3*time.Second))
+
+	// Too few validators seen, no correction should be made
+	sealSlots(engine, clock, driftMinSamples-1, driftLatency)
+	assert.Equal(t, time.Duration(0), engine.drift.offset())
+
+	// Enough validators seen, the skew should be corrected without the latency
+	sealSlots(engine, clock, driftMinSamples, driftLatency)
+	estimate, samples := engine.ClockOffset()
+	assert.Equal(t, 3*time.Second, estimate)
+	assert.Equal(t, driftMinSamples, samples)
+
+	network := time.Unix(1500000000, 0).Add(time.Duration(clock.Now()))
+	assert.Equal(t, network, engine.Now())
+	assert.Equal(t, network.Add(3*time.Second), engine.wallTime())
+
+	// Re-propagations of the same block should not move the estimate
+	clock.Run(5 * time.Second)
+	engine.drift.add(common.Address{1}, common.Hash{1}, network.Unix(), engine.wallTime())
+	estimate, _ = engine.ClockOffset()
+	assert.Equal(t, 3*time.Second, estimate)
+}
+
+func TestClockDriftTolerance(t *testing.T) {
+	var (
+		clock  = new(mclock.Simulated)
+		engine = New(&params.DposConfig{}, nil)
+	)
+	engine.drift = newClockDrift(clock)
+	engine.SetTimeSource(simulatedWallClock(clock, 0))
+
+	// Propagation latency alone should not be corrected, even if slower than expected
+	sealSlots(engine, clock, 5, driftLatency+300*time.Millisecond)
+	estimate, _ := engine.ClockOffset()
+	assert.Equal(t, 300*time.Millisecond, estimate)
+	assert.Equal(t, time.Duration(0), engine.drift.offset())
+	assert.Equal(t, engine.wallTime(), engine.Now())
+
+	// Stale blocks (e.g. during sync) should be ignored
+	engine.drift.add(common.Address{0xff}, common.Hash{0xff}, 1500000000, engine.wallTime())
+	_, samples := engine.ClockOffset()
+	assert.Equal(t, 5, samples)
+}
+
+func TestClockDriftMedian(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		drift = newClockDrift(clock)
+		now   = time.Unix(1500000000, 0)
+	)
+	// A minority of skewed validators should not move the estimate
+	delays := []time.Duration{-20 * time.Second, 0, 200 * time.Millisecond, 400 * time.Millisecond, 25 * time.Second}
+	for i, delay := range delays {
+		drift.add(common.Address{byte(i)}, common.Hash{byte(i)}, now.Unix(), now.Add(delay))
+	}
+	estimate, _ := drift.status()
+	assert.Equal(t, 200*time.Millisecond-driftLatency, estimate)
+
+	// Samples should expire, dropping the estimate once too few are left
+	clock.Run(driftSampleTTL + time.Second)
+	drift.add(common.Address{0xff}, common.Hash{0xff}, now.Unix(), now.Add(4*time.Second))
+	estimate, samples := drift.status()
+	assert.Equal(t, time.Duration(0), estimate)
+	assert.Equal(t, 1, samples)
+}
+
+// blockingChain is a chain whose headers can't be read until it's released.
+type blockingChain struct {
+	consensus.ChainReader
+	release chan struct{}
+}
+
+func (c *blockingChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	<-c.release
+	return nil
+}
+
+// Tests that observing block arrivals never waits for the chain, even once the
+// queue of arrivals to sample is full.
+func TestObserveBlockAsync(t *testing.T) {
+	var (
+		engine = New(&params.DposConfig{}, nil)
+		chain  = &blockingChain{release: make(chan struct{})}
+		done   = make(chan struct{})
+	)
+	defer engine.Close()
+	defer close(chain.release)
+
+	go func() {
+		for i := 0; i < 2*driftQueueSize; i++ {
+			engine.ObserveBlock(chain, &types.Header{Number: big.NewInt(int64(i + 1)), Time: big.NewInt(1500000000)})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("observing blocks blocked on the chain")
+	}
+}
+
+// countingChain is a chain counting the headers read from it.
+type countingChain struct {
+	consensus.ChainReader
+	reads int32
+}
+
+func (c *countingChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	atomic.AddInt32(&c.reads, 1)
+	return nil
+}
+
+// Tests that closing the engine stops the sampling of block arrivals, so the
+// chain isn't accessed any more.
+func TestObserveBlockClose(t *testing.T) {
+	// Closing an engine that never sampled shouldn't block
+	New(&params.DposConfig{}, nil).Close()
+
+	engine := New(&params.DposConfig{}, nil)
+	chain := new(countingChain)
+
+	engine.ObserveBlock(chain, &types.Header{Number: big.NewInt(1), Time: big.NewInt(1500000000)})
+	engine.Close()
+
+	reads := atomic.LoadInt32(&chain.reads)
+	for i := 0; i < driftQueueSize; i++ {
+		engine.ObserveBlock(chain, &types.Header{Number: big.NewInt(int64(i + 2)), Time: big.NewInt(1500000000)})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if have := atomic.LoadInt32(&chain.reads); have != reads {
+		t.Errorf("chain accessed after close: have %d reads, want %d", have, reads)
+	}
+	engine.Close()
+}
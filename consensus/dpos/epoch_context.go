@@ -18,8 +18,19 @@ import (
 
 type EpochContext struct {
 	TimeStamp   int64
+	Interval    int64 // Epoch length in seconds, epochInterval if zero
 	DposContext *types.DposContext
 	statedb     *state.StateDB
+
+	timeOfFirstBlock int64 // Timestamp of block #1, shortening the first epoch
+}
+
+// epochLength returns the length of an epoch in seconds.
+func (ec *EpochContext) epochLength() int64 {
+	if ec.Interval > 0 {
+		return ec.Interval
+	}
+	return epochInterval
 }
 
 // countVotes
@@ -70,13 +81,13 @@ func (ec *EpochContext) kickoutValidator(epoch int64) error {
 		return errors.New("no validator could be kickout")
 	}
 
-	epochDuration := epochInterval
+	epochDuration := ec.epochLength()
 	// First epoch duration may lt epoch interval,
 	// while the first block time wouldn't always align with epoch interval,
 	// so caculate the first epoch duartion with first block time instead of epoch interval,
 	// prevent the validators were kickout incorrectly.
-	if ec.TimeStamp-timeOfFirstBlock < epochInterval {
-		epochDuration = ec.TimeStamp - timeOfFirstBlock
+	if ec.TimeStamp-ec.timeOfFirstBlock < ec.epochLength() {
+		epochDuration = ec.TimeStamp - ec.timeOfFirstBlock
 	}
 
 	needKickoutValidators := sortableAddresses{}
@@ -128,7 +139,7 @@ func (ec *EpochContext) kickoutValidator(epoch int64) error {
 
 func (ec *EpochContext) lookupValidator(now int64) (validator common.Address, err error) {
 	validator = common.Address{}
-	offset := now % epochInterval
+	offset := now % ec.epochLength()
 	if offset%blockInterval != 0 {
 		return common.Address{}, ErrInvalidMintBlockTime
 	}
@@ -147,9 +158,9 @@ func (ec *EpochContext) lookupValidator(now int64) (validator common.Address, er
 }
 
 func (ec *EpochContext) tryElect(genesis, parent *types.Header) error {
-	genesisEpoch := genesis.Time.Int64() / epochInterval
-	prevEpoch := parent.Time.Int64() / epochInterval
-	currentEpoch := ec.TimeStamp / epochInterval
+	genesisEpoch := genesis.Time.Int64() / ec.epochLength()
+	prevEpoch := parent.Time.Int64() / ec.epochLength()
+	currentEpoch := ec.TimeStamp / ec.epochLength()
 
 	prevEpochIsGenesis := prevEpoch == genesisEpoch
 	if prevEpochIsGenesis && prevEpoch < currentEpoch {
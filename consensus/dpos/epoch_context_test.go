@@ -247,7 +247,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 
 func setTestMintCnt(dposContext *types.DposContext, epoch int64, validator common.Address, count int64) {
 	for i := int64(0); i < count; i++ {
-		updateMintCnt(epoch*epochInterval, epoch*epochInterval+blockInterval, validator, dposContext)
+		updateMintCnt(epochInterval, epoch*epochInterval, epoch*epochInterval+blockInterval, validator, dposContext)
 	}
 }
 
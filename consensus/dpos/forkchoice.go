@@ -0,0 +1,157 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"errors"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+)
+
+// ErrRevertConfirmedBlock is returned if a chain reorganisation would drop the
+// block confirmed by the validators from the canonical chain.
+var ErrRevertConfirmedBlock = errors.New("reorg reverts the confirmed block")
+
+// branchWeight is the weight of a chain branch above its fork point.
+type branchWeight struct {
+	validators int // Number of distinct validators that signed the branch
+	scheduled  int // Number of blocks signed by the validator of their slot
+	length     int // Number of blocks in the branch
+}
+
+// heavier reports whether the branch weighs strictly more than other.
+func (w branchWeight) heavier(other branchWeight) bool {
+	if w.validators != other.validators {
+		return w.validators > other.validators
+	}
+	if w.scheduled != other.scheduled {
+		return w.scheduled > other.scheduled
+	}
+	return w.length > other.length
+}
+
+// ConfirmedHeader returns the latest block confirmed by the validators, which
+// no chain reorganisation may revert, or nil if it is not known yet.
+func (d *Dpos) ConfirmedHeader(chain consensus.ChainReader) *types.Header {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.confirmedBlockHeader == nil {
+		if header, err := d.loadConfirmedBlockHeader(chain); err == nil {
+			d.confirmedBlockHeader = header
+		}
+	}
+	return d.confirmedBlockHeader
+}
+
+// ForkChoice reports whether the chain ending in candidate should replace the
+// one ending in current as the canonical chain.
+//
+// All DPoS blocks have the same difficulty, so instead of the total difficulty
+// the branches above the fork point are weighed by the number of distinct
+// validators that signed them, then by the number of blocks signed in schedule
+// and last by their length. A branch reverting the confirmed block is never
+// chosen, and on a complete tie the current chain is kept.
+//
+// The confirmed block is an ancestor of the current head, so the walk down to
+// the fork point stops at its height: only the blocks above it are ever weighed.
+func (d *Dpos) ForkChoice(chain consensus.ChainReader, current, candidate *types.Header) (bool, error) {
+	// Extending the current head is the common case, short circuit it
+	if candidate.ParentHash == current.Hash() {
+		return true, nil
+	}
+	var floor uint64
+	if confirmed := d.ConfirmedHeader(chain); confirmed != nil {
+		floor = confirmed.Number.Uint64()
+	}
+	// Collect both branches down to their common ancestor, giving up on the
+	// candidate as soon as the current branch would reach the confirmed block
+	var (
+		oldBranch []*types.Header
+		newBranch []*types.Header
+	)
+	for current.Number.Uint64() > candidate.Number.Uint64() {
+		if current.Number.Uint64() <= floor {
+			return false, nil
+		}
+		oldBranch = append(oldBranch, current)
+		if current = chain.GetHeader(current.ParentHash, current.Number.Uint64()-1); current == nil {
+			return false, consensus.ErrUnknownAncestor
+		}
+	}
+	for candidate.Number.Uint64() > current.Number.Uint64() {
+		newBranch = append(newBranch, candidate)
+		if candidate = chain.GetHeader(candidate.ParentHash, candidate.Number.Uint64()-1); candidate == nil {
+			return false, consensus.ErrUnknownAncestor
+		}
+	}
+	for current.Hash() != candidate.Hash() {
+		if current.Number.Uint64() <= floor {
+			return false, nil
+		}
+		oldBranch = append(oldBranch, current)
+		newBranch = append(newBranch, candidate)
+
+		current = chain.GetHeader(current.ParentHash, current.Number.Uint64()-1)
+		candidate = chain.GetHeader(candidate.ParentHash, candidate.Number.Uint64()-1)
+		if current == nil || candidate == nil {
+			return false, consensus.ErrUnknownAncestor
+		}
+	}
+	ancestor := current
+	return d.weighBranch(ancestor, newBranch).heavier(d.weighBranch(ancestor, oldBranch)), nil
+}
+
+// weighBranch calculates the weight of a branch on top of ancestor, ordered
+// from its head down to the first block after the fork point.
+func (d *Dpos) weighBranch(ancestor *types.Header, branch []*types.Header) branchWeight {
+	weight := branchWeight{length: len(branch)}
+
+	validators := make(map[common.Address]bool)
+	for i, header := range branch {
+		validators[header.Validator] = true
+
+		parent := ancestor
+		if i+1 < len(branch) {
+			parent = branch[i+1]
+		}
+		if d.inSchedule(parent, header) {
+			weight.scheduled++
+		}
+	}
+	weight.validators = len(validators)
+	return weight
+}
+
+// inSchedule reports whether a block was sealed by the validator of its slot.
+// The answers are cached, as the same branches are weighed over and over while
+// their blocks arrive.
+func (d *Dpos) inSchedule(parent, header *types.Header) bool {
+	hash := header.Hash()
+	if scheduled, ok := d.schedules.Get(hash); ok {
+		return scheduled.(bool)
+	}
+	validator, err := d.LookupValidator(parent, header.Time.Int64())
+	if err != nil {
+		return false
+	}
+	scheduled := validator == header.Validator
+	d.schedules.Add(hash, scheduled)
+	return scheduled
+}
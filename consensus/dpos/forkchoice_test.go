@@ -0,0 +1,108 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchWeightOrdering(t *testing.T) {
+	base := branchWeight{validators: 5, scheduled: 5, length: 6}
+
+	// Distinct validators outweigh everything else
+	assert.True(t, branchWeight{validators: 6, scheduled: 1, length: 1}.heavier(base))
+	assert.False(t, branchWeight{validators: 4, scheduled: 9, length: 9}.heavier(base))
+
+	// Blocks signed in schedule outweigh the branch length
+	assert.True(t, branchWeight{validators: 5, scheduled: 6, length: 6}.heavier(base))
+	assert.False(t, branchWeight{validators: 5, scheduled: 4, length: 9}.heavier(base))
+
+	// Length only breaks the remaining ties, and equal branches don't replace each other
+	assert.True(t, branchWeight{validators: 5, scheduled: 5, length: 7}.heavier(base))
+	assert.False(t, base.heavier(base))
+}
+
+// forkChoiceChain is a chain reader serving a set of headers, and nothing else.
+type forkChoiceChain struct {
+	consensus.ChainReader
+	headers map[common.Hash]*types.Header
+}
+
+func (c *forkChoiceChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.headers[hash]
+}
+
+func (c *forkChoiceChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+
+// add extends a branch from parent with headers of the given validators.
+func (c *forkChoiceChain) add(parent *types.Header, validators ...common.Address) []*types.Header {
+	var branch []*types.Header
+	for _, validator := range validators {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(blockInterval)),
+			Validator:   validator,
+			DposContext: &types.DposContextProto{},
+		}
+		c.headers[header.Hash()] = header
+		branch = append(branch, header)
+		parent = header
+	}
+	return branch
+}
+
+func TestForkChoiceConfirmed(t *testing.T) {
+	var (
+		v1 = common.HexToAddress("0x01")
+		v2 = common.HexToAddress("0x02")
+	)
+	db, _ := datxdb.NewMemDatabase()
+	engine := New(&params.DposConfig{}, db)
+	chain := &forkChoiceChain{headers: make(map[common.Hash]*types.Header)}
+
+	// The root of the chain isn't served, no walk may go past it
+	root := &types.Header{Number: big.NewInt(1), Time: big.NewInt(0), DposContext: &types.DposContextProto{}}
+	canonical := chain.add(root, v1, v1, v1, v1, v1)
+	engine.confirmedBlockHeader = canonical[1]
+
+	// Branches forking above the confirmed block are weighed
+	branch := chain.add(canonical[1], v2, v1, v2, v1)
+	if choice, err := engine.ForkChoice(chain, canonical[4], branch[3]); err != nil || !choice {
+		t.Errorf("branch above confirmed block: have %v, %v, want true", choice, err)
+	}
+	// Branches forking below the confirmed block are refused without walking
+	// down to their fork point
+	branch = chain.add(canonical[0], v2, v1, v2, v1, v2, v1)
+	if choice, err := engine.ForkChoice(chain, canonical[4], branch[5]); err != nil || choice {
+		t.Errorf("branch below confirmed block: have %v, %v, want false", choice, err)
+	}
+	branch = chain.add(root, v2, v1, v2, v1, v2, v1)
+	if choice, err := engine.ForkChoice(chain, canonical[4], branch[5]); err != nil || choice {
+		t.Errorf("branch from unknown root: have %v, %v, want false", choice, err)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/consensus"
 	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
 	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/state/snapshot"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/core/vm"
 	"github.com/DATxChain-Protocol/DATx/crypto"
@@ -43,6 +44,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/rlp"
 	"github.com/DATxChain-Protocol/DATx/trie"
 	"github.com/hashicorp/golang-lru"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
@@ -57,11 +59,28 @@ const (
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	triesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
 )
 
+// CacheConfig contains the configuration values for the trie caching and pruning
+// that's resident in a blockchain.
+type CacheConfig struct {
+	Disabled      bool          // Whether to disable trie write caching (archive node)
+	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Snapshot      bool          // Whether to serve state reads from a flat snapshot of the state
+	SnapshotWait  bool          // Whether to wait for the snapshot to be rebuilt on startup (for tests)
+}
+
+// DefaultCacheConfig is the trie caching configuration of full, non-archive nodes.
+var DefaultCacheConfig = &CacheConfig{
+	TrieNodeLimit: 256,
+	TrieTimeLimit: 5 * time.Minute,
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -77,10 +96,14 @@ const (
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
-	config *params.ChainConfig // chain & network configuration
+	config      *params.ChainConfig // chain & network configuration
+	cacheConfig *CacheConfig        // Cache configuration for pruning
 
 	hc            *HeaderChain
 	chainDb       datxdb.Database
+	triegc        *prque.Prque // Priority queue mapping block numbers to tries to gc
+	lastWrite     uint64       // Number of the last block whose tries were flushed
+	lastWriteTime time.Time    // Time the tries were last flushed to disk
 	rmLogsFeed    event.Feed
 	chainFeed     event.Feed
 	chainSideFeed event.Feed
@@ -98,6 +121,7 @@ type BlockChain struct {
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Flat snapshot of the recent states, nil if disabled
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -119,8 +143,19 @@ type BlockChain struct {
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
-// Processor.
+// Processor. The state of every block is written straight to disk, as with an
+// archive node.
 func NewBlockChain(chainDb datxdb.Database, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	return NewBlockChainWithCache(chainDb, &CacheConfig{Disabled: true}, config, engine, vmConfig)
+}
+
+// NewBlockChainWithCache returns a fully initialised block chain like NewBlockChain,
+// keeping the tries of recent blocks in memory and garbage collecting them as
+// configured by cacheConfig. A nil cacheConfig uses DefaultCacheConfig.
+func NewBlockChainWithCache(chainDb datxdb.Database, cacheConfig *CacheConfig, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if cacheConfig == nil {
+		cacheConfig = DefaultCacheConfig
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -128,21 +163,29 @@ func NewBlockChain(chainDb datxdb.Database, config *params.ChainConfig, engine c
 	badBlocks, _ := lru.New(badBlockLimit)
 
 	bc := &BlockChain{
-		config:       config,
-		chainDb:      chainDb,
-		stateCache:   state.NewDatabase(chainDb),
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
-		engine:       engine,
-		vmConfig:     vmConfig,
-		badBlocks:    badBlocks,
+		config:        config,
+		cacheConfig:   cacheConfig,
+		chainDb:       chainDb,
+		triegc:        prque.New(),
+		lastWriteTime: time.Now(),
+		stateCache:    state.NewDatabase(chainDb),
+		quit:          make(chan struct{}),
+		bodyCache:     bodyCache,
+		bodyRLPCache:  bodyRLPCache,
+		blockCache:    blockCache,
+		futureBlocks:  futureBlocks,
+		engine:        engine,
+		vmConfig:      vmConfig,
+		badBlocks:     badBlocks,
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, engine))
 	bc.SetProcessor(NewStateProcessor(config, bc, engine))
 
+	// Let the engine see the DPoS tries which are not flushed to disk yet
+	if dposEngine, isDpos := engine.(*dpos.Dpos); isDpos {
+		dposEngine.SetTrieDB(bc.stateCache.TrieDB())
+	}
+
 	var err error
 	bc.hc, err = NewHeaderChain(chainDb, config, engine, bc.getProcInterrupt)
 	if err != nil {
@@ -168,8 +211,27 @@ func NewBlockChain(chainDb datxdb.Database, config *params.ChainConfig, engine c
 			}
 		}
 	}
+	// Load the flat state snapshot, rebuilding it in the background if it's not of
+	// the head state
+	if cacheConfig.Snapshot {
+		if bc.snaps, err = snapshot.New(chainDb, bc.stateCache.TrieDB(), bc.CurrentBlock().Root(), !cacheConfig.SnapshotWait); err != nil {
+			return nil, err
+		}
+	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Move the blocks the DPoS validators confirmed to the ancient store, if any.
+	// Chains started from a state checkpoint lack the old bodies and receipts,
+	// so they can't fill it.
+	if ancients, ok := chainDb.(datxdb.AncientStore); ok {
+		if checkpoint := GetCheckpointHash(chainDb); checkpoint != (common.Hash{}) {
+			log.Info("Ancient store disabled, chain started from a state checkpoint", "hash", checkpoint)
+		} else if dposEngine, isDpos := engine.(*dpos.Dpos); isDpos {
+			bc.wg.Add(1)
+			go bc.freezeLoop(ancients, dposEngine)
+		}
+	}
 	return bc, nil
 }
 
@@ -195,10 +257,12 @@ func (bc *BlockChain) loadLastState() error {
 		return bc.Reset()
 	}
 	// Make sure the state associated with the block is available
-	if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
-		// Dangling block without a state associated, init from scratch
-		log.Warn("Head state missing, resetting chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
-		return bc.Reset()
+	if !bc.hasState(currentBlock.Header()) {
+		// Dangling block without a state associated, rewind to the last one with
+		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
+		if err := bc.repair(&currentBlock); err != nil {
+			return err
+		}
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock = currentBlock
@@ -232,6 +296,33 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// repair tries to repair the current blockchain by rolling back the current block
+// until one with associated state is found. This is needed to fix incomplete db
+// writes caused either by crashes/power outages, or simply non-committed tries.
+//
+// This method only rolls back the current block. The current header and current
+// fast block are left intact.
+func (bc *BlockChain) repair(head **types.Block) error {
+	for {
+		// Abort if we've rewound to a head block that does have associated state
+		if bc.hasState((*head).Header()) {
+			log.Info("Rewound blockchain to past state", "number", (*head).Number(), "hash", (*head).Hash())
+			return nil
+		}
+		// Otherwise rewind one block and recheck state availability there
+		parent := bc.GetBlock((*head).ParentHash(), (*head).NumberU64()-1)
+		if parent == nil {
+			return fmt.Errorf("missing block %d [%x…]", (*head).NumberU64()-1, (*head).ParentHash().Bytes()[:4])
+		}
+		*head = parent
+	}
+}
+
+// hasState checks whether the state and DPoS tries of a block are available.
+func (bc *BlockChain) hasState(header *types.Header) bool {
+	return bc.hasAccountState(header) && bc.hasDposState(header)
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
@@ -249,6 +340,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Drop the rewound blocks from the ancient store too
+	if ancients, ok := bc.chainDb.(datxdb.AncientStore); ok {
+		if err := ancients.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			log.Crit("Failed to truncate ancient store", "err", err)
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -282,7 +380,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
 		log.Crit("Failed to reset head fast block", "err", err)
 	}
-	return bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return err
+	}
+	if bc.snaps != nil {
+		bc.capSnapshots(bc.currentBlock.Root())
+	}
+	return nil
 }
 
 // FastSyncCommitHead sets the current head block to the one defined by the hash
@@ -383,7 +487,7 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshots(root, bc.stateCache, bc.snaps)
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -415,6 +519,9 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	bc.hc.SetCurrentHeader(bc.genesisBlock.Header())
 	bc.currentFastBlock = bc.genesisBlock
 
+	if bc.snaps != nil {
+		bc.capSnapshots(bc.genesisBlock.Root())
+	}
 	return nil
 }
 
@@ -520,8 +627,7 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.chainDb.Has(blockBodyKey(hash, number))
-	return ok
+	return HasBody(bc.chainDb, hash, number)
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -544,7 +650,7 @@ func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 	if block, ok := bc.blockCache.Get(hash); ok {
 		return block.(*types.Block)
 	}
-	block := GetBlock(bc.chainDb, hash, number)
+	block := GetBlockWithTries(bc.chainDb, bc.stateCache.TrieDB(), hash, number)
 	if block == nil {
 		return nil
 	}
@@ -607,9 +713,99 @@ func (bc *BlockChain) Stop() {
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
 	bc.wg.Wait()
+
+	// Flatten the state snapshot into its disk layer, so it's of the head state
+	// the chain resumes from. A snapshot still being rebuilt is abandoned.
+	if bc.snaps != nil {
+		if err := bc.snaps.Cap(bc.CurrentBlock().Root(), 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "err", err)
+		}
+		bc.snaps.Close()
+	}
+	// Ensure the state of a recent block is also stored to disk before exiting.
+	// We're writing three different states to catch different restart scenarios:
+	//  - HEAD:     So we don't need to reprocess any blocks in the general case
+	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
+	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
+	if !bc.cacheConfig.Disabled {
+		triedb := bc.stateCache.TrieDB()
+
+		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
+			if number := bc.CurrentBlock().NumberU64(); number > offset {
+				recent := bc.GetBlockByNumber(number - offset)
+
+				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+				if err := bc.commitState(recent.Header()); err != nil {
+					log.Error("Failed to commit recent state trie", "err", err)
+				}
+			}
+		}
+		for !bc.triegc.Empty() {
+			bc.dereferenceState(bc.triegc.PopItem().(*types.Header))
+		}
+		if size := triedb.Size(); size != 0 {
+			log.Error("Dangling trie nodes after full cleanup", "size", size)
+		}
+	}
 	log.Info("Blockchain manager stopped")
 }
 
+// capSnapshots flattens the snapshot layers more than triesInMemory blocks below
+// the state of the head block into the disk layer. If the head state has no
+// snapshot, e.g. after rewinding the chain below the disk layer, the snapshot is
+// rebuilt from its trie in the background instead.
+func (bc *BlockChain) capSnapshots(root common.Hash) {
+	if bc.snaps.Snapshot(root) == nil {
+		log.Warn("State snapshot missing, rebuilding", "root", root)
+		if err := bc.snaps.Rebuild(root); err != nil {
+			log.Error("Failed to rebuild state snapshot", "root", root, "err", err)
+		}
+		return
+	}
+	if err := bc.snaps.Cap(root, triesInMemory); err != nil {
+		log.Error("Failed to flatten state snapshot", "root", root, "err", err)
+	}
+}
+
+// stateRoots returns the roots of the state trie and the DPoS tries of a block.
+func stateRoots(header *types.Header) []common.Hash {
+	roots := []common.Hash{header.Root}
+	if ctx := header.DposContext; ctx != nil {
+		roots = append(roots, ctx.EpochHash, ctx.DelegateHash, ctx.VoteHash, ctx.CandidateHash, ctx.MintCntHash)
+	}
+	return roots
+}
+
+// commitState flushes the state trie and the DPoS tries of a block from the
+// trie database to disk.
+func (bc *BlockChain) commitState(header *types.Header) error {
+	triedb := bc.stateCache.TrieDB()
+	for _, root := range stateRoots(header) {
+		if err := triedb.Commit(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// referenceState keeps the state trie and the DPoS tries of a block alive in
+// the trie database until they are dereferenced.
+func (bc *BlockChain) referenceState(header *types.Header) {
+	triedb := bc.stateCache.TrieDB()
+	for _, root := range stateRoots(header) {
+		triedb.Reference(root, common.Hash{})
+	}
+}
+
+// dereferenceState releases the tries of a block referenced by referenceState,
+// garbage collecting the nodes no other block references.
+func (bc *BlockChain) dereferenceState(header *types.Header) {
+	triedb := bc.stateCache.TrieDB()
+	for _, root := range stateRoots(header) {
+		triedb.Dereference(root)
+	}
+}
+
 func (bc *BlockChain) procFutureBlocks() {
 	blocks := make([]*types.Block, 0, bc.futureBlocks.Len())
 	for _, hash := range bc.futureBlocks.Keys() {
@@ -804,15 +1000,69 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	if err := bc.hc.WriteTd(block.Hash(), block.NumberU64(), externTd); err != nil {
 		return NonStatTy, err
 	}
-	// Write other block data using a batch.
-	batch := bc.chainDb.NewBatch()
-	if err := WriteBlock(batch, block); err != nil {
+	// Commit the state and DPoS tries into the trie database
+	triedb := bc.stateCache.TrieDB()
+	if _, err := block.DposContext.CommitTo(triedb); err != nil {
 		return NonStatTy, err
 	}
-	if _, err := block.DposContext.CommitTo(batch); err != nil {
+	if _, err := state.CommitTo(triedb, bc.config.IsEIP158(block.Number())); err != nil {
 		return NonStatTy, err
 	}
-	if _, err := state.CommitTo(batch, bc.config.IsEIP158(block.Number())); err != nil {
+	if bc.cacheConfig.Disabled {
+		// If we're running an archive node, always flush
+		if err := bc.commitState(block.Header()); err != nil {
+			return NonStatTy, err
+		}
+	} else {
+		// Full but not archive node, do proper garbage collection
+		bc.referenceState(block.Header())
+		bc.triegc.Push(block.Header(), -float32(block.NumberU64()))
+
+		if current := block.NumberU64(); current > triesInMemory {
+			// Find the next state trie we need to commit
+			header := bc.GetHeaderByNumber(current - triesInMemory)
+			chosen := header.Number.Uint64()
+
+			// Only write to disk if we exceeded our memory allowance *and* also have at
+			// least a given number of tries gapped.
+			var (
+				size    = triedb.Size()
+				limit   = common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+				elapsed = time.Since(bc.lastWriteTime)
+			)
+			if size > limit || elapsed > bc.cacheConfig.TrieTimeLimit {
+				// If we're exceeding limits but haven't reached a large enough memory gap,
+				// warn the user that the system is becoming unstable.
+				if chosen < bc.lastWrite+triesInMemory {
+					switch {
+					case size >= 2*limit:
+						log.Warn("State memory usage too high, committing", "size", size, "limit", limit, "optimum", float64(chosen-bc.lastWrite)/triesInMemory)
+					case elapsed >= 2*bc.cacheConfig.TrieTimeLimit:
+						log.Info("State in memory for too long, committing", "time", elapsed, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-bc.lastWrite)/triesInMemory)
+					}
+				}
+				// If optimum or critical limits reached, write to disk
+				if chosen >= bc.lastWrite+triesInMemory || size >= 2*limit || elapsed >= 2*bc.cacheConfig.TrieTimeLimit {
+					if err := bc.commitState(header); err != nil {
+						return NonStatTy, err
+					}
+					bc.lastWrite, bc.lastWriteTime = chosen, time.Now()
+				}
+			}
+			// Garbage collect anything below our required write retention
+			for !bc.triegc.Empty() {
+				header, number := bc.triegc.Pop()
+				if uint64(-number) > chosen {
+					bc.triegc.Push(header, number)
+					break
+				}
+				bc.dereferenceState(header.(*types.Header))
+			}
+		}
+	}
+	// Write other block data using a batch.
+	batch := bc.chainDb.NewBatch()
+	if err := WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
 	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
@@ -822,10 +1072,18 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
-	reorg := externTd.Cmp(localTd) > 0
-	if !reorg && externTd.Cmp(localTd) == 0 {
-		// Split same-difficulty blocks by number, then at random
-		reorg = block.NumberU64() < bc.currentBlock.NumberU64() || (block.NumberU64() == bc.currentBlock.NumberU64() && mrand.Float64() < 0.5)
+	var reorg bool
+	if dposEngine, isDpos := bc.engine.(*dpos.Dpos); isDpos {
+		// DPoS blocks all weigh the same, let the engine pick the best signed chain
+		if reorg, err = dposEngine.ForkChoice(bc, bc.currentBlock.Header(), block.Header()); err != nil {
+			return NonStatTy, err
+		}
+	} else {
+		reorg = externTd.Cmp(localTd) > 0
+		if !reorg && externTd.Cmp(localTd) == 0 {
+			// Split same-difficulty blocks by number, then at random
+			reorg = block.NumberU64() < bc.currentBlock.NumberU64() || (block.NumberU64() == bc.currentBlock.NumberU64() && mrand.Float64() < 0.5)
+		}
 	}
 	if reorg {
 		// Reorganise the chain if the parent is not the head block
@@ -853,6 +1111,10 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		if bc.snaps != nil {
+			bc.capSnapshots(block.Root())
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -967,11 +1229,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		block.DposContext, err = types.NewDposContextFromProto(bc.chainDb, parent.Header().DposContext)
+		block.DposContext, err = types.NewDposContextFromProto(bc.stateCache.TrieDB(), parent.Header().DposContext)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshots(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
@@ -1154,6 +1416,16 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("Invalid new chain")
 		}
 	}
+	// Refuse to drop the block confirmed by the DPoS validators
+	if dposEngine, isDpos := bc.engine.(*dpos.Dpos); isDpos {
+		if confirmed := dposEngine.ConfirmedHeader(bc); confirmed != nil {
+			for _, block := range oldChain {
+				if block.Hash() == confirmed.Hash() {
+					return dpos.ErrRevertConfirmedBlock
+				}
+			}
+		}
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Debug
@@ -1372,9 +1644,17 @@ func (bc *BlockChain) GetHeaderByNumber(number uint64) *types.Header {
 // Config retrieves the blockchain's chain configuration.
 func (bc *BlockChain) Config() *params.ChainConfig { return bc.config }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
+// GetVMConfig returns the VM configuration the blockchain processes blocks with.
+func (bc *BlockChain) GetVMConfig() *vm.Config { return &bc.vmConfig }
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
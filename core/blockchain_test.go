@@ -18,15 +18,19 @@ package core
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"math/rand"
+	"os"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
 	"github.com/DATxChain-Protocol/DATx/consensus/ethash"
 	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/state/snapshot"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/core/vm"
 	"github.com/DATxChain-Protocol/DATx/crypto"
@@ -1116,3 +1120,301 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		t.Error("account should not exist")
 	}
 }
+
+// Tests that DPoS chains prefer the branch signed by more distinct validators,
+// even if it's shorter, irrespective of the order the branches arrive in.
+func TestDposForkChoiceValidators(t *testing.T)        { testDposForkChoiceValidators(t, false) }
+func TestDposForkChoiceValidatorsReverse(t *testing.T) { testDposForkChoiceValidators(t, true) }
+
+func testDposForkChoiceValidators(t *testing.T, reverse bool) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 420)
+		db, _       = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(db)
+	)
+	// Build a long branch sealed by a single validator, skipping the others' slots
+	lonely, _ := GenerateDposChain(gspec.Config, genesis, db, 4, keys, func(i int, gen *BlockGen) {
+		gen.SetTime(int64(40 + 30*i))
+	})
+	// Build a short branch sealed by two distinct validators
+	shared, _ := GenerateDposChain(gspec.Config, genesis, db, 2, keys, nil)
+
+	if lonely[0].Header().Validator != lonely[3].Header().Validator {
+		t.Fatalf("long branch validators mismatch: have %x and %x", lonely[0].Header().Validator, lonely[3].Header().Validator)
+	}
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	first, second := lonely, shared
+	if reverse {
+		first, second = shared, lonely
+	}
+	if i, err := blockchain.InsertChain(first); err != nil {
+		t.Fatalf("failed to insert block %d: %v", first[i].NumberU64(), err)
+	}
+	if i, err := blockchain.InsertChain(second); err != nil {
+		t.Fatalf("failed to insert block %d: %v", second[i].NumberU64(), err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != shared[len(shared)-1].Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, shared[len(shared)-1].Hash())
+	}
+}
+
+// Tests that DPoS chains never reorganise below the block confirmed by the
+// validators, even onto a branch signed by more of them.
+func TestDposForkChoiceConfirmed(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(21, 420)
+		db, _       = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(db)
+	)
+	// Build a canonical chain long enough to confirm its first blocks
+	canon, _ := GenerateDposChain(gspec.Config, genesis, db, 17, keys, nil)
+
+	// Build a fork from the genesis signed by every validator
+	fork, _ := GenerateDposChain(gspec.Config, genesis, db, 21, keys, func(i int, gen *BlockGen) {
+		if i == 0 {
+			gen.SetTime(180)
+		}
+	})
+	engine := dpos.New(gspec.Config.Dpos, db)
+	blockchain, _ := NewBlockChain(db, gspec.Config, engine, vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(canon); err != nil {
+		t.Fatalf("failed to insert canonical block %d: %v", canon[i].NumberU64(), err)
+	}
+	confirmed := engine.ConfirmedHeader(blockchain)
+	if confirmed == nil || confirmed.Number.Sign() == 0 {
+		t.Fatalf("no block confirmed by the validators")
+	}
+	if i, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert forked block %d: %v", fork[i].NumberU64(), err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != canon[len(canon)-1].Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, canon[len(canon)-1].Hash())
+	}
+	if hash := blockchain.GetHeaderByNumber(confirmed.Number.Uint64()).Hash(); hash != confirmed.Hash() {
+		t.Errorf("confirmed block reverted: have %x, want %x", hash, confirmed.Hash())
+	}
+	// The reorg itself should refuse to revert the confirmed block too
+	if err := blockchain.reorg(blockchain.CurrentBlock(), fork[len(fork)-1]); err != dpos.ErrRevertConfirmedBlock {
+		t.Errorf("reorg error mismatch: have %v, want %v", err, dpos.ErrRevertConfirmedBlock)
+	}
+}
+
+// Tests that a pruning blockchain keeps the state of recent blocks in memory,
+// garbage collects the old ones, and flushes enough state on shutdown to resume
+// from its head.
+func TestDposTrieGarbageCollection(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 3600)
+		gendb, _    = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(gendb)
+	)
+	// Stay within the first epoch, three validators are too few to elect a new one
+	blocks, _ := GenerateDposChain(gspec.Config, genesis, gendb, triesInMemory+16, keys, nil)
+
+	db, _ := datxdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	blockchain, _ := NewBlockChainWithCache(db, nil, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	if i, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	head := blocks[len(blocks)-1]
+
+	// Recent state should be served from memory only, old state pruned
+	if !blockchain.hasState(head.Header()) {
+		t.Fatalf("head state missing")
+	}
+	if ok, _ := db.Has(head.Root().Bytes()); ok {
+		t.Fatalf("head state flushed to disk before shutdown")
+	}
+	if old := blocks[0].Header(); blockchain.hasState(old) {
+		t.Fatalf("state of block %d not garbage collected", old.Number)
+	}
+	// Blocks should be assembled with the DPoS tries held in memory only
+	blockchain.blockCache.Purge()
+	if block := blockchain.GetBlock(head.Hash(), head.NumberU64()); block == nil || block.DposContext == nil {
+		t.Fatalf("head block assembled without its in-memory DPoS tries")
+	}
+	blockchain.Stop()
+
+	// Shutting down should flush the head state, so a restart resumes from there
+	blockchain, _ = NewBlockChainWithCache(db, nil, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	if current := blockchain.CurrentBlock().Hash(); current != head.Hash() {
+		t.Fatalf("head mismatch after restart: have %x, want %x", current, head.Hash())
+	}
+	if size := blockchain.StateCache().TrieDB().Size(); size != 0 {
+		t.Errorf("trie database not empty after restart: %v", size)
+	}
+}
+
+// Tests that the state availability of a pruning blockchain starts past the
+// garbage collected blocks, while an archive one serves it from genesis.
+func TestDposStateAvailability(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 3600)
+		gendb, _    = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(gendb)
+	)
+	blocks, _ := GenerateDposChain(gspec.Config, genesis, gendb, triesInMemory+16, keys, nil)
+	head := uint64(len(blocks))
+
+	for _, archive := range []bool{false, true} {
+		db, _ := datxdb.NewMemDatabase()
+		gspec.MustCommit(db)
+
+		blockchain, _ := NewBlockChainWithCache(db, &CacheConfig{Disabled: archive, TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+		if avail := blockchain.StateAvailability(); *avail != (StateAvailability{Head: 0, Accounts: 0, Dpos: 0}) {
+			t.Errorf("archive %v: genesis availability mismatch: have %+v", archive, *avail)
+		}
+		if i, err := blockchain.InsertChain(blocks); err != nil {
+			t.Fatalf("archive %v: failed to insert block %d: %v", archive, blocks[i].NumberU64(), err)
+		}
+		want := StateAvailability{Head: head, Accounts: head - triesInMemory + 1, Dpos: head - triesInMemory + 1}
+		if archive {
+			want.Accounts, want.Dpos = 0, 0
+		}
+		if avail := blockchain.StateAvailability(); *avail != want {
+			t.Errorf("archive %v: availability mismatch: have %+v, want %+v", archive, *avail, want)
+		}
+		blockchain.Stop()
+	}
+}
+
+// Tests that freezing moves old blocks into the ancient store, that they are
+// still served from there, and that rewinding the chain truncates them.
+func TestDposFreezeAncients(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 3600)
+		gendb, _    = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(gendb)
+	)
+	blocks, receipts := GenerateDposChain(gspec.Config, genesis, gendb, 16, keys, nil)
+
+	dir, err := ioutil.TempDir("", "ancient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, _ := datxdb.NewMemDatabase()
+	db, err := datxdb.NewDatabaseWithFreezer(kvdb, dir)
+	if err != nil {
+		t.Fatalf("failed to create ancient store: %v", err)
+	}
+	defer db.Close()
+	gspec.MustCommit(db)
+
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	ancients := db.(datxdb.AncientStore)
+	if n, err := blockchain.freeze(ancients, 10); err != nil || n != 10 {
+		t.Fatalf("freeze mismatch: have %d blocks, %v, want 10 blocks", n, err)
+	}
+	if frozen, _ := ancients.Ancients(); frozen != 10 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 10", frozen)
+	}
+	// Frozen blocks should be gone from the key-value store, but still served
+	for i, block := range blocks[:9] {
+		hash, number := block.Hash(), block.NumberU64()
+		if ok, _ := kvdb.Has(headerKey(hash, number)); ok {
+			t.Errorf("block %d: header still in key-value store", number)
+		}
+		if have := blockchain.GetBlockByNumber(number); have == nil || have.Hash() != hash {
+			t.Errorf("block %d: frozen block not retrievable", number)
+		}
+		if !blockchain.HasBlock(hash, number) || !blockchain.HasHeader(hash, number) {
+			t.Errorf("block %d: frozen block not reported present", number)
+		}
+		if have := GetBlockReceipts(db, hash, number); len(have) != len(receipts[i]) {
+			t.Errorf("block %d: receipts mismatch: have %d, want %d", number, len(have), len(receipts[i]))
+		}
+		if td := blockchain.GetTd(hash, number); td == nil || td.Sign() <= 0 {
+			t.Errorf("block %d: total difficulty missing", number)
+		}
+	}
+	// Freezing again shouldn't move anything, nor freeze the head block
+	if n, err := blockchain.freeze(ancients, 10); err != nil || n != 0 {
+		t.Fatalf("refreeze mismatch: have %d blocks, %v, want none", n, err)
+	}
+	if n, _ := blockchain.freeze(ancients, 100); n != 6 {
+		t.Fatalf("freeze past head mismatch: have %d blocks, want 6", n)
+	}
+	// Rewinding below the frozen blocks should truncate the ancient store
+	if err := blockchain.SetHead(5); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if frozen, _ := ancients.Ancients(); frozen != 6 {
+		t.Fatalf("frozen blocks after rewind mismatch: have %d, want 6", frozen)
+	}
+	if have := blockchain.GetBlockByNumber(5); have == nil || have.Hash() != blocks[4].Hash() {
+		t.Fatalf("new head block not retrievable after rewind")
+	}
+}
+
+// Tests that a blockchain keeps a snapshot of the recent states serving the same
+// reads as the tries, flattening the old ones, and persists it on shutdown.
+func TestDposSnapshot(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 3600)
+		gendb, _    = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(gendb)
+		sender      = crypto.PubkeyToAddress(keys[0].PublicKey)
+	)
+	blocks, _ := GenerateDposChain(gspec.Config, genesis, gendb, triesInMemory+8, keys, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(types.Binary, gen.TxNonce(sender), common.Address{byte(i%16 + 1)}, big.NewInt(int64(i)), new(big.Int).SetUint64(params.TxGas), nil, nil), types.HomesteadSigner{}, keys[0])
+		gen.AddTx(tx)
+	})
+	db, _ := datxdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, Snapshot: true, SnapshotWait: true}
+	blockchain, err := NewBlockChainWithCache(db, cacheConfig, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if i, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	head := blocks[len(blocks)-1]
+
+	// Only the states of the recent blocks should be kept in memory
+	if root := blockchain.snaps.DiskRoot(); root != blocks[len(blocks)-1-triesInMemory].Root() {
+		t.Fatalf("snapshot disk layer mismatch: have %x, want %x", root, blocks[len(blocks)-1-triesInMemory].Root())
+	}
+	// Reads from the snapshot should match the trie
+	snapState, _ := blockchain.State()
+	trieState, _ := state.New(head.Root(), blockchain.StateCache())
+	for _, addr := range []common.Address{sender, {0x01}, {0x10}, {0x11}, head.Coinbase()} {
+		if have, want := snapState.GetBalance(addr), trieState.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("balance of %x mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := snapState.GetNonce(addr), trieState.GetNonce(addr); have != want {
+			t.Errorf("nonce of %x mismatch: have %d, want %d", addr, have, want)
+		}
+	}
+	// Shutting down should flatten the snapshot to the head, so it's reused
+	blockchain.Stop()
+
+	if err := snapshot.Verify(db, db, head.Root()); err != nil {
+		t.Fatalf("persisted snapshot mismatch: %v", err)
+	}
+	blockchain, err = NewBlockChainWithCache(db, cacheConfig, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to restart blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if blockchain.snaps.Snapshot(head.Root()) == nil {
+		t.Fatalf("snapshot of head state missing after restart")
+	}
+}
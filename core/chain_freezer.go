@@ -0,0 +1,161 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+)
+
+const (
+	// freezerMargin is the number of blocks below the block confirmed by the
+	// DPoS validators kept in the key-value store, a day of DPoS blocks.
+	freezerMargin = 8640
+
+	// freezerRecheckInterval is the time between checks for blocks to freeze.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks frozen in one go.
+	freezerBatchLimit = 30000
+)
+
+// freezeLoop periodically moves the headers, bodies, receipts and total
+// difficulties of the blocks that can no longer be reorganised from the
+// key-value store into the ancient store.
+func (bc *BlockChain) freezeLoop(ancients datxdb.AncientStore, engine *dpos.Dpos) {
+	defer bc.wg.Done()
+
+	for {
+		// Freeze everything below the margin under the confirmed block
+		if confirmed := engine.ConfirmedHeader(bc); confirmed != nil && confirmed.Number.Uint64() > freezerMargin {
+			if _, err := bc.freeze(ancients, confirmed.Number.Uint64()-freezerMargin); err != nil {
+				log.Error("Failed to freeze ancient blocks", "err", err)
+			}
+		}
+		select {
+		case <-time.After(freezerRecheckInterval):
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// freeze moves the canonical blocks below limit into the ancient store, up to
+// freezerBatchLimit of them, and deletes them along with any side chain blocks
+// of the same heights from the key-value store. It returns the number of blocks
+// frozen.
+func (bc *BlockChain) freeze(ancients datxdb.AncientStore, limit uint64) (int, error) {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	frozen, err := ancients.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	// Never freeze the head block, nor too many blocks in one go
+	if head := bc.CurrentBlock().NumberU64(); limit > head {
+		limit = head
+	}
+	if limit > frozen+freezerBatchLimit {
+		limit = frozen + freezerBatchLimit
+	}
+	if limit <= frozen {
+		return 0, nil
+	}
+	start := time.Now()
+
+	// Copy the canonical blocks into the ancient store and flush them, so they
+	// are never missing from both stores
+	for number := frozen; number < limit; number++ {
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if hash == (common.Hash{}) {
+			return int(number - frozen), fmt.Errorf("canonical hash #%d missing", number)
+		}
+		header := GetHeaderRLP(bc.chainDb, hash, number)
+		if len(header) == 0 {
+			return int(number - frozen), fmt.Errorf("block header #%d [%x…] missing", number, hash[:4])
+		}
+		body := GetBodyRLP(bc.chainDb, hash, number)
+		if len(body) == 0 {
+			return int(number - frozen), fmt.Errorf("block body #%d [%x…] missing", number, hash[:4])
+		}
+		receipts, _ := bc.chainDb.Get(blockReceiptsKey(hash, number))
+		if len(receipts) == 0 {
+			return int(number - frozen), fmt.Errorf("block receipts #%d [%x…] missing", number, hash[:4])
+		}
+		td, _ := bc.chainDb.Get(tdKey(hash, number))
+		if len(td) == 0 {
+			return int(number - frozen), fmt.Errorf("total difficulty #%d [%x…] missing", number, hash[:4])
+		}
+		if err := ancients.AppendAncient(number, hash[:], header, body, receipts, td); err != nil {
+			return int(number - frozen), err
+		}
+	}
+	if err := ancients.Sync(); err != nil {
+		return int(limit - frozen), err
+	}
+	// Delete the frozen blocks from the key-value store. Side chain blocks of the
+	// same heights can never become canonical, so they are dropped entirely.
+	batch := bc.chainDb.NewBatch()
+	for number := frozen; number < limit; number++ {
+		canonical := GetCanonicalHash(bc.chainDb, number)
+		for _, hash := range getHashesByNumber(bc.chainDb, number) {
+			if hash == canonical {
+				// Keep the number of the canonical hash, it's not frozen
+				batch.Delete(headerKey(hash, number))
+				DeleteBody(batch, hash, number)
+				DeleteBlockReceipts(batch, hash, number)
+				DeleteTd(batch, hash, number)
+			} else {
+				DeleteBlock(batch, hash, number)
+			}
+		}
+		if batch.ValueSize() >= datxdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return int(limit - frozen), err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return int(limit - frozen), err
+	}
+	log.Info("Froze ancient blocks", "blocks", limit-frozen, "number", limit-1, "elapsed", common.PrettyDuration(time.Since(start)))
+	return int(limit - frozen), nil
+}
+
+// getHashesByNumber returns the hashes of all blocks of the given number whose
+// headers are in the key-value store.
+func getHashesByNumber(db datxdb.Database, number uint64) []common.Hash {
+	prefix := append(append([]byte{}, headerPrefix...), encodeBlockNumber(number)...)
+
+	it := db.NewIteratorWithPrefix(prefix, nil)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(prefix):]))
+		}
+	}
+	return hashes
+}
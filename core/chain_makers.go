@@ -17,6 +17,7 @@
 package core
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/core/state"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
 	"github.com/DATxChain-Protocol/DATx/datxdb"
 	"github.com/DATxChain-Protocol/DATx/params"
 )
@@ -52,6 +54,10 @@ type BlockGen struct {
 	uncles   []*types.Header
 
 	config *params.ChainConfig
+
+	engine      *dpos.Dpos         // DPoS engine sealing the block (GenerateDposChain only)
+	dposContext *types.DposContext // DPoS tries the block's transactions are applied to
+	signer      *ecdsa.PrivateKey  // Validator key overriding the scheduled one
 }
 
 // SetCoinbase sets the coinbase of the generated block.
@@ -84,8 +90,11 @@ func (b *BlockGen) AddTx(tx *types.Transaction) {
 	if b.gasPool == nil {
 		b.SetCoinbase(common.Address{})
 	}
+	if tx.Type() != types.Binary && b.dposContext == nil {
+		panic("dpos transactions can only be added with GenerateDposChain")
+	}
 	b.statedb.Prepare(tx.Hash(), common.Hash{}, len(b.txs))
-	receipt, _, err := ApplyTransaction(b.config, nil, nil, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, b.header.GasUsed, vm.Config{})
+	receipt, _, err := ApplyTransaction(b.config, b.dposContext, nil, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, b.header.GasUsed, vm.Config{})
 	if err != nil {
 		panic(err)
 	}
@@ -93,6 +102,76 @@ func (b *BlockGen) AddTx(tx *types.Transaction) {
 	b.receipts = append(b.receipts, receipt)
 }
 
+// AddLoginTx adds a transaction registering the owner of key as a validator
+// candidate. It panics if not generating a DPoS chain.
+func (b *BlockGen) AddLoginTx(key *ecdsa.PrivateKey) {
+	b.addDposTx(types.LoginCandidate, key, crypto.PubkeyToAddress(key.PublicKey))
+}
+
+// AddLogoutTx adds a transaction withdrawing the candidacy of the owner of key.
+// It panics if not generating a DPoS chain.
+func (b *BlockGen) AddLogoutTx(key *ecdsa.PrivateKey) {
+	b.addDposTx(types.LogoutCandidate, key, crypto.PubkeyToAddress(key.PublicKey))
+}
+
+// AddDelegateTx adds a transaction voting for candidate with the balance of the
+// owner of key. It panics if not generating a DPoS chain.
+func (b *BlockGen) AddDelegateTx(key *ecdsa.PrivateKey, candidate common.Address) {
+	b.addDposTx(types.Delegate, key, candidate)
+}
+
+// AddUnDelegateTx adds a transaction withdrawing the vote of the owner of key
+// from candidate. It panics if not generating a DPoS chain.
+func (b *BlockGen) AddUnDelegateTx(key *ecdsa.PrivateKey, candidate common.Address) {
+	b.addDposTx(types.UnDelegate, key, candidate)
+}
+
+// addDposTx signs and adds a DPoS transaction sent by the owner of key. The
+// recipient is always set, as the state processor rejects DPoS transactions
+// without one.
+func (b *BlockGen) addDposTx(txType types.TxType, key *ecdsa.PrivateKey, to common.Address) {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	tx := types.NewTransaction(txType, b.TxNonce(from), to, new(big.Int), new(big.Int).SetUint64(params.TxGas), new(big.Int), nil)
+	tx, err := types.SignTx(tx, types.MakeSigner(b.config, b.header.Number), key)
+	if err != nil {
+		panic(err)
+	}
+	b.AddTx(tx)
+}
+
+// SetTime sets the timestamp of the generated block. DPoS blocks need to be on
+// a slot boundary, moving a block to a later slot leaves the skipped ones empty.
+func (b *BlockGen) SetTime(timestamp int64) {
+	b.header.Time = big.NewInt(timestamp)
+	if b.header.Time.Cmp(b.parent.Header().Time) <= 0 {
+		panic("block time out of range")
+	}
+}
+
+// Time returns the timestamp of the block being generated.
+func (b *BlockGen) Time() int64 {
+	return b.header.Time.Int64()
+}
+
+// Validator returns the validator scheduled to seal the generated block at its
+// current timestamp. It panics if not generating a DPoS chain.
+func (b *BlockGen) Validator() common.Address {
+	if b.engine == nil {
+		panic("validators are only scheduled with GenerateDposChain")
+	}
+	validator, err := b.engine.LookupValidator(b.parent.Header(), b.header.Time.Int64())
+	if err != nil {
+		panic(err)
+	}
+	return validator
+}
+
+// SetSigner seals the generated block with key instead of the key of the
+// scheduled validator. It's useful to test blocks sealed out of turn.
+func (b *BlockGen) SetSigner(key *ecdsa.PrivateKey) {
+	b.signer = key
+}
+
 // Number returns the block number of the block being generated.
 func (b *BlockGen) Number() *big.Int {
 	return new(big.Int).Set(b.header.Number)
@@ -203,6 +282,162 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db datxdb.Da
 	return blocks, receipts
 }
 
+// GenerateDposChain creates a chain of n blocks the way DPoS validators seal
+// them. It behaves like GenerateChain, but blocks are placed in consecutive
+// slots, DPoS transactions are applied to the tries of the parent, and every
+// block is finalized by the engine (running elections, kickouts and mint
+// counting) and signed by the validator scheduled for its slot. Chains created
+// this way pass full validation by a BlockChain running the DPoS engine.
+//
+// keys are the validator keys the blocks can be signed with. Generation panics
+// if a slot falls to a validator without a key; skip the slot with SetTime or
+// sign with another key through SetSigner. db must contain the state and the
+// DPoS tries of parent, the ones of the generated blocks are written into it.
+func GenerateDposChain(config *params.ChainConfig, parent *types.Block, db datxdb.Database, n int, keys []*ecdsa.PrivateKey, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+	if config == nil {
+		config = params.DposChainConfig
+	}
+	signers := make(map[common.Address]*ecdsa.PrivateKey, len(keys))
+	for _, key := range keys {
+		signers[crypto.PubkeyToAddress(key.PublicKey)] = key
+	}
+	var (
+		engine = dpos.New(config.Dpos, db)
+		chain  = newDposChainReader(config, db, parent)
+	)
+	blocks, receipts := make(types.Blocks, n), make([]types.Receipts, n)
+	for i := 0; i < n; i++ {
+		statedb, err := state.New(parent.Root(), state.NewDatabase(db))
+		if err != nil {
+			panic(err)
+		}
+		dposContext, err := types.NewDposContextFromProto(db, parent.Header().DposContext)
+		if err != nil {
+			panic(err)
+		}
+		header := makeHeader(config, parent, statedb)
+		b := &BlockGen{parent: parent, i: i, chain: blocks, header: header, statedb: statedb, config: config, engine: engine, dposContext: dposContext}
+
+		// Execute any user modifications to the block and pick the sealer
+		if gen != nil {
+			gen(i, b)
+		}
+		if err := engine.Prepare(chain, header); err != nil {
+			panic(err)
+		}
+		key := b.signer
+		if key == nil {
+			validator := b.Validator()
+			if key = signers[validator]; key == nil {
+				panic(fmt.Sprintf("no key for validator %x scheduled at %d", validator, header.Time))
+			}
+		}
+		header.Validator = crypto.PubkeyToAddress(key.PublicKey)
+
+		// Finalize the block with the engine and persist the resulting tries
+		block, err := engine.Finalize(chain, header, statedb, b.txs, b.uncles, b.receipts, dposContext)
+		if err != nil {
+			panic(fmt.Sprintf("dpos finalize error: %v", err))
+		}
+		if _, err := statedb.CommitTo(db, config.IsEIP158(header.Number)); err != nil {
+			panic(fmt.Sprintf("state write error: %v", err))
+		}
+		if _, err := dposContext.CommitTo(db); err != nil {
+			panic(fmt.Sprintf("dpos context write error: %v", err))
+		}
+		// Seal the block with the validator's key
+		sealed := block.Header()
+		sig, err := crypto.Sign(dpos.SigHash(sealed).Bytes(), key)
+		if err != nil {
+			panic(err)
+		}
+		copy(sealed.Extra[len(sealed.Extra)-len(sig):], sig)
+		block = block.WithSeal(sealed)
+
+		blocks[i], receipts[i] = block, b.receipts
+		chain.blocks = append(chain.blocks, block)
+		parent = block
+	}
+	return blocks, receipts
+}
+
+// dposChainReader is the consensus.ChainReader the DPoS engine finalizes the
+// blocks of GenerateDposChain against. It serves the blocks generated so far and
+// the ancestors of the first parent from the database.
+type dposChainReader struct {
+	config    *params.ChainConfig
+	db        datxdb.Database
+	blocks    []*types.Block           // First parent and the generated blocks
+	ancestors map[uint64]*types.Header // Cached ancestors of the first parent
+}
+
+func newDposChainReader(config *params.ChainConfig, db datxdb.Database, parent *types.Block) *dposChainReader {
+	return &dposChainReader{
+		config:    config,
+		db:        db,
+		blocks:    []*types.Block{parent},
+		ancestors: make(map[uint64]*types.Header),
+	}
+}
+
+func (cr *dposChainReader) Config() *params.ChainConfig {
+	return cr.config
+}
+
+func (cr *dposChainReader) CurrentHeader() *types.Header {
+	return cr.blocks[len(cr.blocks)-1].Header()
+}
+
+func (cr *dposChainReader) GetHeaderByNumber(number uint64) *types.Header {
+	first := cr.blocks[0].NumberU64()
+	if number >= first {
+		if index := number - first; index < uint64(len(cr.blocks)) {
+			return cr.blocks[index].Header()
+		}
+		return nil
+	}
+	if header, ok := cr.ancestors[number]; ok {
+		return header
+	}
+	header := cr.blocks[0].Header()
+	for header != nil && header.Number.Uint64() > number {
+		header = GetHeader(cr.db, header.ParentHash, header.Number.Uint64()-1)
+	}
+	if header != nil {
+		cr.ancestors[number] = header
+	}
+	return header
+}
+
+func (cr *dposChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := cr.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return GetHeader(cr.db, hash, number)
+}
+
+func (cr *dposChainReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	for i := len(cr.blocks) - 1; i >= 0; i-- {
+		if cr.blocks[i].Hash() == hash {
+			return cr.blocks[i].Header()
+		}
+	}
+	number := GetBlockNumber(cr.db, hash)
+	if number == missingNumber {
+		return nil
+	}
+	return GetHeader(cr.db, hash, number)
+}
+
+func (cr *dposChainReader) GetBlock(hash common.Hash, number uint64) *types.Block {
+	for i := len(cr.blocks) - 1; i >= 0; i-- {
+		if cr.blocks[i].Hash() == hash {
+			return cr.blocks[i]
+		}
+	}
+	return GetBlock(cr.db, hash, number)
+}
+
 func makeHeader(config *params.ChainConfig, parent *types.Block, state *state.StateDB) *types.Header {
 	var time *big.Int
 	if parent.Time() == nil {
@@ -17,9 +17,14 @@
 package core
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"testing"
 
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
 	"github.com/DATxChain-Protocol/DATx/consensus/ethash"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/core/vm"
@@ -98,3 +103,167 @@ func ExampleGenerateChain() {
 	// balance of addr2: 10000
 	// balance of addr3: 15000000000000001000
 }
+
+// newDposTestGenesis creates a DPoS genesis with n equally funded validators
+// electing every epoch seconds, along with the validator keys.
+func newDposTestGenesis(n int, epoch uint64) (*Genesis, []*ecdsa.PrivateKey) {
+	var (
+		keys       = make([]*ecdsa.PrivateKey, n)
+		validators = make([]common.Address, n)
+		alloc      = make(GenesisAlloc)
+	)
+	for i := 0; i < n; i++ {
+		keys[i], _ = crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("validator-%d", i))))
+		validators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[validators[i]] = GenesisAccount{Balance: big.NewInt(1e18)}
+	}
+	config := *params.DposChainConfig
+	config.Dpos = &params.DposConfig{Validators: validators, Epoch: epoch}
+
+	return &Genesis{Config: &config, Difficulty: big.NewInt(1), Alloc: alloc}, keys
+}
+
+// hasAddress reports whether addr is contained in addrs.
+func hasAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Tests that generated DPoS chains pass full validation across several epochs,
+// electing the candidates voted in by transactions and kicking out validators
+// that didn't produce any blocks.
+func TestGenerateDposChain(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(21, 420)
+		candKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		voterKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		candidate   = crypto.PubkeyToAddress(candKey.PublicKey)
+		voter       = crypto.PubkeyToAddress(voterKey.PublicKey)
+		offline     = gspec.Config.Dpos.Validators[0]
+		db, _       = datxdb.NewMemDatabase()
+	)
+	// Pick the validator with the lowest address to go offline, the one with the
+	// highest is voted out of the first elected set by the new candidate
+	for _, validator := range gspec.Config.Dpos.Validators {
+		if bytes.Compare(validator.Bytes(), offline.Bytes()) < 0 {
+			offline = validator
+		}
+	}
+	gspec.Alloc[candidate] = GenesisAccount{Balance: big.NewInt(1e18)}
+	gspec.Alloc[voter] = GenesisAccount{Balance: big.NewInt(3e18)}
+	genesis := gspec.MustCommit(db)
+
+	chain, _ := GenerateDposChain(gspec.Config, genesis, db, 90, append(keys, candKey), func(i int, gen *BlockGen) {
+		switch i {
+		case 0:
+			gen.AddLoginTx(candKey)
+		case 1:
+			gen.AddDelegateTx(voterKey, candidate)
+		}
+		// Take the offline validator down from the second epoch on
+		for gen.Time() >= 420 && gen.Validator() == offline {
+			gen.SetTime(gen.Time() + 10)
+		}
+	})
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert block %d: %v", chain[i].NumberU64(), err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != chain[len(chain)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, chain[len(chain)-1].Hash())
+	}
+	// Check the validator sets of the second and third epochs
+	validators := func(block *types.Block) []common.Address {
+		dposContext, err := types.NewDposContextFromProto(db, block.Header().DposContext)
+		if err != nil {
+			t.Fatalf("failed to load dpos context of block %d: %v", block.NumberU64(), err)
+		}
+		validators, err := dposContext.GetValidators()
+		if err != nil {
+			t.Fatalf("failed to load validators of block %d: %v", block.NumberU64(), err)
+		}
+		return validators
+	}
+	var second, third *types.Block
+	for _, block := range chain {
+		switch block.Time().Uint64() / 420 {
+		case 1:
+			second = block
+		case 2:
+			third = block
+		}
+	}
+	if third == nil {
+		t.Fatalf("chain didn't reach the third epoch")
+	}
+	if set := validators(second); !hasAddress(set, candidate) || !hasAddress(set, offline) {
+		t.Errorf("second epoch validators %x: want candidate %x and offline %x", set, candidate, offline)
+	}
+	if set := validators(third); !hasAddress(set, candidate) || hasAddress(set, offline) {
+		t.Errorf("third epoch validators %x: want candidate %x without offline %x", set, candidate, offline)
+	}
+}
+
+// Tests that blocks sealed out of turn are rejected.
+func TestGenerateDposChainOutOfTurn(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(21, 420)
+		db, _       = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(db)
+	)
+	chain, _ := GenerateDposChain(gspec.Config, genesis, db, 3, keys, func(i int, gen *BlockGen) {
+		if i == 2 {
+			for _, key := range keys {
+				if crypto.PubkeyToAddress(key.PublicKey) != gen.Validator() {
+					gen.SetSigner(key)
+					break
+				}
+			}
+		}
+	})
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(chain); err != dpos.ErrInvalidBlockValidator {
+		t.Fatalf("insert error mismatch: have %v at %d, want %v", err, i, dpos.ErrInvalidBlockValidator)
+	}
+	if head := blockchain.CurrentBlock().NumberU64(); head != 2 {
+		t.Errorf("head number mismatch: have %d, want 2", head)
+	}
+}
+
+// Tests that a longer DPoS fork with skipped slots takes over a shorter one.
+func TestGenerateDposChainReorg(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(21, 420)
+		db, _       = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(db)
+	)
+	short, _ := GenerateDposChain(gspec.Config, genesis, db, 3, keys, nil)
+	long, _ := GenerateDposChain(gspec.Config, genesis, db, 5, keys, func(i int, gen *BlockGen) {
+		if i == 0 {
+			gen.SetTime(gen.Time() + 10)
+		}
+	})
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(short); err != nil {
+		t.Fatalf("failed to insert short block %d: %v", short[i].NumberU64(), err)
+	}
+	if i, err := blockchain.InsertChain(long); err != nil {
+		t.Fatalf("failed to insert long block %d: %v", long[i].NumberU64(), err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != long[len(long)-1].Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, long[len(long)-1].Hash())
+	}
+	if hash := blockchain.GetHeaderByNumber(1).Hash(); hash != long[0].Hash() {
+		t.Errorf("canonical block #1 mismatch: have %x, want %x", hash, long[0].Hash())
+	}
+}
@@ -0,0 +1,403 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// CheckpointVersion is the version of the state checkpoint format written.
+const CheckpointVersion = 1
+
+// Kinds of the chunks of a state checkpoint.
+const (
+	checkpointHeaders = iota // RLP encoded headers of the ancestors, oldest first
+	checkpointState          // Trie nodes and contract codes, parents before children
+)
+
+// checkpointChunkSize is the size of the entries a chunk is closed at.
+const checkpointChunkSize = 1024 * 1024
+
+// checkpointKey tracks the hash of the block the chain was started from with a
+// state checkpoint. The blocks below it have no bodies or receipts.
+var checkpointKey = []byte("LastCheckpoint")
+
+// CheckpointHeader opens a state checkpoint. It's followed by the chunks of the
+// ancestor headers, then those of the state and DPoS tries of the block.
+type CheckpointHeader struct {
+	Version uint64
+	Genesis common.Hash  // Hash of the genesis block of the chain
+	Block   *types.Block // Block the checkpoint is of
+	Td      *big.Int     // Total difficulty of the block
+}
+
+// checkpointChunk is a batch of checkpoint entries of the same kind, along with
+// their hash to catch corruption before anything is written.
+type checkpointChunk struct {
+	Kind    uint64
+	Entries [][]byte
+	Hash    common.Hash
+}
+
+// hash returns the hash of the RLP encoded kind and entries of the chunk.
+func (c *checkpointChunk) hash() common.Hash {
+	blob, _ := rlp.EncodeToBytes([]interface{}{c.Kind, c.Entries})
+	return crypto.Keccak256Hash(blob)
+}
+
+// checkpointWriter groups the entries of a checkpoint into chunks.
+type checkpointWriter struct {
+	w     io.Writer
+	chunk checkpointChunk
+	size  int
+}
+
+// add appends an entry to the current chunk, writing the chunk out if it's of
+// another kind or full.
+func (cw *checkpointWriter) add(kind uint64, entry []byte) error {
+	if cw.chunk.Kind != kind || cw.size >= checkpointChunkSize {
+		if err := cw.flush(); err != nil {
+			return err
+		}
+	}
+	cw.chunk.Kind = kind
+	cw.chunk.Entries = append(cw.chunk.Entries, entry)
+	cw.size += len(entry)
+	return nil
+}
+
+// flush writes out the current chunk, if it has any entries.
+func (cw *checkpointWriter) flush() error {
+	if len(cw.chunk.Entries) == 0 {
+		return nil
+	}
+	cw.chunk.Hash = cw.chunk.hash()
+	if err := rlp.Encode(cw.w, &cw.chunk); err != nil {
+		return err
+	}
+	cw.chunk, cw.size = checkpointChunk{}, 0
+	return nil
+}
+
+// exportTrie adds the stored nodes of a trie to the checkpoint, parents before
+// children, calling onLeaf with the value of every leaf if set.
+func (cw *checkpointWriter) exportTrie(triedb trie.Database, root common.Hash, onLeaf func(blob []byte) error) error {
+	t, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for it.Next(true) {
+		// Nodes embedded in their parent have no hash and aren't stored
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			blob, err := triedb.Get(hash[:])
+			if err != nil {
+				return err
+			}
+			if err := cw.add(checkpointState, blob); err != nil {
+				return err
+			}
+		}
+		if it.Leaf() && onLeaf != nil {
+			if err := onLeaf(it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+// ExportCheckpoint writes a state checkpoint of the canonical block of the given
+// number to w: the block itself, the headers of its ancestors the DPoS engine
+// needs to validate its descendants, and all nodes and codes of its state and
+// DPoS tries.
+func (bc *BlockChain) ExportCheckpoint(w io.Writer, number uint64) error {
+	if number == 0 {
+		return errors.New("genesis block can't be checkpointed")
+	}
+	block := bc.GetBlockByNumber(number)
+	if block == nil {
+		return fmt.Errorf("block #%d not found", number)
+	}
+	dposProto := block.Header().DposContext
+	if dposProto == nil {
+		return fmt.Errorf("block #%d has no DPoS context", number)
+	}
+	header := &CheckpointHeader{
+		Version: CheckpointVersion,
+		Genesis: bc.genesisBlock.Hash(),
+		Block:   block,
+		Td:      bc.GetTd(block.Hash(), number),
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return err
+	}
+	log.Info("Exporting state checkpoint", "number", number, "hash", block.Hash())
+
+	var (
+		cw     = &checkpointWriter{w: w}
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for n := uint64(1); n < number; n++ {
+		ancestor := bc.GetHeaderByNumber(n)
+		if ancestor == nil {
+			return fmt.Errorf("header #%d not found", n)
+		}
+		blob, err := rlp.EncodeToBytes(ancestor)
+		if err != nil {
+			return err
+		}
+		if err := cw.add(checkpointHeaders, blob); err != nil {
+			return err
+		}
+	}
+	// Storage tries and codes follow the account trie node referencing them, so
+	// the importer knows to expect them
+	var (
+		triedb   = bc.stateCache.TrieDB()
+		accounts int
+		storages = make(map[common.Hash]bool)
+		codes    = make(map[common.Hash]bool)
+	)
+	err := cw.exportTrie(triedb, block.Root(), func(blob []byte) error {
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			return err
+		}
+		accounts++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state checkpoint", "accounts", accounts, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		// Accounts with identical storage or code share it
+		if !storages[account.Root] {
+			storages[account.Root] = true
+			if err := cw.exportTrie(triedb, account.Root, nil); err != nil {
+				return err
+			}
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); !bytes.Equal(account.CodeHash, emptyCodeHash) && !codes[codeHash] {
+			codes[codeHash] = true
+			code, err := triedb.Get(codeHash[:])
+			if err != nil {
+				return fmt.Errorf("code %x: %v", codeHash, err)
+			}
+			if err := cw.add(checkpointState, code); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The DPoS tries are plain tries, their key prefixes don't affect the nodes
+	for _, root := range []common.Hash{dposProto.EpochHash, dposProto.DelegateHash, dposProto.CandidateHash, dposProto.VoteHash, dposProto.MintCntHash} {
+		if err := cw.exportTrie(triedb, root, nil); err != nil {
+			return err
+		}
+	}
+	if err := cw.flush(); err != nil {
+		return err
+	}
+	log.Info("Exported state checkpoint", "number", number, "hash", block.Hash(), "accounts", accounts, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// ImportCheckpoint reads a state checkpoint from r into the database, making its
+// block the head of the chain. Every entry is checked against its hash, and the
+// ancestors and tries against the block, which has to be the trusted one: the
+// seals and validators of the block aren't verified, so the trusted hash is the
+// only thing telling a checkpoint apart from a forged one. The database has to be
+// initialised with the same genesis block and hold no other blocks.
+func ImportCheckpoint(db datxdb.Database, r io.Reader, trusted common.Hash) (*types.Block, error) {
+	if trusted == (common.Hash{}) {
+		return nil, errors.New("trusted checkpoint block hash required")
+	}
+	stream := rlp.NewStream(r, 0)
+
+	var header CheckpointHeader
+	if err := stream.Decode(&header); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint header: %v", err)
+	}
+	if header.Version != CheckpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", header.Version)
+	}
+	block := header.Block
+	if block.NumberU64() == 0 || block.Header().DposContext == nil || header.Td == nil {
+		return nil, errors.New("invalid checkpoint block")
+	}
+	if block.Hash() != trusted {
+		return nil, fmt.Errorf("checkpoint of block %x, not the trusted %x", block.Hash(), trusted)
+	}
+	if types.DeriveSha(block.Transactions()) != block.TxHash() || types.CalcUncleHash(block.Uncles()) != block.UncleHash() {
+		return nil, errors.New("checkpoint block body doesn't match its header")
+	}
+	genesis := GetCanonicalHash(db, 0)
+	if genesis != header.Genesis {
+		return nil, fmt.Errorf("checkpoint of genesis %x, database has %x", header.Genesis, genesis)
+	}
+	if head := GetHeadBlockHash(db); head != genesis {
+		return nil, fmt.Errorf("database has blocks beyond genesis, head %x", head)
+	}
+	log.Info("Importing state checkpoint", "number", block.Number(), "hash", block.Hash())
+
+	// Feed the state entries to a sync of the state and DPoS tries, which only
+	// accepts the nodes and codes they reference
+	dposProto := block.Header().DposContext
+	sched := state.NewStateSync(block.Root(), db)
+	for _, root := range []common.Hash{dposProto.EpochHash, dposProto.DelegateHash, dposProto.CandidateHash, dposProto.VoteHash, dposProto.MintCntHash} {
+		sched.AddSubTrie(root, 0, common.Hash{}, nil)
+	}
+	var (
+		parent = GetHeader(db, genesis, 0)
+		td     = GetTd(db, genesis, 0)
+		batch  = db.NewBatch()
+
+		chunks, entries, skipped int
+		start                    = time.Now()
+		logged                   = time.Now()
+	)
+	for {
+		var chunk checkpointChunk
+		if err := stream.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint chunk %d: %v", chunks, err)
+		}
+		if chunk.Hash != chunk.hash() {
+			return nil, fmt.Errorf("checkpoint chunk %d corrupted", chunks)
+		}
+		switch chunk.Kind {
+		case checkpointHeaders:
+			for _, blob := range chunk.Entries {
+				ancestor := new(types.Header)
+				if err := rlp.DecodeBytes(blob, ancestor); err != nil {
+					return nil, fmt.Errorf("invalid header in chunk %d: %v", chunks, err)
+				}
+				number := ancestor.Number.Uint64()
+				if ancestor.ParentHash != parent.Hash() || number != parent.Number.Uint64()+1 {
+					return nil, fmt.Errorf("header #%d doesn't extend its parent", number)
+				}
+				td = new(big.Int).Add(td, ancestor.Difficulty)
+				if err := WriteHeader(batch, ancestor); err != nil {
+					return nil, err
+				}
+				if err := WriteTd(batch, ancestor.Hash(), number, td); err != nil {
+					return nil, err
+				}
+				if err := WriteCanonicalHash(batch, ancestor.Hash(), number); err != nil {
+					return nil, err
+				}
+				parent = ancestor
+			}
+		case checkpointState:
+			results := make([]trie.SyncResult, len(chunk.Entries))
+			for i, blob := range chunk.Entries {
+				results[i] = trie.SyncResult{Hash: crypto.Keccak256Hash(blob), Data: blob}
+			}
+			// Entries already stored or sent before aren't requested, skip them
+			for len(results) > 0 {
+				_, i, err := sched.Process(results)
+				if err == nil {
+					break
+				}
+				if err != trie.ErrNotRequested && err != trie.ErrAlreadyProcessed {
+					return nil, fmt.Errorf("invalid state entry %x: %v", results[i].Hash, err)
+				}
+				skipped++
+				results = results[i+1:]
+			}
+			// Nothing is fetched, drop the queued requests of the scheduler
+			sched.Missing(0)
+			if _, err := sched.Commit(batch); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown kind %d of checkpoint chunk %d", chunk.Kind, chunks)
+		}
+		chunks++
+		entries += len(chunk.Entries)
+
+		// The sync checks the database for the tries and codes it already has,
+		// so every chunk has to be written out before the next one
+		if err := batch.Write(); err != nil {
+			return nil, err
+		}
+		batch.Reset()
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state checkpoint", "chunks", chunks, "entries", entries, "pending", sched.Pending(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	// Everything is checked against the block, make it the head of the chain
+	if parent.Hash() != block.ParentHash() || parent.Number.Uint64()+1 != block.NumberU64() {
+		return nil, fmt.Errorf("checkpoint ancestors incomplete, last #%d", parent.Number)
+	}
+	if td = new(big.Int).Add(td, block.Difficulty()); td.Cmp(header.Td) != 0 {
+		return nil, fmt.Errorf("checkpoint total difficulty mismatch: have %v, want %v", header.Td, td)
+	}
+	if pending := sched.Pending(); pending > 0 {
+		return nil, fmt.Errorf("checkpoint state incomplete, %d entries missing", pending)
+	}
+	if err := WriteBlock(batch, block); err != nil {
+		return nil, err
+	}
+	if err := WriteTd(batch, block.Hash(), block.NumberU64(), td); err != nil {
+		return nil, err
+	}
+	if err := WriteCanonicalHash(batch, block.Hash(), block.NumberU64()); err != nil {
+		return nil, err
+	}
+	if err := batch.Put(checkpointKey, block.Hash().Bytes()); err != nil {
+		return nil, err
+	}
+	WriteHeadHeaderHash(batch, block.Hash())
+	WriteHeadFastBlockHash(batch, block.Hash())
+	WriteHeadBlockHash(batch, block.Hash())
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	log.Info("Imported state checkpoint", "number", block.Number(), "hash", block.Hash(), "chunks", chunks, "entries", entries, "skipped", skipped, "elapsed", common.PrettyDuration(time.Since(start)))
+	return block, nil
+}
+
+// GetCheckpointHash returns the hash of the block the chain was started from
+// with a state checkpoint, the zero hash if it was synced from genesis.
+func GetCheckpointHash(db DatabaseReader) common.Hash {
+	data, _ := db.Get(checkpointKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+)
+
+// Tests that a node started from a state checkpoint has the state and DPoS
+// tries of the block, and goes on validating and importing the chain from it.
+func TestCheckpointExportImport(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 3600)
+		candKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		candidate   = crypto.PubkeyToAddress(candKey.PublicKey)
+		sender      = crypto.PubkeyToAddress(keys[0].PublicKey)
+		code        = []byte{byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.STOP)}
+		storage     = map[common.Hash]common.Hash{{}: {0x2a}}
+	)
+	// Two contracts sharing their code and storage trie
+	gspec.Alloc[candidate] = GenesisAccount{Balance: big.NewInt(1e18)}
+	gspec.Alloc[common.Address{0xc1}] = GenesisAccount{Balance: big.NewInt(1), Code: code, Storage: storage}
+	gspec.Alloc[common.Address{0xc2}] = GenesisAccount{Balance: big.NewInt(1), Code: code, Storage: storage}
+
+	gendb, _ := datxdb.NewMemDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := GenerateDposChain(gspec.Config, genesis, gendb, 24, keys, func(i int, gen *BlockGen) {
+		switch i {
+		case 0:
+			gen.AddLoginTx(candKey)
+		case 1:
+			gen.AddDelegateTx(keys[1], candidate)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(types.Binary, gen.TxNonce(sender), common.Address{byte(i + 1)}, big.NewInt(int64(i+1)), new(big.Int).SetUint64(params.TxGas), nil, nil), types.HomesteadSigner{}, keys[0])
+		gen.AddTx(tx)
+	})
+	srcdb, _ := datxdb.NewMemDatabase()
+	gspec.MustCommit(srcdb)
+	source, _ := NewBlockChain(srcdb, gspec.Config, dpos.New(gspec.Config.Dpos, srcdb), vm.Config{})
+	defer source.Stop()
+
+	if i, err := source.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	checkpoint := blocks[15]
+
+	buf := new(bytes.Buffer)
+	if err := source.ExportCheckpoint(buf, checkpoint.NumberU64()); err != nil {
+		t.Fatalf("failed to export checkpoint: %v", err)
+	}
+	blob := buf.Bytes()
+
+	// importInto imports a checkpoint into a fresh database of the same genesis
+	importInto := func(blob []byte, trusted common.Hash) (datxdb.Database, error) {
+		db, _ := datxdb.NewMemDatabase()
+		gspec.MustCommit(db)
+		_, err := ImportCheckpoint(db, bytes.NewReader(blob), trusted)
+		return db, err
+	}
+	db, err := importInto(blob, checkpoint.Hash())
+	if err != nil {
+		t.Fatalf("failed to import checkpoint: %v", err)
+	}
+	if hash := GetCheckpointHash(db); hash != checkpoint.Hash() {
+		t.Fatalf("checkpoint hash mismatch: have %x, want %x", hash, checkpoint.Hash())
+	}
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	defer blockchain.Stop()
+
+	if head := blockchain.CurrentBlock().Hash(); head != checkpoint.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, checkpoint.Hash())
+	}
+	have, _ := blockchain.State()
+	want, _ := source.StateAt(checkpoint.Root())
+	for _, addr := range []common.Address{sender, candidate, {0x10}, {0xc1}, {0xc2}} {
+		if have.GetBalance(addr).Cmp(want.GetBalance(addr)) != 0 {
+			t.Errorf("account %x balance mismatch: have %v, want %v", addr, have.GetBalance(addr), want.GetBalance(addr))
+		}
+		if !bytes.Equal(have.GetCode(addr), want.GetCode(addr)) {
+			t.Errorf("account %x code mismatch: have %x, want %x", addr, have.GetCode(addr), want.GetCode(addr))
+		}
+		if have.GetState(addr, common.Hash{}) != want.GetState(addr, common.Hash{}) {
+			t.Errorf("account %x storage mismatch: have %x, want %x", addr, have.GetState(addr, common.Hash{}), want.GetState(addr, common.Hash{}))
+		}
+	}
+	dposContext, err := types.NewDposContextFromProto(db, checkpoint.Header().DposContext)
+	if err != nil {
+		t.Fatalf("failed to load checkpoint dpos context: %v", err)
+	}
+	if value, err := dposContext.CandidateTrie().TryGet(candidate.Bytes()); err != nil || !bytes.Equal(value, candidate.Bytes()) {
+		t.Fatalf("candidate missing from candidate trie: %x, %v", value, err)
+	}
+	// The rest of the chain should validate on top of the checkpoint
+	if i, err := blockchain.InsertChain(blocks[16:]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[16+i].NumberU64(), err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("final head mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+	// Checkpoints of other blocks, or damaged ones, should be refused
+	if _, err := importInto(blob, blocks[14].Hash()); err == nil {
+		t.Errorf("checkpoint of untrusted block imported")
+	}
+	if _, err := importInto(blob, common.Hash{}); err == nil {
+		t.Errorf("checkpoint imported without a trusted block")
+	}
+	corrupt := common.CopyBytes(blob)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := importInto(corrupt, checkpoint.Hash()); err == nil {
+		t.Errorf("corrupted checkpoint imported")
+	}
+	if _, err := importInto(truncateCheckpoint(t, blob), checkpoint.Hash()); err == nil {
+		t.Errorf("truncated checkpoint imported")
+	}
+	if _, err := ImportCheckpoint(srcdb, bytes.NewReader(blob), checkpoint.Hash()); err == nil {
+		t.Errorf("checkpoint imported over existing chain")
+	}
+}
+
+// truncateCheckpoint drops the last chunk of a checkpoint.
+func truncateCheckpoint(t *testing.T, blob []byte) []byte {
+	var (
+		stream = rlp.NewStream(bytes.NewReader(blob), 0)
+		header CheckpointHeader
+		chunks []checkpointChunk
+	)
+	if err := stream.Decode(&header); err != nil {
+		t.Fatalf("failed to decode checkpoint header: %v", err)
+	}
+	for {
+		var chunk checkpointChunk
+		if err := stream.Decode(&chunk); err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	buf := new(bytes.Buffer)
+	rlp.Encode(buf, &header)
+	for _, chunk := range chunks[:len(chunks)-1] {
+		rlp.Encode(buf, &chunk)
+	}
+	return buf.Bytes()
+}
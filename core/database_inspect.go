@@ -0,0 +1,450 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/state/snapshot"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// Categories of database entries reported by InspectDatabase, in the order they
+// are listed.
+const (
+	CategoryHeaders         = "Headers"
+	CategoryTDs             = "Total difficulties"
+	CategoryCanonicalHashes = "Canonical hashes"
+	CategoryBlockNumbers    = "Block number index"
+	CategoryBodies          = "Bodies"
+	CategoryReceipts        = "Receipts"
+	CategoryTxLookups       = "Transaction lookups"
+	CategoryBloomBits       = "Bloombits"
+	CategoryBloomBitsIndex  = "Bloombits index"
+	CategoryAddressTxs      = "Address transactions"
+	CategoryAddressTxsIndex = "Address transactions index"
+	CategoryAccountTrie     = "Account trie"
+	CategoryStorageTries    = "Storage tries"
+	CategoryCodes           = "Contract codes"
+	CategoryEpochTrie       = "DPoS epoch trie"
+	CategoryDelegateTrie    = "DPoS delegate trie"
+	CategoryVoteTrie        = "DPoS vote trie"
+	CategoryCandidateTrie   = "DPoS candidate trie"
+	CategoryMintCntTrie     = "DPoS mint count trie"
+	CategoryStaleState      = "Unreferenced state"
+	CategorySnapAccounts    = "Snapshot accounts"
+	CategorySnapStorage     = "Snapshot storage"
+	CategoryPreimages       = "Preimages"
+	CategoryChainConfigs    = "Chain configs"
+	CategoryCHT             = "Canonical hash tries"
+	CategoryBloomTrie       = "Bloom tries"
+	CategoryClique          = "Clique snapshots"
+	CategoryLegacyReceipts  = "Legacy receipts"
+	CategoryMetadata        = "Metadata"
+	CategoryUnknown         = "Unknown"
+
+	// Tables of the ancient store, reported if the database has one.
+	CategoryAncientHashes   = "Ancient hashes"
+	CategoryAncientHeaders  = "Ancient headers"
+	CategoryAncientBodies   = "Ancient bodies"
+	CategoryAncientReceipts = "Ancient receipts"
+	CategoryAncientDiffs    = "Ancient total difficulties"
+)
+
+// DefaultInspectBloomSize is the default size in megabytes of the filter of the
+// head state's trie nodes and codes used to attribute them when inspecting.
+const DefaultInspectBloomSize = 256
+
+// ownerBloomHashes is the number of bits set in the owner filter for every node.
+const ownerBloomHashes = 4
+
+var inspectCategories = []string{
+	CategoryHeaders, CategoryTDs, CategoryCanonicalHashes, CategoryBlockNumbers,
+	CategoryBodies, CategoryReceipts, CategoryTxLookups, CategoryBloomBits,
+	CategoryBloomBitsIndex, CategoryAddressTxs, CategoryAddressTxsIndex,
+	CategoryAccountTrie, CategoryStorageTries, CategoryCodes,
+	CategoryEpochTrie, CategoryDelegateTrie, CategoryVoteTrie, CategoryCandidateTrie,
+	CategoryMintCntTrie, CategoryStaleState, CategorySnapAccounts, CategorySnapStorage,
+	CategoryPreimages, CategoryChainConfigs, CategoryCHT, CategoryBloomTrie,
+	CategoryClique, CategoryLegacyReceipts, CategoryMetadata, CategoryUnknown,
+}
+
+// ancientCategories maps the tables of the ancient store to their categories.
+var ancientCategories = []struct {
+	kind     string
+	category string
+}{
+	{datxdb.FreezerHashTable, CategoryAncientHashes},
+	{datxdb.FreezerHeaderTable, CategoryAncientHeaders},
+	{datxdb.FreezerBodiesTable, CategoryAncientBodies},
+	{datxdb.FreezerReceiptTable, CategoryAncientReceipts},
+	{datxdb.FreezerDifficultyTable, CategoryAncientDiffs},
+}
+
+// ownerCategories are the categories of the trie nodes and codes of the head
+// state. A node shared by several tries is attributed to the first one listed.
+var ownerCategories = []string{
+	CategoryAccountTrie, CategoryStorageTries, CategoryCodes,
+	CategoryEpochTrie, CategoryDelegateTrie, CategoryVoteTrie, CategoryCandidateTrie,
+	CategoryMintCntTrie,
+}
+
+var (
+	// metadataKeys are the single entries tracking the state of the database.
+	metadataKeys = [][]byte{
+		headHeaderKey, headBlockKey, headFastKey,
+		[]byte("BlockchainVersion"),
+		[]byte("confirmed-block-head"), // see consensus/dpos
+		[]byte("_requestCostStats"),    // see les
+		snapshot.SnapshotRootKey,
+		checkpointKey,
+	}
+
+	// Prefixes of the light client tries, see light/postprocess.go.
+	chtPrefixes       = [][]byte{[]byte("cht-"), []byte("chtRoot-"), []byte("chtIndex-")}
+	bloomTriePrefixes = [][]byte{[]byte("blt-"), []byte("bltRoot-"), []byte("bltIndex-")}
+
+	// emptyCodeHash is the code hash of accounts without code.
+	emptyCodeHash = crypto.Keccak256(nil)
+)
+
+// DatabaseStat is the number and total size of the entries of a category.
+type DatabaseStat struct {
+	Category string `json:"category"`
+	Count    uint64 `json:"count"`
+	Size     uint64 `json:"size"` // Total size of the keys and values in bytes
+}
+
+// DatabaseInspection is the breakdown of the entries of a chain database.
+type DatabaseInspection struct {
+	// Head is the number of the head block, whose state trie, storage tries,
+	// codes and DPoS tries are attributed to their owners. Trie nodes and codes
+	// only reachable from other blocks are reported as unreferenced state.
+	Head uint64 `json:"head"`
+
+	// HeadState reports whether the state of the head block was available to
+	// attribute. If not, all trie nodes and codes are reported unreferenced.
+	HeadState bool `json:"headState"`
+
+	Stats   []*DatabaseStat `json:"stats"`   // Categories in display order
+	Total   DatabaseStat    `json:"total"`   // Sum of all categories
+	Unknown []hexutil.Bytes `json:"unknown"` // Sample of the keys not matching any category
+}
+
+// InspectDatabase iterates over every entry of the chain database and groups
+// them by the data they hold, the keys of trie nodes and codes being matched
+// against the tries of the head block, recorded in a bloom filter of bloomSize
+// megabytes. The tables of the ancient store, if the database has one, are
+// reported too. At most maxUnknown keys not matching any known category are
+// collected.
+func InspectDatabase(db datxdb.Database, maxUnknown int, bloomSize uint64) (*DatabaseInspection, error) {
+	result := &DatabaseInspection{
+		Unknown: []hexutil.Bytes{},
+	}
+	stats := make(map[string]*DatabaseStat)
+	for _, category := range inspectCategories {
+		stat := &DatabaseStat{Category: category}
+		result.Stats = append(result.Stats, stat)
+		stats[category] = stat
+	}
+	// Attribute the nodes of the head state to their tries
+	owners := newOwnerBloom(bloomSize)
+	hash := GetHeadBlockHash(db)
+	if head := GetBlock(db, hash, GetBlockNumber(db, hash)); head != nil {
+		result.Head = head.NumberU64()
+
+		err := collectTrieOwners(db, head.Root(), head.Header().DposContext, owners)
+		switch {
+		case err == nil:
+			result.HeadState = true
+		case isMissingNode(err):
+			log.Warn("Head state unavailable, not attributing trie nodes", "number", head.NumberU64(), "err", err)
+			owners.reset()
+		default:
+			return nil, err
+		}
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	it := db.NewIteratorWithPrefix(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+
+		category := classifyKey(key, owners)
+		if category == CategoryUnknown && len(result.Unknown) < maxUnknown {
+			result.Unknown = append(result.Unknown, common.CopyBytes(key))
+		}
+		size := uint64(len(key) + len(it.Value()))
+
+		stat := stats[category]
+		stat.Count++
+		stat.Size += size
+		result.Total.Count++
+		result.Total.Size += size
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "entries", result.Total.Count, "size", common.StorageSize(result.Total.Size), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	// Add the tables of the ancient store, every block having one item in each
+	if ancients, ok := db.(datxdb.AncientReader); ok {
+		frozen, err := ancients.Ancients()
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range ancientCategories {
+			size, err := ancients.AncientSize(table.kind)
+			if err != nil {
+				return nil, err
+			}
+			result.Stats = append(result.Stats, &DatabaseStat{Category: table.category, Count: frozen, Size: size})
+			result.Total.Count += frozen
+			result.Total.Size += size
+		}
+	}
+	result.Total.Category = "Total"
+	return result, nil
+}
+
+// classifyKey returns the category of a database key, owners holding the hashes
+// of the head state's trie nodes and codes by category.
+func classifyKey(key []byte, owners *ownerBloom) string {
+	for _, meta := range metadataKeys {
+		if bytes.Equal(key, meta) {
+			return CategoryMetadata
+		}
+	}
+	// Trie nodes and codes are keyed by their bare hash
+	if len(key) == common.HashLength {
+		return owners.owner(key)
+	}
+	switch {
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength:
+		return CategoryHeaders
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength+len(tdSuffix) && bytes.HasSuffix(key, tdSuffix):
+		return CategoryTDs
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+len(numSuffix) && bytes.HasSuffix(key, numSuffix):
+		return CategoryCanonicalHashes
+	case bytes.HasPrefix(key, blockHashPrefix) && len(key) == len(blockHashPrefix)+common.HashLength:
+		return CategoryBlockNumbers
+	case bytes.HasPrefix(key, bodyPrefix) && len(key) == len(bodyPrefix)+8+common.HashLength:
+		return CategoryBodies
+	case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == len(blockReceiptsPrefix)+8+common.HashLength:
+		return CategoryReceipts
+	case bytes.HasPrefix(key, lookupPrefix) && len(key) == len(lookupPrefix)+common.HashLength:
+		return CategoryTxLookups
+	case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+2+8+common.HashLength:
+		return CategoryBloomBits
+	case bytes.HasPrefix(key, BloomBitsIndexPrefix):
+		return CategoryBloomBitsIndex
+	case bytes.HasPrefix(key, addressTxsPrefix) && len(key) == len(addressTxsPrefix)+common.AddressLength+8+common.HashLength:
+		return CategoryAddressTxs
+	case bytes.HasPrefix(key, AddressTxsIndexPrefix):
+		return CategoryAddressTxsIndex
+	case bytes.HasPrefix(key, snapshot.SnapshotAccountPrefix) && len(key) == len(snapshot.SnapshotAccountPrefix)+common.HashLength:
+		return CategorySnapAccounts
+	case bytes.HasPrefix(key, snapshot.SnapshotStoragePrefix) && len(key) == len(snapshot.SnapshotStoragePrefix)+2*common.HashLength:
+		return CategorySnapStorage
+	case bytes.HasPrefix(key, []byte(preimagePrefix)) && len(key) == len(preimagePrefix)+common.HashLength:
+		return CategoryPreimages
+	case bytes.HasPrefix(key, configPrefix) && len(key) == len(configPrefix)+common.HashLength:
+		return CategoryChainConfigs
+	case hasAnyPrefix(key, chtPrefixes):
+		return CategoryCHT
+	case hasAnyPrefix(key, bloomTriePrefixes):
+		return CategoryBloomTrie
+	case bytes.HasPrefix(key, []byte("clique-")) && len(key) == len("clique-")+common.HashLength:
+		return CategoryClique
+	case bytes.HasPrefix(key, oldReceiptsPrefix) && len(key) == len(oldReceiptsPrefix)+common.HashLength:
+		return CategoryLegacyReceipts
+	}
+	return CategoryUnknown
+}
+
+// hasAnyPrefix reports whether key starts with any of the prefixes.
+func hasAnyPrefix(key []byte, prefixes [][]byte) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMissingNode reports whether err is caused by a trie node missing from the
+// database.
+func isMissingNode(err error) bool {
+	_, ok := err.(*trie.MissingNodeError)
+	return ok
+}
+
+// collectTrieOwners walks the state trie rooted at root, the storage tries and
+// codes of its accounts and the DPoS tries, recording the category of every
+// stored node and code. Only the roots of the storage tries are kept in memory,
+// to walk each of them once.
+func collectTrieOwners(db datxdb.Database, root common.Hash, dpos *types.DposContextProto, owners *ownerBloom) error {
+	storages := make(map[common.Hash]bool)
+
+	err := collectTrieNodes(db, root, CategoryAccountTrie, owners, func(blob []byte) error {
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			return err
+		}
+		if !bytes.Equal(account.CodeHash, emptyCodeHash) {
+			owners.add(account.CodeHash, CategoryCodes)
+		}
+		// Accounts with identical storage share their trie
+		if storages[account.Root] {
+			return nil
+		}
+		storages[account.Root] = true
+		return collectTrieNodes(db, account.Root, CategoryStorageTries, owners, nil)
+	})
+	if err != nil || dpos == nil {
+		return err
+	}
+	tries := []struct {
+		root     common.Hash
+		category string
+	}{
+		{dpos.EpochHash, CategoryEpochTrie},
+		{dpos.DelegateHash, CategoryDelegateTrie},
+		{dpos.VoteHash, CategoryVoteTrie},
+		{dpos.CandidateHash, CategoryCandidateTrie},
+		{dpos.MintCntHash, CategoryMintCntTrie},
+	}
+	for _, t := range tries {
+		if err := collectTrieNodes(db, t.root, t.category, owners, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectTrieNodes records the category of every stored node of a trie, calling
+// onLeaf with the value of every leaf if set.
+func collectTrieNodes(db datxdb.Database, root common.Hash, category string, owners *ownerBloom, onLeaf func(blob []byte) error) error {
+	t, err := trie.New(root, db)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for it.Next(true) {
+		// Nodes embedded in their parent have no hash and aren't stored
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			owners.add(hash[:], category)
+		}
+		if it.Leaf() && onLeaf != nil {
+			if err := onLeaf(it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+// ownerBloom is a bloom filter of the trie nodes and codes of the head state,
+// keyed by their hash and category, so that a single filter of a fixed size
+// holds all the tries however large the state is. The keys being Keccak256
+// hashes, the bits are taken from the hash mixed with the category instead of
+// hashing it again.
+//
+// False positives report some unreferenced nodes as part of the head state,
+// which only skews the breakdown, never the total.
+type ownerBloom struct {
+	bits []byte
+}
+
+// newOwnerBloom creates an owner filter of the given size in megabytes.
+func newOwnerBloom(size uint64) *ownerBloom {
+	if size == 0 {
+		size = 1
+	}
+	return &ownerBloom{bits: make([]byte, size*1024*1024)}
+}
+
+// reset clears the filter, dropping every node added.
+func (b *ownerBloom) reset() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+}
+
+// add records a hash key as belonging to a category of ownerCategories.
+func (b *ownerBloom) add(key []byte, category string) {
+	salt := ownerSalt(category)
+	for i := 0; i < ownerBloomHashes; i++ {
+		bit := b.bit(key, salt, i)
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// owner returns the category a hash key may have been added with, or the stale
+// state category if none.
+func (b *ownerBloom) owner(key []byte) string {
+	for _, category := range ownerCategories {
+		if b.contains(key, ownerSalt(category)) {
+			return category
+		}
+	}
+	return CategoryStaleState
+}
+
+// contains reports whether a hash key may have been added with the given salt.
+func (b *ownerBloom) contains(key []byte, salt uint64) bool {
+	for i := 0; i < ownerBloomHashes; i++ {
+		bit := b.bit(key, salt, i)
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bit returns the index of the n-th bit of the filter belonging to a hash key
+// of the category of the given salt.
+func (b *ownerBloom) bit(key []byte, salt uint64, n int) uint64 {
+	return (binary.BigEndian.Uint64(key[n*8:]) ^ salt) % (uint64(len(b.bits)) * 8)
+}
+
+// ownerSalt returns the value the bits of the keys of a category are mixed with.
+func ownerSalt(category string) uint64 {
+	for i, owner := range ownerCategories {
+		if owner == category {
+			return uint64(i+1) * 0x9e3779b97f4a7c15
+		}
+	}
+	panic("unknown owner category " + category)
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+// Tests that inspecting a database attributes every entry of an archive DPoS
+// chain to its category, and reports the keys it doesn't know.
+func TestInspectDatabase(t *testing.T) {
+	var (
+		gspec, keys = newDposTestGenesis(3, 3600)
+		db, _       = datxdb.NewMemDatabase()
+		genesis     = gspec.MustCommit(db)
+		sender      = crypto.PubkeyToAddress(keys[0].PublicKey)
+	)
+	blocks, _ := GenerateDposChain(gspec.Config, genesis, db, 8, keys, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(types.Binary, gen.TxNonce(sender), common.Address{byte(i + 1)}, big.NewInt(1), new(big.Int).SetUint64(params.TxGas), nil, nil), types.HomesteadSigner{}, keys[0])
+		gen.AddTx(tx)
+	})
+	blockchain, _ := NewBlockChain(db, gspec.Config, dpos.New(gspec.Config.Dpos, db), vm.Config{})
+	if i, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	blockchain.Stop()
+
+	unknown := []byte("unknown-key")
+	db.Put(unknown, []byte{0x01})
+
+	result, err := InspectDatabase(db, 16, 1)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	if result.Head != 8 || !result.HeadState {
+		t.Fatalf("head mismatch: have %d (state %v), want 8 (state true)", result.Head, result.HeadState)
+	}
+	counts := make(map[string]uint64)
+	var total uint64
+	for _, stat := range result.Stats {
+		counts[stat.Category] = stat.Count
+		total += stat.Count
+	}
+	if total != result.Total.Count || total != uint64(len(db.Keys())) {
+		t.Fatalf("total mismatch: categories %d, reported %d, database %d", total, result.Total.Count, len(db.Keys()))
+	}
+	for category, want := range map[string]uint64{
+		CategoryHeaders:         9,
+		CategoryTDs:             9,
+		CategoryCanonicalHashes: 9,
+		CategoryBlockNumbers:    9,
+		CategoryBodies:          9,
+		CategoryReceipts:        9,
+		CategoryTxLookups:       8,
+		CategoryChainConfigs:    1,
+		CategoryUnknown:         1,
+	} {
+		if counts[category] != want {
+			t.Errorf("%s count mismatch: have %d, want %d", category, counts[category], want)
+		}
+	}
+	for _, category := range []string{CategoryAccountTrie, CategoryEpochTrie, CategoryDelegateTrie, CategoryCandidateTrie, CategoryMintCntTrie, CategoryStaleState, CategoryPreimages, CategoryMetadata} {
+		if counts[category] == 0 {
+			t.Errorf("no %s reported", category)
+		}
+	}
+	if len(result.Unknown) != 1 || !bytes.Equal(result.Unknown[0], unknown) {
+		t.Errorf("unknown keys mismatch: have %x, want [%x]", result.Unknown, unknown)
+	}
+}
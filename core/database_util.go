@@ -31,6 +31,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/metrics"
 	"github.com/DATxChain-Protocol/DATx/params"
 	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
 )
 
 // DatabaseReader wraps the Get method of a backing data store.
@@ -57,12 +58,14 @@ var (
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 	lookupPrefix        = []byte("l") // lookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix     = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	addressTxsPrefix    = []byte("x") // addressTxsPrefix + address + section (uint64 big endian) + hash -> address transactions
 
 	preimagePrefix = "secure-key-"              // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("DATx-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
-	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	BloomBitsIndexPrefix  = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	AddressTxsIndexPrefix = []byte("iA") // AddressTxsIndexPrefix is the data table of a chain indexer to track its progress
 
 	// used by old db, now only used for conversion
 	oldReceiptsPrefix = []byte("receipts-")
@@ -82,6 +85,13 @@ type TxLookupEntry struct {
 	Index      uint64
 }
 
+// AddressTxEntry is the position of a transaction sent from or to an address,
+// as recorded by the address transactions index.
+type AddressTxEntry struct {
+	BlockIndex uint64
+	Index      uint64
+}
+
 // encodeBlockNumber encodes a block number as big endian uint64
 func encodeBlockNumber(number uint64) []byte {
 	enc := make([]byte, 8)
@@ -150,9 +160,21 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, datxdb.FreezerHeaderTable, hash, number)
+	}
 	return data
 }
 
+// HasHeader checks whether the block header corresponding to the hash is in the
+// database.
+func HasHeader(db datxdb.Database, hash common.Hash, number uint64) bool {
+	if ok, _ := db.Has(headerKey(hash, number)); ok {
+		return true
+	}
+	return hasAncient(db, hash, number)
+}
+
 // GetHeader retrieves the block header corresponding to the hash, nil if none
 // found.
 func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header {
@@ -171,6 +193,42 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, datxdb.FreezerBodiesTable, hash, number)
+	}
+	return data
+}
+
+// HasBody checks whether the block body corresponding to the hash is in the
+// database.
+func HasBody(db datxdb.Database, hash common.Hash, number uint64) bool {
+	if ok, _ := db.Has(blockBodyKey(hash, number)); ok {
+		return true
+	}
+	return hasAncient(db, hash, number)
+}
+
+// hasAncient checks whether the ancient store of the database, if it has one,
+// holds the canonical block of the given number and hash.
+func hasAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	ancients, ok := db.(datxdb.AncientReader)
+	if !ok {
+		return false
+	}
+	if frozen, _ := ancients.Ancients(); number >= frozen {
+		return false
+	}
+	stored, _ := ancients.Ancient(datxdb.FreezerHashTable, number)
+	return bytes.Equal(stored, hash[:])
+}
+
+// getAncient retrieves an item of a block from the ancient store of the
+// database, or nil if the block isn't frozen.
+func getAncient(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if !hasAncient(db, hash, number) {
+		return nil
+	}
+	data, _ := db.(datxdb.AncientReader).Ancient(kind, number)
 	return data
 }
 
@@ -182,6 +240,14 @@ func blockBodyKey(hash common.Hash, number uint64) []byte {
 	return append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+func blockReceiptsKey(hash common.Hash, number uint64) []byte {
+	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+func tdKey(hash common.Hash, number uint64) []byte {
+	return append(append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...), tdSuffix...)
+}
+
 // GetBody retrieves the block body (transactons, uncles) corresponding to the
 // hash, nil if none found.
 func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
@@ -200,7 +266,10 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data, _ := db.Get(tdKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, datxdb.FreezerDifficultyTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -218,7 +287,16 @@ func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
 //
 // Note, due to concurrent download of header and block body the header and thus
 // canonical hash can be stored in the database but the body data not (yet).
+//
+// The DPoS tries of the block are loaded from db too, those only held by a trie
+// database in memory are loaded by GetBlockWithTries instead.
 func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
+	return GetBlockWithTries(db, db.(datxdb.Database), hash, number)
+}
+
+// GetBlockWithTries retrieves an entire block like GetBlock, loading its DPoS
+// tries from the given trie database.
+func GetBlockWithTries(db DatabaseReader, triedb trie.Database, hash common.Hash, number uint64) *types.Block {
 	// Retrieve the block header and body contents
 	header := GetHeader(db, hash, number)
 	if header == nil {
@@ -233,11 +311,11 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 	block := types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
 
 	// add dposContext to block
-	block.DposContext = getDposContextTrie(db.(datxdb.Database), header)
+	block.DposContext = getDposContextTrie(triedb, header)
 	return block
 }
 
-func getDposContextTrie(db datxdb.Database, header *types.Header) *types.DposContext {
+func getDposContextTrie(db trie.Database, header *types.Header) *types.DposContext {
 	dposContestProto := header.DposContext
 	if dposContestProto != nil {
 		dposContext, err := types.NewDposContextFromProto(db, dposContestProto)
@@ -252,7 +330,10 @@ func getDposContextTrie(db datxdb.Database, header *types.Header) *types.DposCon
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data, _ := db.Get(blockReceiptsKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, datxdb.FreezerReceiptTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -358,6 +439,21 @@ func GetBloomBits(db DatabaseReader, bit uint, section uint64, head common.Hash)
 	return db.Get(key)
 }
 
+// GetAddressTxs retrieves the positions of the transactions of an address in the
+// given section, nil if the address has none there.
+func GetAddressTxs(db DatabaseReader, address common.Address, section uint64, head common.Hash) []AddressTxEntry {
+	data, _ := db.Get(addressTxsKey(address, section, head))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []AddressTxEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid address transactions RLP", "address", address, "section", section, "err", err)
+		return nil
+	}
+	return entries
+}
+
 // WriteCanonicalHash stores the canonical hash for the given block number.
 func WriteCanonicalHash(db datxdb.Putter, hash common.Hash, number uint64) error {
 	key := append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...)
@@ -510,6 +606,25 @@ func WriteBloomBits(db datxdb.Putter, bit uint, section uint64, head common.Hash
 	}
 }
 
+// WriteAddressTxs writes the positions of the transactions of an address in the
+// given section.
+func WriteAddressTxs(db datxdb.Putter, address common.Address, section uint64, head common.Hash, entries []AddressTxEntry) {
+	data, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		log.Crit("Failed to RLP encode address transactions", "err", err)
+	}
+	if err := db.Put(addressTxsKey(address, section, head), data); err != nil {
+		log.Crit("Failed to store address transactions", "err", err)
+	}
+}
+
+// addressTxsKey = addressTxsPrefix + address + section (uint64 big endian) + hash
+func addressTxsKey(address common.Address, section uint64, head common.Hash) []byte {
+	key := append(append(addressTxsPrefix, address.Bytes()...), make([]byte, 8)...)
+	binary.BigEndian.PutUint64(key[len(addressTxsPrefix)+common.AddressLength:], section)
+	return append(key, head.Bytes()...)
+}
+
 // DeleteCanonicalHash removes the number to hash canonical mapping.
 func DeleteCanonicalHash(db DatabaseDeleter, number uint64) {
 	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
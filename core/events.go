@@ -24,6 +24,10 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// TxReplacedEvent is posted when a pooled transaction is replaced by another one
+// of the same sender and nonce.
+type TxReplacedEvent struct{ Old, New *types.Transaction }
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
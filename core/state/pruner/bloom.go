@@ -0,0 +1,135 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+)
+
+// stateBloomHashes is the number of bits set in the filter for every key.
+const stateBloomHashes = 4
+
+// errBloomCorrupted is returned if a persisted state bloom cannot be loaded.
+var errBloomCorrupted = errors.New("state bloom corrupted")
+
+// stateBloom is a bloom filter of the database keys reachable from the retained
+// state roots. Trie nodes and contract codes are keyed by their Keccak256 hash,
+// so instead of hashing the keys again, the bits are taken from the keys.
+//
+// False positives only leave some garbage behind in the database, they never
+// cause live data to be deleted.
+type stateBloom struct {
+	bits []byte
+}
+
+// newStateBloom creates a state bloom filter of the given size in megabytes.
+func newStateBloom(size uint64) *stateBloom {
+	if size == 0 {
+		size = 1
+	}
+	return &stateBloom{bits: make([]byte, size*1024*1024)}
+}
+
+// add inserts a hash key into the filter.
+func (b *stateBloom) add(key []byte) {
+	for i := 0; i < stateBloomHashes; i++ {
+		bit := b.bit(key, i)
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// contains reports whether a hash key may have been inserted into the filter.
+func (b *stateBloom) contains(key []byte) bool {
+	for i := 0; i < stateBloomHashes; i++ {
+		bit := b.bit(key, i)
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bit returns the index of the n-th bit of the filter belonging to a hash key.
+func (b *stateBloom) bit(key []byte, n int) uint64 {
+	return binary.BigEndian.Uint64(key[n*8:]) % (uint64(len(b.bits)) * 8)
+}
+
+// commit writes the filter to the given file, gzip compressed. The file is only
+// replaced once it was completely written, so a crash never leaves a truncated
+// filter behind.
+func (b *stateBloom) commit(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(tmp)
+	if _, err := zw.Write(b.bits); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadStateBloom loads a filter previously written by commit.
+func loadStateBloom(path string) (*stateBloom, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if len(bits) == 0 || len(bits)%(1024*1024) != 0 {
+		return nil, errBloomCorrupted
+	}
+	return &stateBloom{bits: bits}, nil
+}
+
+// isHashKey reports whether a database key may belong to a trie node or a
+// contract code, i.e. whether it's a raw hash.
+func isHashKey(key []byte) bool {
+	return len(key) == common.HashLength
+}
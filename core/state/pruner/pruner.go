@@ -0,0 +1,384 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline pruning of the historical state of a
+// full node database.
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+const (
+	// bloomFileName is the name of the file in the node's data directory holding
+	// the state bloom while the database is being swept. Its presence means that
+	// a pruning was interrupted and must be finished before using the database.
+	bloomFileName = "statebloom.bf.gz"
+
+	// DefaultBlocks is the default number of recent blocks whose state is retained.
+	DefaultBlocks = 128
+
+	// DefaultBloomSize is the default size of the state bloom in megabytes.
+	DefaultBloomSize = 2048
+)
+
+var (
+	// errNoHead is returned if the database has no head block to prune around.
+	errNoHead = errors.New("head block missing")
+
+	// errNoState is returned if none of the retained blocks has its state in the
+	// database, in which case pruning would delete every state.
+	errNoState = errors.New("no state available for the retained blocks")
+
+	// emptyCode is the hash of the code of accounts without one.
+	emptyCode = crypto.Keccak256(nil)
+)
+
+// Pruner deletes the state trie nodes and contract codes that are not reachable
+// from the state of the most recent blocks.
+//
+// Pruning is done in two phases. The mark phase walks the state and DPoS tries
+// of the retained blocks and records every node in a bloom filter, which is then
+// persisted. The sweep phase deletes every trie node or code not in the filter.
+// If the sweep is interrupted, it's resumed from the persisted filter.
+type Pruner struct {
+	db        datxdb.Database
+	bloomPath string
+	bloomSize uint64
+}
+
+// NewPruner creates a pruner for the chain database of a node, with datadir
+// being the node's data directory and bloomSize the size of the state bloom in
+// megabytes. Larger filters leave less garbage behind.
+func NewPruner(db datxdb.Database, datadir string, bloomSize uint64) *Pruner {
+	return &Pruner{
+		db:        db,
+		bloomPath: filepath.Join(datadir, bloomFileName),
+		bloomSize: bloomSize,
+	}
+}
+
+// Prune deletes the state of every block except the genesis and the last blocks
+// ones, which always include the head. In dry run mode nothing is deleted, only
+// the space that would be reclaimed is reported. If an earlier pruning was
+// interrupted, that one is finished instead.
+func (p *Pruner) Prune(blocks uint64, dryRun bool) error {
+	if common.FileExist(p.bloomPath) {
+		log.Info("Resuming interrupted state pruning", "bloom", p.bloomPath)
+		return p.resume(dryRun)
+	}
+	bloom, err := p.mark(blocks)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		_, err := p.sweep(bloom, true)
+		return err
+	}
+	// Persist the filter before deleting anything, so an interrupted sweep can
+	// be resumed without deleting the state marked live
+	if err := bloom.commit(p.bloomPath); err != nil {
+		return err
+	}
+	return p.finish(bloom)
+}
+
+// resume finishes the sweep of an interrupted pruning.
+func (p *Pruner) resume(dryRun bool) error {
+	bloom, err := loadStateBloom(p.bloomPath)
+	if err != nil {
+		return fmt.Errorf("failed to load state bloom: %v", err)
+	}
+	if dryRun {
+		_, err := p.sweep(bloom, true)
+		return err
+	}
+	return p.finish(bloom)
+}
+
+// finish sweeps the database with a persisted filter, removes the filter and
+// compacts the database to reclaim the freed space.
+func (p *Pruner) finish(bloom *stateBloom) error {
+	deleted, err := p.sweep(bloom, false)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p.bloomPath); err != nil {
+		return err
+	}
+	if deleted > 0 {
+		start := time.Now()
+		log.Info("Compacting database to reclaim space")
+		if err := p.db.Compact(nil, nil); err != nil {
+			return err
+		}
+		log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// RecoverPruning finishes a pruning of the database that was interrupted in its
+// sweep phase. It must be called before any new state is written, which the
+// resumed sweep would delete.
+func RecoverPruning(db datxdb.Database, datadir string) error {
+	pruner := NewPruner(db, datadir, 0)
+	if !common.FileExist(pruner.bloomPath) {
+		return nil
+	}
+	log.Warn("Finishing interrupted state pruning", "bloom", pruner.bloomPath)
+	return pruner.resume(false)
+}
+
+// mark walks the state of the retained blocks and records every reachable trie
+// node and contract code in a new state bloom.
+func (p *Pruner) mark(blocks uint64) (*stateBloom, error) {
+	head := core.GetHeadBlockHash(p.db)
+	if head == (common.Hash{}) {
+		return nil, errNoHead
+	}
+	number := core.GetBlockNumber(p.db, head)
+	if core.GetHeader(p.db, head, number) == nil {
+		return nil, errNoHead
+	}
+	// Retain the last blocks, always including the head and the genesis
+	if blocks == 0 {
+		blocks = 1
+	}
+	first := uint64(1)
+	if number+1 > blocks {
+		first = number + 1 - blocks
+	}
+	numbers := []uint64{0}
+	for n := first; n <= number; n++ {
+		numbers = append(numbers, n)
+	}
+	var (
+		bloom  = newStateBloom(p.bloomSize)
+		marker = &stateMarker{db: p.db, bloom: bloom, start: time.Now(), logged: time.Now()}
+		prev   *markedTries
+		kept   int
+	)
+	for _, n := range numbers {
+		header := core.GetHeader(p.db, core.GetCanonicalHash(p.db, n), n)
+		if header == nil {
+			return nil, fmt.Errorf("missing header #%d", n)
+		}
+		tries, err := openTries(p.db, header)
+		if err != nil {
+			// Non-archive nodes only flush the state of some blocks, skip the others
+			log.Debug("Skipping block without state", "number", n, "hash", header.Hash(), "err", err)
+			continue
+		}
+		if err := marker.markTries(prev, tries); err != nil {
+			return nil, fmt.Errorf("failed to mark state of block #%d: %v", n, err)
+		}
+		if n > 0 {
+			kept++
+		}
+		prev = tries
+	}
+	if kept == 0 {
+		return nil, errNoState
+	}
+	log.Info("Marked retained state", "blocks", kept, "nodes", marker.nodes, "codes", marker.codes, "elapsed", common.PrettyDuration(time.Since(marker.start)))
+	return bloom, nil
+}
+
+// sweep iterates the database and deletes every trie node or contract code not
+// contained in the filter, returning the number of deleted entries. In dry run
+// mode the entries are only counted.
+func (p *Pruner) sweep(bloom *stateBloom, dryRun bool) (int, error) {
+	var (
+		count  int
+		size   common.StorageSize
+		start  = time.Now()
+		logged = time.Now()
+		batch  = p.db.NewBatch()
+	)
+	it := p.db.NewIteratorWithPrefix(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if !isHashKey(key) || bloom.contains(key) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(it.Value()))
+
+		if !dryRun {
+			if err := batch.Delete(common.CopyBytes(key)); err != nil {
+				return count, err
+			}
+			if batch.ValueSize() >= datxdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return count, err
+				}
+				batch.Reset()
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Sweeping stale state", "entries", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return count, err
+	}
+	if dryRun {
+		log.Info("Pruning would reclaim space", "entries", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+		return count, nil
+	}
+	if err := batch.Write(); err != nil {
+		return count, err
+	}
+	log.Info("Pruned stale state", "entries", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return count, nil
+}
+
+// markedTries are the state trie and the DPoS tries of a block.
+type markedTries struct {
+	state *trie.Trie
+	dpos  []*trie.Trie
+}
+
+// openTries opens the state and DPoS tries of a block, failing if any of their
+// roots is missing from the database.
+func openTries(db trie.Database, header *types.Header) (*markedTries, error) {
+	tries := new(markedTries)
+
+	var err error
+	if tries.state, err = trie.New(header.Root, db); err != nil {
+		return nil, err
+	}
+	if ctx := header.DposContext; ctx != nil {
+		for _, root := range []common.Hash{ctx.EpochHash, ctx.DelegateHash, ctx.VoteHash, ctx.CandidateHash, ctx.MintCntHash} {
+			t, err := trie.New(root, db)
+			if err != nil {
+				return nil, err
+			}
+			tries.dpos = append(tries.dpos, t)
+		}
+	}
+	return tries, nil
+}
+
+// stateMarker records the nodes of tries in a state bloom.
+type stateMarker struct {
+	db    trie.Database
+	bloom *stateBloom
+
+	nodes  int       // Number of trie nodes marked
+	codes  int       // Number of contract codes marked
+	start  time.Time // Time the marking started
+	logged time.Time // Time the progress was last reported
+}
+
+// markTries marks the tries of a block. The tries of the previously marked block
+// are used to only walk the nodes that changed since, all others being already
+// marked.
+func (m *stateMarker) markTries(prev, cur *markedTries) error {
+	var prevState *trie.Trie
+	if prev != nil {
+		prevState = prev.state
+	}
+	err := m.markTrie(prevState, cur.state, func(key, blob []byte) error {
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			return err
+		}
+		if !bytes.Equal(account.CodeHash, emptyCode) {
+			m.bloom.add(account.CodeHash)
+			m.codes++
+		}
+		// Walk the storage changed since the previous version of the account
+		var prevStorage *trie.Trie
+		if prevState != nil {
+			if enc, err := prevState.TryGet(key); err != nil {
+				return err
+			} else if len(enc) > 0 {
+				var prevAccount state.Account
+				if err := rlp.DecodeBytes(enc, &prevAccount); err != nil {
+					return err
+				}
+				if prevAccount.Root == account.Root {
+					return nil
+				}
+				if prevStorage, err = trie.New(prevAccount.Root, m.db); err != nil {
+					return err
+				}
+			}
+		}
+		storage, err := trie.New(account.Root, m.db)
+		if err != nil {
+			return err
+		}
+		return m.markTrie(prevStorage, storage, nil)
+	})
+	if err != nil {
+		return err
+	}
+	for i, t := range cur.dpos {
+		var prevTrie *trie.Trie
+		if prev != nil && i < len(prev.dpos) {
+			prevTrie = prev.dpos[i]
+		}
+		if err := m.markTrie(prevTrie, t, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markTrie marks the nodes of cur missing from prev, or all of them if prev is
+// nil, calling onleaf for the leaves among them.
+func (m *stateMarker) markTrie(prev, cur *trie.Trie, onleaf func(key, blob []byte) error) error {
+	it := cur.NodeIterator(nil)
+	if prev != nil {
+		it, _ = trie.NewDifferenceIterator(prev.NodeIterator(nil), it)
+	}
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			m.bloom.add(hash[:])
+			m.nodes++
+		}
+		if it.Leaf() && onleaf != nil {
+			if err := onleaf(it.LeafKey(), it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+		if time.Since(m.logged) > 8*time.Second {
+			log.Info("Marking retained state", "nodes", m.nodes, "codes", m.codes, "elapsed", common.PrettyDuration(time.Since(m.start)))
+			m.logged = time.Now()
+		}
+	}
+	return it.Error()
+}
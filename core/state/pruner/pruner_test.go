@@ -0,0 +1,211 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+// newTestChain creates a database in a temporary directory holding an archive
+// DPoS chain of the given length, with a storage contract in the genesis and
+// balance transfers in every block.
+func newTestChain(t *testing.T, length int) (string, *datxdb.LDBDatabase, []*types.Block) {
+	dir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	db, err := datxdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	var (
+		keys       = make([]*ecdsa.PrivateKey, 3)
+		validators = make([]common.Address, 3)
+		alloc      = make(core.GenesisAlloc)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("validator-%d", i))))
+		validators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[validators[i]] = core.GenesisAccount{Balance: big.NewInt(1e18)}
+	}
+	alloc[common.Address{0xc0}] = core.GenesisAccount{
+		Balance: big.NewInt(1),
+		Code:    []byte{0x60, 0x00},
+		Storage: map[common.Hash]common.Hash{{0x01}: {0x01}, {0x02}: {0x02}},
+	}
+	config := *params.DposChainConfig
+	config.Dpos = &params.DposConfig{Validators: validators, Epoch: 3600}
+
+	gspec := &core.Genesis{Config: &config, Difficulty: big.NewInt(1), Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := core.GenerateDposChain(&config, genesis, db, length, keys, func(i int, gen *core.BlockGen) {
+		sender := crypto.PubkeyToAddress(keys[0].PublicKey)
+		tx, _ := types.SignTx(types.NewTransaction(types.Binary, gen.TxNonce(sender), common.Address{byte(i + 1)}, big.NewInt(1), new(big.Int).SetUint64(params.TxGas), nil, nil), types.HomesteadSigner{}, keys[0])
+		gen.AddTx(tx)
+	})
+	chain, err := core.NewBlockChain(db, &config, dpos.New(config.Dpos, db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if i, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	chain.Stop()
+
+	return dir, db, append([]*types.Block{genesis}, blocks...)
+}
+
+// checkState walks the whole state and DPoS tries of a block, failing if any
+// node or code is missing.
+func checkState(db *datxdb.LDBDatabase, header *types.Header) error {
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		return it.Error
+	}
+	tries, err := openTries(db, header)
+	if err != nil {
+		return err
+	}
+	for _, t := range tries.dpos {
+		it := t.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countKeys returns the number of entries in the database.
+func countKeys(db datxdb.Database) int {
+	it := db.NewIteratorWithPrefix(nil, nil)
+	defer it.Release()
+
+	count := 0
+	for it.Next() {
+		count++
+	}
+	return count
+}
+
+// Tests that pruning keeps the complete state of the retained blocks and the
+// genesis, and deletes the state of all others.
+func TestPrune(t *testing.T) {
+	dir, db, blocks := newTestChain(t, 32)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	keys := countKeys(db)
+	if err := NewPruner(db, dir, 1).Prune(8, false); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if count := countKeys(db); count >= keys {
+		t.Fatalf("nothing pruned: have %d entries, had %d", count, keys)
+	}
+	for _, block := range blocks {
+		err := checkState(db, block.Header())
+		if number := block.NumberU64(); number == 0 || number > 24 {
+			if err != nil {
+				t.Errorf("block %d: retained state inaccessible: %v", number, err)
+			}
+		} else if err == nil {
+			t.Errorf("block %d: state not pruned", number)
+		}
+	}
+	if common.FileExist(filepath.Join(dir, bloomFileName)) {
+		t.Errorf("state bloom left behind")
+	}
+}
+
+// Tests that a dry run doesn't delete anything.
+func TestPruneDryRun(t *testing.T) {
+	dir, db, blocks := newTestChain(t, 16)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	keys := countKeys(db)
+	if err := NewPruner(db, dir, 1).Prune(4, true); err != nil {
+		t.Fatalf("failed to dry run pruning: %v", err)
+	}
+	if count := countKeys(db); count != keys {
+		t.Fatalf("entries deleted: have %d, had %d", count, keys)
+	}
+	if err := checkState(db, blocks[1].Header()); err != nil {
+		t.Errorf("state inaccessible after dry run: %v", err)
+	}
+	if common.FileExist(filepath.Join(dir, bloomFileName)) {
+		t.Errorf("state bloom persisted")
+	}
+}
+
+// Tests that a pruning interrupted after marking is finished on recovery, with
+// the state marked before the interruption retained.
+func TestPruneRecovery(t *testing.T) {
+	dir, db, blocks := newTestChain(t, 16)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	// Mark the state and persist the bloom, as if crashing before the sweep
+	pruner := NewPruner(db, dir, 1)
+	bloom, err := pruner.mark(4)
+	if err != nil {
+		t.Fatalf("failed to mark state: %v", err)
+	}
+	if err := bloom.commit(pruner.bloomPath); err != nil {
+		t.Fatalf("failed to persist state bloom: %v", err)
+	}
+	if err := RecoverPruning(db, dir); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	if err := checkState(db, blocks[len(blocks)-1].Header()); err != nil {
+		t.Errorf("head state inaccessible: %v", err)
+	}
+	if err := checkState(db, blocks[1].Header()); err == nil {
+		t.Errorf("stale state not pruned")
+	}
+	if common.FileExist(pruner.bloomPath) {
+		t.Errorf("state bloom left behind")
+	}
+	// Recovering without an interrupted pruning is a noop
+	if err := RecoverPruning(db, dir); err != nil {
+		t.Fatalf("failed to recover without pruning: %v", err)
+	}
+}
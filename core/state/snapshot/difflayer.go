@@ -0,0 +1,175 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+)
+
+// diffLayer is an in-memory layer of the snapshot tree, the accounts and storage
+// slots a block changed on top of the state of its parent.
+type diffLayer struct {
+	parent snapshot
+	root   common.Hash
+	stale  bool
+
+	destructs map[common.Hash]struct{}               // Accounts deleted or recreated, storage included
+	accounts  map[common.Hash][]byte                 // Accounts changed, nil if deleted
+	storage   map[common.Hash]map[common.Hash][]byte // Storage slots changed, nil if emptied
+
+	lock sync.RWMutex
+}
+
+// newDiffLayer creates a diff layer on top of parent.
+func newDiffLayer(parent snapshot, root common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	if destructs == nil {
+		destructs = make(map[common.Hash]struct{})
+	}
+	if accounts == nil {
+		accounts = make(map[common.Hash][]byte)
+	}
+	if storage == nil {
+		storage = make(map[common.Hash]map[common.Hash][]byte)
+	}
+	return &diffLayer{
+		parent:    parent,
+		root:      root,
+		destructs: destructs,
+		accounts:  accounts,
+		storage:   storage,
+	}
+}
+
+// Root returns the root hash of the state trie the layer is of.
+func (dl *diffLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent returns the layer below.
+func (dl *diffLayer) Parent() snapshot {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.parent
+}
+
+// setParent moves the layer on top of another one holding the same state, the
+// disk layer its parent was flattened into.
+func (dl *diffLayer) setParent(parent snapshot) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.parent = parent
+}
+
+// Stale reports whether the layer was flattened or dropped.
+func (dl *diffLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale marks the layer flattened or dropped, failing any further reads.
+func (dl *diffLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// Account returns the RLP encoded account of the given account hash, looking
+// it up in the layers below if this one didn't change it.
+func (dl *diffLayer) Account(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if blob, ok := dl.accounts[hash]; ok {
+		dl.lock.RUnlock()
+		return blob, nil
+	}
+	if _, ok := dl.destructs[hash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.Account(hash)
+}
+
+// Storage returns the RLP encoded value of a storage slot, looking it up in the
+// layers below if this one didn't change it.
+func (dl *diffLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if blob, ok := dl.storage[accountHash][storageHash]; ok {
+		dl.lock.RUnlock()
+		return blob, nil
+	}
+	if _, ok := dl.destructs[accountHash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.Storage(accountHash, storageHash)
+}
+
+// merge combines the layer with the newer one on top of it into a new layer of
+// the newer one's state on top of this one's parent. Neither is modified.
+func (dl *diffLayer) merge(newer *diffLayer) *diffLayer {
+	merged := newDiffLayer(dl.parent, newer.root, nil, nil, nil)
+	for hash := range dl.destructs {
+		merged.destructs[hash] = struct{}{}
+	}
+	for hash, blob := range dl.accounts {
+		merged.accounts[hash] = blob
+	}
+	for hash, slots := range dl.storage {
+		merged.storage[hash] = make(map[common.Hash][]byte, len(slots))
+		for slot, blob := range slots {
+			merged.storage[hash][slot] = blob
+		}
+	}
+	// Destructs of the newer layer wipe out the older changes of the account
+	for hash := range newer.destructs {
+		merged.destructs[hash] = struct{}{}
+		delete(merged.accounts, hash)
+		delete(merged.storage, hash)
+	}
+	for hash, blob := range newer.accounts {
+		merged.accounts[hash] = blob
+	}
+	for hash, slots := range newer.storage {
+		if merged.storage[hash] == nil {
+			merged.storage[hash] = make(map[common.Hash][]byte, len(slots))
+		}
+		for slot, blob := range slots {
+			merged.storage[hash][slot] = blob
+		}
+	}
+	return merged
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+)
+
+// diskLayer is the bottom layer of the snapshot tree, the accounts and storage
+// slots of a persisted state stored in the database.
+type diskLayer struct {
+	diskdb  datxdb.Database
+	root    common.Hash
+	stale   bool
+	pending bool  // Whether the layer is still being generated
+	genErr  error // Error the generation of the layer failed with
+
+	lock sync.RWMutex
+}
+
+// Root returns the root hash of the state trie the layer is of.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent returns nil, there's no layer below the disk layer.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale reports whether the layer was replaced by a newer disk layer.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale marks the layer replaced, failing any further reads.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// generation reports whether the layer is still being generated, and the error
+// its generation failed with.
+func (dl *diskLayer) generation() (bool, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.pending, dl.genErr
+}
+
+// generated marks the generation of the layer over, failed if err is set.
+func (dl *diskLayer) generated(err error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.pending, dl.genErr = false, err
+}
+
+// Account returns the RLP encoded account of the given account hash.
+func (dl *diskLayer) Account(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if dl.pending || dl.genErr != nil {
+		return nil, ErrNotConstructed
+	}
+	blob, _ := dl.diskdb.Get(accountKey(hash))
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	return blob, nil
+}
+
+// Storage returns the RLP encoded value of a storage slot.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if dl.pending || dl.genErr != nil {
+		return nil, ErrNotConstructed
+	}
+	blob, _ := dl.diskdb.Get(storageKey(accountHash, storageHash))
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	return blob, nil
+}
+
+// apply writes the changes of a diff layer on top of this one to the database,
+// returning the disk layer of the diff's state.
+func (dl *diskLayer) apply(diff *diffLayer) (*diskLayer, error) {
+	batch := dl.diskdb.NewBatch()
+
+	// Wipe the accounts destructed, storage included, before writing anything
+	for hash := range diff.destructs {
+		if err := batch.Delete(accountKey(hash)); err != nil {
+			return nil, err
+		}
+		if err := deleteStorage(dl.diskdb, batch, hash); err != nil {
+			return nil, err
+		}
+	}
+	for hash, blob := range diff.accounts {
+		var err error
+		if len(blob) == 0 {
+			err = batch.Delete(accountKey(hash))
+		} else {
+			err = batch.Put(accountKey(hash), blob)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for accountHash, slots := range diff.storage {
+		for storageHash, blob := range slots {
+			var err error
+			if len(blob) == 0 {
+				err = batch.Delete(storageKey(accountHash, storageHash))
+			} else {
+				err = batch.Put(storageKey(accountHash, storageHash), blob)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := batch.Put(SnapshotRootKey, diff.root[:]); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	return &diskLayer{diskdb: dl.diskdb, root: diff.root}, nil
+}
+
+// deleteStorage adds the deletion of all storage slots of an account in the
+// database to the batch.
+func deleteStorage(db datxdb.Database, batch datxdb.Batch, accountHash common.Hash) error {
+	prefix := append(append([]byte{}, SnapshotStoragePrefix...), accountHash[:]...)
+
+	it := db.NewIteratorWithPrefix(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			if err := batch.Delete(common.CopyBytes(key)); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// emptyRoot is the root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// account is the RLP layout of the accounts in the state trie, see state.Account.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// generate replaces the snapshot in the database with the accounts and storage
+// slots of the state trie of the given root, until aborted.
+func generate(diskdb datxdb.Database, triedb trie.Database, root common.Hash, abort <-chan struct{}) error {
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	log.Info("Rebuilding state snapshot", "root", root)
+
+	// Invalidate the old snapshot first, so it's not used if we crash midway
+	if err := diskdb.Delete(SnapshotRootKey); err != nil {
+		return err
+	}
+	batch := diskdb.NewBatch()
+	if err := wipe(diskdb, batch); err != nil {
+		return err
+	}
+	var (
+		accounts, slots int
+		start           = time.Now()
+		logged          = time.Now()
+	)
+	flush := func() error {
+		if batch.ValueSize() < datxdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Rebuilding state snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return nil
+	}
+	aborted := func() bool {
+		select {
+		case <-abort:
+			log.Info("Aborted state snapshot rebuild", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			return true
+		default:
+			return false
+		}
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		if aborted() {
+			return errAborted
+		}
+		accountHash := common.BytesToHash(accIt.Key)
+		if err := batch.Put(accountKey(accountHash), common.CopyBytes(accIt.Value)); err != nil {
+			return err
+		}
+		accounts++
+
+		var acc account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			return fmt.Errorf("invalid account %x: %v", accIt.Key, err)
+		}
+		if acc.Root != emptyRoot && acc.Root != (common.Hash{}) {
+			storageTrie, err := trie.New(acc.Root, triedb)
+			if err != nil {
+				return err
+			}
+			storageIt := trie.NewIterator(storageTrie.NodeIterator(nil))
+			for storageIt.Next() {
+				if err := batch.Put(storageKey(accountHash, common.BytesToHash(storageIt.Key)), common.CopyBytes(storageIt.Value)); err != nil {
+					return err
+				}
+				slots++
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if storageIt.Err != nil {
+				return storageIt.Err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if accIt.Err != nil {
+		return accIt.Err
+	}
+	if aborted() {
+		return errAborted
+	}
+	if err := batch.Put(SnapshotRootKey, root[:]); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Rebuilt state snapshot", "root", root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// wipe adds the deletion of all snapshot accounts and storage slots in the
+// database to the batch, flushing it as it grows.
+func wipe(db datxdb.Database, batch datxdb.Batch) error {
+	for _, prefix := range []struct {
+		prefix []byte
+		length int
+	}{
+		{SnapshotAccountPrefix, len(SnapshotAccountPrefix) + common.HashLength},
+		{SnapshotStoragePrefix, len(SnapshotStoragePrefix) + 2*common.HashLength},
+	} {
+		it := db.NewIteratorWithPrefix(prefix.prefix, nil)
+		for it.Next() {
+			// Trie nodes are 32 bytes long and may share the prefix
+			if key := it.Key(); len(key) == prefix.length {
+				batch.Delete(common.CopyBytes(key))
+			}
+			if batch.ValueSize() >= datxdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks that the snapshot persisted in the database is of the state of
+// the given root and holds exactly the accounts and storage slots of its tries.
+func Verify(diskdb datxdb.Database, triedb trie.Database, root common.Hash) error {
+	if stored := ReadRoot(diskdb); stored != root {
+		return fmt.Errorf("snapshot of state %x, not %x", stored, root)
+	}
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		slots int
+		start = time.Now()
+	)
+	accIt := diskdb.NewIteratorWithPrefix(SnapshotAccountPrefix, nil)
+	defer accIt.Release()
+
+	accounts, err := verifyRange("account", trie.NewIterator(accTrie.NodeIterator(nil)), accIt, SnapshotAccountPrefix, func(key, value []byte) error {
+		var acc account
+		if err := rlp.DecodeBytes(value, &acc); err != nil {
+			return fmt.Errorf("invalid account %x: %v", key, err)
+		}
+		storageTrie, err := trie.New(acc.Root, triedb)
+		if err != nil {
+			return err
+		}
+		prefix := append(append([]byte{}, SnapshotStoragePrefix...), key...)
+		storageIt := diskdb.NewIteratorWithPrefix(prefix, nil)
+		defer storageIt.Release()
+
+		count, err := verifyRange(fmt.Sprintf("account %x slot", key), trie.NewIterator(storageTrie.NodeIterator(nil)), storageIt, prefix, nil)
+		slots += count
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// Every slot was matched to the trie of its account, so any more belong to
+	// accounts which don't exist
+	it := diskdb.NewIteratorWithPrefix(SnapshotStoragePrefix, nil)
+	defer it.Release()
+
+	stored := 0
+	for it.Next() {
+		if len(it.Key()) == len(SnapshotStoragePrefix)+2*common.HashLength {
+			stored++
+		}
+	}
+	if stored != slots {
+		return fmt.Errorf("%d storage slots of missing accounts in snapshot", stored-slots)
+	}
+	log.Info("Verified state snapshot", "root", root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyRange walks the leaves of a trie alongside the snapshot entries with the
+// given prefix, both sorted by key hash, checking that they match one to one.
+// The callback is invoked for every matched entry. It returns the number of
+// entries matched.
+func verifyRange(kind string, trieIt *trie.Iterator, flatIt datxdb.Iterator, prefix []byte, onMatch func(key, value []byte) error) (int, error) {
+	next := func() bool {
+		for flatIt.Next() {
+			// Trie nodes are 32 bytes long and may share the prefix
+			if len(flatIt.Key()) == len(prefix)+common.HashLength {
+				return true
+			}
+		}
+		return false
+	}
+	var (
+		trieOk  = trieIt.Next()
+		flatOk  = next()
+		matched int
+	)
+	for trieOk || flatOk {
+		var flatKey []byte
+		if flatOk {
+			flatKey = flatIt.Key()[len(prefix):]
+		}
+		switch {
+		case !flatOk || (trieOk && bytes.Compare(trieIt.Key, flatKey) < 0):
+			return matched, fmt.Errorf("%s %x missing from snapshot", kind, trieIt.Key)
+		case !trieOk || bytes.Compare(trieIt.Key, flatKey) > 0:
+			return matched, fmt.Errorf("%s %x in snapshot but not in trie", kind, flatKey)
+		case !bytes.Equal(trieIt.Value, flatIt.Value()):
+			return matched, fmt.Errorf("%s %x mismatch: snapshot %x, trie %x", kind, flatKey, flatIt.Value(), trieIt.Value)
+		}
+		if onMatch != nil {
+			if err := onMatch(common.CopyBytes(trieIt.Key), common.CopyBytes(trieIt.Value)); err != nil {
+				return matched, err
+			}
+		}
+		matched++
+		trieOk, flatOk = trieIt.Next(), next()
+	}
+	if trieIt.Err != nil {
+		return matched, trieIt.Err
+	}
+	return matched, flatIt.Error()
+}
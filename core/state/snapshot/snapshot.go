@@ -0,0 +1,343 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat snapshot of the state, serving account and
+// storage reads without walking the tries.
+//
+// The snapshot of a state is a tree of layers. The disk layer keeps the accounts
+// and storage slots of a persisted state keyed by their hashes in the database.
+// Every block on top of it adds an in-memory diff layer with the accounts and
+// slots it changed. Diff layers deep enough to be safe from reorganisations are
+// flattened into the disk layer.
+//
+// A missing disk layer is generated from the state trie in the background.
+// Until it's done, reads through it fail with ErrNotConstructed and are served
+// from the tries instead, while the diff layers of new blocks pile up on top.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+var (
+	// SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotAccountPrefix = []byte("a")
+
+	// SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+	SnapshotStoragePrefix = []byte("o")
+
+	// SnapshotRootKey tracks the state root of the disk layer.
+	SnapshotRootKey = []byte("SnapshotRoot")
+)
+
+var (
+	// ErrSnapshotStale is returned from reads of a layer which was flattened
+	// into the disk layer or dropped. The state has to be read from the trie.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotConstructed is returned from reads of a disk layer which is still
+	// being generated, or failed to. The state has to be read from the trie.
+	ErrNotConstructed = errors.New("snapshot not constructed")
+
+	// errSnapshotCycle is returned if a layer is added on top of itself.
+	errSnapshotCycle = errors.New("snapshot cycle")
+
+	// errAborted is returned from generating a disk layer which was aborted.
+	errAborted = errors.New("snapshot generation aborted")
+)
+
+// Snapshot is the flat view of the accounts and storage slots of a state.
+type Snapshot interface {
+	// Root returns the root hash of the state trie the snapshot is of.
+	Root() common.Hash
+
+	// Account returns the RLP encoded account of the given account hash, as
+	// stored in the state trie, or nil if there is no such account.
+	Account(hash common.Hash) ([]byte, error)
+
+	// Storage returns the RLP encoded value of a storage slot, as stored in the
+	// storage trie, or nil if the slot is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is a layer of the snapshot tree.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the layer below, nil for the disk layer.
+	Parent() snapshot
+
+	// Stale reports whether the layer was flattened or dropped.
+	Stale() bool
+
+	// markStale marks the layer stale, failing any further reads.
+	markStale()
+}
+
+// Tree is the tree of snapshot layers, a disk layer at the bottom with diff
+// layers of the blocks on top of it, keyed by their state roots.
+type Tree struct {
+	diskdb datxdb.Database
+	triedb trie.Database
+	layers map[common.Hash]snapshot
+	gen    *generation // Generation of the disk layer, nil if never started
+
+	lock sync.RWMutex
+}
+
+// generation is the disk layer being generated in the background.
+type generation struct {
+	abort chan struct{} // Closed to abort the generation
+	done  chan struct{} // Closed once the generation is over, done or not
+}
+
+// New opens the snapshot persisted in the database. If it's missing or of a
+// state other than root, it is rebuilt from the state trie of root, which takes
+// a while for a large state: in the background if async is set, before
+// returning otherwise.
+func New(diskdb datxdb.Database, triedb trie.Database, root common.Hash, async bool) (*Tree, error) {
+	t := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: make(map[common.Hash]snapshot),
+	}
+	if stored := ReadRoot(diskdb); stored == root {
+		t.layers[root] = &diskLayer{diskdb: diskdb, root: root}
+		log.Info("Loaded state snapshot", "root", root)
+		return t, nil
+	} else if stored != (common.Hash{}) {
+		log.Warn("State snapshot of other state, rebuilding", "stored", stored, "root", root)
+	}
+	if err := t.Rebuild(root); err != nil {
+		return nil, err
+	}
+	if !async {
+		t.lock.RLock()
+		gen := t.gen
+		t.lock.RUnlock()
+
+		<-gen.done
+	}
+	return t, nil
+}
+
+// ReadRoot returns the state root of the snapshot persisted in the database,
+// the zero hash if there is none.
+func ReadRoot(db datxdb.Database) common.Hash {
+	data, _ := db.Get(SnapshotRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// Snapshot returns the snapshot of the given state root, nil if there is none.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[root]; ok {
+		return layer
+	}
+	return nil
+}
+
+// DiskRoot returns the state root of the disk layer.
+func (t *Tree) DiskRoot() common.Hash {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for root, layer := range t.layers {
+		if _, ok := layer.(*diskLayer); ok {
+			return root
+		}
+	}
+	return common.Hash{}
+}
+
+// Update adds a diff layer for the state root on top of the layer of its parent.
+// The accounts whose storage was wiped are in destructs, those changed with
+// their new values in accounts and the changed storage slots in storage, nil
+// values being deletions. The maps are owned by the snapshot afterwards.
+func (t *Tree) Update(root common.Hash, parent common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	if root == parent {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Blocks of the same state share their layer
+	if _, ok := t.layers[root]; ok {
+		return nil
+	}
+	base, ok := t.layers[parent]
+	if !ok {
+		return fmt.Errorf("parent snapshot [%x…] missing", parent[:4])
+	}
+	t.layers[root] = newDiffLayer(base, root, destructs, accounts, storage)
+	return nil
+}
+
+// Cap flattens the diff layers below the given state root into the disk layer,
+// keeping at most layers of them in memory, and drops the layers on other
+// branches which no longer descend from the disk layer.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snap, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%x…] missing", root[:4])
+	}
+	// Collect the diff layers from the root down to the disk layer
+	var diffs []*diffLayer
+	for layer := snap; ; layer = layer.Parent() {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+	}
+	if len(diffs) <= layers {
+		return nil
+	}
+	base := diffs[len(diffs)-1].parent.(*diskLayer)
+	switch pending, err := base.generation(); {
+	case err != nil:
+		// Without a disk layer to build on, drop the snapshot altogether so the
+		// state is read from the tries until it's rebuilt
+		for _, layer := range t.layers {
+			layer.markStale()
+		}
+		t.layers = make(map[common.Hash]snapshot)
+		return err
+
+	case pending:
+		// The disk layer is written by its generation, keep the diff layers in
+		// memory until it's done
+		return nil
+	}
+	flattened := diffs[layers:]
+
+	// Merge the flattened layers oldest first and write them out in one batch,
+	// along with the new root, so the disk layer is never half updated
+	merged := flattened[len(flattened)-1]
+	for i := len(flattened) - 2; i >= 0; i-- {
+		merged = merged.merge(flattened[i])
+	}
+	disk, err := base.apply(merged)
+	if err != nil {
+		return err
+	}
+	base.markStale()
+	for _, diff := range flattened {
+		diff.markStale()
+	}
+	if layers > 0 {
+		diffs[layers-1].setParent(disk)
+	}
+	// Keep only the layers still building on the new disk layer
+	remaining := map[common.Hash]snapshot{disk.root: disk}
+	for root, layer := range t.layers {
+		if descendsFrom(layer, disk) {
+			remaining[root] = layer
+		} else {
+			layer.markStale()
+		}
+	}
+	t.layers = remaining
+	return nil
+}
+
+// descendsFrom reports whether the given disk layer is at the bottom of layer.
+func descendsFrom(layer snapshot, disk *diskLayer) bool {
+	for layer.Parent() != nil {
+		layer = layer.Parent()
+	}
+	return layer == disk
+}
+
+// Rebuild drops all layers and starts regenerating the disk layer from the state
+// trie of the given root in the background, aborting any previous generation.
+// Diff layers can be added on top of the new disk layer right away.
+func (t *Tree) Rebuild(root common.Hash) error {
+	if _, err := trie.New(root, t.triedb); err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.abortGeneration()
+	for _, layer := range t.layers {
+		layer.markStale()
+	}
+	disk := &diskLayer{diskdb: t.diskdb, root: root, pending: true}
+	t.layers = map[common.Hash]snapshot{root: disk}
+
+	gen := &generation{abort: make(chan struct{}), done: make(chan struct{})}
+	t.gen = gen
+	go func() {
+		defer close(gen.done)
+
+		err := generate(t.diskdb, t.triedb, root, gen.abort)
+		if err != nil && err != errAborted {
+			log.Error("Failed to rebuild state snapshot", "root", root, "err", err)
+		}
+		disk.generated(err)
+	}()
+	return nil
+}
+
+// Close aborts the generation of the disk layer if it's still running. The
+// snapshot is rebuilt from scratch the next time it's opened then.
+func (t *Tree) Close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.abortGeneration()
+}
+
+// abortGeneration stops the generation of the disk layer if it's running, and
+// waits for it to return. It assumes the lock is held.
+func (t *Tree) abortGeneration() {
+	if t.gen == nil {
+		return
+	}
+	select {
+	case <-t.gen.done:
+	default:
+		close(t.gen.abort)
+		<-t.gen.done
+	}
+}
+
+// accountKey = SnapshotAccountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash[:]...)
+}
+
+// storageKey = SnapshotStoragePrefix + account hash + storage hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	key := append(append([]byte{}, SnapshotStoragePrefix...), accountHash[:]...)
+	return append(key, storageHash[:]...)
+}
@@ -0,0 +1,346 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// makeTestState commits a state trie of accounts with the given balances and
+// storage to the database, returning its root.
+func makeTestState(t *testing.T, db datxdb.Database, balances map[byte]int64, storage map[byte]map[byte]byte) common.Hash {
+	accTrie, _ := trie.New(common.Hash{}, db)
+	for id, balance := range balances {
+		storageTrie, _ := trie.New(common.Hash{}, db)
+		for slot, value := range storage[id] {
+			blob, _ := rlp.EncodeToBytes([]byte{value})
+			storageTrie.Update(crypto.Keccak256([]byte{slot}), blob)
+		}
+		root, err := storageTrie.CommitTo(db)
+		if err != nil {
+			t.Fatalf("failed to commit storage trie: %v", err)
+		}
+		blob, _ := rlp.EncodeToBytes(&account{Balance: big.NewInt(balance), Root: root, CodeHash: crypto.Keccak256(nil)})
+		accTrie.Update(crypto.Keccak256([]byte{id}), blob)
+	}
+	root, err := accTrie.CommitTo(db)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	return root
+}
+
+// hashOf returns the hash the test accounts and slots are keyed by.
+func hashOf(id byte) common.Hash {
+	return crypto.Keccak256Hash([]byte{id})
+}
+
+// checkBalance checks the balance of a test account in a snapshot, -1 meaning
+// the account doesn't exist.
+func checkBalance(t *testing.T, snap Snapshot, id byte, want int64) {
+	blob, err := snap.Account(hashOf(id))
+	if err != nil {
+		t.Fatalf("account %d: %v", id, err)
+	}
+	if blob == nil {
+		if want != -1 {
+			t.Errorf("account %d missing, want balance %d", id, want)
+		}
+		return
+	}
+	var acc account
+	if err := rlp.DecodeBytes(blob, &acc); err != nil {
+		t.Fatalf("account %d: %v", id, err)
+	}
+	if acc.Balance.Int64() != want {
+		t.Errorf("account %d balance mismatch: have %v, want %d", id, acc.Balance, want)
+	}
+}
+
+// checkSlot checks the value of a test storage slot in a snapshot, 0 meaning
+// the slot is empty.
+func checkSlot(t *testing.T, snap Snapshot, id, slot, want byte) {
+	blob, err := snap.Storage(hashOf(id), hashOf(slot))
+	if err != nil {
+		t.Fatalf("account %d slot %d: %v", id, slot, err)
+	}
+	var value []byte
+	if blob != nil {
+		rlp.DecodeBytes(blob, &value)
+	}
+	if want == 0 && len(value) != 0 || want != 0 && !bytes.Equal(value, []byte{want}) {
+		t.Errorf("account %d slot %d mismatch: have %x, want %x", id, slot, value, want)
+	}
+}
+
+// testAccount returns the snapshot blob of a test account with the given balance.
+func testAccount(balance int64) []byte {
+	blob, _ := rlp.EncodeToBytes(&account{Balance: big.NewInt(balance), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)})
+	return blob
+}
+
+// testSlot returns the snapshot blob of a test storage value.
+func testSlot(value byte) []byte {
+	blob, _ := rlp.EncodeToBytes([]byte{value})
+	return blob
+}
+
+// Tests that a snapshot rebuilt from the state trie serves its accounts and
+// storage, and that verification catches any difference from the trie.
+func TestGenerateVerify(t *testing.T) {
+	db, _ := datxdb.NewMemDatabase()
+	root := makeTestState(t, db, map[byte]int64{1: 100, 2: 200, 3: 300}, map[byte]map[byte]byte{
+		1: {1: 11, 2: 12},
+		3: {1: 31},
+	})
+	snaps, err := New(db, db, root, false)
+	if err != nil {
+		t.Fatalf("failed to build snapshot: %v", err)
+	}
+	if stored := ReadRoot(db); stored != root {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", stored, root)
+	}
+	snap := snaps.Snapshot(root)
+	checkBalance(t, snap, 1, 100)
+	checkBalance(t, snap, 3, 300)
+	checkBalance(t, snap, 4, -1)
+	checkSlot(t, snap, 1, 2, 12)
+	checkSlot(t, snap, 2, 1, 0)
+	checkSlot(t, snap, 3, 1, 31)
+
+	if err := Verify(db, db, root); err != nil {
+		t.Fatalf("verification of built snapshot failed: %v", err)
+	}
+	// Every kind of difference from the trie should be caught
+	tamperers := []struct {
+		name   string
+		tamper func()
+		undo   func()
+	}{
+		{"changed account", func() { db.Put(accountKey(hashOf(2)), testAccount(201)) }, func() { db.Put(accountKey(hashOf(2)), testAccount(200)) }},
+		{"missing account", func() { db.Delete(accountKey(hashOf(2))) }, func() { db.Put(accountKey(hashOf(2)), testAccount(200)) }},
+		{"extra account", func() { db.Put(accountKey(hashOf(4)), testAccount(400)) }, func() { db.Delete(accountKey(hashOf(4))) }},
+		{"changed slot", func() { db.Put(storageKey(hashOf(1), hashOf(1)), testSlot(99)) }, func() { db.Put(storageKey(hashOf(1), hashOf(1)), testSlot(11)) }},
+		{"extra slot", func() { db.Put(storageKey(hashOf(2), hashOf(1)), testSlot(21)) }, func() { db.Delete(storageKey(hashOf(2), hashOf(1))) }},
+		{"orphan slot", func() { db.Put(storageKey(hashOf(4), hashOf(1)), testSlot(41)) }, func() { db.Delete(storageKey(hashOf(4), hashOf(1))) }},
+	}
+	for _, tt := range tamperers {
+		tt.tamper()
+		if err := Verify(db, db, root); err == nil {
+			t.Errorf("%s: verification passed", tt.name)
+		}
+		tt.undo()
+	}
+	if err := Verify(db, db, root); err != nil {
+		t.Fatalf("verification of restored snapshot failed: %v", err)
+	}
+	// Reopening with the same root reuses the snapshot, another root rebuilds it
+	db.Put(accountKey(hashOf(4)), testAccount(400))
+	if snaps, _ = New(db, db, root, false); snaps.Snapshot(root) == nil {
+		t.Fatalf("reopened snapshot missing")
+	}
+	checkBalance(t, snaps.Snapshot(root), 4, 400)
+
+	other := makeTestState(t, db, map[byte]int64{4: 400}, nil)
+	if snaps, _ = New(db, db, other, false); snaps.Snapshot(other) == nil {
+		t.Fatalf("rebuilt snapshot missing")
+	}
+	checkBalance(t, snaps.Snapshot(other), 1, -1)
+	if err := Verify(db, db, other); err != nil {
+		t.Fatalf("verification of rebuilt snapshot failed: %v", err)
+	}
+}
+
+// Tests that diff layers shadow the layers below, and that capping the tree
+// flattens them into the disk layer and drops the branches left behind.
+func TestDiffLayers(t *testing.T) {
+	db, _ := datxdb.NewMemDatabase()
+	base := makeTestState(t, db, map[byte]int64{1: 100, 2: 200}, map[byte]map[byte]byte{
+		1: {1: 11},
+		2: {1: 21, 2: 22},
+	})
+	snaps, err := New(db, db, base, false)
+	if err != nil {
+		t.Fatalf("failed to build snapshot: %v", err)
+	}
+	var (
+		first  = common.Hash{0x01}
+		second = common.Hash{0x02}
+		side   = common.Hash{0x03}
+	)
+	// The first block changes account 1 and recreates account 2 with one slot
+	err = snaps.Update(first, base,
+		map[common.Hash]struct{}{hashOf(2): {}},
+		map[common.Hash][]byte{hashOf(1): testAccount(101), hashOf(2): testAccount(201)},
+		map[common.Hash]map[common.Hash][]byte{
+			hashOf(1): {hashOf(1): nil, hashOf(2): testSlot(12)},
+			hashOf(2): {hashOf(2): testSlot(23)},
+		})
+	if err != nil {
+		t.Fatalf("failed to add first layer: %v", err)
+	}
+	// The second block deletes account 1, a side block changes account 2
+	if err := snaps.Update(second, first, map[common.Hash]struct{}{hashOf(1): {}}, nil, nil); err != nil {
+		t.Fatalf("failed to add second layer: %v", err)
+	}
+	if err := snaps.Update(side, base, nil, map[common.Hash][]byte{hashOf(2): testAccount(202)}, nil); err != nil {
+		t.Fatalf("failed to add side layer: %v", err)
+	}
+	if err := snaps.Update(common.Hash{0x04}, common.Hash{0x05}, nil, nil, nil); err == nil {
+		t.Fatalf("layer added on missing parent")
+	}
+	check := func(snap Snapshot) {
+		checkBalance(t, snap, 1, -1)
+		checkSlot(t, snap, 1, 2, 0)
+		checkBalance(t, snap, 2, 201)
+		checkSlot(t, snap, 2, 1, 0)
+		checkSlot(t, snap, 2, 2, 23)
+	}
+	check(snaps.Snapshot(second))
+	checkBalance(t, snaps.Snapshot(first), 1, 101)
+	checkSlot(t, snaps.Snapshot(first), 1, 1, 0)
+	checkSlot(t, snaps.Snapshot(first), 1, 2, 12)
+	checkBalance(t, snaps.Snapshot(side), 2, 202)
+	checkSlot(t, snaps.Snapshot(side), 2, 1, 21)
+
+	// Capping to one layer flattens the first block, dropping the side block
+	oldFirst, oldSide := snaps.Snapshot(first), snaps.Snapshot(side)
+	if err := snaps.Cap(second, 1); err != nil {
+		t.Fatalf("failed to cap snapshot: %v", err)
+	}
+	if root := snaps.DiskRoot(); root != first {
+		t.Fatalf("disk layer mismatch: have %x, want %x", root, first)
+	}
+	if snaps.Snapshot(side) != nil {
+		t.Fatalf("side layer not dropped")
+	}
+	for _, snap := range []Snapshot{oldFirst, oldSide} {
+		if _, err := snap.Account(hashOf(2)); err != ErrSnapshotStale {
+			t.Errorf("read of dropped layer %x: have error %v, want %v", snap.Root(), err, ErrSnapshotStale)
+		}
+	}
+	check(snaps.Snapshot(second))
+
+	// Flattening everything persists the final state
+	if err := snaps.Cap(second, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if stored := ReadRoot(db); stored != second {
+		t.Fatalf("persisted root mismatch: have %x, want %x", stored, second)
+	}
+	check(snaps.Snapshot(second))
+	for _, key := range db.Keys() {
+		if bytes.HasPrefix(key, storageKey(hashOf(2), hashOf(1))) || bytes.HasPrefix(key, accountKey(hashOf(1))) {
+			t.Errorf("deleted entry %x still stored", key)
+		}
+	}
+}
+
+// blockingDB is a trie database whose reads of a node wait until it's released.
+type blockingDB struct {
+	datxdb.Database
+	node    common.Hash
+	release chan struct{}
+}
+
+func (db *blockingDB) Get(key []byte) ([]byte, error) {
+	if bytes.Equal(key, db.node[:]) {
+		<-db.release
+	}
+	return db.Database.Get(key)
+}
+
+// Tests that a snapshot rebuilt in the background fails reads through its disk
+// layer until it's done, while diff layers are added and kept on top of it, and
+// that an aborted rebuild is never used.
+func TestGenerateAsync(t *testing.T) {
+	db, _ := datxdb.NewMemDatabase()
+	root := makeTestState(t, db, map[byte]int64{1: 100, 2: 200}, map[byte]map[byte]byte{1: {1: 11}})
+
+	// Hold the generation on reading the storage trie of account 1
+	accTrie, _ := trie.New(root, db)
+	var acc account
+	rlp.DecodeBytes(accTrie.Get(hashOf(1).Bytes()), &acc)
+
+	triedb := &blockingDB{Database: db, node: acc.Root, release: make(chan struct{})}
+	snaps, err := New(db, triedb, root, true)
+	if err != nil {
+		t.Fatalf("failed to start rebuilding snapshot: %v", err)
+	}
+	if _, err := snaps.Snapshot(root).Account(hashOf(1)); err != ErrNotConstructed {
+		t.Fatalf("read of generating layer: have error %v, want %v", err, ErrNotConstructed)
+	}
+	first := common.Hash{0x01}
+	if err := snaps.Update(first, root, nil, map[common.Hash][]byte{hashOf(2): testAccount(201)}, nil); err != nil {
+		t.Fatalf("failed to add layer: %v", err)
+	}
+	checkBalance(t, snaps.Snapshot(first), 2, 201)
+	if _, err := snaps.Snapshot(first).Account(hashOf(1)); err != ErrNotConstructed {
+		t.Fatalf("read through generating layer: have error %v, want %v", err, ErrNotConstructed)
+	}
+	// Layers can't be flattened into the disk layer until it's generated
+	if err := snaps.Cap(first, 0); err != nil {
+		t.Fatalf("failed to cap snapshot: %v", err)
+	}
+	if disk := snaps.DiskRoot(); disk != root {
+		t.Fatalf("disk layer mismatch: have %x, want %x", disk, root)
+	}
+	close(triedb.release)
+	<-snaps.gen.done
+
+	checkBalance(t, snaps.Snapshot(first), 1, 100)
+	checkSlot(t, snaps.Snapshot(first), 1, 1, 11)
+	if err := snaps.Cap(first, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if stored := ReadRoot(db); stored != first {
+		t.Fatalf("persisted root mismatch: have %x, want %x", stored, first)
+	}
+	// A rebuild aborted halfway is neither persisted nor read from
+	other := makeTestState(t, db, map[byte]int64{3: 300}, map[byte]map[byte]byte{3: {1: 31}})
+	accTrie, _ = trie.New(other, db)
+	rlp.DecodeBytes(accTrie.Get(hashOf(3).Bytes()), &acc)
+
+	triedb = &blockingDB{Database: db, node: acc.Root, release: make(chan struct{})}
+	if snaps, err = New(db, triedb, other, true); err != nil {
+		t.Fatalf("failed to start rebuilding snapshot: %v", err)
+	}
+	gen := snaps.gen
+	closed := make(chan struct{})
+	go func() {
+		snaps.Close()
+		close(closed)
+	}()
+	<-gen.abort
+	close(triedb.release)
+	<-closed
+
+	if stored := ReadRoot(db); stored != (common.Hash{}) {
+		t.Fatalf("aborted snapshot persisted for %x", stored)
+	}
+	if _, err := snaps.Snapshot(other).Account(hashOf(3)); err != ErrNotConstructed {
+		t.Fatalf("read of aborted layer: have error %v, want %v", err, ErrNotConstructed)
+	}
+}
@@ -81,10 +81,14 @@ type stateObject struct {
 	cachedStorage Storage // Storage entry cache to avoid duplicate reads
 	dirtyStorage  Storage // Storage entries that need to be flushed to disk
 
+	// Storage values written to the trie, keyed by slot hash, for the snapshot
+	snapStorage map[common.Hash][]byte
+
 	// Cache flags.
 	// When an object is marked suicided it will be delete from the trie
 	// during the "update" phase of the state transition.
 	dirtyCode bool // true if the code was updated
+	created   bool // true if the account was created in this state, not loaded
 	suicided  bool
 	touched   bool
 	deleted   bool
@@ -175,8 +179,24 @@ func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
 	if exists {
 		return value
 	}
-	// Load from DB in case it is missing.
-	enc, err := self.getTrie(db).TryGet(key[:])
+	// Load from the snapshot in case it is missing, or from the DB if there's
+	// none. The snapshot is of the original state, so it doesn't know the slots
+	// written since nor the storage of accounts created since.
+	var (
+		enc   []byte
+		err   error
+		found bool
+	)
+	if snap := self.db.snap; snap != nil && !self.created {
+		hash := crypto.Keccak256Hash(key[:])
+		if enc, found = self.snapStorage[hash]; !found {
+			enc, err = snap.Storage(self.addrHash, hash)
+			found = err == nil
+		}
+	}
+	if !found {
+		enc, err = self.getTrie(db).TryGet(key[:])
+	}
 	if err != nil {
 		self.setError(err)
 		return common.Hash{}
@@ -219,13 +239,21 @@ func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
 	for key, value := range self.dirtyStorage {
 		delete(self.dirtyStorage, key)
+
+		var v []byte
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
-			continue
+		} else {
+			// Encoding []byte cannot fail, ok to ignore the error.
+			v, _ = rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+			self.setError(tr.TryUpdate(key[:], v))
+		}
+		if self.db.snap != nil {
+			if self.snapStorage == nil {
+				self.snapStorage = make(map[common.Hash][]byte)
+			}
+			self.snapStorage[crypto.Keccak256Hash(key[:])] = v
 		}
-		// Encoding []byte cannot fail, ok to ignore the error.
-		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
-		self.setError(tr.TryUpdate(key[:], v))
 	}
 	return tr
 }
@@ -301,8 +329,15 @@ func (self *stateObject) deepCopy(db *StateDB, onDirty func(addr common.Address)
 	stateObject.code = self.code
 	stateObject.dirtyStorage = self.dirtyStorage.Copy()
 	stateObject.cachedStorage = self.dirtyStorage.Copy()
+	if self.snapStorage != nil {
+		stateObject.snapStorage = make(map[common.Hash][]byte, len(self.snapStorage))
+		for hash, blob := range self.snapStorage {
+			stateObject.snapStorage[hash] = blob
+		}
+	}
 	stateObject.suicided = self.suicided
 	stateObject.dirtyCode = self.dirtyCode
+	stateObject.created = self.created
 	stateObject.deleted = self.deleted
 	return stateObject
 }
@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core/state/snapshot"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/crypto"
 	"github.com/DATxChain-Protocol/DATx/log"
@@ -45,6 +46,13 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// Flat snapshot serving reads, and the changes to add to it on commit.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -77,19 +85,43 @@ type StateDB struct {
 
 // Create a new state from a given trie
 func New(root common.Hash, db Database) (*StateDB, error) {
+	return NewWithSnapshots(root, db, nil)
+}
+
+// NewWithSnapshots creates a new state from a given trie, reading accounts and
+// storage from its flat snapshot if snaps has one. Committing the state adds
+// its snapshot to snaps.
+func NewWithSnapshots(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
 	tr, err := db.OpenTrie(root)
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snaps,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot looks up the snapshot of the given root, clearing the changes
+// collected for the previous one.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -111,6 +143,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 		return err
 	}
 	self.trie = tr
+	self.openSnapshot(root)
 	self.stateObjects = make(map[common.Address]*stateObject)
 	self.stateObjectsDirty = make(map[common.Address]struct{})
 	self.thash = common.Hash{}
@@ -336,6 +369,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -343,6 +380,12 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given my the address. Returns nil if not found.
@@ -355,8 +398,26 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if it has one, the database otherwise.
+	// The snapshot is of the original state, accounts written since are taken
+	// from the changes collected for it.
+	var (
+		enc   []byte
+		err   error
+		found bool
+	)
+	if self.snap != nil {
+		hash := crypto.Keccak256Hash(addr[:])
+		if enc, found = self.snapAccounts[hash]; !found {
+			if _, found = self.snapDestructs[hash]; !found {
+				enc, err = self.snap.Account(hash)
+				found = err == nil
+			}
+		}
+	}
+	if !found {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -396,6 +457,7 @@ func (self *StateDB) MarkStateObjectDirty(addr common.Address) {
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
 	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
+	newobj.created = true
 	newobj.setNonce(0) // sets the object to dirty
 	if prev == nil {
 		self.journal = append(self.journal, createObjectChange{account: &addr})
@@ -453,7 +515,9 @@ func (self *StateDB) Copy() *StateDB {
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
 		db:                self.db,
-		trie:              self.trie,
+		trie:              self.db.CopyTrie(self.trie),
+		snaps:             self.snaps,
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		refund:            new(big.Int).Set(self.refund),
@@ -473,6 +537,23 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, blob := range self.snapAccounts {
+			state.snapAccounts[hash] = blob
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, slots := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(slots))
+			for slot, blob := range slots {
+				state.snapStorage[hash][slot] = blob
+			}
+		}
+	}
 	return state
 }
 
@@ -597,11 +678,44 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 			}
 			// Update the object in the main account trie.
 			s.updateStateObject(stateObject)
+
+			// Hand the storage written to the snapshot. Accounts created anew
+			// drop the storage of any previous account of the same address.
+			if s.snap != nil {
+				if stateObject.created {
+					s.snapDestructs[stateObject.addrHash] = struct{}{}
+				}
+				s.snapStorage[stateObject.addrHash] = stateObject.snapStorage
+			}
 		}
 		delete(s.stateObjectsDirty, addr)
 	}
-	// Write trie changes.
-	root, err = s.trie.CommitTo(dbw)
+	// Write trie changes. If they go into a trie database collecting garbage,
+	// reference the storage trie and code of the accounts from the trie nodes
+	// holding them, so they are kept alive along with the account trie.
+	var onleaf trie.LeafCallback
+	if triedb, ok := dbw.(*trie.NodeDatabase); ok {
+		onleaf = func(leaf []byte, parent common.Hash) error {
+			var account Account
+			if err := rlp.DecodeBytes(leaf, &account); err != nil {
+				return nil
+			}
+			triedb.Reference(account.Root, parent)
+			triedb.Reference(common.BytesToHash(account.CodeHash), parent)
+			return nil
+		}
+	}
+	root, err = s.trie.CommitToWithCallback(dbw, onleaf)
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
+
+	// Add the changes on top of the snapshot of the original state
+	if err == nil && s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update state snapshot", "from", parent, "to", root, "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
 	return root, err
 }
@@ -31,6 +31,7 @@ import (
 	check "gopkg.in/check.v1"
 
 	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core/state/snapshot"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/datxdb"
 )
@@ -117,6 +118,32 @@ func TestIntermediateLeaks(t *testing.T) {
 	}
 }
 
+// Tests that changes finalised in a copy of a state don't leak into the state
+// it was copied from, nor into its other copies.
+func TestCopyIsolation(t *testing.T) {
+	db, _ := datxdb.NewMemDatabase()
+	orig, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.BytesToAddress([]byte{0x01})
+	orig.AddBalance(addr, big.NewInt(42))
+	orig.Finalise(true)
+
+	other := common.BytesToAddress([]byte{0x02})
+	copied := orig.Copy()
+	copied.AddBalance(addr, big.NewInt(1))
+	copied.AddBalance(other, big.NewInt(1))
+	copied.Finalise(true)
+
+	for i, state := range []*StateDB{orig, orig.Copy()} {
+		if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(42)) != 0 {
+			t.Errorf("state %d: balance mismatch: have %v, want 42", i, balance)
+		}
+		if state.Exist(other) {
+			t.Errorf("state %d: account of the copy leaked", i)
+		}
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
@@ -372,3 +399,77 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that a state reads the accounts and storage of its original state from
+// the snapshot, the ones written since from itself, and that committing it adds
+// a snapshot matching its trie.
+func TestSnapshotReads(t *testing.T) {
+	db, _ := datxdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+
+	var (
+		kept      = common.BytesToAddress([]byte{0x01})
+		destroyed = common.BytesToAddress([]byte{0x02})
+		recreated = common.BytesToAddress([]byte{0x03})
+		slot      = common.Hash{0xaa}
+		other     = common.Hash{0xbb}
+	)
+	state, _ := New(common.Hash{}, sdb)
+	for _, addr := range []common.Address{kept, destroyed, recreated} {
+		state.SetBalance(addr, big.NewInt(100))
+		state.SetState(addr, slot, common.Hash{0x01})
+		state.SetState(addr, other, common.Hash{0x02})
+	}
+	root, _ := state.CommitTo(sdb.TrieDB(), false)
+
+	snaps, err := snapshot.New(db, sdb.TrieDB(), root, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	state, _ = NewWithSnapshots(root, sdb, snaps)
+	if state.snap == nil {
+		t.Fatalf("snapshot not used")
+	}
+	if balance := state.GetBalance(kept); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 100", balance)
+	}
+	// Modify the state across transactions, the later ones reading the changes
+	state.SetState(kept, slot, common.Hash{0x03})
+	state.SetState(kept, other, common.Hash{})
+	state.Suicide(destroyed)
+	state.IntermediateRoot(false)
+
+	state.CreateAccount(recreated)
+	state.SetState(recreated, other, common.Hash{0x04})
+	state.IntermediateRoot(false)
+
+	copied := state.Copy()
+	for _, s := range []*StateDB{state, copied} {
+		if value := s.GetState(kept, slot); value != (common.Hash{0x03}) {
+			t.Errorf("written slot mismatch: have %x, want %x", value, common.Hash{0x03})
+		}
+		if value := s.GetState(kept, other); value != (common.Hash{}) {
+			t.Errorf("cleared slot mismatch: have %x, want empty", value)
+		}
+		if s.Exist(destroyed) {
+			t.Errorf("destroyed account still exists")
+		}
+		if value := s.GetState(recreated, slot); value != (common.Hash{}) {
+			t.Errorf("slot of recreated account mismatch: have %x, want empty", value)
+		}
+	}
+	root, err = state.CommitTo(sdb.TrieDB(), false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	// The new snapshot must match the new trie once flattened
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("snapshot of committed state missing")
+	}
+	if err := snaps.Cap(root, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if err := snapshot.Verify(db, sdb.TrieDB(), root); err != nil {
+		t.Fatalf("snapshot mismatch: %v", err)
+	}
+}
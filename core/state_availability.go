@@ -0,0 +1,93 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sort"
+
+	"github.com/DATxChain-Protocol/DATx/core/types"
+)
+
+// StateAvailability is the range of blocks whose state a node retains, from the
+// oldest block with state up to the chain head. An oldest block past the head
+// means no state of the kind is retained.
+type StateAvailability struct {
+	Head     uint64 // Number of the chain head
+	Accounts uint64 // Oldest block with its account trie available
+	Dpos     uint64 // Oldest block with its DPoS tries available
+}
+
+// StateAvailability reports the oldest blocks whose account trie and DPoS tries
+// are available.
+//
+// Nodes garbage collecting state retain it for the recent blocks, a fast synced
+// node only from its pivot block on. The search assumes the retained blocks to
+// form a range ending at the head, older states flushed to disk now and then
+// are not reported but can still be served.
+func (bc *BlockChain) StateAvailability() *StateAvailability {
+	head := bc.CurrentBlock().NumberU64()
+	return &StateAvailability{
+		Head:     head,
+		Accounts: bc.oldestState(head, bc.hasAccountState),
+		Dpos:     bc.oldestState(head, bc.hasDposState),
+	}
+}
+
+// oldestState searches the oldest block up to head for which has reports the
+// state available, returning head+1 if the head has none. The genesis state is
+// always kept, so it's only reported if its descendants are available too.
+//
+// The binary search is only meaningful if the availability is monotonic, every
+// block from the oldest one on having its state. That holds for the recent blocks
+// a garbage collecting node keeps and the blocks past a fast sync pivot, but not
+// for the states flushed to disk now and then before them: a search probing one
+// of those reports an oldest block lying before a gap of pruned ones.
+func (bc *BlockChain) oldestState(head uint64, has func(*types.Header) bool) uint64 {
+	available := func(number uint64) bool {
+		header := bc.GetHeaderByNumber(number)
+		return header != nil && has(header)
+	}
+	if head == 0 {
+		if available(0) {
+			return 0
+		}
+		return 1
+	}
+	oldest := 1 + uint64(sort.Search(int(head), func(i int) bool {
+		return available(uint64(i) + 1)
+	}))
+	if oldest == 1 && available(0) {
+		return 0
+	}
+	return oldest
+}
+
+// hasAccountState checks whether the account trie of a block is available.
+func (bc *BlockChain) hasAccountState(header *types.Header) bool {
+	_, err := bc.stateCache.OpenTrie(header.Root)
+	return err == nil
+}
+
+// hasDposState checks whether the DPoS tries of a block are available, blocks
+// without a DPoS context having none to miss.
+func (bc *BlockChain) hasDposState(header *types.Header) bool {
+	if header.DposContext == nil {
+		return true
+	}
+	_, err := types.NewDposContextFromProto(bc.stateCache.TrieDB(), header.DposContext)
+	return err == nil
+}
@@ -68,8 +68,17 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		misc.ApplyDAOHardFork(statedb)
 	}
 	// Set block dpos context
+	// Trace the changes made to the dpos context if requested
+	tracer := cfg.DposTracer
+	if block.DposCtx() == nil {
+		tracer = nil
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
+		var prev *types.DposContext
+		if tracer != nil {
+			prev = block.DposCtx().Copy()
+		}
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
 		receipt, _, err := ApplyTransaction(p.config, block.DposCtx(), p.bc, nil, gp, statedb, header, tx, totalUsedGas, cfg)
 		if err != nil {
@@ -77,10 +86,29 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
+
+		if tracer != nil {
+			diff, err := types.DiffDposContext(prev, block.DposCtx())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			tracer.CaptureDposTx(i, tx.Hash(), diff)
+		}
+	}
+	var prev *types.DposContext
+	if tracer != nil {
+		prev = block.DposCtx().Copy()
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts, block.DposCtx())
 
+	if tracer != nil {
+		diff, err := types.DiffDposContext(prev, block.DposCtx())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tracer.CaptureDposFinalize(diff)
+	}
 	return receipts, allLogs, totalUsedGas, nil
 }
 
@@ -93,7 +121,18 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 	if err != nil {
 		return nil, nil, err
 	}
+	receipt, result, err := ApplyMessageTransaction(config, dposContext, bc, coinbase, gp, statedb, header, msg, tx.Hash(), usedGas, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return receipt, result.UsedGas, nil
+}
 
+// ApplyMessageTransaction applies a message the way ApplyTransaction applies the
+// transaction it was derived from, identified by the given hash, including the
+// change a typed message makes to the DPoS context. Besides the receipt, it
+// returns the outcome of the EVM execution.
+func ApplyMessageTransaction(config *params.ChainConfig, dposContext *types.DposContext, bc ChainContext, coinbase *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, msg types.Message, hash common.Hash, usedGas *big.Int, cfg vm.Config) (*types.Receipt, *ExecutionResult, error) {
 	if msg.To() == nil && msg.Type() != types.Binary {
 		return nil, nil, types.ErrInvalidType
 	}
@@ -104,12 +143,12 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	result, err := ApplyMessageResult(vmenv, msg, gp)
 	if err != nil {
 		return nil, nil, err
 	}
 	if msg.Type() != types.Binary {
-		if err = applyDposMessage(dposContext, msg); err != nil {
+		if err = ApplyDposMessage(dposContext, msg); err != nil {
 			return nil, nil, err
 		}
 	}
@@ -121,26 +160,31 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 	} else {
 		root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
 	}
-	usedGas.Add(usedGas, gas)
+	usedGas.Add(usedGas, result.UsedGas)
 
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
-	receipt := types.NewReceipt(root, failed, usedGas)
-	receipt.TxHash = tx.Hash()
-	receipt.GasUsed = new(big.Int).Set(gas)
+	receipt := types.NewReceipt(root, result.Failed(), usedGas)
+	receipt.TxHash = hash
+	receipt.GasUsed = new(big.Int).Set(result.UsedGas)
 	// if the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
-		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
+		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, msg.Nonce())
+	}
+	// if the transaction reverted, keep the data it returned as the reason
+	if cfg.RecordRevertData && result.Err == vm.ErrExecutionReverted {
+		receipt.RevertData = common.CopyBytes(result.ReturnData)
 	}
 
 	// Set the receipt logs and create a bloom for filtering
-	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Logs = statedb.GetLogs(hash)
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 
-	return receipt, gas, err
+	return receipt, result, nil
 }
 
-func applyDposMessage(dposContext *types.DposContext, msg types.Message) error {
+// ApplyDposMessage applies the change a typed message makes to the DPoS context.
+func ApplyDposMessage(dposContext *types.DposContext, msg types.Message) error {
 	switch msg.Type() {
 	case types.LoginCandidate:
 		dposContext.BecomeCandidate(msg.From())
@@ -126,10 +126,34 @@ func NewStateTransition(evm *vm.EVM, msg Message, gp *GasPool) *StateTransition
 // indicates a core error meaning that the message would always fail for that particular
 // state and would never be accepted within a block.
 func ApplyMessage(evm *vm.EVM, msg Message, gp *GasPool) ([]byte, *big.Int, bool, error) {
+	result, err := ApplyMessageResult(evm, msg, gp)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return result.ReturnData, result.UsedGas, result.Failed(), nil
+}
+
+// ExecutionResult is the outcome of the EVM execution of a message.
+type ExecutionResult struct {
+	UsedGas    *big.Int // Gas used by the message, including refunds
+	Err        error    // Error the execution failed with (e.g. vm.ErrOutOfGas), nil on success
+	ReturnData []byte   // Data returned by the execution, the revert reason if reverted
+}
+
+// Failed reports whether the execution failed, which doesn't invalidate the
+// message but reverts all the changes it made besides paying for the gas.
+func (result *ExecutionResult) Failed() bool { return result.Err != nil }
+
+// ApplyMessageResult applies a message like ApplyMessage, reporting the error
+// the EVM execution failed with instead of only flagging the failure.
+func ApplyMessageResult(evm *vm.EVM, msg Message, gp *GasPool) (*ExecutionResult, error) {
 	st := NewStateTransition(evm, msg, gp)
 
-	ret, _, gasUsed, failed, err := st.TransitionDb()
-	return ret, gasUsed, failed, err
+	ret, _, gasUsed, vmerr, err := st.transitionDb()
+	if err != nil {
+		return nil, err
+	}
+	return &ExecutionResult{UsedGas: gasUsed, Err: vmerr, ReturnData: ret}, nil
 }
 
 func (st *StateTransition) from() vm.AccountRef {
@@ -210,6 +234,13 @@ func (st *StateTransition) preCheck() error {
 // including the required gas for the operation as well as the used gas. It returns an error if it
 // failed. An error indicates a consensus issue.
 func (st *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *big.Int, failed bool, err error) {
+	ret, requiredGas, usedGas, vmerr, err := st.transitionDb()
+	return ret, requiredGas, usedGas, vmerr != nil, err
+}
+
+// transitionDb implements TransitionDb, returning the error the EVM execution
+// failed with, if any, in place of the failed flag.
+func (st *StateTransition) transitionDb() (ret []byte, requiredGas, usedGas *big.Int, vmerr error, err error) {
 	if err = st.preCheck(); err != nil {
 		return
 	}
@@ -223,19 +254,17 @@ func (st *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *big
 	// TODO convert to uint64
 	intrinsicGas := IntrinsicGas(st.data, contractCreation, homestead)
 	if intrinsicGas.BitLen() > 64 {
-		return nil, nil, nil, false, vm.ErrOutOfGas
+		return nil, nil, nil, nil, vm.ErrOutOfGas
 	}
 	if err = st.useGas(intrinsicGas.Uint64()); err != nil {
-		return nil, nil, nil, false, err
+		return nil, nil, nil, nil, err
 	}
 
-	var (
-		evm = st.evm
-		// vm errors do not effect consensus and are therefor
-		// not assigned to err, except for insufficient balance
-		// error.
-		vmerr error
-	)
+	// vm errors do not effect consensus and are therefor
+	// not assigned to err, except for insufficient balance
+	// error.
+	evm := st.evm
+
 	if contractCreation {
 		ret, _, st.gas, vmerr = evm.Create(sender, st.data, st.gas, st.value)
 	} else {
@@ -249,7 +278,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *big
 		// sufficient balance to make the transfer happen. The first
 		// balance transfer may never fail.
 		if vmerr == vm.ErrInsufficientBalance {
-			return nil, nil, nil, false, vmerr
+			return nil, nil, nil, nil, vmerr
 		}
 	}
 	requiredGas = new(big.Int).Set(st.gasUsed())
@@ -257,7 +286,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *big
 	st.refundGas()
 	st.state.AddBalance(st.evm.Coinbase, new(big.Int).Mul(st.gasUsed(), st.gasPrice))
 
-	return ret, requiredGas, st.gasUsed(), vmerr != nil, err
+	return ret, requiredGas, st.gasUsed(), vmerr, err
 }
 
 func (st *StateTransition) refundGas() {
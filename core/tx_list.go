@@ -456,8 +456,10 @@ func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transact
 }
 
 // Underpriced checks whether a transaction is cheaper than (or as cheap as) the
-// lowest priced transaction currently being tracked.
-func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) bool {
+// lowest priced transaction currently being tracked. If evictable is set, only
+// the transactions it accepts are compared against, and a transaction is always
+// underpriced if there are none.
+func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet, evictable func(*types.Transaction) bool) bool {
 	// Local transactions cannot be underpriced
 	if local.containsTx(tx) {
 		return false
@@ -477,13 +479,35 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
 		return false
 	}
-	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	if evictable == nil {
+		cheapest := []*types.Transaction(*l.items)[0]
+		return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	}
+	// Look for the cheapest transaction that may be evicted, restoring the others
+	save := make(types.Transactions, 0, 64)
+	defer func() {
+		for _, tx := range save {
+			heap.Push(l.items, tx)
+		}
+	}()
+	for len(*l.items) > 0 {
+		cheapest := heap.Pop(l.items).(*types.Transaction)
+		if _, ok := (*l.all)[cheapest.Hash()]; !ok {
+			l.stales--
+			continue
+		}
+		save = append(save, cheapest)
+		if !local.containsTx(cheapest) && evictable(cheapest) {
+			return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+		}
+	}
+	return true
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
-// priced list and returns them for further removal from the entire pool.
-func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions {
+// priced list and returns them for further removal from the entire pool. If
+// evictable is set, only the transactions it accepts are discarded.
+func (l *txPricedList) Discard(count int, local *accountSet, evictable func(*types.Transaction) bool) types.Transactions {
 	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
 	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
 
@@ -494,8 +518,8 @@ func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions
 			l.stales--
 			continue
 		}
-		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
+		// Non stale transaction found, discard unless local or kept by the policy
+		if local.containsTx(tx) || (evictable != nil && !evictable(tx)) {
 			save = append(save, tx)
 		} else {
 			drop = append(drop, tx)
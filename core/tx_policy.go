@@ -0,0 +1,187 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+)
+
+// ErrSenderRateLimit is returned if a remote transaction is refused because its
+// sender went over the number of transactions the pool accepts from it.
+var ErrSenderRateLimit = errors.New("sender rate limit exceeded")
+
+// TxPolicy is the admission and eviction policy of the transaction pool, on top
+// of its pricing rules.
+type TxPolicy interface {
+	// Admit checks whether a remote transaction of the given sender may enter
+	// the pool. It's called once for every valid transaction arriving that the
+	// pool doesn't know yet.
+	Admit(from common.Address, tx *types.Transaction) error
+
+	// Reserved reports whether transactions of the given type are of a kind
+	// the pool reserves capacity for.
+	Reserved(txType types.TxType) bool
+
+	// ReservedSlots returns the number of slots of the full pool other
+	// transactions can't take from reserved ones.
+	ReservedSlots() uint64
+}
+
+// TxPolicyConfig are the configuration parameters of the default policy of the
+// transaction pool.
+type TxPolicyConfig struct {
+	ReservedSlots uint64         // Pool slots reserved for transactions of the reserved types
+	ReservedTypes []types.TxType // Transaction types the reserved slots are for
+
+	SenderLimit  uint64        // Maximum number of remote transactions accepted per sender and window, 0 for no limit
+	SenderWindow time.Duration // Time window of the sender limit
+}
+
+// DefaultTxPolicyConfig contains the default configuration of the policy of the
+// transaction pool, reserving no capacity and limiting no senders.
+var DefaultTxPolicyConfig = TxPolicyConfig{
+	ReservedTypes: []types.TxType{types.LoginCandidate, types.LogoutCandidate, types.Delegate, types.UnDelegate},
+	SenderWindow:  time.Minute,
+}
+
+// txPolicyConfigJSON is the JSON form of the policy configuration, used by the
+// admin API, with the reserved types as numbers (a byte slice would be encoded
+// as base64) and the sender window as a duration string.
+type txPolicyConfigJSON struct {
+	ReservedSlots uint64   `json:"reservedSlots"`
+	ReservedTypes []uint64 `json:"reservedTypes"`
+	SenderLimit   uint64   `json:"senderLimit"`
+	SenderWindow  string   `json:"senderWindow"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c TxPolicyConfig) MarshalJSON() ([]byte, error) {
+	reserved := make([]uint64, len(c.ReservedTypes))
+	for i, txType := range c.ReservedTypes {
+		reserved[i] = uint64(txType)
+	}
+	return json.Marshal(&txPolicyConfigJSON{
+		ReservedSlots: c.ReservedSlots,
+		ReservedTypes: reserved,
+		SenderLimit:   c.SenderLimit,
+		SenderWindow:  c.SenderWindow.String(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *TxPolicyConfig) UnmarshalJSON(input []byte) error {
+	var dec txPolicyConfigJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	window := DefaultTxPolicyConfig.SenderWindow
+	if dec.SenderWindow != "" {
+		var err error
+		if window, err = time.ParseDuration(dec.SenderWindow); err != nil {
+			return err
+		}
+	}
+	// An omitted type list reserves for the defaults, an empty one for none
+	reserved := append([]types.TxType(nil), DefaultTxPolicyConfig.ReservedTypes...)
+	if dec.ReservedTypes != nil {
+		reserved = make([]types.TxType, len(dec.ReservedTypes))
+		for i, txType := range dec.ReservedTypes {
+			if txType > math.MaxUint8 {
+				return fmt.Errorf("invalid transaction type %d", txType)
+			}
+			reserved[i] = types.TxType(txType)
+		}
+	}
+	*c = TxPolicyConfig{
+		ReservedSlots: dec.ReservedSlots,
+		ReservedTypes: reserved,
+		SenderLimit:   dec.SenderLimit,
+		SenderWindow:  window,
+	}
+	return nil
+}
+
+// DposTxPolicy is the default policy of the transaction pool. It reserves pool
+// capacity for the DPoS transaction types, so they aren't evicted first when the
+// network is congested, and limits the transactions accepted from every remote
+// sender in a fixed time window.
+type DposTxPolicy struct {
+	config   TxPolicyConfig
+	reserved map[types.TxType]bool
+
+	window time.Time                 // Start of the current rate limit window
+	counts map[common.Address]uint64 // Transactions accepted per sender in the window
+	lock   sync.Mutex
+}
+
+// NewDposTxPolicy creates a transaction pool policy of the given configuration.
+func NewDposTxPolicy(config TxPolicyConfig) *DposTxPolicy {
+	if config.SenderWindow <= 0 {
+		config.SenderWindow = DefaultTxPolicyConfig.SenderWindow
+	}
+	policy := &DposTxPolicy{
+		config:   config,
+		reserved: make(map[types.TxType]bool),
+		counts:   make(map[common.Address]uint64),
+	}
+	for _, txType := range config.ReservedTypes {
+		policy.reserved[txType] = true
+	}
+	return policy
+}
+
+// Config returns the configuration of the policy.
+func (p *DposTxPolicy) Config() TxPolicyConfig {
+	return p.config
+}
+
+// Admit checks the sender of a remote transaction against its rate limit.
+func (p *DposTxPolicy) Admit(from common.Address, tx *types.Transaction) error {
+	if p.config.SenderLimit == 0 {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if now := time.Now(); now.Sub(p.window) >= p.config.SenderWindow {
+		p.window, p.counts = now, make(map[common.Address]uint64)
+	}
+	if p.counts[from] >= p.config.SenderLimit {
+		return ErrSenderRateLimit
+	}
+	p.counts[from]++
+	return nil
+}
+
+// Reserved reports whether a transaction type is one of the reserved ones.
+func (p *DposTxPolicy) Reserved(txType types.TxType) bool {
+	return p.reserved[txType]
+}
+
+// ReservedSlots returns the number of slots reserved for transactions of the
+// reserved types.
+func (p *DposTxPolicy) ReservedSlots() uint64 {
+	return p.config.ReservedSlots
+}
@@ -123,12 +123,22 @@ type blockChain interface {
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
 
+// TxReplacement records a pooled transaction replaced by another one of the same
+// sender and nonce paying a higher gas price.
+type TxReplacement struct {
+	Tx   *types.Transaction // Transaction that was replaced
+	By   *types.Transaction // Transaction that replaced it
+	Time time.Time          // Time of the replacement
+}
+
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
 	NoLocals  bool          // Whether local transaction handling should be disabled
 	Journal   string        // Journal of local transactions to survive node restarts
 	Rejournal time.Duration // Time interval to regenerate the local transaction journal
 
+	RemoteJournal string // Journal of remote transactions to survive node restarts, disabled if empty
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -137,7 +147,10 @@ type TxPoolConfig struct {
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
-	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+	Lifetime     time.Duration // Maximum amount of time non-executable transaction are queued
+	Replacements time.Duration // Amount of time replaced transactions are remembered, 0 to disable
+
+	Policy TxPolicyConfig // Capacity reservation and sender limits of the pool
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -154,7 +167,10 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	AccountQueue: 64,
 	GlobalQueue:  1024,
 
-	Lifetime: 3 * time.Hour,
+	Lifetime:     3 * time.Hour,
+	Replacements: time.Hour,
+
+	Policy: DefaultTxPolicyConfig,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -189,6 +205,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	replaceFeed  event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -201,13 +218,18 @@ type TxPool struct {
 
 	locals  *accountSet // Set of local transaction to exepmt from evicion rules
 	journal *txJournal  // Journal of local transaction to back up to disk
+	remotes *txJournal  // Journal of remote transactions to back up to disk, if enabled
+	policy  TxPolicy    // Admission and eviction policy on top of the pricing rules
 
 	pending map[common.Address]*txList         // All currently processable transactions
 	queue   map[common.Address]*txList         // Queued but non-processable transactions
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
+	typed   map[types.TxType]int               // Number of transactions of every type
 	priced  *txPricedList                      // All transactions sorted by price
 
+	replacements map[common.Hash]*TxReplacement // Recently replaced transactions, if tracked
+
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead bool
@@ -221,16 +243,19 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 
 	// Create the transaction pool with its initial settings
 	pool := &TxPool{
-		config:      config,
-		chainconfig: chainconfig,
-		chain:       chain,
-		signer:      types.NewEIP155Signer(chainconfig.ChainId),
-		pending:     make(map[common.Address]*txList),
-		queue:       make(map[common.Address]*txList),
-		beats:       make(map[common.Address]time.Time),
-		all:         make(map[common.Hash]*types.Transaction),
-		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
-		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		config:       config,
+		chainconfig:  chainconfig,
+		chain:        chain,
+		signer:       types.NewEIP155Signer(chainconfig.ChainId),
+		pending:      make(map[common.Address]*txList),
+		queue:        make(map[common.Address]*txList),
+		beats:        make(map[common.Address]time.Time),
+		all:          make(map[common.Hash]*types.Transaction),
+		typed:        make(map[types.TxType]int),
+		replacements: make(map[common.Hash]*TxReplacement),
+		chainHeadCh:  make(chan ChainHeadEvent, chainHeadChanSize),
+		gasPrice:     new(big.Int).SetUint64(config.PriceLimit),
+		policy:       NewDposTxPolicy(config.Policy),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	pool.priced = newTxPricedList(&pool.all)
@@ -247,6 +272,18 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// Remote transactions are reloaded without the sender limits, they were
+	// already accepted once
+	if config.RemoteJournal != "" {
+		pool.remotes = newTxJournal(config.RemoteJournal)
+
+		if err := pool.remotes.load(func(tx *types.Transaction) error { return pool.addTx(tx, false) }); err != nil {
+			log.Warn("Failed to load remote transaction journal", "err", err)
+		}
+		if err := pool.remotes.rotate(pool.remote()); err != nil {
+			log.Warn("Failed to rotate remote transaction journal", "err", err)
+		}
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
@@ -324,9 +361,15 @@ func (pool *TxPool) loop() {
 					}
 				}
 			}
+			// Forget the replacements older than the tracked window
+			for hash, replacement := range pool.replacements {
+				if time.Since(replacement.Time) > pool.config.Replacements {
+					delete(pool.replacements, hash)
+				}
+			}
 			pool.mu.Unlock()
 
-		// Handle local transaction journal rotation
+		// Handle local and remote transaction journal rotation
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
@@ -335,6 +378,13 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.remotes != nil {
+				pool.mu.Lock()
+				if err := pool.remotes.rotate(pool.remote()); err != nil {
+					log.Warn("Failed to rotate remote tx journal", "err", err)
+				}
+				pool.mu.Unlock()
+			}
 		}
 	}
 }
@@ -443,6 +493,9 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remotes != nil {
+		pool.remotes.close()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -452,6 +505,12 @@ func (pool *TxPool) SubscribeTxPreEvent(ch chan<- TxPreEvent) event.Subscription
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxReplacedEvent registers a subscription of TxReplacedEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeTxReplacedEvent(ch chan<- TxReplacedEvent) event.Subscription {
+	return pool.scope.Track(pool.replaceFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -473,6 +532,24 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// Policy returns the admission and eviction policy of the transaction pool.
+func (pool *TxPool) Policy() TxPolicy {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.policy
+}
+
+// SetPolicy replaces the admission and eviction policy of the transaction pool.
+// Transactions already pooled are kept.
+func (pool *TxPool) SetPolicy(policy TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policy = policy
+	log.Info("Transaction pool policy updated", "reserved", policy.ReservedSlots())
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
@@ -551,6 +628,24 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remote retrieves all currently known remote transactions, groupped by origin
+// account and sorted by nonce. The returned transaction set is a copy and can be
+// freely modified by calling code.
+func (pool *TxPool) remote() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.pending {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], list.Flatten()...)
+		}
+	}
+	for addr, list := range pool.queue {
+		if !pool.locals.contains(addr) {
+			txs[addr] = append(txs[addr], list.Flatten()...)
+		}
+	}
+	return txs
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -617,13 +712,19 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
-		if pool.priced.Underpriced(tx, pool.locals) {
+		evictable, reserved := pool.evictable(tx)
+		if !reserved && pool.priced.Underpriced(tx, pool.locals, evictable) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
 			return false, ErrUnderpriced
 		}
 		// New transaction is better than our worse ones, make room for it
-		drop := pool.priced.Discard(len(pool.all)-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals)
+		drop := pool.priced.Discard(len(pool.all)-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals, evictable)
+		if len(drop) == 0 {
+			log.Trace("Discarding transaction of full pool", "hash", hash, "price", tx.GasPrice())
+			underpricedTxCounter.Inc(1)
+			return false, ErrUnderpriced
+		}
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
@@ -642,11 +743,15 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		// New transaction is better, replace old one
 		if old != nil {
 			delete(pool.all, old.Hash())
+			pool.typed[old.Type()]--
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+			pool.trackReplacement(old, tx)
 		}
 		pool.all[tx.Hash()] = tx
+		pool.typed[tx.Type()]++
 		pool.priced.Put(tx)
+		delete(pool.replacements, hash)
 		pool.journalTx(from, tx)
 
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
@@ -665,12 +770,47 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if local {
 		pool.locals.add(from)
 	}
+	delete(pool.replacements, hash)
 	pool.journalTx(from, tx)
 
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replace, nil
 }
 
+// evictable returns the filter of the transactions a new one may evict from the
+// full pool, nil for any, and whether it's entitled to reserved capacity, which
+// lets it evict them regardless of price.
+//
+// Transactions of the types the policy reserves capacity for evict others while
+// they're short of their reservation. Within it, other transactions can't evict
+// them.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) evictable(tx *types.Transaction) (func(*types.Transaction) bool, bool) {
+	slots := pool.policy.ReservedSlots()
+	if slots == 0 {
+		return nil, false
+	}
+	reserved := uint64(0)
+	for txType, count := range pool.typed {
+		if pool.policy.Reserved(txType) {
+			reserved += uint64(count)
+		}
+	}
+	unreserved := func(pooled *types.Transaction) bool { return !pool.policy.Reserved(pooled.Type()) }
+
+	if pool.policy.Reserved(tx.Type()) {
+		if reserved < slots {
+			return unreserved, true
+		}
+		return nil, false
+	}
+	if reserved <= slots {
+		return unreserved, false
+	}
+	return nil, false
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -689,23 +829,48 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	// Discard any previous transaction and mark this
 	if old != nil {
 		delete(pool.all, old.Hash())
+		pool.typed[old.Type()]--
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.trackReplacement(old, tx)
+	}
+	if pool.all[hash] == nil {
+		pool.typed[tx.Type()]++
 	}
 	pool.all[hash] = tx
 	pool.priced.Put(tx)
 	return old != nil, nil
 }
 
+// trackReplacement remembers that a pooled transaction was replaced by another
+// one of the same nonce, if replacements are tracked, and notifies subsystems.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) trackReplacement(old, tx *types.Transaction) {
+	if pool.config.Replacements > 0 {
+		pool.replacements[old.Hash()] = &TxReplacement{Tx: old, By: tx, Time: time.Now()}
+	}
+	go pool.replaceFeed.Send(TxReplacedEvent{Old: old, New: tx})
+}
+
 // journalTx adds the specified transaction to the local disk journal if it is
-// deemed to have been sent from a local account.
+// deemed to have been sent from a local account, or to the remote one if that
+// is enabled.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
-	// Only journal if it's enabled and the transaction is local
-	if pool.journal == nil || !pool.locals.contains(from) {
+	if pool.locals.contains(from) {
+		if pool.journal == nil {
+			return
+		}
+		if err := pool.journal.insert(tx); err != nil {
+			log.Warn("Failed to journal local transaction", "err", err)
+		}
+		return
+	}
+	if pool.remotes == nil {
 		return
 	}
-	if err := pool.journal.insert(tx); err != nil {
-		log.Warn("Failed to journal local transaction", "err", err)
+	if err := pool.remotes.insert(tx); err != nil {
+		log.Warn("Failed to journal remote transaction", "err", err)
 	}
 }
 
@@ -723,6 +888,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	if !inserted {
 		// An older transaction was better, discard this
 		delete(pool.all, hash)
+		pool.typed[tx.Type()]--
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
@@ -731,6 +897,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
 		delete(pool.all, old.Hash())
+		pool.typed[old.Type()]--
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
@@ -738,6 +905,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all[hash] == nil {
 		pool.all[hash] = tx
+		pool.typed[tx.Type()]++
 		pool.priced.Put(tx)
 	}
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
@@ -755,10 +923,10 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 }
 
 // AddRemote enqueues a single transaction into the pool if it is valid. If the
-// sender is not among the locally tracked ones, full pricing constraints will
-// apply.
+// sender is not among the locally tracked ones, full pricing constraints and
+// the sender limits of the pool policy will apply.
 func (pool *TxPool) AddRemote(tx *types.Transaction) error {
-	return pool.addTx(tx, false)
+	return pool.AddRemotes([]*types.Transaction{tx})[0]
 }
 
 // AddLocals enqueues a batch of transactions into the pool if they are valid,
@@ -770,9 +938,42 @@ func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
 
 // AddRemotes enqueues a batch of transactions into the pool if they are valid.
 // If the senders are not among the locally tracked ones, full pricing constraints
-// will apply.
+// and the sender limits of the pool policy will apply.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false)
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// Refuse the transactions the policy doesn't admit before pooling any
+	var (
+		errs     = make([]error, len(txs))
+		admitted = make([]*types.Transaction, 0, len(txs))
+		indexes  = make([]int, 0, len(txs))
+	)
+	for i, tx := range txs {
+		from, err := types.Sender(pool.signer, tx)
+		if err != nil {
+			errs[i] = ErrInvalidSender
+			continue
+		}
+		// Known transactions are relayed by many peers, only count them once,
+		// and invalid ones shouldn't take from the sender's allowance
+		if pool.all[tx.Hash()] == nil && !pool.locals.contains(from) {
+			if errs[i] = pool.validateTx(tx, false); errs[i] != nil {
+				log.Trace("Discarding invalid transaction", "hash", tx.Hash(), "err", errs[i])
+				invalidTxCounter.Inc(1)
+				continue
+			}
+			if errs[i] = pool.policy.Admit(from, tx); errs[i] != nil {
+				continue
+			}
+		}
+		admitted = append(admitted, tx)
+		indexes = append(indexes, i)
+	}
+	for i, err := range pool.addTxsLocked(admitted, false) {
+		errs[indexes[i]] = err
+	}
+	return errs
 }
 
 // addTx enqueues a single transaction into the pool if it is valid.
@@ -857,6 +1058,15 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all[hash]
 }
 
+// Replacement returns how a transaction left the pool if it was replaced within
+// the tracked window, and nil otherwise.
+func (pool *TxPool) Replacement(hash common.Hash) *TxReplacement {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.replacements[hash]
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash) {
@@ -869,6 +1079,7 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 
 	// Remove it from the list of known transactions
 	delete(pool.all, hash)
+	pool.typed[tx.Type()]--
 	pool.priced.Removed()
 
 	// Remove the transaction from the pending lists and reset the account nonce
@@ -922,6 +1133,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			hash := tx.Hash()
 			log.Trace("Removed old queued transaction", "hash", hash)
 			delete(pool.all, hash)
+			pool.typed[tx.Type()]--
 			pool.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
@@ -930,6 +1142,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
 			delete(pool.all, hash)
+			pool.typed[tx.Type()]--
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
 		}
@@ -944,6 +1157,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
 				hash := tx.Hash()
 				delete(pool.all, hash)
+				pool.typed[tx.Type()]--
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
@@ -989,6 +1203,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							// Drop the transaction from the global pools too
 							hash := tx.Hash()
 							delete(pool.all, hash)
+							pool.typed[tx.Type()]--
 							pool.priced.Removed()
 
 							// Update the account nonce to the dropped transaction
@@ -1011,6 +1226,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
 						delete(pool.all, hash)
+						pool.typed[tx.Type()]--
 						pool.priced.Removed()
 
 						// Update the account nonce to the dropped transaction
@@ -1080,6 +1296,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			hash := tx.Hash()
 			log.Trace("Removed old pending transaction", "hash", hash)
 			delete(pool.all, hash)
+			pool.typed[tx.Type()]--
 			pool.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
@@ -1088,6 +1305,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			delete(pool.all, hash)
+			pool.typed[tx.Type()]--
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
 		}
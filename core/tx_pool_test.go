@@ -18,11 +18,13 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -101,6 +103,16 @@ func validateTxPoolInternals(pool *TxPool) error {
 	if priced := pool.priced.items.Len() - pool.priced.stales; priced != pending+queued {
 		return fmt.Errorf("total priced transaction count %d != %d pending + %d queued", priced, pending, queued)
 	}
+	// Ensure the transactions are counted by type correctly
+	typed := make(map[types.TxType]int)
+	for _, tx := range pool.all {
+		typed[tx.Type()]++
+	}
+	for txType, count := range pool.typed {
+		if count != typed[txType] {
+			return fmt.Errorf("type %d transaction count mismatch: have %d, want %d", txType, count, typed[txType])
+		}
+	}
 	// Ensure the next nonce to assign is the correct one
 	for addr, txs := range pool.pending {
 		// Find the last transaction
@@ -1563,6 +1575,309 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that remote transactions are persisted into their own journal if it's
+// enabled, and are reloaded as remotes on restart.
+func TestTransactionRemoteJournaling(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	// Create the original pool to inject transaction into the journal
+	db, _ := datxdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.RemoteJournal = journal
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a local and two remote transactions, only the remotes should be journaled
+	if err := pool.AddLocal(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, big.NewInt(100000), big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pool.Stop()
+
+	// Create a new pool and ensure the remote transactions survived as remotes
+	blockchain = &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pending, queued := pool.Stats()
+	if pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Fatalf("journaled remote account marked local")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the pool policy reserves capacity for DPoS transactions, letting
+// them evict cheaper plain transactions while short of their reservation, and
+// keeping plain transactions from evicting them within it.
+func TestTransactionPoolReservedSlots(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the reservation with
+	db, _ := datxdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+	config.Policy.ReservedSlots = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 8)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	delegate := func(gasprice int64, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(types.Delegate, 0, common.Address{0x01}, new(big.Int), big.NewInt(100000), big.NewInt(gasprice), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	// Fill the pool with plain transactions
+	for i := 0; i < 4; i++ {
+		if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(2), keys[i])); err != nil {
+			t.Fatalf("failed to add plain transaction %d: %v", i, err)
+		}
+	}
+	// Ensure cheaper DPoS transactions are still accepted into the reservation
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[4])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced plain transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	reserved := []*types.Transaction{delegate(1, keys[4]), delegate(1, keys[5])}
+	for i, tx := range reserved {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add reserved transaction %d: %v", i, err)
+		}
+	}
+	// Ensure plain transactions can't evict them within the reservation
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(10), keys[6])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	for i, tx := range reserved {
+		if pool.all[tx.Hash()] == nil {
+			t.Errorf("reserved transaction %d evicted", i)
+		}
+	}
+	// Ensure DPoS transactions over the reservation are priced as any other
+	if err := pool.AddRemote(delegate(1, keys[7])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced reserved transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if len(pool.all) != 4 {
+		t.Fatalf("pooled transactions mismatched: have %d, want %d", len(pool.all), 4)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the pool policy limits the remote transactions accepted from a
+// sender, without counting the ones already known or local.
+func TestTransactionSenderLimit(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	policy := testTxPoolConfig.Policy
+	policy.SenderLimit = 2
+	policy.SenderWindow = time.Hour
+	pool.SetPolicy(NewDposTxPolicy(policy))
+
+	local, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	for _, k := range []*ecdsa.PrivateKey{key, local, other} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000000))
+	}
+	// Fill the sender's allowance, known transactions shouldn't count
+	tx0 := transaction(0, big.NewInt(100000), key)
+	if err := pool.AddRemote(tx0); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(tx0); err == nil {
+		t.Fatalf("known transaction accepted")
+	}
+	if err := pool.AddRemote(transaction(1, big.NewInt(100000), key)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(transaction(2, big.NewInt(100000), key)); err != ErrSenderRateLimit {
+		t.Fatalf("adding transaction over sender limit error mismatch: have %v, want %v", err, ErrSenderRateLimit)
+	}
+	// Ensure other and local senders are unaffected, and invalid transactions
+	// don't take from the allowance
+	for i := 0; i < 3; i++ {
+		if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(100000), other)); err != ErrInsufficientFunds {
+			t.Fatalf("adding unpayable transaction error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+		}
+	}
+	for i := uint64(0); i < 2; i++ {
+		if err := pool.AddRemote(transaction(i, big.NewInt(100000), other)); err != nil {
+			t.Fatalf("failed to add remote transaction %d of other sender: %v", i, err)
+		}
+	}
+	for i := uint64(0); i < 3; i++ {
+		if err := pool.AddLocal(transaction(i, big.NewInt(100000), local)); err != nil {
+			t.Fatalf("failed to add local transaction %d: %v", i, err)
+		}
+	}
+	// Ensure a new policy starts from a clean allowance
+	pool.SetPolicy(NewDposTxPolicy(policy))
+	if err := pool.AddRemote(transaction(2, big.NewInt(100000), key)); err != nil {
+		t.Fatalf("failed to add remote transaction after policy update: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the policy configuration decodes with defaults for the omitted
+// fields, keeping an explicitly empty list of reserved types.
+func TestTxPolicyConfigJSON(t *testing.T) {
+	var config TxPolicyConfig
+	if err := json.Unmarshal([]byte(`{"reservedSlots": 16}`), &config); err != nil {
+		t.Fatalf("failed to decode policy: %v", err)
+	}
+	if !reflect.DeepEqual(config.ReservedTypes, DefaultTxPolicyConfig.ReservedTypes) {
+		t.Errorf("omitted reserved types mismatch: have %v, want %v", config.ReservedTypes, DefaultTxPolicyConfig.ReservedTypes)
+	}
+	if config.SenderWindow != DefaultTxPolicyConfig.SenderWindow {
+		t.Errorf("omitted sender window mismatch: have %v, want %v", config.SenderWindow, DefaultTxPolicyConfig.SenderWindow)
+	}
+	if err := json.Unmarshal([]byte(`{"reservedSlots": 16, "reservedTypes": [], "senderWindow": "30s"}`), &config); err != nil {
+		t.Fatalf("failed to decode policy: %v", err)
+	}
+	if len(config.ReservedTypes) != 0 || config.SenderWindow != 30*time.Second {
+		t.Errorf("explicit policy mismatch: have types %v, window %v", config.ReservedTypes, config.SenderWindow)
+	}
+}
+
+// Tests that the policy configuration encodes the reserved types as numbers, as
+// it's decoded, and survives a round trip through JSON.
+func TestTxPolicyConfigJSONRoundTrip(t *testing.T) {
+	config := TxPolicyConfig{
+		ReservedSlots: 16,
+		ReservedTypes: []types.TxType{types.LoginCandidate, types.Delegate},
+		SenderLimit:   8,
+		SenderWindow:  30 * time.Second,
+	}
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to encode policy: %v", err)
+	}
+	want := fmt.Sprintf(`{"reservedSlots":16,"reservedTypes":[%d,%d],"senderLimit":8,"senderWindow":"30s"}`, types.LoginCandidate, types.Delegate)
+	if string(blob) != want {
+		t.Errorf("encoded policy mismatch:\nhave %s\nwant %s", blob, want)
+	}
+	var decoded TxPolicyConfig
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatalf("failed to decode policy: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, config) {
+		t.Errorf("decoded policy mismatch: have %+v, want %+v", decoded, config)
+	}
+	if err := json.Unmarshal([]byte(`{"reservedTypes": [256]}`), &decoded); err == nil {
+		t.Errorf("out of range transaction type accepted")
+	}
+}
+
+// Tests that the pool remembers the transactions replaced by others of the same
+// nonce, both pending and queued, and announces the replacements.
+func TestTransactionReplacementTracking(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	events := make(chan TxReplacedEvent, 8)
+	sub := pool.SubscribeTxReplacedEvent(events)
+	defer sub.Unsubscribe()
+
+	// Replace a pending and a queued transaction, and the queued replacement again
+	var (
+		pending = pricedTransaction(0, big.NewInt(100000), big.NewInt(1), key)
+		queued  = pricedTransaction(2, big.NewInt(100000), big.NewInt(1), key)
+
+		pendingBy = pricedTransaction(0, big.NewInt(100000), big.NewInt(2), key)
+		queuedBy  = pricedTransaction(2, big.NewInt(100000), big.NewInt(2), key)
+		queuedBy2 = pricedTransaction(2, big.NewInt(100000), big.NewInt(3), key)
+	)
+	for i, tx := range []*types.Transaction{pending, queued, pendingBy, queuedBy, queuedBy2} {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	want := map[common.Hash]common.Hash{
+		pending.Hash():  pendingBy.Hash(),
+		queued.Hash():   queuedBy.Hash(),
+		queuedBy.Hash(): queuedBy2.Hash(),
+	}
+	for old, by := range want {
+		replacement := pool.Replacement(old)
+		if replacement == nil {
+			t.Fatalf("replacement of %x not tracked", old)
+		}
+		if replacement.Tx.Hash() != old || replacement.By.Hash() != by {
+			t.Errorf("replacement mismatch: have %x -> %x, want %x -> %x", replacement.Tx.Hash(), replacement.By.Hash(), old, by)
+		}
+	}
+	for _, tx := range []*types.Transaction{pendingBy, queuedBy2} {
+		if pool.Replacement(tx.Hash()) != nil {
+			t.Errorf("pooled transaction %x reported replaced", tx.Hash())
+		}
+	}
+	// Ensure all the replacements were announced
+	for i := 0; i < len(want); i++ {
+		select {
+		case ev := <-events:
+			if by, ok := want[ev.Old.Hash()]; !ok || by != ev.New.Hash() {
+				t.Errorf("unexpected replacement event: %x -> %x", ev.Old.Hash(), ev.New.Hash())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("replacement event %d not fired", i)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
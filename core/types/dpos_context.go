@@ -7,7 +7,6 @@ import (
 
 	"github.com/DATxChain-Protocol/DATx/common"
 	"github.com/DATxChain-Protocol/DATx/crypto/sha3"
-	"github.com/DATxChain-Protocol/DATx/datxdb"
 	"github.com/DATxChain-Protocol/DATx/rlp"
 	"github.com/DATxChain-Protocol/DATx/trie"
 )
@@ -19,7 +18,7 @@ type DposContext struct {
 	candidateTrie *trie.Trie
 	mintCntTrie   *trie.Trie
 
-	db datxdb.Database
+	db trie.Database
 }
 
 var (
@@ -30,27 +29,27 @@ var (
 	mintCntPrefix   = []byte("mintCnt-")
 )
 
-func NewEpochTrie(root common.Hash, db datxdb.Database) (*trie.Trie, error) {
+func NewEpochTrie(root common.Hash, db trie.Database) (*trie.Trie, error) {
 	return trie.NewTrieWithPrefix(root, epochPrefix, db)
 }
 
-func NewDelegateTrie(root common.Hash, db datxdb.Database) (*trie.Trie, error) {
+func NewDelegateTrie(root common.Hash, db trie.Database) (*trie.Trie, error) {
 	return trie.NewTrieWithPrefix(root, delegatePrefix, db)
 }
 
-func NewVoteTrie(root common.Hash, db datxdb.Database) (*trie.Trie, error) {
+func NewVoteTrie(root common.Hash, db trie.Database) (*trie.Trie, error) {
 	return trie.NewTrieWithPrefix(root, votePrefix, db)
 }
 
-func NewCandidateTrie(root common.Hash, db datxdb.Database) (*trie.Trie, error) {
+func NewCandidateTrie(root common.Hash, db trie.Database) (*trie.Trie, error) {
 	return trie.NewTrieWithPrefix(root, candidatePrefix, db)
 }
 
-func NewMintCntTrie(root common.Hash, db datxdb.Database) (*trie.Trie, error) {
+func NewMintCntTrie(root common.Hash, db trie.Database) (*trie.Trie, error) {
 	return trie.NewTrieWithPrefix(root, mintCntPrefix, db)
 }
 
-func NewDposContext(db datxdb.Database) (*DposContext, error) {
+func NewDposContext(db trie.Database) (*DposContext, error) {
 	epochTrie, err := NewEpochTrie(common.Hash{}, db)
 	if err != nil {
 		return nil, err
@@ -81,7 +80,7 @@ func NewDposContext(db datxdb.Database) (*DposContext, error) {
 	}, nil
 }
 
-func NewDposContextFromProto(db datxdb.Database, ctxProto *DposContextProto) (*DposContext, error) {
+func NewDposContextFromProto(db trie.Database, ctxProto *DposContextProto) (*DposContext, error) {
 	epochTrie, err := NewEpochTrie(ctxProto.EpochHash, db)
 	if err != nil {
 		return nil, err
@@ -331,7 +330,7 @@ func (d *DposContext) DelegateTrie() *trie.Trie           { return d.delegateTri
 func (d *DposContext) VoteTrie() *trie.Trie               { return d.voteTrie }
 func (d *DposContext) EpochTrie() *trie.Trie              { return d.epochTrie }
 func (d *DposContext) MintCntTrie() *trie.Trie            { return d.mintCntTrie }
-func (d *DposContext) DB() datxdb.Database                 { return d.db }
+func (d *DposContext) DB() trie.Database                  { return d.db }
 func (dc *DposContext) SetEpoch(epoch *trie.Trie)         { dc.epochTrie = epoch }
 func (dc *DposContext) SetDelegate(delegate *trie.Trie)   { dc.delegateTrie = delegate }
 func (dc *DposContext) SetVote(vote *trie.Trie)           { dc.voteTrie = vote }
@@ -0,0 +1,226 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// Actions of the entries of the DPoS context tries that are sets.
+const (
+	DposAdded   = "added"
+	DposRemoved = "removed"
+)
+
+// DposDiff is the difference between two versions of a DPoS context, decoded
+// from the changes of its tries.
+type DposDiff struct {
+	Candidates []*CandidateChange `json:"candidates,omitempty"`
+	Delegates  []*DelegateChange  `json:"delegates,omitempty"`
+	Votes      []*VoteChange      `json:"votes,omitempty"`
+	Validators *ValidatorsChange  `json:"validators,omitempty"`
+	MintCounts []*MintCountChange `json:"mintCounts,omitempty"`
+}
+
+// CandidateChange is a candidate added to or removed from the candidate trie.
+type CandidateChange struct {
+	Candidate common.Address `json:"candidate"`
+	Action    string         `json:"action"`
+}
+
+// DelegateChange is a delegation added to or removed from the delegate trie.
+type DelegateChange struct {
+	Candidate common.Address `json:"candidate"`
+	Delegator common.Address `json:"delegator"`
+	Action    string         `json:"action"`
+}
+
+// VoteChange is a vote of a delegator moved in the vote trie. A missing candidate
+// means there was no vote before, or there's none after.
+type VoteChange struct {
+	Delegator common.Address  `json:"delegator"`
+	Prev      *common.Address `json:"prev,omitempty"`
+	Post      *common.Address `json:"post,omitempty"`
+}
+
+// ValidatorsChange is the change of the validators elected in the epoch trie.
+type ValidatorsChange struct {
+	Prev []common.Address `json:"prev"`
+	Post []common.Address `json:"post"`
+}
+
+// MintCountChange is the change of the number of blocks minted by a validator in
+// an epoch, as counted in the mint count trie.
+type MintCountChange struct {
+	Epoch     hexutil.Uint64 `json:"epoch"`
+	Validator common.Address `json:"validator"`
+	Prev      hexutil.Uint64 `json:"prev"`
+	Post      hexutil.Uint64 `json:"post"`
+}
+
+// Empty reports whether the diff holds no change.
+func (d *DposDiff) Empty() bool {
+	return len(d.Candidates) == 0 && len(d.Delegates) == 0 && len(d.Votes) == 0 && d.Validators == nil && len(d.MintCounts) == 0
+}
+
+// DiffDposContext returns the changes made to a DPoS context from prev to post.
+// Subtries with the same hash aren't visited, so the cost of the diff depends on
+// the size of the changes and not on the size of the context.
+func DiffDposContext(prev, post *DposContext) (*DposDiff, error) {
+	diff := new(DposDiff)
+
+	changes, err := diffDposTrie(prev.candidateTrie, post.candidateTrie, candidatePrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		diff.Candidates = append(diff.Candidates, &CandidateChange{
+			Candidate: common.BytesToAddress(c.key),
+			Action:    c.action(),
+		})
+	}
+	if changes, err = diffDposTrie(prev.delegateTrie, post.delegateTrie, delegatePrefix); err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if len(c.key) != 2*common.AddressLength {
+			continue
+		}
+		diff.Delegates = append(diff.Delegates, &DelegateChange{
+			Candidate: common.BytesToAddress(c.key[:common.AddressLength]),
+			Delegator: common.BytesToAddress(c.key[common.AddressLength:]),
+			Action:    c.action(),
+		})
+	}
+	if changes, err = diffDposTrie(prev.voteTrie, post.voteTrie, votePrefix); err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		vote := &VoteChange{Delegator: common.BytesToAddress(c.key)}
+		if c.prev != nil {
+			candidate := common.BytesToAddress(c.prev)
+			vote.Prev = &candidate
+		}
+		if c.post != nil {
+			candidate := common.BytesToAddress(c.post)
+			vote.Post = &candidate
+		}
+		diff.Votes = append(diff.Votes, vote)
+	}
+	if changes, err = diffDposTrie(prev.epochTrie, post.epochTrie, epochPrefix); err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if string(c.key) != "validator" {
+			continue
+		}
+		validators := new(ValidatorsChange)
+		if c.prev != nil {
+			if err := rlp.DecodeBytes(c.prev, &validators.Prev); err != nil {
+				return nil, err
+			}
+		}
+		if c.post != nil {
+			if err := rlp.DecodeBytes(c.post, &validators.Post); err != nil {
+				return nil, err
+			}
+		}
+		diff.Validators = validators
+	}
+	if changes, err = diffDposTrie(prev.mintCntTrie, post.mintCntTrie, mintCntPrefix); err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if len(c.key) != 8+common.AddressLength {
+			continue
+		}
+		count := &MintCountChange{
+			Epoch:     hexutil.Uint64(binary.BigEndian.Uint64(c.key[:8])),
+			Validator: common.BytesToAddress(c.key[8:]),
+		}
+		if len(c.prev) == 8 {
+			count.Prev = hexutil.Uint64(binary.BigEndian.Uint64(c.prev))
+		}
+		if len(c.post) == 8 {
+			count.Post = hexutil.Uint64(binary.BigEndian.Uint64(c.post))
+		}
+		diff.MintCounts = append(diff.MintCounts, count)
+	}
+	return diff, nil
+}
+
+// dposTrieChange is a changed entry of a DPoS context trie. A nil value means the
+// entry is missing in that version of the trie.
+type dposTrieChange struct {
+	key        []byte
+	prev, post []byte
+}
+
+// action returns whether the entry was added or removed.
+func (c *dposTrieChange) action() string {
+	if c.post == nil {
+		return DposRemoved
+	}
+	return DposAdded
+}
+
+// diffDposTrie returns the changed entries between two versions of a DPoS context
+// trie, sorted by key, with the trie prefix stripped from the keys.
+func diffDposTrie(prev, post *trie.Trie, prefix []byte) ([]*dposTrieChange, error) {
+	// Hash both tries so that the iterators skip the unchanged subtries
+	prev.Hash()
+	post.Hash()
+
+	changes := make(map[string]*dposTrieChange)
+	collect := func(a, b *trie.Trie, set func(c *dposTrieChange, value []byte)) error {
+		diff, _ := trie.NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+		it := trie.NewIterator(diff)
+		for it.Next() {
+			key := bytes.TrimPrefix(it.Key, prefix)
+			c, ok := changes[string(key)]
+			if !ok {
+				c = &dposTrieChange{key: common.CopyBytes(key)}
+				changes[string(key)] = c
+			}
+			set(c, common.CopyBytes(it.Value))
+		}
+		return it.Err
+	}
+	if err := collect(prev, post, func(c *dposTrieChange, value []byte) { c.post = value }); err != nil {
+		return nil, err
+	}
+	if err := collect(post, prev, func(c *dposTrieChange, value []byte) { c.prev = value }); err != nil {
+		return nil, err
+	}
+	result := make([]*dposTrieChange, 0, len(changes))
+	for _, c := range changes {
+		if !bytes.Equal(c.prev, c.post) {
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].key, result[j].key) < 0
+	})
+	return result, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDposContext(t *testing.T) {
+	var (
+		candidateA = common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+		candidateB = common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2")
+		delegator  = common.HexToAddress("0x4e080e49f62694554871e669aeb4ebe17c4a9670")
+	)
+	db, _ := datxdb.NewMemDatabase()
+	dposContext, err := NewDposContext(db)
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(candidateA))
+	assert.Nil(t, dposContext.BecomeCandidate(candidateB))
+	assert.Nil(t, dposContext.Delegate(delegator, candidateA))
+	assert.Nil(t, dposContext.SetValidators([]common.Address{candidateA}))
+
+	// nothing changed yet
+	prev := dposContext.Copy()
+	diff, err := DiffDposContext(prev, dposContext)
+	assert.Nil(t, err)
+	assert.True(t, diff.Empty())
+
+	// move the vote, kick out the first candidate and elect the second one
+	assert.Nil(t, dposContext.Delegate(delegator, candidateB))
+	assert.Nil(t, dposContext.KickoutCandidate(candidateA))
+	assert.Nil(t, dposContext.SetValidators([]common.Address{candidateB}))
+	key, count := make([]byte, 8), make([]byte, 8)
+	binary.BigEndian.PutUint64(key, 3)
+	binary.BigEndian.PutUint64(count, 1)
+	dposContext.MintCntTrie().Update(append(key, candidateB.Bytes()...), count)
+
+	diff, err = DiffDposContext(prev, dposContext)
+	assert.Nil(t, err)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []*CandidateChange{{Candidate: candidateA, Action: DposRemoved}}, diff.Candidates)
+	assert.Equal(t, []*DelegateChange{
+		{Candidate: candidateA, Delegator: delegator, Action: DposRemoved},
+		{Candidate: candidateB, Delegator: delegator, Action: DposAdded},
+	}, diff.Delegates)
+	assert.Equal(t, []*VoteChange{{Delegator: delegator, Prev: &candidateA, Post: &candidateB}}, diff.Votes)
+	assert.Equal(t, &ValidatorsChange{Prev: []common.Address{candidateA}, Post: []common.Address{candidateB}}, diff.Validators)
+	assert.Equal(t, []*MintCountChange{{Epoch: 3, Validator: candidateB, Prev: 0, Post: 1}}, diff.MintCounts)
+}
@@ -21,6 +21,7 @@ func (r Receipt) MarshalJSON() ([]byte, error) {
 		TxHash            common.Hash    `json:"transactionHash" gencodec:"required"`
 		ContractAddress   common.Address `json:"contractAddress"`
 		GasUsed           *hexutil.Big   `json:"gasUsed" gencodec:"required"`
+		RevertData        hexutil.Bytes  `json:"revertData,omitempty"`
 	}
 	var enc Receipt
 	enc.PostState = r.PostState
@@ -31,6 +32,7 @@ func (r Receipt) MarshalJSON() ([]byte, error) {
 	enc.TxHash = r.TxHash
 	enc.ContractAddress = r.ContractAddress
 	enc.GasUsed = (*hexutil.Big)(r.GasUsed)
+	enc.RevertData = r.RevertData
 	return json.Marshal(&enc)
 }
 
@@ -44,6 +46,7 @@ func (r *Receipt) UnmarshalJSON(input []byte) error {
 		TxHash            *common.Hash    `json:"transactionHash" gencodec:"required"`
 		ContractAddress   *common.Address `json:"contractAddress"`
 		GasUsed           *hexutil.Big    `json:"gasUsed" gencodec:"required"`
+		RevertData        hexutil.Bytes   `json:"revertData,omitempty"`
 	}
 	var dec Receipt
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -78,5 +81,8 @@ func (r *Receipt) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'gasUsed' for Receipt")
 	}
 	r.GasUsed = (*big.Int)(dec.GasUsed)
+	if dec.RevertData != nil {
+		r.RevertData = dec.RevertData
+	}
 	return nil
 }
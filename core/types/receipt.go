@@ -55,6 +55,7 @@ type Receipt struct {
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
 	GasUsed         *big.Int       `json:"gasUsed" gencodec:"required"`
+	RevertData      []byte         `json:"revertData,omitempty"` // Data returned by the reverted execution, if recorded
 }
 
 type receiptMarshaling struct {
@@ -62,6 +63,7 @@ type receiptMarshaling struct {
 	Status            hexutil.Uint
 	CumulativeGasUsed *hexutil.Big
 	GasUsed           *hexutil.Big
+	RevertData        hexutil.Bytes
 }
 
 // receiptRLP is the consensus encoding of a receipt.
@@ -80,6 +82,7 @@ type receiptStorageRLP struct {
 	ContractAddress   common.Address
 	Logs              []*LogForStorage
 	GasUsed           *big.Int
+	RevertData        [][]byte `rlp:"tail"` // Optional, missing from receipts stored without it
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -164,6 +167,9 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
 	}
+	if len(r.RevertData) > 0 {
+		enc.RevertData = [][]byte{r.RevertData}
+	}
 	return rlp.Encode(w, enc)
 }
 
@@ -185,6 +191,9 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
+	if len(dec.RevertData) > 0 {
+		r.RevertData = dec.RevertData[0]
+	}
 	return nil
 }
 
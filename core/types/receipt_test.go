@@ -0,0 +1,69 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/rlp"
+)
+
+// Tests that the revert data of stored receipts survives a round trip, and that
+// receipts stored without it still decode.
+func TestReceiptStorageRevertData(t *testing.T) {
+	receipt := NewReceipt(nil, true, big.NewInt(50000))
+	receipt.TxHash = common.HexToHash("0x01")
+	receipt.GasUsed = big.NewInt(30000)
+	receipt.Logs = []*Log{}
+
+	for _, data := range [][]byte{nil, {0x08, 0xc3, 0x79, 0xa0}} {
+		receipt.RevertData = data
+
+		enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+		if err != nil {
+			t.Fatalf("failed to encode receipt: %v", err)
+		}
+		var dec ReceiptForStorage
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatalf("failed to decode receipt: %v", err)
+		}
+		if !bytes.Equal(dec.RevertData, data) {
+			t.Errorf("revert data mismatch: have %x, want %x", dec.RevertData, data)
+		}
+		if dec.Status != ReceiptStatusFailed || dec.TxHash != receipt.TxHash || dec.GasUsed.Cmp(receipt.GasUsed) != 0 {
+			t.Errorf("receipt mismatch: have %v, want %v", (*Receipt)(&dec), receipt)
+		}
+	}
+	// Receipts stored before the revert data was recorded lack the field
+	legacy, err := rlp.EncodeToBytes([]interface{}{
+		receiptStatusFailedRLP, receipt.CumulativeGasUsed, receipt.Bloom, receipt.TxHash,
+		receipt.ContractAddress, []*LogForStorage{}, receipt.GasUsed,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode legacy receipt: %v", err)
+	}
+	var dec ReceiptForStorage
+	if err := rlp.DecodeBytes(legacy, &dec); err != nil {
+		t.Fatalf("failed to decode legacy receipt: %v", err)
+	}
+	if dec.RevertData != nil || dec.TxHash != receipt.TxHash {
+		t.Errorf("legacy receipt mismatch: have %v", (*Receipt)(&dec))
+	}
+}
@@ -503,6 +503,12 @@ func NewMessage(from common.Address, to *common.Address, nonce uint64, amount, g
 	}
 }
 
+// WithType returns a copy of the message with the given transaction type.
+func (m Message) WithType(txType TxType) Message {
+	m.txType = txType
+	return m
+}
+
 func (m Message) From() common.Address { return m.from }
 func (m Message) To() *common.Address  { return m.to }
 func (m Message) GasPrice() *big.Int   { return m.price }
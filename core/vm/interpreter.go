@@ -36,6 +36,8 @@ type Config struct {
 	ForceJit bool
 	// Tracer is the op code logger
 	Tracer Tracer
+	// DposTracer collects the DPoS context changes of processed blocks
+	DposTracer DposTracer
 	// NoRecursion disabled Interpreter call, callcode,
 	// delegate call and create.
 	NoRecursion bool
@@ -43,6 +45,8 @@ type Config struct {
 	DisableGasMetering bool
 	// Enable recording of SHA3/keccak preimages
 	EnablePreimageRecording bool
+	// Enable recording of the data returned by reverted transactions in their receipts
+	RecordRevertData bool
 	// JumpTable contains the EVM instruction table. This
 	// may be left uninitialised and will be set to the default
 	// table.
@@ -232,7 +236,7 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 		case err != nil:
 			return nil, err
 		case operation.reverts:
-			return res, errExecutionReverted
+			return res, ErrExecutionReverted
 		case operation.halts:
 			return res, nil
 		case !operation.jumps:
@@ -88,6 +88,14 @@ type Tracer interface {
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
 }
 
+// DposTracer is used to collect the changes a block makes to its DPoS context,
+// which happen outside of the VM. CaptureDposTx is called after every transaction
+// of the block, and CaptureDposFinalize after the consensus engine finalized it.
+type DposTracer interface {
+	CaptureDposTx(index int, hash common.Hash, diff *types.DposDiff)
+	CaptureDposFinalize(diff *types.DposDiff)
+}
+
 // StructLogger is an EVM state logger and implements Tracer.
 //
 // StructLogger can capture state based on the given Log configuration and also keeps
@@ -0,0 +1,198 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datx
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+const (
+	// addressIndexSectionSize is the number of blocks the transactions of addresses
+	// are indexed together for.
+	addressIndexSectionSize = 4096
+
+	// addressIndexConfirms is the number of confirmation blocks before an address
+	// section is considered probably final and indexed.
+	addressIndexConfirms = 256
+
+	// addressIndexThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful during chain upgrades to prevent disk overload.
+	addressIndexThrottling = 100 * time.Millisecond
+
+	// maxAddressScanBlocks is the number of blocks a listing of the transactions of
+	// an address scans at most, beyond the ones read from the index. It covers the
+	// blocks not indexed yet at the head of the chain, along with some sections
+	// rolled back by a reorg and not reindexed yet.
+	maxAddressScanBlocks = 2*addressIndexSectionSize + addressIndexConfirms
+)
+
+// AddressIndexer implements a core.ChainIndexer, recording for every address the
+// positions of the transactions sent from and to it. DPoS transactions are sent
+// to their candidate, so votes are indexed under the voted candidate too.
+type AddressIndexer struct {
+	size uint64 // section size to index the transactions of
+
+	db     datxdb.Database     // database instance to read blocks from and write index data into
+	config *params.ChainConfig // chain configuration to recover the transaction senders with
+
+	section uint64                                   // Section is the section number being processed currently
+	head    common.Hash                              // Head is the hash of the last header processed
+	entries map[common.Address][]core.AddressTxEntry // Transactions of the addresses in the section
+	err     error                                    // Failure processing the section, reported on commit
+}
+
+// NewAddressIndexer returns a chain indexer that generates the transactions of
+// every address in the canonical chain for listing them without block scans.
+func NewAddressIndexer(db datxdb.Database, config *params.ChainConfig, size uint64) *core.ChainIndexer {
+	backend := &AddressIndexer{
+		db:     db,
+		config: config,
+		size:   size,
+	}
+	table := datxdb.NewTable(db, string(core.AddressTxsIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, addressIndexConfirms, addressIndexThrottling, "addresstxs")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new address index
+// section.
+func (b *AddressIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	b.section, b.head, b.err = section, common.Hash{}, nil
+	b.entries = make(map[common.Address][]core.AddressTxEntry)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the transactions of a new
+// header's block into the index.
+func (b *AddressIndexer) Process(header *types.Header) {
+	b.head = header.Hash()
+	if b.err != nil {
+		return
+	}
+	number := header.Number.Uint64()
+	body := core.GetBody(b.db, b.head, number)
+	if body == nil {
+		b.err = fmt.Errorf("block #%d [%x…] body not found", number, b.head[:4])
+		return
+	}
+	signer := types.MakeSigner(b.config, header.Number)
+	for i, tx := range body.Transactions {
+		entry := core.AddressTxEntry{BlockIndex: number, Index: uint64(i)}
+		for _, addr := range txAddresses(signer, tx) {
+			b.entries[addr] = append(b.entries[addr], entry)
+		}
+	}
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the address section and
+// writing it out into the database.
+func (b *AddressIndexer) Commit() error {
+	if b.err != nil {
+		return b.err
+	}
+	batch := b.db.NewBatch()
+	for addr, entries := range b.entries {
+		core.WriteAddressTxs(batch, addr, b.section, b.head, entries)
+	}
+	return batch.Write()
+}
+
+// txAddresses returns the distinct addresses a transaction is indexed under: its
+// sender, its recipient or DPoS candidate, and the contract it creates. Login and
+// logout transactions may lack a recipient, but never create a contract.
+func txAddresses(signer types.Signer, tx *types.Transaction) []common.Address {
+	var addrs []common.Address
+	from, err := types.Sender(signer, tx)
+	if err == nil {
+		addrs = append(addrs, from)
+	}
+	switch to := tx.To(); {
+	case to != nil && (err != nil || *to != from):
+		addrs = append(addrs, *to)
+	case to == nil && err == nil && tx.Type() == types.Binary:
+		addrs = append(addrs, crypto.CreateAddress(from, tx.Nonce()))
+	}
+	return addrs
+}
+
+// addressTxs calls fn with the positions of the transactions of an address in
+// the canonical blocks from and to, in chain order, until fn returns false.
+// Sections the indexer has processed are read from the index, as long as they
+// are still canonical; the blocks of any other section are scanned, failing the
+// listing once more than maxScan of them would be.
+func addressTxs(ctx context.Context, db datxdb.Database, indexer *core.ChainIndexer, config *params.ChainConfig, size, maxScan uint64, addr common.Address, from, to uint64, fn func(number, index uint64) bool) error {
+	sections, _, _ := indexer.Sections()
+
+	var scanned uint64
+	for number := from; number <= to; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Serve whole sections from the index if it wasn't rolled back by a reorg yet
+		if section := number / size; section < sections {
+			last := (section+1)*size - 1
+			if head := core.GetCanonicalHash(db, last); head == indexer.SectionHead(section) {
+				for _, entry := range core.GetAddressTxs(db, addr, section, head) {
+					if entry.BlockIndex < number || entry.BlockIndex > to {
+						continue
+					}
+					if !fn(entry.BlockIndex, entry.Index) {
+						return nil
+					}
+				}
+				number = last + 1
+				continue
+			}
+		}
+		// Section not indexed, scan the block
+		if scanned++; scanned > maxScan {
+			return fmt.Errorf("too many unindexed blocks to scan from #%d, limit %d", number, maxScan)
+		}
+		hash := core.GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return nil
+		}
+		body := core.GetBody(db, hash, number)
+		if body == nil {
+			return fmt.Errorf("block #%d [%x…] body not found", number, hash[:4])
+		}
+		signer := types.MakeSigner(config, new(big.Int).SetUint64(number))
+		for i, tx := range body.Transactions {
+			for _, a := range txAddresses(signer, tx) {
+				if a != addr {
+					continue
+				}
+				if !fn(number, uint64(i)) {
+					return nil
+				}
+				break
+			}
+		}
+		number++
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/event"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+// indexerTestChain feeds the chain events of a test to a chain indexer.
+type indexerTestChain struct {
+	head *types.Header
+	feed event.Feed
+}
+
+func (c *indexerTestChain) CurrentHeader() *types.Header {
+	return c.head
+}
+
+func (c *indexerTestChain) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// Tests that the transactions of addresses are served from the index and the
+// scanned unindexed blocks alike, and that reorged sections are rolled back.
+func TestAddressIndex(t *testing.T) {
+	var (
+		key1, _   = crypto.GenerateKey()
+		key2, _   = crypto.GenerateKey()
+		addr1     = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2     = crypto.PubkeyToAddress(key2.PublicKey)
+		candidate = common.HexToAddress("0xcc")
+		contract  = crypto.CreateAddress(addr1, 1)
+		config    = params.TestChainConfig
+		signer    = types.MakeSigner(config, big.NewInt(0))
+		db, _     = datxdb.NewMemDatabase()
+	)
+	sign := func(key int, tx *types.Transaction) *types.Transaction {
+		tx, _ = types.SignTx(tx, signer, []*ecdsa.PrivateKey{key1, key2}[key])
+		return tx
+	}
+	// makeChain writes blocks up to number 13 on top of parent as the canonical
+	// chain, the transactions of each block taken from txs
+	makeChain := func(parent *types.Block, extra string, txs map[uint64][]*types.Transaction) *types.Block {
+		for number := parent.NumberU64() + 1; number <= 13; number++ {
+			header := &types.Header{
+				ParentHash: parent.Hash(),
+				Number:     new(big.Int).SetUint64(number),
+				Difficulty: big.NewInt(1),
+				GasLimit:   big.NewInt(8000000),
+				GasUsed:    new(big.Int),
+				Time:       new(big.Int).SetUint64(number * 10),
+				Extra:      []byte(extra),
+			}
+			parent = types.NewBlock(header, txs[number], nil, nil)
+			core.WriteBlock(db, parent)
+			core.WriteCanonicalHash(db, parent.Hash(), number)
+		}
+		return parent
+	}
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1), GasLimit: big.NewInt(8000000), GasUsed: new(big.Int), Time: new(big.Int)}, nil, nil, nil)
+	core.WriteBlock(db, genesis)
+	core.WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	head := makeChain(genesis, "", map[uint64][]*types.Transaction{
+		1:  {sign(0, types.NewTransaction(types.Binary, 0, addr2, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil))},
+		5:  {sign(0, types.NewContractCreation(1, new(big.Int), big.NewInt(100000), big.NewInt(1), []byte{0x00}))},
+		6:  {sign(0, types.NewTransaction(types.Delegate, 2, candidate, new(big.Int), big.NewInt(21000), big.NewInt(1), nil)), sign(1, types.NewTransaction(types.Delegate, 0, candidate, new(big.Int), big.NewInt(21000), big.NewInt(1), nil))},
+		9:  {sign(0, types.NewTransaction(types.LoginCandidate, 3, addr1, new(big.Int), big.NewInt(21000), big.NewInt(1), nil))},
+		13: {sign(1, types.NewTransaction(types.Binary, 1, addr1, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil))},
+	})
+	// Index the chain in sections of 4 blocks, leaving blocks 12 and 13 unindexed
+	chain := &indexerTestChain{head: head.Header()}
+	indexer := core.NewChainIndexer(db, datxdb.NewTable(db, string(core.AddressTxsIndexPrefix)), &AddressIndexer{db: db, config: config, size: 4}, 4, 0, 0, "addresstxs")
+	indexer.Start(chain)
+	defer indexer.Close()
+
+	waitSections := func(head common.Hash) {
+		for i := 0; i < 100; i++ {
+			if sections, _, _ := indexer.Sections(); sections == 3 && indexer.SectionHead(2) == head {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("chain not indexed")
+	}
+	waitSections(core.GetCanonicalHash(db, 11))
+
+	at := func(number, index uint64) core.AddressTxEntry {
+		return core.AddressTxEntry{BlockIndex: number, Index: index}
+	}
+	check := func(name string, addr common.Address, from, to uint64, want []core.AddressTxEntry) {
+		var have []core.AddressTxEntry
+		err := addressTxs(context.Background(), db, indexer, config, 4, 16, addr, from, to, func(number, index uint64) bool {
+			have = append(have, at(number, index))
+			return true
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to list transactions of %x: %v", name, addr, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: transactions of %x mismatch: have %v, want %v", name, addr, have, want)
+		}
+	}
+	check("indexed", addr1, 0, 13, []core.AddressTxEntry{at(1, 0), at(5, 0), at(6, 0), at(9, 0), at(13, 0)})
+	check("indexed", addr2, 0, 13, []core.AddressTxEntry{at(1, 0), at(6, 1), at(13, 0)})
+	check("indexed", candidate, 0, 13, []core.AddressTxEntry{at(6, 0), at(6, 1)})
+	check("indexed", contract, 0, 13, []core.AddressTxEntry{at(5, 0)})
+	check("indexed range", addr1, 6, 12, []core.AddressTxEntry{at(6, 0), at(9, 0)})
+
+	// Reorg the chain from block 4, the stale sections must not be served any more
+	fork := makeChain(core.GetBlock(db, core.GetCanonicalHash(db, 3), 3), "fork", map[uint64][]*types.Transaction{
+		7: {sign(0, types.NewTransaction(types.Binary, 1, addr2, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil))},
+	})
+	check("reorged", addr1, 0, 13, []core.AddressTxEntry{at(1, 0), at(7, 0)})
+	check("reorged", candidate, 0, 13, nil)
+
+	// Scanning the stale sections is limited, the index isn't
+	scan := func(maxScan uint64) error {
+		return addressTxs(context.Background(), db, indexer, config, 4, maxScan, addr1, 0, 13, func(number, index uint64) bool { return true })
+	}
+	if err := scan(9); err == nil {
+		t.Errorf("scanned 10 unindexed blocks with a limit of 9")
+	}
+	if err := scan(10); err != nil {
+		t.Errorf("failed to scan 10 unindexed blocks with a limit of 10: %v", err)
+	}
+
+	// Notifying the indexer of the reorg should reindex the sections
+	chain.feed.Send(core.ChainEvent{Block: fork, Hash: fork.Hash()})
+	waitSections(core.GetCanonicalHash(db, 11))
+
+	check("reindexed", addr1, 0, 13, []core.AddressTxEntry{at(1, 0), at(7, 0)})
+	check("reindexed", addr2, 0, 13, []core.AddressTxEntry{at(1, 0), at(7, 0)})
+	check("reindexed", candidate, 0, 13, nil)
+}
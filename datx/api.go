@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -34,6 +36,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/core/state"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/datx/tracers"
 	"github.com/DATxChain-Protocol/DATx/internal/ethapi"
 	"github.com/DATxChain-Protocol/DATx/log"
 	"github.com/DATxChain-Protocol/DATx/params"
@@ -44,6 +47,9 @@ import (
 
 const defaultTraceTimeout = 5 * time.Second
 
+// errTraceFailed is reported to native tracers when the traced message failed.
+var errTraceFailed = errors.New("execution failed")
+
 // PublicEthereumAPI provides an API to access Ethereum full node-related
 // information.
 type PublicEthereumAPI struct {
@@ -268,6 +274,23 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// TxPoolPolicy returns the configuration of the admission and eviction policy of
+// the transaction pool.
+func (api *PrivateAdminAPI) TxPoolPolicy() (core.TxPolicyConfig, error) {
+	policy, ok := api.datx.TxPool().Policy().(*core.DposTxPolicy)
+	if !ok {
+		return core.TxPolicyConfig{}, errors.New("custom transaction pool policy")
+	}
+	return policy.Config(), nil
+}
+
+// SetTxPoolPolicy replaces the admission and eviction policy of the transaction
+// pool with one of the given configuration.
+func (api *PrivateAdminAPI) SetTxPoolPolicy(config core.TxPolicyConfig) (bool, error) {
+	api.datx.TxPool().SetPolicy(core.NewDposTxPolicy(config))
+	return true, nil
+}
+
 // PublicDebugAPI is the collection of Ethereum full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -319,10 +342,15 @@ func NewPrivateDebugAPI(config *params.ChainConfig, datx *Ethereum) *PrivateDebu
 }
 
 // BlockTraceResult is the returned value when replaying a block to check for
-// consensus results and full VM trace logs for all included transactions.
+// consensus results and full VM trace logs for all included transactions. When
+// traced with a native tracer, the results of every transaction are returned in
+// Traces instead of the VM logs. The changes to the DPoS context are returned in
+// Dpos if requested.
 type BlockTraceResult struct {
 	Validated  bool                  `json:"validated"`
 	StructLogs []ethapi.StructLogRes `json:"structLogs"`
+	Traces     []json.RawMessage     `json:"traces,omitempty"`
+	Dpos       *DposBlockTrace       `json:"dpos,omitempty"`
 	Error      string                `json:"error"`
 }
 
@@ -331,6 +359,7 @@ type TraceArgs struct {
 	*vm.LogConfig
 	Tracer  *string
 	Timeout *string
+	Dpos    bool
 }
 
 // TraceBlock processes the given block'api RLP but does not import the block in to
@@ -361,7 +390,7 @@ func (api *PrivateDebugAPI) TraceBlockFromFile(file string, config *vm.LogConfig
 }
 
 // TraceBlockByNumber processes the block by canonical block number.
-func (api *PrivateDebugAPI) TraceBlockByNumber(blockNr rpc.BlockNumber, config *vm.LogConfig) BlockTraceResult {
+func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, config *TraceArgs) BlockTraceResult {
 	// Fetch the block that we aim to reprocess
 	var block *types.Block
 	switch blockNr {
@@ -377,29 +406,141 @@ func (api *PrivateDebugAPI) TraceBlockByNumber(blockNr rpc.BlockNumber, config *
 	if block == nil {
 		return BlockTraceResult{Error: fmt.Sprintf("block #%d not found", blockNr)}
 	}
-
-	validated, logs, err := api.traceBlock(block, config)
-	return BlockTraceResult{
-		Validated:  validated,
-		StructLogs: ethapi.FormatLogs(logs),
-		Error:      formatError(err),
-	}
+	return api.traceBlockArgs(ctx, block, config)
 }
 
 // TraceBlockByHash processes the block by hash.
-func (api *PrivateDebugAPI) TraceBlockByHash(hash common.Hash, config *vm.LogConfig) BlockTraceResult {
+func (api *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceArgs) BlockTraceResult {
 	// Fetch the block that we aim to reprocess
 	block := api.datx.BlockChain().GetBlockByHash(hash)
 	if block == nil {
 		return BlockTraceResult{Error: fmt.Sprintf("block #%x not found", hash)}
 	}
+	return api.traceBlockArgs(ctx, block, config)
+}
 
-	validated, logs, err := api.traceBlock(block, config)
-	return BlockTraceResult{
-		Validated:  validated,
-		StructLogs: ethapi.FormatLogs(logs),
-		Error:      formatError(err),
+// traceBlockArgs processes the given block with the tracer selected by the trace
+// arguments: the struct logger over the whole block, or a native tracer over each
+// of its transactions.
+func (api *PrivateDebugAPI) traceBlockArgs(ctx context.Context, block *types.Block, config *TraceArgs) BlockTraceResult {
+	var result BlockTraceResult
+	if config == nil || config.Tracer == nil {
+		var logConfig *vm.LogConfig
+		if config != nil {
+			logConfig = config.LogConfig
+		}
+		validated, logs, err := api.traceBlock(block, logConfig)
+		result = BlockTraceResult{
+			Validated:  validated,
+			StructLogs: ethapi.FormatLogs(logs),
+			Error:      formatError(err),
+		}
+	} else {
+		traces, err := api.traceBlockTxs(ctx, block, config)
+		if err != nil {
+			return BlockTraceResult{Error: formatError(err)}
+		}
+		result = BlockTraceResult{Validated: true, Traces: traces}
+	}
+	if config != nil && config.Dpos && result.Error == "" {
+		dpos, err := api.traceDpos(block)
+		if err != nil {
+			result.Error = formatError(err)
+		}
+		result.Dpos = dpos
 	}
+	return result
+}
+
+// traceBlockTxs replays the transactions of the given block on top of its parent
+// state, each one under a new native tracer, and returns their results.
+func (api *PrivateDebugAPI) traceBlockTxs(ctx context.Context, block *types.Block, config *TraceArgs) ([]json.RawMessage, error) {
+	if _, ok := tracers.New(*config.Tracer); !ok {
+		return nil, fmt.Errorf("blocks can only be traced by native tracers: %s", strings.Join(tracers.Names(), ", "))
+	}
+	timeout, err := traceTimeout(config)
+	if err != nil {
+		return nil, err
+	}
+	blockchain := api.datx.BlockChain()
+	if err := api.datx.engine.VerifyHeader(blockchain, block.Header(), true); err != nil {
+		return nil, err
+	}
+	parent := blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	return api.traceTxs(ctx, block, statedb, *config.Tracer, timeout)
+}
+
+// traceTxs applies the transactions of the given block on top of its parent
+// state, each one under a new native tracer of the given name, and returns their
+// results.
+func (api *PrivateDebugAPI) traceTxs(ctx context.Context, block *types.Block, statedb *state.StateDB, name string, timeout time.Duration) ([]json.RawMessage, error) {
+	var (
+		signer = types.MakeSigner(api.config, block.Number())
+		traces = make([]json.RawMessage, 0, len(block.Transactions()))
+	)
+	for _, tx := range block.Transactions() {
+		msg, _ := tx.AsMessage(signer)
+		vmctx := core.NewEVMContext(msg, block.Header(), api.datx.BlockChain(), nil)
+
+		tracer, _ := tracers.New(name)
+		result, err := api.traceNative(ctx, tracer, msg, vmctx, statedb, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("tx %x: %v", tx.Hash(), err)
+		}
+		traces = append(traces, result)
+		statedb.DeleteSuicides()
+	}
+	return traces, nil
+}
+
+// traceNative applies a message to the given state under a native tracer, and
+// returns the result of the trace.
+func (api *PrivateDebugAPI) traceNative(ctx context.Context, tracer tracers.Tracer, msg core.Message, vmctx vm.Context, statedb *state.StateDB, timeout time.Duration) (json.RawMessage, error) {
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
+
+	// Abort the execution on timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		vmenv.Cancel()
+	}()
+	defer cancel()
+
+	if err := tracer.CaptureStart(vmenv, msg); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	if deadlineCtx.Err() != nil {
+		return nil, &timeoutError{}
+	}
+	var vmerr error
+	if failed {
+		vmerr = errTraceFailed
+	}
+	if err := tracer.CaptureEnd(ret, gas.Uint64(), time.Since(start), vmerr); err != nil {
+		return nil, err
+	}
+	return tracer.GetResult()
+}
+
+// traceTimeout returns the timeout requested by the trace arguments, or the
+// default one.
+func traceTimeout(config *TraceArgs) (time.Duration, error) {
+	if config.Timeout == nil {
+		return defaultTraceTimeout, nil
+	}
+	return time.ParseDuration(*config.Timeout)
 }
 
 // traceBlock processes the given block but does not save the state.
@@ -451,30 +592,34 @@ func (t *timeoutError) Error() string {
 }
 
 // TraceTransaction returns the structured logs created during the execution of EVM
-// and returns them as a JSON object.
+// and returns them as a JSON object. If a tracer is named, the transaction is
+// traced by the native tracer of that name, or else the tracer is interpreted as
+// JavaScript.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
-	var tracer vm.Tracer
+	var (
+		tracer  vm.Tracer
+		timeout time.Duration
+	)
 	if config != nil && config.Tracer != nil {
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			var err error
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
-
 		var err error
-		if tracer, err = ethapi.NewJavascriptTracer(*config.Tracer); err != nil {
+		if timeout, err = traceTimeout(config); err != nil {
 			return nil, err
 		}
+		if native, ok := tracers.New(*config.Tracer); ok {
+			tracer = native
+		} else {
+			if tracer, err = ethapi.NewJavascriptTracer(*config.Tracer); err != nil {
+				return nil, err
+			}
 
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*ethapi.JavascriptTracer).Stop(&timeoutError{})
-		}()
-		defer cancel()
+			// Handle timeouts and RPC cancellations
+			deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+			go func() {
+				<-deadlineCtx.Done()
+				tracer.(*ethapi.JavascriptTracer).Stop(&timeoutError{})
+			}()
+			defer cancel()
+		}
 	} else if config == nil {
 		tracer = vm.NewStructLogger(nil)
 	} else {
@@ -490,6 +635,9 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 	if err != nil {
 		return nil, err
 	}
+	if native, ok := tracer.(tracers.Tracer); ok {
+		return api.traceNative(ctx, native, msg, context, statedb, timeout)
+	}
 
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
@@ -667,11 +815,11 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 		return nil, fmt.Errorf("start block height (%d) must be less than end block height (%d)", startBlock.Number().Uint64(), endBlock.Number().Uint64())
 	}
 
-	oldTrie, err := trie.NewSecure(startBlock.Root(), api.datx.chainDb, 0)
+	oldTrie, err := trie.NewSecure(startBlock.Root(), api.datx.blockchain.StateCache().TrieDB(), 0)
 	if err != nil {
 		return nil, err
 	}
-	newTrie, err := trie.NewSecure(endBlock.Root(), api.datx.chainDb, 0)
+	newTrie, err := trie.NewSecure(endBlock.Root(), api.datx.blockchain.StateCache().TrieDB(), 0)
 	if err != nil {
 		return nil, err
 	}
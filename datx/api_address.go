@@ -0,0 +1,107 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// addressTxsPageSize is the number of transactions listed per page by
+// GetTransactionsByAddress.
+const addressTxsPageSize = 100
+
+// AddressTransaction is the position of a transaction sent from or to an address.
+type AddressTransaction struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint   `json:"transactionIndex"`
+	Hash             common.Hash    `json:"hash"`
+}
+
+// PublicAddressIndexAPI provides an API to list the transactions of addresses
+// from the address index of full nodes.
+type PublicAddressIndexAPI struct {
+	datx *Ethereum
+}
+
+// NewPublicAddressIndexAPI creates a new API listing the transactions of
+// addresses.
+func NewPublicAddressIndexAPI(datx *Ethereum) *PublicAddressIndexAPI {
+	return &PublicAddressIndexAPI{datx}
+}
+
+// GetTransactionsByAddress returns a page of the transactions sent from or to an
+// address, DPoS transactions also to their candidate, in the canonical blocks
+// from fromBlock to toBlock. Transactions are listed in chain order, pages
+// holding addressTxsPageSize transactions each. Blocks the index doesn't cover
+// yet are scanned, and calls scanning more than maxAddressScanBlocks of them fail.
+func (api *PublicAddressIndexAPI) GetTransactionsByAddress(ctx context.Context, address common.Address, fromBlock, toBlock rpc.BlockNumber, page hexutil.Uint64) ([]*AddressTransaction, error) {
+	from, to := api.resolveBlockNumber(fromBlock), api.resolveBlockNumber(toBlock)
+	if head := api.datx.BlockChain().CurrentBlock().NumberU64(); to > head {
+		to = head
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d > %d", from, to)
+	}
+	var (
+		db   = api.datx.ChainDb()
+		skip = uint64(page) * addressTxsPageSize
+		txs  = []*AddressTransaction{}
+		err  error
+	)
+	iterErr := addressTxs(ctx, db, api.datx.addressIndexer, api.datx.chainConfig, addressIndexSectionSize, maxAddressScanBlocks, address, from, to, func(number, index uint64) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		hash := core.GetCanonicalHash(db, number)
+		body := core.GetBody(db, hash, number)
+		if body == nil || index >= uint64(len(body.Transactions)) {
+			err = fmt.Errorf("transaction %d of block #%d not found", index, number)
+			return false
+		}
+		txs = append(txs, &AddressTransaction{
+			BlockNumber:      hexutil.Uint64(number),
+			BlockHash:        hash,
+			TransactionIndex: hexutil.Uint(index),
+			Hash:             body.Transactions[index].Hash(),
+		})
+		return len(txs) < addressTxsPageSize
+	})
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// resolveBlockNumber returns the number of a block of the canonical chain, the
+// pending block resolving to the head, as it has no index entries yet.
+func (api *PublicAddressIndexAPI) resolveBlockNumber(number rpc.BlockNumber) uint64 {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return api.datx.BlockChain().CurrentBlock().NumberU64()
+	}
+	return uint64(number)
+}
@@ -32,8 +32,10 @@ import (
 	"github.com/DATxChain-Protocol/DATx/datx/gasprice"
 	"github.com/DATxChain-Protocol/DATx/datxdb"
 	"github.com/DATxChain-Protocol/DATx/event"
+	"github.com/DATxChain-Protocol/DATx/internal/ethapi"
 	"github.com/DATxChain-Protocol/DATx/params"
 	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
 )
 
 // EthApiBackend implements ethapi.Backend for full nodes
@@ -93,9 +95,18 @@ func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 		return nil, nil, err
 	}
 	stateDb, err := b.datx.BlockChain().StateAt(header.Root)
+	if _, missing := err.(*trie.MissingNodeError); missing {
+		// Report pruned state as such rather than as a corrupted database
+		avail := b.datx.BlockChain().StateAvailability()
+		return nil, nil, &ethapi.StateUnavailableError{Number: header.Number.Uint64(), Oldest: avail.Accounts}
+	}
 	return stateDb, header, err
 }
 
+func (b *EthApiBackend) StateAvailability(ctx context.Context) (*core.StateAvailability, error) {
+	return b.datx.blockchain.StateAvailability(), nil
+}
+
 func (b *EthApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
 	return b.datx.blockchain.GetBlockByHash(blockHash), nil
 }
@@ -152,6 +163,10 @@ func (b *EthApiBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 	return b.datx.txPool.Get(hash)
 }
 
+func (b *EthApiBackend) GetPoolReplacement(hash common.Hash) *core.TxReplacement {
+	return b.datx.txPool.Replacement(hash)
+}
+
 func (b *EthApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.datx.txPool.State().GetNonce(addr), nil
 }
@@ -168,6 +183,10 @@ func (b *EthApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Sub
 	return b.datx.TxPool().SubscribeTxPreEvent(ch)
 }
 
+func (b *EthApiBackend) SubscribeTxReplacedEvent(ch chan<- core.TxReplacedEvent) event.Subscription {
+	return b.datx.TxPool().SubscribeTxReplacedEvent(ch)
+}
+
 func (b *EthApiBackend) Downloader() *downloader.Downloader {
 	return b.datx.Downloader()
 }
@@ -184,6 +203,10 @@ func (b *EthApiBackend) ChainDb() datxdb.Database {
 	return b.datx.ChainDb()
 }
 
+func (b *EthApiBackend) TrieDB() trie.Database {
+	return b.datx.blockchain.StateCache().TrieDB()
+}
+
 func (b *EthApiBackend) EventMux() *event.TypeMux {
 	return b.datx.EventMux()
 }
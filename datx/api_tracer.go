@@ -0,0 +1,363 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/datx/tracers"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// ChainTraceResult is the notification sent for every block of a traced chain
+// range, holding the traces of its transactions in order.
+type ChainTraceResult struct {
+	Number hexutil.Uint64    `json:"number"`
+	Hash   common.Hash       `json:"hash"`
+	Traces []json.RawMessage `json:"traces"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// chainTraceTask is a block of a traced chain range, along with the state of its
+// parent to trace it on.
+type chainTraceTask struct {
+	block   *types.Block
+	statedb *state.StateDB
+	result  *ChainTraceResult
+}
+
+// TraceChain traces the blocks from start to end, both included, with the native
+// tracer named by the trace arguments, and streams the results block by block in
+// order.
+//
+// The blocks are traced concurrently on copies of their parent state, which is
+// derived from the previous block instead of being recomputed for every one. At
+// most a few blocks per CPU are in flight at any time, bounding the memory used
+// when the subscriber reads slower than the blocks are traced.
+func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceArgs) (*rpc.Subscription, error) {
+	if config == nil || config.Tracer == nil {
+		return nil, fmt.Errorf("tracer required: %s", strings.Join(tracers.Names(), ", "))
+	}
+	if _, ok := tracers.New(*config.Tracer); !ok {
+		return nil, fmt.Errorf("chains can only be traced by native tracers: %s", strings.Join(tracers.Names(), ", "))
+	}
+	timeout, err := traceTimeout(config)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve the range and the state it starts from
+	from, to := api.chainBlock(start), api.chainBlock(end)
+	if from == nil {
+		return nil, fmt.Errorf("block #%d not found", start)
+	}
+	if to == nil {
+		return nil, fmt.Errorf("block #%d not found", end)
+	}
+	if from.NumberU64() == 0 {
+		return nil, errors.New("genesis block can't be traced")
+	}
+	if from.NumberU64() > to.NumberU64() {
+		return nil, fmt.Errorf("invalid range: start #%d after end #%d", from.NumberU64(), to.NumberU64())
+	}
+	blockchain := api.datx.BlockChain()
+	parent := blockchain.GetBlock(from.ParentHash(), from.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", from.ParentHash())
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	// Stream the traces to the subscriber in the background
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go api.traceChain(notifier, rpcSub, parent, from, to, statedb, *config.Tracer, timeout)
+	return rpcSub, nil
+}
+
+// chainBlock returns the canonical block of the given number, or nil if there's
+// none. The pending block isn't part of the chain, so it can't be traced along.
+func (api *PrivateDebugAPI) chainBlock(number rpc.BlockNumber) *types.Block {
+	switch number {
+	case rpc.PendingBlockNumber:
+		return nil
+	case rpc.LatestBlockNumber:
+		return api.datx.blockchain.CurrentBlock()
+	default:
+		return api.datx.blockchain.GetBlockByNumber(uint64(number))
+	}
+}
+
+// traceChain traces the blocks from start to end on top of the given parent state
+// and sends their results to the subscriber, until it unsubscribes.
+func (api *PrivateDebugAPI) traceChain(notifier *rpc.Notifier, sub *rpc.Subscription, parent, start, end *types.Block, statedb *state.StateDB, name string, timeout time.Duration) {
+	// Abort the tracing once the subscriber is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-sub.Err():
+		case <-notifier.Closed():
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	threads := runtime.NumCPU()
+	if blocks := end.NumberU64() - start.NumberU64() + 1; uint64(threads) > blocks {
+		threads = int(blocks)
+	}
+	pipeline := &chainTracePipeline{
+		threads: threads,
+		limit:   2 * threads,
+		// Feed the blocks in order, moving the state along the chain
+		feed: func(send func(*chainTraceTask) bool) error {
+			blockchain := api.datx.BlockChain()
+			for block := start; ; {
+				if !send(&chainTraceTask{block: block, statedb: statedb.Copy()}) {
+					return nil
+				}
+				if block.NumberU64() == end.NumberU64() {
+					return nil
+				}
+				var err error
+				if statedb, err = api.chainTraceState(parent, block, statedb); err != nil {
+					return fmt.Errorf("block #%d: %v", block.NumberU64(), err)
+				}
+				parent, block = block, blockchain.GetBlockByNumber(block.NumberU64()+1)
+				if block == nil || block.ParentHash() != parent.Hash() {
+					return fmt.Errorf("block #%d not found", parent.NumberU64()+1)
+				}
+			}
+		},
+		trace: func(ctx context.Context, task *chainTraceTask) *ChainTraceResult {
+			traces, err := api.traceTxs(ctx, task.block, task.statedb, name, timeout)
+			return &ChainTraceResult{
+				Number: hexutil.Uint64(task.block.NumberU64()),
+				Hash:   task.block.Hash(),
+				Traces: traces,
+				Error:  formatError(err),
+			}
+		},
+		notify: func(result *ChainTraceResult) error {
+			return notifier.Notify(sub.ID, result)
+		},
+	}
+	pipeline.run(ctx, start.NumberU64())
+}
+
+// chainTracePipeline traces a range of blocks concurrently and notifies their
+// results in order.
+type chainTracePipeline struct {
+	threads int // Number of blocks traced concurrently
+	limit   int // Number of blocks traced or waiting for their notification at most
+
+	feed   func(send func(*chainTraceTask) bool) error                       // Sends the blocks in order, until send fails
+	trace  func(ctx context.Context, task *chainTraceTask) *ChainTraceResult // Traces a block
+	notify func(result *ChainTraceResult) error                              // Notifies the result of a block
+}
+
+// run traces the blocks fed from the first one on, until the feed ends or fails,
+// a notification fails or the context is cancelled. A failure of the feed is
+// notified after the results of the blocks fed before it.
+//
+// Blocks traced ahead of a slow one wait for it to be notified in order. The feed
+// takes a slot of the limit for every block, given back once the block is
+// notified, so the results waiting are bounded along with the blocks traced.
+func (p *chainTracePipeline) run(ctx context.Context, first uint64) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		slots   = make(chan struct{}, p.limit)
+		tasks   = make(chan *chainTraceTask, p.threads)
+		results = make(chan *chainTraceTask, p.threads)
+		failed  = make(chan error, 1)
+		pend    sync.WaitGroup
+	)
+	for i := 0; i < p.threads; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+			for task := range tasks {
+				task.result = p.trace(ctx, task)
+				task.statedb = nil
+				select {
+				case results <- task:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		pend.Wait()
+		close(results)
+	}()
+	go func() {
+		defer close(tasks)
+
+		send := func(task *chainTraceTask) bool {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+			select {
+			case tasks <- task:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := p.feed(send); err != nil {
+			failed <- err
+		}
+	}()
+	// Reorder the traced blocks and notify them, until a notification fails
+	var (
+		done = make(map[uint64]*ChainTraceResult)
+		next = first
+	)
+	for task := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		done[task.block.NumberU64()] = task.result
+		for result, ok := done[next]; ok; result, ok = done[next] {
+			if err := p.notify(result); err != nil {
+				cancel()
+				break
+			}
+			delete(done, next)
+			next++
+			<-slots
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case err := <-failed:
+		log.Warn("Chain tracing aborted", "err", err)
+		p.notify(&ChainTraceResult{Number: hexutil.Uint64(next), Error: err.Error()})
+	default:
+	}
+}
+
+// chainTraceState returns the state after the given block. The state is read from
+// the database if it's still available there, or else it's computed by processing
+// the block on top of the state of its parent.
+func (api *PrivateDebugAPI) chainTraceState(parent, block *types.Block, statedb *state.StateDB) (*state.StateDB, error) {
+	blockchain := api.datx.BlockChain()
+	if stored, err := blockchain.StateAt(block.Root()); err == nil {
+		return stored, nil
+	}
+	replay, err := api.replayBlock(parent, block)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, _, err := blockchain.Processor().Process(replay, statedb, vm.Config{}); err != nil {
+		return nil, err
+	}
+	if root := statedb.IntermediateRoot(api.config.IsEIP158(block.Number())); root != block.Root() {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	return statedb, nil
+}
+
+// replayBlock returns a copy of the given block to process again, along with the
+// DPoS context of its parent. Processing the cached block itself would modify its
+// DPoS context.
+func (api *PrivateDebugAPI) replayBlock(parent, block *types.Block) (*types.Block, error) {
+	dposContext, err := types.NewDposContextFromProto(api.datx.BlockChain().StateCache().TrieDB(), parent.Header().DposContext)
+	if err != nil {
+		return nil, err
+	}
+	replay := block.WithSeal(block.Header())
+	replay.DposContext = dposContext
+	return replay, nil
+}
+
+// DposTxTrace is the change a transaction made to the DPoS context.
+type DposTxTrace struct {
+	Index int             `json:"index"`
+	Hash  common.Hash     `json:"hash"`
+	Diff  *types.DposDiff `json:"diff"`
+}
+
+// DposBlockTrace is the change a block made to its DPoS context, by the transactions
+// that changed it, and by its finalization, which elects the validators of a new
+// epoch and counts the blocks minted by them.
+type DposBlockTrace struct {
+	Txs      []*DposTxTrace  `json:"txs"`
+	Finalize *types.DposDiff `json:"finalize"`
+}
+
+// CaptureDposTx implements vm.DposTracer, recording the change of a transaction.
+func (t *DposBlockTrace) CaptureDposTx(index int, hash common.Hash, diff *types.DposDiff) {
+	if !diff.Empty() {
+		t.Txs = append(t.Txs, &DposTxTrace{Index: index, Hash: hash, Diff: diff})
+	}
+}
+
+// CaptureDposFinalize implements vm.DposTracer, recording the change of the block
+// finalization.
+func (t *DposBlockTrace) CaptureDposFinalize(diff *types.DposDiff) {
+	t.Finalize = diff
+}
+
+// traceDpos processes the given block on top of the state of its parent and
+// returns the changes it made to the DPoS context.
+func (api *PrivateDebugAPI) traceDpos(block *types.Block) (*DposBlockTrace, error) {
+	blockchain := api.datx.BlockChain()
+	parent := blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	replay, err := api.replayBlock(parent, block)
+	if err != nil {
+		return nil, err
+	}
+	trace := &DposBlockTrace{Txs: []*DposTxTrace{}}
+	if _, _, _, err := blockchain.Processor().Process(replay, statedb, vm.Config{DposTracer: trace}); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
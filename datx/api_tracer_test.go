@@ -0,0 +1,234 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// testTracePipeline creates a pipeline feeding the blocks from 1 to last, or
+// forever if last is zero, and tracing them after a random delay.
+func testTracePipeline(last uint64, notify func(*ChainTraceResult) error) *chainTracePipeline {
+	return &chainTracePipeline{
+		threads: 4,
+		limit:   8,
+		feed: func(send func(*chainTraceTask) bool) error {
+			for n := uint64(1); last == 0 || n <= last; n++ {
+				if !send(&chainTraceTask{block: types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(n)})}) {
+					return nil
+				}
+			}
+			return nil
+		},
+		trace: func(ctx context.Context, task *chainTraceTask) *ChainTraceResult {
+			time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+			return &ChainTraceResult{Number: hexutil.Uint64(task.block.NumberU64())}
+		},
+		notify: notify,
+	}
+}
+
+func TestChainTraceOrder(t *testing.T) {
+	var (
+		notified []uint64
+		inflight int32
+		peak     int32
+	)
+	pipeline := testTracePipeline(100, func(result *ChainTraceResult) error {
+		notified = append(notified, uint64(result.Number))
+		atomic.AddInt32(&inflight, -1)
+		return nil
+	})
+	// Count the blocks fed and not notified yet
+	feed := pipeline.feed
+	pipeline.feed = func(send func(*chainTraceTask) bool) error {
+		return feed(func(task *chainTraceTask) bool {
+			if !send(task) {
+				return false
+			}
+			if n := atomic.AddInt32(&inflight, 1); n > atomic.LoadInt32(&peak) {
+				atomic.StoreInt32(&peak, n)
+			}
+			return true
+		})
+	}
+	pipeline.run(context.Background(), 1)
+
+	if len(notified) != 100 {
+		t.Fatalf("notified blocks mismatch: have %d, want 100", len(notified))
+	}
+	for i, number := range notified {
+		if number != uint64(i+1) {
+			t.Fatalf("notification %d: block mismatch: have #%d, want #%d", i, number, i+1)
+		}
+	}
+	// A block is counted after its slot is taken, so it may be notified before
+	if peak := atomic.LoadInt32(&peak); peak > int32(pipeline.limit)+1 {
+		t.Errorf("blocks in flight exceeded the limit: have %d, limit %d", peak, pipeline.limit)
+	}
+}
+
+func TestChainTraceCancel(t *testing.T) {
+	// A failed notification stops the endless feed
+	var notified int
+	failing := testTracePipeline(0, func(result *ChainTraceResult) error {
+		if notified++; notified == 5 {
+			return errors.New("subscriber gone")
+		}
+		return nil
+	})
+	finished := make(chan struct{})
+	go func() {
+		failing.run(context.Background(), 1)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("tracing not stopped by failed notification")
+	}
+	if notified != 5 {
+		t.Errorf("notifications mismatch: have %d, want 5", notified)
+	}
+	// Cancelling the context stops it too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := testTracePipeline(0, func(result *ChainTraceResult) error {
+		if result.Number == 10 {
+			cancel()
+		}
+		return nil
+	})
+	finished = make(chan struct{})
+	go func() {
+		cancelled.run(ctx, 1)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("tracing not stopped by cancellation")
+	}
+}
+
+func TestChainTraceFeedError(t *testing.T) {
+	var notified []*ChainTraceResult
+	pipeline := testTracePipeline(10, func(result *ChainTraceResult) error {
+		notified = append(notified, result)
+		return nil
+	})
+	feed := pipeline.feed
+	pipeline.feed = func(send func(*chainTraceTask) bool) error {
+		feed(send)
+		return errors.New("block #11 not found")
+	}
+	pipeline.run(context.Background(), 1)
+
+	if len(notified) != 11 {
+		t.Fatalf("notifications mismatch: have %d, want 11", len(notified))
+	}
+	for i, result := range notified[:10] {
+		if result.Number != hexutil.Uint64(i+1) || result.Error != "" {
+			t.Errorf("notification %d: have #%d %q, want #%d", i, result.Number, result.Error, i+1)
+		}
+	}
+	if last := notified[10]; last.Number != 11 || last.Error != "block #11 not found" {
+		t.Errorf("failure notification mismatch: have #%d %q", last.Number, last.Error)
+	}
+}
+
+// Tests that tracing a block reports the changes its candidate and delegation
+// transactions made to the DPoS context, and the mint counted on finalization.
+func TestTraceBlockDpos(t *testing.T) {
+	var (
+		validatorKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		candidateKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		validator       = crypto.PubkeyToAddress(validatorKey.PublicKey)
+		candidate       = crypto.PubkeyToAddress(candidateKey.PublicKey)
+		db, _           = datxdb.NewMemDatabase()
+	)
+	config := *params.DposChainConfig
+	config.Dpos = &params.DposConfig{Validators: []common.Address{validator}, Epoch: 3600}
+
+	gspec := &core.Genesis{
+		Config:     &config,
+		Difficulty: big.NewInt(1),
+		Alloc: core.GenesisAlloc{
+			validator: {Balance: big.NewInt(1e18)},
+			candidate: {Balance: big.NewInt(1e18)},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+	blocks, _ := core.GenerateDposChain(&config, genesis, db, 1, []*ecdsa.PrivateKey{validatorKey}, func(i int, gen *core.BlockGen) {
+		gen.AddLoginTx(candidateKey)
+		gen.AddDelegateTx(validatorKey, candidate)
+	})
+	engine := dpos.New(config.Dpos, db)
+	blockchain, _ := core.NewBlockChain(db, &config, engine, vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", blocks[i].NumberU64(), err)
+	}
+	api := NewPrivateDebugAPI(&config, &Ethereum{blockchain: blockchain, engine: engine})
+
+	result := api.TraceBlockByNumber(context.Background(), rpc.BlockNumber(1), &TraceArgs{Dpos: true})
+	if result.Error != "" || !result.Validated {
+		t.Fatalf("failed to trace block: %s", result.Error)
+	}
+	if result.Dpos == nil {
+		t.Fatalf("DPoS changes not traced")
+	}
+	txs := blocks[0].Transactions()
+	want := []*DposTxTrace{
+		{Index: 0, Hash: txs[0].Hash(), Diff: &types.DposDiff{
+			Candidates: []*types.CandidateChange{{Candidate: candidate, Action: types.DposAdded}},
+		}},
+		{Index: 1, Hash: txs[1].Hash(), Diff: &types.DposDiff{
+			Delegates: []*types.DelegateChange{{Candidate: candidate, Delegator: validator, Action: types.DposAdded}},
+			Votes:     []*types.VoteChange{{Delegator: validator, Post: &candidate}},
+		}},
+	}
+	if !reflect.DeepEqual(result.Dpos.Txs, want) {
+		have, _ := json.MarshalIndent(result.Dpos.Txs, "", "  ")
+		t.Errorf("transaction changes mismatch:\n%s", have)
+	}
+	finalize := result.Dpos.Finalize
+	if finalize == nil || len(finalize.MintCounts) != 1 || finalize.MintCounts[0].Validator != validator || finalize.MintCounts[0].Post != finalize.MintCounts[0].Prev+1 {
+		have, _ := json.MarshalIndent(finalize, "", "  ")
+		t.Errorf("finalization changes mismatch:\n%s", have)
+	}
+}
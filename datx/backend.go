@@ -32,6 +32,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
 	"github.com/DATxChain-Protocol/DATx/core"
 	"github.com/DATxChain-Protocol/DATx/core/bloombits"
+	"github.com/DATxChain-Protocol/DATx/core/state/pruner"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/core/vm"
 	"github.com/DATxChain-Protocol/DATx/datx/downloader"
@@ -81,6 +82,8 @@ type Ethereum struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	addressIndexer *core.ChainIndexer // Address transactions indexer operating during block imports, nil if disabled
+
 	ApiBackend *EthApiBackend
 
 	miner     *miner.Miner
@@ -108,10 +111,16 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := ctx.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, "datx/db/chaindata/")
 	if err != nil {
 		return nil, err
 	}
+	// Finish any state pruning interrupted before it could delete the stale state
+	if datadir := ctx.ResolvePath(""); datadir != "" {
+		if err := pruner.RecoverPruning(chainDb, datadir); err != nil {
+			return nil, err
+		}
+	}
 	stopDbUpgrade := upgradeDeduplicateData(chainDb)
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
@@ -145,8 +154,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		core.WriteBlockChainVersion(chainDb, core.BlockChainVersion)
 	}
-	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-	datx.blockchain, err = core.NewBlockChain(chainDb, datx.chainConfig, datx.engine, vmConfig)
+	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording, RecordRevertData: config.RecordRevertData}
+	cacheConfig := &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, Snapshot: config.Snapshot}
+	datx.blockchain, err = core.NewBlockChainWithCache(chainDb, cacheConfig, datx.chainConfig, datx.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -157,10 +167,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		core.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	datx.bloomIndexer.Start(datx.blockchain)
+	if config.AddressIndex {
+		datx.addressIndexer = NewAddressIndexer(chainDb, datx.chainConfig, addressIndexSectionSize)
+		datx.addressIndexer.Start(datx.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.RemoteJournal != "" {
+		config.TxPool.RemoteJournal = ctx.ResolvePath(config.TxPool.RemoteJournal)
+	}
 	datx.txPool = core.NewTxPool(config.TxPool, datx.chainConfig, datx.blockchain)
 
 	if datx.protocolManager, err = NewProtocolManager(datx.chainConfig, config.SyncMode, config.NetworkId, datx.eventMux, datx.txPool, datx.engine, datx.blockchain, chainDb); err != nil {
@@ -202,7 +219,7 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (datxdb.Dat
 	if err != nil {
 		return nil, err
 	}
-	if db, ok := db.(*datxdb.LDBDatabase); ok {
+	if db, ok := db.(datxdb.Meterer); ok {
 		db.Meter("datx/db/chaindata/")
 	}
 	return db, nil
@@ -216,6 +233,15 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the address index API if the transactions of addresses are indexed
+	if s.addressIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "datx",
+			Version:   "1.0",
+			Service:   NewPublicAddressIndexAPI(s),
+			Public:    true,
+		})
+	}
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -404,6 +430,9 @@ func (s *Ethereum) Stop() error {
 		s.stopDbUpgrade()
 	}
 	s.bloomIndexer.Close()
+	if s.addressIndexer != nil {
+		s.addressIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
@@ -412,6 +441,9 @@ func (s *Ethereum) Stop() error {
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
+	if dpos, ok := s.engine.(*dpos.Dpos); ok {
+		dpos.Close()
+	}
 
 	s.chainDb.Close()
 	close(s.shutdownChan)
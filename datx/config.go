@@ -20,6 +20,7 @@ import (
 	"math/big"
 	"os"
 	"os/user"
+	"time"
 
 	"github.com/DATxChain-Protocol/DATx/common"
 	"github.com/DATxChain-Protocol/DATx/common/hexutil"
@@ -35,6 +36,8 @@ var DefaultConfig = Config{
 	NetworkId:     1357,
 	LightPeers:    20,
 	DatabaseCache: 128,
+	TrieCache:     256,
+	TrieTimeout:   5 * time.Minute,
 	GasPrice:      big.NewInt(18 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
@@ -63,6 +66,7 @@ type Config struct {
 	// Protocol options
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
+	NoPruning bool // Whether to disable pruning and flush everything to disk
 
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
@@ -72,6 +76,10 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	TrieCache          int           // Megabytes of trie nodes cached in memory before flushing
+	TrieTimeout        time.Duration // Time after which the cached trie nodes are flushed
+	Snapshot           bool          // Whether to serve state reads from a flat snapshot of the state
+	AddressIndex       bool          // Whether to index the transactions sent from and to each address
 
 	// Mining-related options
 	Validator    common.Address `toml:",omitempty"`
@@ -89,6 +97,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables storing the data returned by reverted transactions in their receipts
+	RecordRevertData bool
+
 	// Miscellaneous options
 	DocRoot   string `toml:"-"`
 	PowFake   bool   `toml:"-"`
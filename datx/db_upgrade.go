@@ -50,7 +50,7 @@ func upgradeDeduplicateData(db datxdb.Database) func() error {
 
 	go func() {
 		// Create an iterator to read the entire database and covert old lookup entires
-		it := db.(*datxdb.LDBDatabase).NewIterator()
+		it := db.NewIteratorWithPrefix(nil, nil)
 		defer func() {
 			if it != nil {
 				it.Release()
@@ -99,9 +99,9 @@ func upgradeDeduplicateData(db datxdb.Database) func() error {
 			// avoid too high memory consumption.
 			converted++
 			if converted%100000 == 0 {
+				key = common.CopyBytes(key)
 				it.Release()
-				it = db.(*datxdb.LDBDatabase).NewIterator()
-				it.Seek(key)
+				it = db.NewIteratorWithPrefix(nil, key)
 
 				log.Info("Deduplicating database entries", "deduped", converted)
 			}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/DATxChain-Protocol/DATx/common"
 	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core"
 	"github.com/DATxChain-Protocol/DATx/core/types"
 	"github.com/DATxChain-Protocol/DATx/datxdb"
 	"github.com/DATxChain-Protocol/DATx/event"
@@ -163,6 +164,45 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// ReplacedTransaction is the notification of a pooled transaction replaced by
+// another one of the same sender and nonce.
+type ReplacedTransaction struct {
+	Hash       common.Hash `json:"hash"`
+	ReplacedBy common.Hash `json:"replacedBy"`
+}
+
+// NewReplacedTransactions creates a subscription that is triggered each time a
+// transaction in the transaction pool is replaced by another one of the same
+// sender and nonce, paying more gas.
+func (api *PublicFilterAPI) NewReplacedTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		replaced := make(chan core.TxReplacedEvent)
+		replacedSub := api.events.SubscribeReplacedTxEvents(replaced)
+
+		for {
+			select {
+			case ev := <-replaced:
+				notifier.Notify(rpcSub.ID, &ReplacedTransaction{Hash: ev.Old.Hash(), ReplacedBy: ev.New.Hash()})
+			case <-rpcSub.Err():
+				replacedSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				replacedSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with datx_getFilterChanges.
 //
@@ -50,6 +50,9 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// ReplacedTransactionsSubscription queries pooled transactions replaced by
+	// others of the same nonce
+	ReplacedTransactionsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -59,6 +62,8 @@ const (
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+	// replacedChanSize is the size of channel listening to TxReplacedEvent.
+	replacedChanSize = 256
 	// rmLogsChanSize is the size of channel listening to RemovedLogsEvent.
 	rmLogsChanSize = 10
 	// logsChanSize is the size of channel listening to LogsEvent.
@@ -79,6 +84,7 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan common.Hash
 	headers   chan *types.Header
+	replaced  chan core.TxReplacedEvent
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -142,6 +148,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.replaced:
 			}
 		}
 
@@ -209,6 +216,7 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit FilterCriteria, logs chan
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		replaced:  make(chan core.TxReplacedEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -226,6 +234,7 @@ func (es *EventSystem) subscribeLogs(crit FilterCriteria, logs chan []*types.Log
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		replaced:  make(chan core.TxReplacedEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -243,6 +252,7 @@ func (es *EventSystem) subscribePendingLogs(crit FilterCriteria, logs chan []*ty
 		logs:      logs,
 		hashes:    make(chan common.Hash),
 		headers:   make(chan *types.Header),
+		replaced:  make(chan core.TxReplacedEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -259,6 +269,7 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan common.Hash),
 		headers:   headers,
+		replaced:  make(chan core.TxReplacedEvent),
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -275,6 +286,24 @@ func (es *EventSystem) SubscribePendingTxEvents(hashes chan common.Hash) *Subscr
 		logs:      make(chan []*types.Log),
 		hashes:    hashes,
 		headers:   make(chan *types.Header),
+		replaced:  make(chan core.TxReplacedEvent),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeReplacedTxEvents creates a subscription that writes the pooled
+// transactions replaced by others of the same nonce.
+func (es *EventSystem) SubscribeReplacedTxEvents(replaced chan core.TxReplacedEvent) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       ReplacedTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		headers:   make(chan *types.Header),
+		replaced:  replaced,
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
@@ -319,6 +348,10 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[PendingTransactionsSubscription] {
 			f.hashes <- e.Tx.Hash()
 		}
+	case core.TxReplacedEvent:
+		for _, f := range filters[ReplacedTransactionsSubscription] {
+			f.replaced <- e
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
@@ -400,6 +433,9 @@ func (es *EventSystem) eventLoop() {
 		// Subscribe TxPreEvent form txpool
 		txCh  = make(chan core.TxPreEvent, txChanSize)
 		txSub = es.backend.SubscribeTxPreEvent(txCh)
+		// Subscribe TxReplacedEvent from txpool
+		replacedCh  = make(chan core.TxReplacedEvent, replacedChanSize)
+		replacedSub = es.backend.SubscribeTxReplacedEvent(replacedCh)
 		// Subscribe RemovedLogsEvent
 		rmLogsCh  = make(chan core.RemovedLogsEvent, rmLogsChanSize)
 		rmLogsSub = es.backend.SubscribeRemovedLogsEvent(rmLogsCh)
@@ -414,6 +450,7 @@ func (es *EventSystem) eventLoop() {
 	// Unsubscribe all events
 	defer sub.Unsubscribe()
 	defer txSub.Unsubscribe()
+	defer replacedSub.Unsubscribe()
 	defer rmLogsSub.Unsubscribe()
 	defer logsSub.Unsubscribe()
 	defer chainEvSub.Unsubscribe()
@@ -433,6 +470,8 @@ func (es *EventSystem) eventLoop() {
 		// Handle subscribed events
 		case ev := <-txCh:
 			es.broadcast(index, ev)
+		case ev := <-replacedCh:
+			es.broadcast(index, ev)
 		case ev := <-rmLogsCh:
 			es.broadcast(index, ev)
 		case ev := <-logsCh:
@@ -462,6 +501,8 @@ func (es *EventSystem) eventLoop() {
 		// System stopped
 		case <-txSub.Err():
 			return
+		case <-replacedSub.Err():
+			return
 		case <-rmLogsSub.Err():
 			return
 		case <-logsSub.Err():
@@ -43,6 +43,7 @@ type testBackend struct {
 	rmLogsFeed *event.Feed
 	logsFeed   *event.Feed
 	chainFeed  *event.Feed
+	replFeed   *event.Feed
 }
 
 func (b *testBackend) ChainDb() datxdb.Database {
@@ -75,6 +76,10 @@ func (b *testBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subsc
 	return b.txFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeTxReplacedEvent(ch chan<- core.TxReplacedEvent) event.Subscription {
+	return b.replFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.rmLogsFeed.Subscribe(ch)
 }
@@ -133,7 +138,7 @@ func TestBlockSubscription(t *testing.T) {
 		rmLogsFeed  = new(event.Feed)
 		logsFeed    = new(event.Feed)
 		chainFeed   = new(event.Feed)
-		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api         = NewPublicFilterAPI(backend, false)
 		genesis     = new(core.Genesis).MustCommit(db)
 		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, db, 10, func(i int, gen *core.BlockGen) {})
@@ -190,7 +195,7 @@ func TestPendingTxFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		transactions = []*types.Transaction{
@@ -243,6 +248,54 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestReplacedTxSubscription tests whether a replaced transactions subscription
+// receives the replacements posted by the transaction pool.
+func TestReplacedTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux        = new(event.TypeMux)
+		db, _      = datxdb.NewMemDatabase()
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		replFeed   = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, replFeed}
+		api        = NewPublicFilterAPI(backend, false)
+
+		to   = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		olds = []*types.Transaction{
+			types.NewTransaction(types.Binary, 0, to, new(big.Int), new(big.Int), big.NewInt(1), nil),
+			types.NewTransaction(types.Binary, 1, to, new(big.Int), new(big.Int), big.NewInt(1), nil),
+		}
+		news = []*types.Transaction{
+			types.NewTransaction(types.Binary, 0, to, new(big.Int), new(big.Int), big.NewInt(2), nil),
+			types.NewTransaction(types.Binary, 1, to, new(big.Int), new(big.Int), big.NewInt(2), nil),
+		}
+	)
+
+	replaced := make(chan core.TxReplacedEvent)
+	sub := api.events.SubscribeReplacedTxEvents(replaced)
+	defer sub.Unsubscribe()
+
+	go func() {
+		for i := range olds {
+			replFeed.Send(core.TxReplacedEvent{Old: olds[i], New: news[i]})
+		}
+	}()
+	for i := range olds {
+		select {
+		case ev := <-replaced:
+			if ev.Old.Hash() != olds[i].Hash() || ev.New.Hash() != news[i].Hash() {
+				t.Errorf("replacement %d mismatch: have %x -> %x, want %x -> %x", i, ev.Old.Hash(), ev.New.Hash(), olds[i].Hash(), news[i].Hash())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("replacement %d not received", i)
+		}
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
@@ -253,7 +306,7 @@ func TestLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		testCases = []struct {
@@ -302,7 +355,7 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 	)
 
@@ -332,7 +385,7 @@ func TestLogFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
@@ -451,7 +504,7 @@ func TestPendingLogsSubscription(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
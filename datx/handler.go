@@ -28,6 +28,7 @@ import (
 
 	"github.com/DATxChain-Protocol/DATx/common"
 	"github.com/DATxChain-Protocol/DATx/consensus"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
 	"github.com/DATxChain-Protocol/DATx/consensus/misc"
 	"github.com/DATxChain-Protocol/DATx/core"
 	"github.com/DATxChain-Protocol/DATx/core/types"
@@ -70,6 +71,7 @@ type ProtocolManager struct {
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
+	engine      consensus.Engine
 	blockchain  *core.BlockChain
 	chaindb     datxdb.Database
 	chainconfig *params.ChainConfig
@@ -105,6 +107,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		networkId:   networkId,
 		eventMux:    mux,
 		txpool:      txpool,
+		engine:      engine,
 		blockchain:  blockchain,
 		chaindb:     chaindb,
 		chainconfig: config,
@@ -626,6 +629,11 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		request.Block.ReceivedAt = msg.ReceivedAt
 		request.Block.ReceivedFrom = p
 
+		// Measure the local clock against the validator's from the block arrival
+		if engine, ok := pm.engine.(*dpos.Dpos); ok {
+			engine.ObserveBlock(pm.blockchain, request.Block.Header())
+		}
+
 		// Mark the peer as owning the block and schedule it for import
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
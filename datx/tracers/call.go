@@ -0,0 +1,250 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+)
+
+var (
+	errExecutionReverted = errors.New("execution reverted")
+	errInternalFailure   = errors.New("internal failure")
+)
+
+// callFrame is a single call of the call tree of a message.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas,omitempty"`
+	GasUsed hexutil.Uint64  `json:"gasUsed,omitempty"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64   // Gas of the caller before the call
+	gasCost uint64   // Cost of the call opcode
+	entered bool     // Whether the callee ran code, setting Gas
+	outOff  *big.Int // Memory offset of the output of the call
+	outLen  *big.Int // Memory size of the output of the call
+}
+
+// callTracer reconstructs the call tree of a message from the EVM steps. Calls
+// are entered at the call opcodes and exited at the first step back in the
+// caller. Calls into precompiled contracts are left out.
+type callTracer struct {
+	callstack []*callFrame // Open calls, the message itself first
+	descended bool         // Whether the last step entered a call
+}
+
+// newCallTracer creates a tracer of the call tree of a message.
+func newCallTracer() Tracer {
+	return &callTracer{}
+}
+
+// CaptureStart opens the call of the message itself.
+func (t *callTracer) CaptureStart(env *vm.EVM, msg core.Message) error {
+	frame := &callFrame{
+		Type:  vm.CALL.String(),
+		From:  msg.From(),
+		To:    msg.To(),
+		Value: (*hexutil.Big)(new(big.Int).Set(msg.Value())),
+		Gas:   hexutil.Uint64(msg.Gas().Uint64()),
+		Input: common.CopyBytes(msg.Data()),
+	}
+	if msg.To() == nil {
+		created := crypto.CreateAddress(msg.From(), env.StateDB.GetNonce(msg.From()))
+		frame.Type, frame.To = vm.CREATE.String(), &created
+	}
+	t.callstack = []*callFrame{frame}
+	return nil
+}
+
+// CaptureState opens and closes the inner calls, and records their failures.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if len(t.callstack) == 0 {
+		return nil
+	}
+	if err != nil {
+		t.fault(depth, err)
+		return nil
+	}
+	switch op {
+	case vm.CREATE:
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			Input:   memorySlice(memory, stack.Back(1), stack.Back(2)),
+			Value:   (*hexutil.Big)(new(big.Int).Set(stack.Back(0))),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		to := stackAddress(stack, 0)
+		t.addCall(&callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			To:      &to,
+			Value:   (*hexutil.Big)(env.StateDB.GetBalance(contract.Address())),
+			Gas:     hexutil.Uint64(gas),
+			GasUsed: hexutil.Uint64(cost),
+			Input:   []byte{},
+		})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		to := stackAddress(stack, 1)
+		if isPrecompiled(env, to) {
+			return nil
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		frame := &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			To:      &to,
+			Input:   memorySlice(memory, stack.Back(2+off), stack.Back(3+off)),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  new(big.Int).Set(stack.Back(4 + off)),
+			outLen:  new(big.Int).Set(stack.Back(5 + off)),
+		}
+		if off == 1 {
+			frame.Value = (*hexutil.Big)(new(big.Int).Set(stack.Back(2)))
+		}
+		t.callstack = append(t.callstack, frame)
+		t.descended = true
+		return nil
+	}
+	// If we've just entered an inner call, record the gas it was given
+	if t.descended {
+		if depth >= len(t.callstack) {
+			frame := t.callstack[len(t.callstack)-1]
+			frame.Gas, frame.entered = hexutil.Uint64(gas), true
+		}
+		t.descended = false
+	}
+	if op == vm.REVERT {
+		frame := t.callstack[len(t.callstack)-1]
+		frame.Error = errExecutionReverted.Error()
+		frame.Output = memorySlice(memory, stack.Back(0), stack.Back(1))
+		return nil
+	}
+	// If we've just returned from an inner call, close it
+	if depth == len(t.callstack)-1 {
+		frame := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		success := stack.Back(0).Sign() != 0
+		if frame.Type == vm.CREATE.String() {
+			frame.GasUsed = hexutil.Uint64(frame.gasIn - frame.gasCost - gas)
+			if success {
+				created := stackAddress(stack, 0)
+				frame.To, frame.Output = &created, env.StateDB.GetCode(created)
+			}
+		} else {
+			if frame.entered {
+				frame.GasUsed = hexutil.Uint64(frame.gasIn - frame.gasCost + uint64(frame.Gas) - gas)
+			}
+			if success {
+				frame.Output = memorySlice(memory, frame.outOff, frame.outLen)
+			}
+		}
+		if !success && frame.Error == "" {
+			frame.Error = errInternalFailure.Error()
+		}
+		t.addCall(frame)
+	}
+	return nil
+}
+
+// fault closes the call running at the given depth, failed with the given error.
+// All the gas it was given is consumed.
+func (t *callTracer) fault(depth int, err error) {
+	t.descended = false
+
+	// Close the inner calls that ended without a step back in the failed one
+	for len(t.callstack) > depth && len(t.callstack) > 1 {
+		frame := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+		t.addCall(frame)
+	}
+	frame := t.callstack[len(t.callstack)-1]
+	if frame.Error != "" {
+		return
+	}
+	frame.Error = err.Error()
+	if frame.entered {
+		frame.GasUsed = frame.Gas
+	}
+	if len(t.callstack) == 1 {
+		return
+	}
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	t.addCall(frame)
+}
+
+// addCall appends a closed call to the calls of the current one.
+func (t *callTracer) addCall(frame *callFrame) {
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, frame)
+}
+
+// CaptureEnd closes the call of the message.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	if len(t.callstack) == 0 {
+		return nil
+	}
+	frame := t.callstack[0]
+	frame.GasUsed = hexutil.Uint64(gasUsed)
+	if err != nil {
+		if frame.Error == "" {
+			frame.Error = err.Error()
+		}
+		if frame.Type == vm.CREATE.String() {
+			frame.To = nil
+		}
+	} else {
+		frame.Output = common.CopyBytes(output)
+	}
+	t.callstack = t.callstack[:1]
+	return nil
+}
+
+// GetResult returns the call tree of the message.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if len(t.callstack) == 0 {
+		return nil, errors.New("message not traced")
+	}
+	return json.Marshal(t.callstack[0])
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+)
+
+// fourByteTracer counts the 4 byte function selectors called by a message, keyed
+// by the selector and the size of the call data following it, since differently
+// sized arguments hint at selector collisions.
+type fourByteTracer struct {
+	ids map[string]int
+}
+
+// newFourByteTracer creates a tracer counting the selectors called by a message.
+func newFourByteTracer() Tracer {
+	return &fourByteTracer{ids: make(map[string]int)}
+}
+
+// store counts the selector of the given call data, if it has one.
+func (t *fourByteTracer) store(input []byte) {
+	if len(input) < 4 {
+		return
+	}
+	t.ids[fmt.Sprintf("%s-%d", hexutil.Encode(input[:4]), len(input)-4)]++
+}
+
+// CaptureStart counts the selector called by the message itself.
+func (t *fourByteTracer) CaptureStart(env *vm.EVM, msg core.Message) error {
+	if msg.To() != nil && !isPrecompiled(env, *msg.To()) {
+		t.store(msg.Data())
+	}
+	return nil
+}
+
+// CaptureState counts the selectors of the inner calls.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil {
+		return nil
+	}
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		if !isPrecompiled(env, stackAddress(stack, 1)) {
+			t.store(memorySlice(memory, stack.Back(3), stack.Back(4)))
+		}
+	case vm.DELEGATECALL, vm.STATICCALL:
+		if !isPrecompiled(env, stackAddress(stack, 1)) {
+			t.store(memorySlice(memory, stack.Back(2), stack.Back(3)))
+		}
+	}
+	return nil
+}
+
+// CaptureEnd is a no-op, the selectors are all counted by then.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the number of calls of every selector and call data size.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.ids)
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+)
+
+// prestateAccount is the part of an account touched by a message.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// stateDiff is the result of the prestate tracer in diff mode.
+type stateDiff struct {
+	Pre  map[common.Address]*prestateAccount `json:"pre"`
+	Post map[common.Address]*prestateAccount `json:"post"`
+}
+
+// prestateTracer collects the accounts and storage slots a message touches, as
+// they were before it was applied. In diff mode it returns only the accounts the
+// message modified, along with the fields they were modified to.
+type prestateTracer struct {
+	env  *vm.EVM
+	pre  map[common.Address]*prestateAccount
+	diff bool
+}
+
+// newPrestateTracer creates a tracer of the state touched by a message, or of the
+// changes it made to it in diff mode.
+func newPrestateTracer(diff bool) Tracer {
+	return &prestateTracer{
+		pre:  make(map[common.Address]*prestateAccount),
+		diff: diff,
+	}
+}
+
+// CaptureStart records the accounts of the sender, the recipient and the coinbase
+// before the message touches them.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, msg core.Message) error {
+	t.env = env
+
+	t.lookupAccount(msg.From())
+	t.lookupAccount(env.Coinbase)
+	if to := msg.To(); to != nil {
+		t.lookupAccount(*to)
+	} else {
+		t.lookupAccount(crypto.CreateAddress(msg.From(), env.StateDB.GetNonce(msg.From())))
+	}
+	return nil
+}
+
+// CaptureState records the accounts and storage slots touched by the step before
+// it runs.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.env == nil || err != nil {
+		return nil
+	}
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		t.lookupStorage(contract.Address(), common.BigToHash(stack.Back(0)))
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.SELFDESTRUCT:
+		t.lookupAccount(stackAddress(stack, 0))
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(stackAddress(stack, 1))
+	case vm.CREATE:
+		t.lookupAccount(crypto.CreateAddress(contract.Address(), env.StateDB.GetNonce(contract.Address())))
+	}
+	return nil
+}
+
+// CaptureEnd is a no-op, the state is compared at the end when diffing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the touched accounts, or the diff of the modified ones.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.env == nil {
+		return nil, errors.New("message not traced")
+	}
+	if !t.diff {
+		return json.Marshal(t.pre)
+	}
+	result := &stateDiff{
+		Pre:  make(map[common.Address]*prestateAccount),
+		Post: make(map[common.Address]*prestateAccount),
+	}
+	db := t.env.StateDB
+	for addr, pre := range t.pre {
+		// Deleted accounts only have a pre state
+		if db.HasSuicided(addr) {
+			result.Pre[addr] = pre
+			continue
+		}
+		var (
+			post     = new(prestateAccount)
+			modified bool
+		)
+		if balance := db.GetBalance(addr); balance.Cmp(pre.Balance.ToInt()) != 0 {
+			post.Balance, modified = (*hexutil.Big)(new(big.Int).Set(balance)), true
+		}
+		if nonce := db.GetNonce(addr); nonce != pre.Nonce {
+			post.Nonce, modified = nonce, true
+		}
+		if code := db.GetCode(addr); !bytes.Equal(code, pre.Code) {
+			post.Code, modified = common.CopyBytes(code), true
+		}
+		for key, value := range pre.Storage {
+			if current := db.GetState(addr, key); current != value {
+				if post.Storage == nil {
+					post.Storage = make(map[common.Hash]common.Hash)
+				}
+				post.Storage[key], modified = current, true
+			}
+		}
+		if modified {
+			result.Pre[addr], result.Post[addr] = pre, post
+		}
+	}
+	return json.Marshal(result)
+}
+
+// lookupAccount records an account unless it was already.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	db := t.env.StateDB
+	t.pre[addr] = &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(db.GetBalance(addr))),
+		Nonce:   db.GetNonce(addr),
+		Code:    common.CopyBytes(db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage records a storage slot of an account unless it was already.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].Storage[key]; ok {
+		return
+	}
+	t.pre[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracers implements the built-in transaction tracers, which run natively
+// instead of interpreting user supplied JavaScript.
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+)
+
+// Tracer is a native tracer of the execution of a single message. On top of the
+// EVM steps it's told about the start and the end of the message, which the EVM
+// itself doesn't report.
+type Tracer interface {
+	vm.Tracer
+
+	// CaptureStart is called with the message before it's applied to the state
+	// of the given EVM.
+	CaptureStart(env *vm.EVM, msg core.Message) error
+
+	// GetResult returns the JSON result of the trace once the message ended.
+	GetResult() (json.RawMessage, error)
+}
+
+// natives are the constructors of the native tracers by name.
+var natives = map[string]func() Tracer{
+	"callTracer":      newCallTracer,
+	"prestateTracer":  func() Tracer { return newPrestateTracer(false) },
+	"stateDiffTracer": func() Tracer { return newPrestateTracer(true) },
+	"4byteTracer":     newFourByteTracer,
+}
+
+// New creates the native tracer of the given name, returning false if there's
+// no native tracer called so.
+func New(name string) (Tracer, bool) {
+	constructor, ok := natives[name]
+	if !ok {
+		return nil, false
+	}
+	return constructor(), true
+}
+
+// Names returns the sorted names of the native tracers.
+func Names() []string {
+	names := make([]string, 0, len(natives))
+	for name := range natives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isPrecompiled reports whether an address is a precompiled contract under the
+// rules of the EVM.
+func isPrecompiled(env *vm.EVM, addr common.Address) bool {
+	precompiles := vm.PrecompiledContractsHomestead
+	if env.ChainConfig().IsByzantium(env.BlockNumber) {
+		precompiles = vm.PrecompiledContractsByzantium
+	}
+	_, ok := precompiles[addr]
+	return ok
+}
+
+// memorySlice returns a copy of a region of the EVM memory, clipped to the part
+// of it that's allocated.
+func memorySlice(memory *vm.Memory, offset, size *big.Int) []byte {
+	if !offset.IsUint64() || !size.IsUint64() {
+		return nil
+	}
+	start, end := offset.Uint64(), offset.Uint64()+size.Uint64()
+	if end < start || end > uint64(memory.Len()) {
+		return nil
+	}
+	return common.CopyBytes(memory.Data()[start:end])
+}
+
+// stackAddress returns the n-th item from the top of the stack as an address.
+func stackAddress(stack *vm.Stack, n int) common.Address {
+	return common.BigToAddress(stack.Back(n))
+}
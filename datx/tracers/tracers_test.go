@@ -0,0 +1,157 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+var (
+	tracerSender   = common.HexToAddress("0x00000000000000000000000000000000000000f0")
+	tracerCoinbase = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+
+	// tracerCaller calls tracerCallee with a value and a selector, then with call
+	// data making it revert, then the identity precompile.
+	tracerCaller     = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	tracerCallerCode = common.FromHex("6312345678600052" + // MSTORE(0, 0x12345678)
+		"602060206004601c600160bb61fffff150" + // CALL(0xffff, 0xbb, 1, 28, 4, 32, 32)
+		"60ff604053" + // MSTORE8(64, 0xff)
+		"6000600060016040600060bb61fffff150" + // CALL(0xffff, 0xbb, 0, 64, 1, 0, 0)
+		"600060006004601c600461fffffa50" + // STATICCALL(0xffff, 0x04, 28, 4, 0, 0)
+		"00")
+
+	// tracerCallee reverts if the first byte of its call data is 0xff, otherwise
+	// stores 42 in slot 0 and returns the call value.
+	tracerCallee     = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	tracerCalleeCode = common.FromHex("60003560001a60ff14601a57" + // JUMPI(0x1a, BYTE(0, CALLDATALOAD(0)) == 0xff)
+		"602a600055" + // SSTORE(0, 42)
+		"3460005260206000f3" + // RETURN(CALLVALUE)
+		"5b600080fd") // REVERT(0, 0)
+
+	// tracerSuicide sends its balance to 0xdd when called.
+	tracerSuicide     = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	tracerSuicideCode = common.FromHex("60ddff")
+
+	// tracerInitCode calls tracerSuicide, then tracerCallee with too little gas,
+	// and deploys a single STOP.
+	tracerInitCode = common.FromHex("6000600060006000600060cc61fffff150" + // CALL(0xffff, 0xcc, 0, 0, 0, 0, 0)
+		"6000600060006000600060bb6064f150" + // CALL(100, 0xbb, 0, 0, 0, 0, 0)
+		"60016000f3") // RETURN(0, 1)
+)
+
+// tracerCases are the messages the tracers are tested on, by golden file suffix.
+var tracerCases = map[string]struct {
+	to    *common.Address
+	value int64
+	data  []byte
+}{
+	"call":   {to: &tracerCaller, data: common.FromHex("0xdeadbeef00000000")},
+	"create": {value: 7, data: tracerInitCode},
+}
+
+// traceMessage applies a test message on top of the test state under a tracer.
+func traceMessage(t *testing.T, tracer Tracer, to *common.Address, value int64, data []byte) json.RawMessage {
+	db, _ := datxdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.SetBalance(tracerSender, big.NewInt(1000000000))
+	statedb.SetNonce(tracerSender, 3)
+	statedb.SetBalance(tracerCaller, big.NewInt(10))
+	statedb.SetCode(tracerCaller, tracerCallerCode)
+	statedb.SetCode(tracerCallee, tracerCalleeCode)
+	statedb.SetBalance(tracerSuicide, big.NewInt(5))
+	statedb.SetCode(tracerSuicide, tracerSuicideCode)
+
+	msg := types.NewMessage(tracerSender, to, 3, big.NewInt(value), big.NewInt(200000), big.NewInt(2), data, false)
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      tracerSender,
+		Coinbase:    tracerCoinbase,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+		GasLimit:    big.NewInt(1000000),
+		GasPrice:    big.NewInt(2),
+	}
+	env := vm.NewEVM(context, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if err := tracer.CaptureStart(env, msg); err != nil {
+		t.Fatalf("failed to start trace: %v", err)
+	}
+	ret, gas, failed, err := core.ApplyMessage(env, msg, new(core.GasPool).AddGas(big.NewInt(1000000)))
+	if err != nil {
+		t.Fatalf("failed to apply message: %v", err)
+	}
+	var vmerr error
+	if failed {
+		vmerr = errInternalFailure
+	}
+	if err := tracer.CaptureEnd(ret, gas.Uint64(), 0, vmerr); err != nil {
+		t.Fatalf("failed to end trace: %v", err)
+	}
+	result, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to get trace result: %v", err)
+	}
+	return result
+}
+
+// Tests that the native tracers produce the results of their golden files.
+func TestNativeTracers(t *testing.T) {
+	for _, name := range Names() {
+		for suffix, msg := range tracerCases {
+			tracer, _ := New(name)
+			have := new(bytes.Buffer)
+			if err := json.Indent(have, traceMessage(t, tracer, msg.to, msg.value, msg.data), "", "  "); err != nil {
+				t.Fatalf("%s/%s: invalid result: %v", name, suffix, err)
+			}
+			golden := filepath.Join("testdata", name+"_"+suffix+".json")
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s/%s: failed to read golden file: %v", name, suffix, err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(have.Bytes()), bytes.TrimSpace(want)) {
+				t.Errorf("%s/%s: result mismatch\nhave:\n%s\nwant:\n%s", name, suffix, have, want)
+			}
+		}
+	}
+}
+
+// Tests that tracers are created by name, and unknown ones are left to the
+// JavaScript tracer.
+func TestNewTracer(t *testing.T) {
+	for _, name := range []string{"callTracer", "prestateTracer", "stateDiffTracer", "4byteTracer"} {
+		if _, ok := New(name); !ok {
+			t.Errorf("native tracer %q missing", name)
+		}
+	}
+	if _, ok := New("{step: function() {}, result: function() {}}"); ok {
+		t.Errorf("JavaScript tracer resolved as native")
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datxdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/metrics"
+	"github.com/coreos/bbolt"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// boltFileName is the name of the data file within a bolt database directory.
+const boltFileName = "bolt.db"
+
+var (
+	// boltBucket is the single bucket holding all the entries of a database.
+	boltBucket = []byte("datx")
+
+	// boltKeyPrefix is prepended to every key stored in bolt, which does not
+	// allow empty keys.
+	boltKeyPrefix = []byte{0x00}
+
+	// errBoltNotFound is returned if a requested key is not in the database.
+	errBoltNotFound = errors.New("not found")
+)
+
+// BoltDatabase is a persistent database backed by a single bolt B+tree file. It
+// trades the write throughput of LevelDB for a simpler on-disk layout with no
+// background compaction. Every write transaction is synced to disk, making
+// single writes about two orders of magnitude slower than LevelDB's, see the
+// benchmarks of the datxdb package.
+type BoltDatabase struct {
+	fn string   // filename for reporting
+	db *bolt.DB // bolt instance
+
+	pending    []*boltWrite // Single writes waiting for the next shared transaction
+	committing bool         // Whether a writer is committing the pending writes
+	writeLock  sync.Mutex   // Protects the pending writes
+
+	getTimer   gometrics.Timer // Timer for measuring the database get request counts and latencies
+	putTimer   gometrics.Timer // Timer for measuring the database put request counts and latencies
+	delTimer   gometrics.Timer // Timer for measuring the database delete request counts and latencies
+	missMeter  gometrics.Meter // Meter for measuring the missed database get requests
+	readMeter  gometrics.Meter // Meter for measuring the database get request data usage
+	writeMeter gometrics.Meter // Meter for measuring the database put request data usage
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewBoltDatabase opens or creates a bolt database in the given directory. The
+// cache and file handle allowances are not used, bolt relies on the operating
+// system's page cache instead.
+func NewBoltDatabase(file string, cache int, handles int) (*BoltDatabase, error) {
+	logger := log.New("database", file)
+
+	if err := os.MkdirAll(file, 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(file, boltFileName), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Info("Opened bolt database")
+
+	return &BoltDatabase{
+		fn:  file,
+		db:  db,
+		log: logger,
+	}, nil
+}
+
+// boltKey converts a database key into the key stored in bolt.
+func boltKey(key []byte) []byte {
+	return append(append(make([]byte, 0, len(boltKeyPrefix)+len(key)), boltKeyPrefix...), key...)
+}
+
+// Path returns the path to the database directory.
+func (db *BoltDatabase) Path() string {
+	return db.fn
+}
+
+// Put inserts the given key / value into the database.
+//
+// Every bolt transaction is synced to disk, so single writes made concurrently
+// share a transaction, see write. Many writes should still be made through a
+// batch instead.
+func (db *BoltDatabase) Put(key []byte, value []byte) error {
+	if db.putTimer != nil {
+		defer db.putTimer.UpdateSince(time.Now())
+	}
+	if db.writeMeter != nil {
+		db.writeMeter.Mark(int64(len(value)))
+	}
+	value = common.CopyBytes(value)
+	return db.write(func(bucket *bolt.Bucket) error {
+		return bucket.Put(boltKey(key), value)
+	})
+}
+
+// Has checks whether the given key is present in the database.
+func (db *BoltDatabase) Has(key []byte) (bool, error) {
+	var found bool
+	err := db.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(boltBucket).Get(boltKey(key)) != nil
+		return nil
+	})
+	return found, err
+}
+
+// Get returns the given key if it's present.
+func (db *BoltDatabase) Get(key []byte) ([]byte, error) {
+	if db.getTimer != nil {
+		defer db.getTimer.UpdateSince(time.Now())
+	}
+	var value []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		// Values are only valid within the transaction, copy them out
+		if data := tx.Bucket(boltBucket).Get(boltKey(key)); data != nil {
+			value = common.CopyBytes(data)
+			return nil
+		}
+		return errBoltNotFound
+	})
+	if err != nil {
+		if db.missMeter != nil {
+			db.missMeter.Mark(1)
+		}
+		return nil, err
+	}
+	if db.readMeter != nil {
+		db.readMeter.Mark(int64(len(value)))
+	}
+	return value, nil
+}
+
+// Delete deletes the key from the database, sharing a transaction with the
+// concurrent single writes like Put.
+func (db *BoltDatabase) Delete(key []byte) error {
+	if db.delTimer != nil {
+		defer db.delTimer.UpdateSince(time.Now())
+	}
+	return db.write(func(bucket *bolt.Bucket) error {
+		return bucket.Delete(boltKey(key))
+	})
+}
+
+// boltWrite is a single write waiting for a shared transaction.
+type boltWrite struct {
+	apply func(bucket *bolt.Bucket) error
+	done  chan error
+}
+
+// write applies a single write to the database. If no other write is being
+// committed, it's committed right away. Otherwise it waits for the commit and
+// is committed along with all the writes made in the meantime, so concurrent
+// writers share the cost of a disk sync without delaying a lone one.
+//
+// If the shared transaction fails, its writes are retried one by one so a bad
+// write only fails its own caller.
+func (db *BoltDatabase) write(apply func(bucket *bolt.Bucket) error) error {
+	call := &boltWrite{apply: apply, done: make(chan error, 1)}
+
+	db.writeLock.Lock()
+	db.pending = append(db.pending, call)
+	if db.committing {
+		db.writeLock.Unlock()
+		return <-call.done
+	}
+	db.committing = true
+	for len(db.pending) > 0 {
+		calls := db.pending
+		db.pending = nil
+		db.writeLock.Unlock()
+
+		err := db.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(boltBucket)
+			for _, call := range calls {
+				if err := call.apply(bucket); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		for _, call := range calls {
+			if err != nil && len(calls) > 1 {
+				call.done <- db.db.Update(func(tx *bolt.Tx) error { return call.apply(tx.Bucket(boltBucket)) })
+				continue
+			}
+			call.done <- err
+		}
+		db.writeLock.Lock()
+	}
+	db.committing = false
+	db.writeLock.Unlock()
+
+	return <-call.done
+}
+
+// NewIteratorWithPrefix implements Iteratee, iterating over the entries whose
+// keys start with prefix, from prefix followed by start.
+//
+// The iterator reads the entries a page at a time, each in a read transaction
+// of its own, so writes made while iterating never wait on the iteration. The
+// entries are as such not a consistent snapshot of the database.
+func (db *BoltDatabase) NewIteratorWithPrefix(prefix []byte, start []byte) Iterator {
+	return &boltIterator{
+		db:     db.db,
+		prefix: boltKey(prefix),
+		next:   boltKey(append(common.CopyBytes(prefix), start...)),
+	}
+}
+
+// Compact is a noop, bolt reuses the pages of deleted data instead of
+// compacting them.
+func (db *BoltDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// Stat returns a particular internal stat of the database. The only supported
+// property is "boltdb.stats".
+func (db *BoltDatabase) Stat(property string) (string, error) {
+	if property != "boltdb.stats" {
+		return "", errors.New("unknown property")
+	}
+	var (
+		stats = db.db.Stats()
+		size  int64
+	)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("File size: %v\nFree pages: %d\nPending pages: %d\nFree allocated: %v\nRead transactions: %d\n",
+		common.StorageSize(size), stats.FreePageN, stats.PendingPageN, common.StorageSize(stats.FreeAlloc), stats.TxN), nil
+}
+
+// Meter configures the metrics collectors of the database requests. Bolt has no
+// background compaction, so unlike LevelDB there are no internal stats to poll.
+func (db *BoltDatabase) Meter(prefix string) {
+	if !metrics.Enabled {
+		return
+	}
+	db.getTimer = metrics.NewTimer(prefix + "user/gets")
+	db.putTimer = metrics.NewTimer(prefix + "user/puts")
+	db.delTimer = metrics.NewTimer(prefix + "user/dels")
+	db.missMeter = metrics.NewMeter(prefix + "user/misses")
+	db.readMeter = metrics.NewMeter(prefix + "user/reads")
+	db.writeMeter = metrics.NewMeter(prefix + "user/writes")
+}
+
+// Close closes the database file.
+func (db *BoltDatabase) Close() {
+	if err := db.db.Close(); err != nil {
+		db.log.Error("Failed to close database", "err", err)
+	} else {
+		db.log.Info("Database closed")
+	}
+}
+
+// NewBatch creates a batch collecting writes until committed in a single bolt
+// transaction.
+func (db *BoltDatabase) NewBatch() Batch {
+	return &boltBatch{db: db.db}
+}
+
+type boltBatch struct {
+	db     *bolt.DB
+	writes []kv
+	size   int
+}
+
+func (b *boltBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{boltKey(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *boltBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{boltKey(key), nil, true})
+	b.size += len(key)
+	return nil
+}
+
+func (b *boltBatch) Write() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, kv := range b.writes {
+			var err error
+			if kv.del {
+				err = bucket.Delete(kv.k)
+			} else {
+				err = bucket.Put(kv.k, kv.v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *boltBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// boltIteratorPage is the number of entries a bolt iterator reads per read
+// transaction.
+const boltIteratorPage = 1024
+
+// boltIterator iterates over the entries of a bolt database, copying them out a
+// page at a time.
+type boltIterator struct {
+	db     *bolt.DB
+	prefix []byte
+	next   []byte // Key to resume the iteration from, nil once exhausted
+
+	page []kv // Entries of the current page
+	pos  int  // Position of the current entry in the page, plus one
+	err  error
+}
+
+func (it *boltIterator) Next() bool {
+	if it.pos < len(it.page) {
+		it.pos++
+		return true
+	}
+	if it.next == nil || it.err != nil {
+		it.page, it.pos = nil, 0
+		return false
+	}
+	it.page, it.pos = it.page[:0], 0
+	it.err = it.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltBucket).Cursor()
+		for key, value := cursor.Seek(it.next); ; key, value = cursor.Next() {
+			if key == nil || !bytes.HasPrefix(key, it.prefix) {
+				it.next = nil
+				return nil
+			}
+			if len(it.page) == boltIteratorPage {
+				it.next = common.CopyBytes(key)
+				return nil
+			}
+			it.page = append(it.page, kv{k: common.CopyBytes(key[len(boltKeyPrefix):]), v: common.CopyBytes(value)})
+		}
+	})
+	if it.err != nil || len(it.page) == 0 {
+		it.page = nil
+		return false
+	}
+	it.pos = 1
+	return true
+}
+
+func (it *boltIterator) Error() error {
+	return it.err
+}
+
+func (it *boltIterator) Key() []byte {
+	if it.pos == 0 {
+		return nil
+	}
+	return it.page[it.pos-1].k
+}
+
+func (it *boltIterator) Value() []byte {
+	if it.pos == 0 {
+		return nil
+	}
+	return it.page[it.pos-1].v
+}
+
+func (it *boltIterator) Release() {
+	it.page, it.pos, it.next = nil, 0, nil
+}
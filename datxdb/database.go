@@ -29,6 +29,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	gometrics "github.com/rcrowley/go-metrics"
 )
@@ -149,6 +150,25 @@ func (db *LDBDatabase) NewIterator() iterator.Iterator {
 	return db.db.NewIterator(nil, nil)
 }
 
+// NewIteratorWithPrefix implements Iteratee, iterating over the entries whose
+// keys start with prefix, from prefix followed by start.
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte, start []byte) Iterator {
+	r := util.BytesPrefix(prefix)
+	r.Start = append(r.Start, start...)
+	return db.db.NewIterator(r, nil)
+}
+
+// Compact flattens the underlying data store for the given key range.
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
+// Stat returns a particular internal stat of the database, the property being
+// one of the leveldb properties (e.g. "leveldb.stats").
+func (db *LDBDatabase) Stat(property string) (string, error) {
+	return db.db.GetProperty(property)
+}
+
 func (db *LDBDatabase) Close() {
 	// Stop the metrics collection to avoid internal database races
 	db.quitLock.Lock()
@@ -291,6 +311,12 @@ func (b *ldbBatch) Put(key, value []byte) error {
 	return nil
 }
 
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	b.size += len(key)
+	return nil
+}
+
 func (b *ldbBatch) Write() error {
 	return b.db.Write(b.b, nil)
 }
@@ -299,6 +325,11 @@ func (b *ldbBatch) ValueSize() int {
 	return b.size
 }
 
+func (b *ldbBatch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
+
 type table struct {
 	db     Database
 	prefix string
@@ -329,10 +360,61 @@ func (dt *table) Delete(key []byte) error {
 	return dt.db.Delete(append([]byte(dt.prefix), key...))
 }
 
+func (dt *table) NewIteratorWithPrefix(prefix []byte, start []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...), start),
+		prefix: len(dt.prefix),
+	}
+}
+
+func (dt *table) Compact(start []byte, limit []byte) error {
+	// Stay within the table even if the range is open
+	if limit == nil {
+		limit = util.BytesPrefix([]byte(dt.prefix)).Limit
+	} else {
+		limit = append([]byte(dt.prefix), limit...)
+	}
+	return dt.db.Compact(append([]byte(dt.prefix), start...), limit)
+}
+
+func (dt *table) Stat(property string) (string, error) {
+	return dt.db.Stat(property)
+}
+
 func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+// tableIterator iterates over the entries of a table, stripping the table
+// prefix from the keys.
+type tableIterator struct {
+	it     Iterator
+	prefix int
+}
+
+func (it *tableIterator) Next() bool {
+	return it.it.Next()
+}
+
+func (it *tableIterator) Error() error {
+	return it.it.Error()
+}
+
+func (it *tableIterator) Key() []byte {
+	if key := it.it.Key(); key != nil {
+		return key[it.prefix:]
+	}
+	return nil
+}
+
+func (it *tableIterator) Value() []byte {
+	return it.it.Value()
+}
+
+func (it *tableIterator) Release() {
+	it.it.Release()
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
@@ -351,6 +433,10 @@ func (tb *tableBatch) Put(key, value []byte) error {
 	return tb.batch.Put(append([]byte(tb.prefix), key...), value)
 }
 
+func (tb *tableBatch) Delete(key []byte) error {
+	return tb.batch.Delete(append([]byte(tb.prefix), key...))
+}
+
 func (tb *tableBatch) Write() error {
 	return tb.batch.Write()
 }
@@ -358,3 +444,7 @@ func (tb *tableBatch) Write() error {
 func (tb *tableBatch) ValueSize() int {
 	return tb.batch.ValueSize()
 }
+
+func (tb *tableBatch) Reset() {
+	tb.batch.Reset()
+}
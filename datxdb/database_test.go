@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/datxdb/dbtest"
 )
 
 func newTestLDB() (*datxdb.LDBDatabase, func()) {
@@ -44,6 +45,71 @@ func newTestLDB() (*datxdb.LDBDatabase, func()) {
 	}
 }
 
+func newTestBoltDB() (*datxdb.BoltDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "datxdb_test_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := datxdb.NewBoltDatabase(dirname, 0, 0)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+func TestLDB_Suite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() (datxdb.Database, func()) {
+		return newTestLDB()
+	})
+}
+
+func TestMemoryDB_Suite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() (datxdb.Database, func()) {
+		db, _ := datxdb.NewMemDatabase()
+		return db, func() {}
+	})
+}
+
+func TestBoltDB_Suite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() (datxdb.Database, func()) {
+		return newTestBoltDB()
+	})
+}
+
+func BenchmarkLDB_Suite(b *testing.B) {
+	dbtest.BenchDatabaseSuite(b, func() (datxdb.Database, func()) {
+		return newTestLDB()
+	})
+}
+
+func BenchmarkBoltDB_Suite(b *testing.B) {
+	dbtest.BenchDatabaseSuite(b, func() (datxdb.Database, func()) {
+		return newTestBoltDB()
+	})
+}
+
+func TestFreezerDB_Suite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() (datxdb.Database, func()) {
+		dirname, err := ioutil.TempDir(os.TempDir(), "datxdb_test_test_")
+		if err != nil {
+			panic("failed to create test file: " + err.Error())
+		}
+		kvdb, _ := datxdb.NewMemDatabase()
+		db, err := datxdb.NewDatabaseWithFreezer(kvdb, dirname)
+		if err != nil {
+			panic("failed to create test database: " + err.Error())
+		}
+		return db, func() {
+			db.Close()
+			os.RemoveAll(dirname)
+		}
+	})
+}
+
 var test_values = []string{"", "a", "1251", "\x00123\x00"}
 
 func TestLDB_PutGet(t *testing.T) {
@@ -0,0 +1,631 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dbtest contains the conformance test suite every datxdb backend must
+// pass.
+package dbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+)
+
+// TestDatabaseSuite runs the conformance tests against a database backend. The
+// New function must return a fresh, empty database along with a function
+// closing and deleting it.
+func TestDatabaseSuite(t *testing.T, New func() (datxdb.Database, func())) {
+	t.Run("PutGet", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testPutGet(t, db)
+	})
+	t.Run("ParallelPutGet", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testParallelPutGet(t, db)
+	})
+	t.Run("Has", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testHas(t, db)
+	})
+	t.Run("Batch", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testBatch(t, db)
+	})
+	t.Run("Iterator", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testIterator(t, db)
+	})
+	t.Run("IteratorWrites", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testIteratorWrites(t, db)
+	})
+	t.Run("Table", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testTable(t, db)
+	})
+	t.Run("TableIterator", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testTableIterator(t, db)
+	})
+	t.Run("CompactStat", func(t *testing.T) {
+		db, remove := New()
+		defer remove()
+		testCompactStat(t, db)
+	})
+}
+
+// BenchDatabaseSuite runs the write and read benchmarks against a database
+// backend, so backends can be compared with each other. The New function is as
+// in TestDatabaseSuite.
+func BenchDatabaseSuite(b *testing.B, New func() (datxdb.Database, func())) {
+	b.Run("Put", func(b *testing.B) {
+		db, remove := New()
+		defer remove()
+		benchPut(b, db)
+	})
+	b.Run("ParallelPut", func(b *testing.B) {
+		db, remove := New()
+		defer remove()
+		benchParallelPut(b, db)
+	})
+	b.Run("BatchWrite", func(b *testing.B) {
+		db, remove := New()
+		defer remove()
+		benchBatchWrite(b, db)
+	})
+	b.Run("Get", func(b *testing.B) {
+		db, remove := New()
+		defer remove()
+		benchGet(b, db)
+	})
+}
+
+// benchKey returns the n-th key written by the benchmarks, a hash sized one as
+// most of the chain's keys are.
+func benchKey(n int) []byte {
+	key := make([]byte, 32)
+	binary.BigEndian.PutUint64(key[24:], uint64(n))
+	return key
+}
+
+// benchValue is the value written by the benchmarks, sized like a trie node.
+var benchValue = bytes.Repeat([]byte{0xaa}, 128)
+
+func benchPut(b *testing.B, db datxdb.Database) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Put(benchKey(i), benchValue); err != nil {
+			b.Fatalf("put failed: %v", err)
+		}
+	}
+}
+
+func benchParallelPut(b *testing.B, db datxdb.Database) {
+	var next uint64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := db.Put(benchKey(int(atomic.AddUint64(&next, 1))), benchValue); err != nil {
+				panic("put failed: " + err.Error())
+			}
+		}
+	})
+}
+
+func benchBatchWrite(b *testing.B, db datxdb.Database) {
+	const size = 1000
+
+	b.ResetTimer()
+	batch := db.NewBatch()
+	for i := 0; i < b.N; i++ {
+		if err := batch.Put(benchKey(i), benchValue); err != nil {
+			b.Fatalf("batch put failed: %v", err)
+		}
+		if batch.ValueSize() >= size*len(benchValue) {
+			if err := batch.Write(); err != nil {
+				b.Fatalf("batch write failed: %v", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		b.Fatalf("batch write failed: %v", err)
+	}
+}
+
+func benchGet(b *testing.B, db datxdb.Database) {
+	const keys = 10000
+
+	batch := db.NewBatch()
+	for i := 0; i < keys; i++ {
+		batch.Put(benchKey(i), benchValue)
+	}
+	if err := batch.Write(); err != nil {
+		b.Fatalf("batch write failed: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Get(benchKey(i % keys)); err != nil {
+			b.Fatalf("get failed: %v", err)
+		}
+	}
+}
+
+var testValues = []string{"", "a", "1251", "\x00123\x00"}
+
+func testPutGet(t *testing.T, db datxdb.Database) {
+	for _, v := range testValues {
+		if err := db.Put([]byte(v), []byte(v)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	for _, v := range testValues {
+		data, err := db.Get([]byte(v))
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if !bytes.Equal(data, []byte(v)) {
+			t.Fatalf("get returned wrong result, got %q expected %q", string(data), v)
+		}
+	}
+	for _, v := range testValues {
+		if err := db.Put([]byte(v), []byte("?")); err != nil {
+			t.Fatalf("put override failed: %v", err)
+		}
+	}
+	for _, v := range testValues {
+		orig, err := db.Get([]byte(v))
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if !bytes.Equal(orig, []byte("?")) {
+			t.Fatalf("get returned wrong result, got %q expected ?", string(orig))
+		}
+		// Modifying the returned value must not modify the database
+		orig[0] = byte(0xff)
+		data, err := db.Get([]byte(v))
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if !bytes.Equal(data, []byte("?")) {
+			t.Fatalf("get returned wrong result, got %q expected ?", string(data))
+		}
+	}
+	// Modifying an inserted value must not modify the database either
+	value := []byte("value")
+	if err := db.Put([]byte("mutable"), value); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	value[0] = 'V'
+	if data, _ := db.Get([]byte("mutable")); !bytes.Equal(data, []byte("value")) {
+		t.Fatalf("inserted value modified, got %q expected %q", data, "value")
+	}
+	for _, v := range testValues {
+		if err := db.Delete([]byte(v)); err != nil {
+			t.Fatalf("delete %q failed: %v", v, err)
+		}
+	}
+	for _, v := range testValues {
+		if _, err := db.Get([]byte(v)); err == nil {
+			t.Fatalf("got deleted value %q", v)
+		}
+	}
+	// Deleting missing keys is not an error
+	if err := db.Delete([]byte("missing")); err != nil {
+		t.Fatalf("delete of missing key failed: %v", err)
+	}
+}
+
+func testParallelPutGet(t *testing.T, db datxdb.Database) {
+	const n = 8
+	var pending sync.WaitGroup
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			if err := db.Put([]byte(key), []byte("v"+key)); err != nil {
+				panic("put failed: " + err.Error())
+			}
+		}(strconv.Itoa(i))
+	}
+	pending.Wait()
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			data, err := db.Get([]byte(key))
+			if err != nil {
+				panic("get failed: " + err.Error())
+			}
+			if !bytes.Equal(data, []byte("v"+key)) {
+				panic(fmt.Sprintf("get failed, got %q expected %q", data, []byte("v"+key)))
+			}
+		}(strconv.Itoa(i))
+	}
+	pending.Wait()
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			if err := db.Delete([]byte(key)); err != nil {
+				panic("delete failed: " + err.Error())
+			}
+		}(strconv.Itoa(i))
+	}
+	pending.Wait()
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			if _, err := db.Get([]byte(key)); err == nil {
+				panic("get succeeded")
+			}
+		}(strconv.Itoa(i))
+	}
+	pending.Wait()
+}
+
+func testHas(t *testing.T, db datxdb.Database) {
+	if has, err := db.Has([]byte("key")); err != nil || has {
+		t.Fatalf("missing key reported: has %v, err %v", has, err)
+	}
+	if err := db.Put([]byte("key"), []byte{}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if has, err := db.Has([]byte("key")); err != nil || !has {
+		t.Fatalf("key with empty value not reported: has %v, err %v", has, err)
+	}
+	if err := db.Delete([]byte("key")); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if has, err := db.Has([]byte("key")); err != nil || has {
+		t.Fatalf("deleted key reported: has %v, err %v", has, err)
+	}
+}
+
+func testBatch(t *testing.T, db datxdb.Database) {
+	batch := db.NewBatch()
+	for i, v := range testValues {
+		if err := batch.Put([]byte(v), []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("batch put failed: %v", err)
+		}
+	}
+	if size := batch.ValueSize(); size != len(testValues) {
+		t.Fatalf("batch value size mismatch: have %d, want %d", size, len(testValues))
+	}
+	// Nothing may be written before the batch is
+	for _, v := range testValues {
+		if has, _ := db.Has([]byte(v)); has {
+			t.Fatalf("batched key %q written before the batch", v)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for i, v := range testValues {
+		if data, err := db.Get([]byte(v)); err != nil || !bytes.Equal(data, []byte(strconv.Itoa(i))) {
+			t.Fatalf("batched key %q mismatch: have %q, want %q (err %v)", v, data, strconv.Itoa(i), err)
+		}
+	}
+	// A reset batch must be empty and reusable
+	batch.Reset()
+	if size := batch.ValueSize(); size != 0 {
+		t.Fatalf("reset batch value size mismatch: have %d, want 0", size)
+	}
+	if err := batch.Put([]byte("after-reset"), []byte("x")); err != nil {
+		t.Fatalf("batch put failed: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	if data, err := db.Get([]byte("after-reset")); err != nil || !bytes.Equal(data, []byte("x")) {
+		t.Fatalf("key batched after reset mismatch: have %q, want %q (err %v)", data, "x", err)
+	}
+	// Batched deletions must only apply on write, in order with the puts
+	batch.Reset()
+	for _, v := range testValues {
+		if err := batch.Delete([]byte(v)); err != nil {
+			t.Fatalf("batch delete failed: %v", err)
+		}
+	}
+	if err := batch.Put([]byte(testValues[1]), []byte("again")); err != nil {
+		t.Fatalf("batch put failed: %v", err)
+	}
+	if err := batch.Delete([]byte("missing")); err != nil {
+		t.Fatalf("batch delete of missing key failed: %v", err)
+	}
+	if batch.ValueSize() == 0 {
+		t.Fatalf("batched deletions not accounted in value size")
+	}
+	if has, _ := db.Has([]byte(testValues[0])); !has {
+		t.Fatalf("batched deletion applied before the batch")
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for i, v := range testValues {
+		has, err := db.Has([]byte(v))
+		if err != nil {
+			t.Fatalf("has failed: %v", err)
+		}
+		if i == 1 {
+			if data, _ := db.Get([]byte(v)); !bytes.Equal(data, []byte("again")) {
+				t.Fatalf("key put after deletion mismatch: have %q, want %q", data, "again")
+			}
+		} else if has {
+			t.Fatalf("batch deleted key %q still present", v)
+		}
+	}
+}
+
+func testIterator(t *testing.T, db datxdb.Database) {
+	content := map[string]string{
+		"":      "empty",
+		"1":     "one",
+		"2":     "two",
+		"20":    "twenty",
+		"21":    "twenty one",
+		"3":     "three",
+		"\xff1": "high",
+	}
+	for key, value := range content {
+		if err := db.Put([]byte(key), []byte(value)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		start  string
+		keys   []string
+	}{
+		{"", "", []string{"", "1", "2", "20", "21", "3", "\xff1"}},
+		{"2", "", []string{"2", "20", "21"}},
+		{"20", "", []string{"20"}},
+		{"\xff", "", []string{"\xff1"}},
+		{"4", "", nil},
+		{"", "2", []string{"2", "20", "21", "3", "\xff1"}},
+		{"", "4", []string{"\xff1"}},
+		{"2", "1", []string{"21"}},
+		{"2", "05", []string{"21"}},
+		{"2", "3", nil},
+		{"\xff", "\xff", nil},
+	}
+	for i, tt := range tests {
+		it := db.NewIteratorWithPrefix([]byte(tt.prefix), []byte(tt.start))
+
+		var keys []string
+		for it.Next() {
+			keys = append(keys, string(it.Key()))
+			if value := string(it.Value()); value != content[string(it.Key())] {
+				t.Errorf("test %d: key %q value mismatch: have %q, want %q", i, it.Key(), value, content[string(it.Key())])
+			}
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("test %d: iteration failed: %v", i, err)
+		}
+		it.Release()
+
+		if !sort.StringsAreSorted(keys) {
+			t.Errorf("test %d: keys not in ascending order: %q", i, keys)
+		}
+		if fmt.Sprintf("%q", keys) != fmt.Sprintf("%q", tt.keys) {
+			t.Errorf("test %d: keys mismatch: have %q, want %q", i, keys, tt.keys)
+		}
+	}
+}
+
+// testIteratorWrites checks that a database can be written to while iterating
+// over more entries than an engine may buffer at once, as the state pruner does.
+func testIteratorWrites(t *testing.T, db datxdb.Database) {
+	const count = 3000
+	for i := 0; i < count; i++ {
+		if err := db.Put([]byte(fmt.Sprintf("iter-%05d", i)), []byte{byte(i)}); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	it := db.NewIteratorWithPrefix([]byte("iter-"), nil)
+	defer it.Release()
+
+	visited := 0
+	for ; it.Next(); visited++ {
+		if want := fmt.Sprintf("iter-%05d", visited); string(it.Key()) != want {
+			t.Fatalf("key mismatch: have %q, want %q", it.Key(), want)
+		}
+		if value := it.Value(); len(value) != 1 || value[0] != byte(visited) {
+			t.Fatalf("key %q value mismatch: have %x", it.Key(), value)
+		}
+		batch := db.NewBatch()
+		batch.Delete(it.Key())
+		batch.Put([]byte(fmt.Sprintf("other-%05d", visited)), it.Value())
+		if err := batch.Write(); err != nil {
+			t.Fatalf("batch write failed while iterating: %v", err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if visited != count {
+		t.Fatalf("visited entries mismatch: have %d, want %d", visited, count)
+	}
+}
+
+func testTable(t *testing.T, db datxdb.Database) {
+	var (
+		left  = datxdb.NewTable(db, "left-")
+		right = datxdb.NewTable(db, "right-")
+	)
+	if err := left.Put([]byte("key"), []byte("left")); err != nil {
+		t.Fatalf("table put failed: %v", err)
+	}
+	batch := datxdb.NewTableBatch(db, "right-")
+	if err := batch.Put([]byte("key"), []byte("right")); err != nil {
+		t.Fatalf("table batch put failed: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("table batch write failed: %v", err)
+	}
+	// Tables must only see their own entries, stored under their prefix
+	if data, err := left.Get([]byte("key")); err != nil || !bytes.Equal(data, []byte("left")) {
+		t.Fatalf("left table value mismatch: have %q, want %q (err %v)", data, "left", err)
+	}
+	if data, err := right.Get([]byte("key")); err != nil || !bytes.Equal(data, []byte("right")) {
+		t.Fatalf("right table value mismatch: have %q, want %q (err %v)", data, "right", err)
+	}
+	if data, err := db.Get([]byte("left-key")); err != nil || !bytes.Equal(data, []byte("left")) {
+		t.Fatalf("prefixed value mismatch: have %q, want %q (err %v)", data, "left", err)
+	}
+	if has, _ := db.Has([]byte("key")); has {
+		t.Fatalf("table entry stored without prefix")
+	}
+	if err := left.Delete([]byte("key")); err != nil {
+		t.Fatalf("table delete failed: %v", err)
+	}
+	if has, _ := left.Has([]byte("key")); has {
+		t.Fatalf("deleted table entry reported")
+	}
+	if has, _ := right.Has([]byte("key")); !has {
+		t.Fatalf("entry of other table deleted")
+	}
+}
+
+func testTableIterator(t *testing.T, db datxdb.Database) {
+	// Sibling prefixes of the table must not leak into its iteration
+	content := map[string]string{
+		"left":    "outside",
+		"left-":   "empty",
+		"left-1":  "one",
+		"left-2":  "two",
+		"left-20": "twenty",
+		"left-x":  "ex",
+		"left.1":  "sibling",
+		"lefty":   "sibling",
+		"right-1": "other",
+	}
+	for key, value := range content {
+		if err := db.Put([]byte(key), []byte(value)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	table := datxdb.NewTable(db, "left-")
+
+	tests := []struct {
+		prefix string
+		start  string
+		keys   []string
+	}{
+		{"", "", []string{"", "1", "2", "20", "x"}},
+		{"2", "", []string{"2", "20"}},
+		{"", "2", []string{"2", "20", "x"}},
+		{"2", "0", []string{"20"}},
+		{"y", "", nil},
+	}
+	for i, tt := range tests {
+		it := table.NewIteratorWithPrefix([]byte(tt.prefix), []byte(tt.start))
+
+		var keys []string
+		for it.Next() {
+			keys = append(keys, string(it.Key()))
+			if value, want := string(it.Value()), content["left-"+string(it.Key())]; value != want {
+				t.Errorf("test %d: key %q value mismatch: have %q, want %q", i, it.Key(), value, want)
+			}
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("test %d: iteration failed: %v", i, err)
+		}
+		it.Release()
+
+		if fmt.Sprintf("%q", keys) != fmt.Sprintf("%q", tt.keys) {
+			t.Errorf("test %d: keys mismatch: have %q, want %q", i, keys, tt.keys)
+		}
+	}
+	// Batched deletions of a table must stay within it
+	batch := datxdb.NewTableBatch(db, "left-")
+	if err := batch.Delete([]byte("1")); err != nil {
+		t.Fatalf("table batch delete failed: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("table batch write failed: %v", err)
+	}
+	if has, _ := db.Has([]byte("left-1")); has {
+		t.Fatalf("table batch deletion not applied")
+	}
+	if has, _ := db.Has([]byte("right-1")); !has {
+		t.Fatalf("table batch deleted entry of other table")
+	}
+	// Compacting a table must not touch the data of any other
+	if err := table.Compact(nil, nil); err != nil {
+		t.Fatalf("table compaction failed: %v", err)
+	}
+	for key, value := range content {
+		if key == "left-1" {
+			continue
+		}
+		if data, err := db.Get([]byte(key)); err != nil || string(data) != value {
+			t.Fatalf("key %q mismatch after table compaction: have %q, want %q (err %v)", key, data, value, err)
+		}
+	}
+}
+
+func testCompactStat(t *testing.T, db datxdb.Database) {
+	for i := 0; i < 256; i++ {
+		if err := db.Put([]byte{byte(i)}, bytes.Repeat([]byte{byte(i)}, 64)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	for i := 0; i < 128; i++ {
+		if err := db.Delete([]byte{byte(i)}); err != nil {
+			t.Fatalf("delete failed: %v", err)
+		}
+	}
+	if err := db.Compact([]byte{0x00}, []byte{0x80}); err != nil {
+		t.Fatalf("range compaction failed: %v", err)
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatalf("full compaction failed: %v", err)
+	}
+	for i := 0; i < 256; i++ {
+		has, err := db.Has([]byte{byte(i)})
+		if err != nil {
+			t.Fatalf("has failed: %v", err)
+		}
+		if has != (i >= 128) {
+			t.Fatalf("key %d presence mismatch after compaction: have %v, want %v", i, has, i >= 128)
+		}
+	}
+	if _, err := db.Stat("nonexistent.property"); err == nil {
+		t.Fatalf("unknown property reported")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datxdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+)
+
+// DefaultEngine is the engine of new databases if none is requested.
+const DefaultEngine = "leveldb"
+
+// Engine is a persistent database backend that can be selected by name.
+type Engine struct {
+	// Open opens or creates a database in the given directory. The cache (in
+	// megabytes) and file handle allowances are hints the engine may ignore.
+	Open func(file string, cache int, handles int) (Database, error)
+
+	// Exists reports whether the given directory holds a database of the engine.
+	Exists func(file string) bool
+}
+
+var (
+	engines     = make(map[string]Engine)
+	enginesLock sync.RWMutex
+)
+
+func init() {
+	RegisterEngine("leveldb", Engine{
+		Open: func(file string, cache int, handles int) (Database, error) {
+			return NewLDBDatabase(file, cache, handles)
+		},
+		Exists: func(file string) bool {
+			return common.FileExist(filepath.Join(file, "CURRENT"))
+		},
+	})
+	RegisterEngine("boltdb", Engine{
+		Open: func(file string, cache int, handles int) (Database, error) {
+			return NewBoltDatabase(file, cache, handles)
+		},
+		Exists: func(file string) bool {
+			return common.FileExist(filepath.Join(file, boltFileName))
+		},
+	})
+}
+
+// RegisterEngine makes a database engine available under the given name. It
+// panics if the name is already taken.
+func RegisterEngine(name string, engine Engine) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("database engine %q registered twice", name))
+	}
+	engines[name] = engine
+}
+
+// Engines returns the sorted names of the registered database engines.
+func Engines() []string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DetectEngine returns the name of the engine of the database stored in the
+// given directory, or an empty string if there is no database there.
+func DetectEngine(file string) string {
+	names := Engines()
+
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	for _, name := range names {
+		if engines[name].Exists(file) {
+			return name
+		}
+	}
+	return ""
+}
+
+// Open opens the database stored in the given directory, or creates one with
+// the requested engine if there is none. An empty engine name opens existing
+// databases with their own engine and creates new ones with DefaultEngine.
+// Opening an existing database with a different engine fails, databases need
+// to be copied to migrate between engines.
+func Open(engine string, file string, cache int, handles int) (Database, error) {
+	existing := DetectEngine(file)
+	if engine == "" {
+		engine = existing
+		if engine == "" {
+			engine = DefaultEngine
+		}
+	}
+	enginesLock.RLock()
+	backend, ok := engines[engine]
+	enginesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database engine %q, available: %v", engine, Engines())
+	}
+	if existing != "" && existing != engine {
+		return nil, fmt.Errorf("database %s was created with engine %q, not %q", file, existing, engine)
+	}
+	return backend.Open(file, cache, handles)
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datxdb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+)
+
+// Tests that databases are created with the requested engine, reopened with
+// their own one, and refused by the others.
+func TestOpenEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datxdb-engine-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, engine := range datxdb.Engines() {
+		file := filepath.Join(dir, engine)
+		if name := datxdb.DetectEngine(file); name != "" {
+			t.Fatalf("%s: engine detected without a database: %s", engine, name)
+		}
+		db, err := datxdb.Open(engine, file, 0, 0)
+		if err != nil {
+			t.Fatalf("%s: failed to create database: %v", engine, err)
+		}
+		db.Put([]byte("key"), []byte(engine))
+		db.Close()
+
+		if name := datxdb.DetectEngine(file); name != engine {
+			t.Fatalf("%s: detected engine mismatch: have %q", engine, name)
+		}
+		// Reopening without an engine should pick the database's own
+		if db, err = datxdb.Open("", file, 0, 0); err != nil {
+			t.Fatalf("%s: failed to reopen database: %v", engine, err)
+		}
+		if value, err := db.Get([]byte("key")); err != nil || string(value) != engine {
+			t.Fatalf("%s: value mismatch: have %q, want %q (err %v)", engine, value, engine, err)
+		}
+		db.Close()
+
+		// Reopening with any other engine should fail
+		for _, other := range datxdb.Engines() {
+			if other == engine {
+				continue
+			}
+			if db, err := datxdb.Open(other, file, 0, 0); err == nil {
+				db.Close()
+				t.Fatalf("%s: database opened with engine %s", engine, other)
+			}
+		}
+	}
+	if _, err := datxdb.Open("unknown", filepath.Join(dir, "unknown"), 0, 0); err == nil {
+		t.Fatalf("database opened with unknown engine")
+	}
+}
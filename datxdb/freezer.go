@@ -0,0 +1,249 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datxdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/log"
+)
+
+// Kinds of the items kept in the ancient store, one table each.
+const (
+	// FreezerHashTable holds the canonical hashes of the frozen blocks.
+	FreezerHashTable = "hashes"
+
+	// FreezerHeaderTable holds the RLP encoded headers of the frozen blocks.
+	FreezerHeaderTable = "headers"
+
+	// FreezerBodiesTable holds the RLP encoded bodies of the frozen blocks.
+	FreezerBodiesTable = "bodies"
+
+	// FreezerReceiptTable holds the RLP encoded receipts of the frozen blocks.
+	FreezerReceiptTable = "receipts"
+
+	// FreezerDifficultyTable holds the RLP encoded total difficulties of the
+	// frozen blocks.
+	FreezerDifficultyTable = "diffs"
+)
+
+// FreezerTables lists the kinds of the ancient store, with whether their items
+// are compressed. Hashes don't compress.
+var FreezerTables = map[string]bool{
+	FreezerHashTable:       false,
+	FreezerHeaderTable:     true,
+	FreezerBodiesTable:     true,
+	FreezerReceiptTable:    true,
+	FreezerDifficultyTable: true,
+}
+
+var (
+	// errUnknownTable is returned if an item of an unknown kind is requested.
+	errUnknownTable = errors.New("unknown table")
+
+	// errOrderViolation is returned if a block is appended out of order.
+	errOrderViolation = errors.New("ancient block appended out of order")
+)
+
+// freezer is an ancient store keeping every kind of item in a freezerTable, all
+// tables holding the same number of blocks.
+type freezer struct {
+	dir    string
+	tables map[string]*freezerTable
+	frozen uint64 // Number of blocks in all tables
+
+	lock sync.RWMutex // Mutex keeping the tables in step
+}
+
+// newFreezer opens or creates the ancient store in the given directory. Tables
+// left out of step by a crash are truncated to the blocks all of them hold.
+func newFreezer(dir string) (*freezer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &freezer{
+		dir:    dir,
+		tables: make(map[string]*freezerTable),
+	}
+	for name, compress := range FreezerTables {
+		table, err := newFreezerTable(dir, name, compress)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.tables[name] = table
+	}
+	f.frozen = f.tables[FreezerHashTable].Items()
+	for _, table := range f.tables {
+		if items := table.Items(); items < f.frozen {
+			f.frozen = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.Truncate(f.frozen); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	log.Info("Opened ancient database", "path", dir, "frozen", f.frozen)
+	return f, nil
+}
+
+// Ancient retrieves the item of a kind stored for the given block number.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	table, ok := f.tables[kind]
+	if !ok {
+		return nil, errUnknownTable
+	}
+	return table.Retrieve(number)
+}
+
+// Ancients returns the number of blocks in the ancient store.
+func (f *freezer) Ancients() (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.frozen, nil
+}
+
+// AncientSize returns the size of the table of a kind on disk.
+func (f *freezer) AncientSize(kind string) (uint64, error) {
+	table, ok := f.tables[kind]
+	if !ok {
+		return 0, errUnknownTable
+	}
+	return table.Size(), nil
+}
+
+// AppendAncient stores the items of the next block. If any of them can't be
+// stored, the others are discarded too.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if number != f.frozen {
+		return errOrderViolation
+	}
+	items := map[string][]byte{
+		FreezerHashTable:       hash,
+		FreezerHeaderTable:     header,
+		FreezerBodiesTable:     body,
+		FreezerReceiptTable:    receipts,
+		FreezerDifficultyTable: td,
+	}
+	for kind, item := range items {
+		if err := f.tables[kind].Append(number, item); err != nil {
+			f.truncate(f.frozen)
+			return err
+		}
+	}
+	f.frozen++
+	return nil
+}
+
+// TruncateAncients discards all but the first items blocks of the store.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.frozen <= items {
+		return nil
+	}
+	if err := f.truncate(items); err != nil {
+		return err
+	}
+	f.frozen = items
+	return nil
+}
+
+// truncate truncates every table to the given number of items. The caller must
+// hold the lock.
+func (f *freezer) truncate(items uint64) error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Truncate(items); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// Sync flushes the appended items of all tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// Close closes all tables.
+func (f *freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freezerdb is a database whose ancient chain data is kept in a freezer.
+type freezerdb struct {
+	Database
+	*freezer
+}
+
+// NewDatabaseWithFreezer combines a key-value database with the ancient store
+// in the given directory, which is created if needed. Closing the returned
+// database closes both.
+func NewDatabaseWithFreezer(db Database, ancient string) (Database, error) {
+	frdb, err := newFreezer(ancient)
+	if err != nil {
+		return nil, err
+	}
+	return &freezerdb{Database: db, freezer: frdb}, nil
+}
+
+// Meter implements Meterer, metering the key-value database if it supports it.
+func (db *freezerdb) Meter(prefix string) {
+	if m, ok := db.Database.(Meterer); ok {
+		m.Meter(prefix)
+	}
+}
+
+// Close closes the ancient store and the key-value database.
+func (db *freezerdb) Close() {
+	if err := db.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "path", db.freezer.dir, "err", err)
+	}
+	db.Database.Close()
+}
@@ -0,0 +1,283 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datxdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/golang/snappy"
+)
+
+var (
+	// errOutOfBounds is returned if an item past the end of a table is requested.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errTableClosed is returned if a closed table is accessed.
+	errTableClosed = errors.New("table already closed")
+)
+
+// indexEntrySize is the size of an index entry, the offset in the data file at
+// which an item ends.
+const indexEntrySize = 8
+
+// freezerTable is an append-only table of items stored back to back in a data
+// file, with the offsets they end at stored in an index file. The index starts
+// with a zero offset, so item n spans the offsets of index entries n and n+1.
+type freezerTable struct {
+	name     string
+	compress bool // Whether the items are snappy compressed
+
+	index *os.File // File of the item end offsets
+	data  *os.File // File of the item contents
+	items uint64   // Number of items in the table
+	size  uint64   // Size of the data file, the end offset of the last item
+
+	log  log.Logger
+	lock sync.RWMutex // Mutex protecting the files from concurrent access
+}
+
+// newFreezerTable opens or creates a table in the given directory, repairing it
+// if it was not closed cleanly.
+func newFreezerTable(dir string, name string, compress bool) (*freezerTable, error) {
+	idxExt, dataExt := ".ridx", ".rdat"
+	if compress {
+		idxExt, dataExt = ".cidx", ".cdat"
+	}
+	index, err := os.OpenFile(filepath.Join(dir, name+idxExt), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.OpenFile(filepath.Join(dir, name+dataExt), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	t := &freezerTable{
+		name:     name,
+		compress: compress,
+		index:    index,
+		data:     data,
+		log:      log.New("table", name),
+	}
+	if err := t.repair(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// repair makes the index and data files consistent after a crash, dropping the
+// items whose index entries or contents were not completely written.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	// A new table starts with the zero offset of the first item
+	if stat.Size() == 0 {
+		if _, err := t.index.WriteAt(make([]byte, indexEntrySize), 0); err != nil {
+			return err
+		}
+		stat, err = t.index.Stat()
+		if err != nil {
+			return err
+		}
+	}
+	entries := uint64(stat.Size()) / indexEntrySize
+	if entries == 0 {
+		return fmt.Errorf("table %s: corrupt index", t.name)
+	}
+	if stat, err = t.data.Stat(); err != nil {
+		return err
+	}
+	dataSize := uint64(stat.Size())
+
+	// Drop trailing items whose contents are not completely in the data file
+	for ; entries > 1; entries-- {
+		offset, err := t.readOffset(entries - 1)
+		if err != nil {
+			return err
+		}
+		if offset <= dataSize {
+			break
+		}
+	}
+	offset, err := t.readOffset(entries - 1)
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64(entries * indexEntrySize)); err != nil {
+		return err
+	}
+	if offset != dataSize {
+		t.log.Warn("Truncating dangling freezer data", "indexed", offset, "stored", dataSize)
+		if err := t.data.Truncate(int64(offset)); err != nil {
+			return err
+		}
+	}
+	t.items, t.size = entries-1, offset
+	return nil
+}
+
+// readOffset returns the data offset stored in the given index entry.
+func (t *freezerTable) readOffset(entry uint64) (uint64, error) {
+	var buf [indexEntrySize]byte
+	if _, err := t.index.ReadAt(buf[:], int64(entry*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// Items returns the number of items in the table.
+func (t *freezerTable) Items() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.items
+}
+
+// Append stores an item at the end of the table, which must be item number n.
+func (t *freezerTable) Append(n uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errTableClosed
+	}
+	if n != t.items {
+		return fmt.Errorf("table %s: appending item %d, expected %d", t.name, n, t.items)
+	}
+	if t.compress {
+		blob = snappy.Encode(nil, blob)
+	}
+	if _, err := t.data.WriteAt(blob, int64(t.size)); err != nil {
+		return err
+	}
+	var entry [indexEntrySize]byte
+	binary.BigEndian.PutUint64(entry[:], t.size+uint64(len(blob)))
+	if _, err := t.index.WriteAt(entry[:], int64((t.items+1)*indexEntrySize)); err != nil {
+		return err
+	}
+	t.items++
+	t.size += uint64(len(blob))
+	return nil
+}
+
+// Retrieve returns item number n of the table.
+func (t *freezerTable) Retrieve(n uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return nil, errTableClosed
+	}
+	if n >= t.items {
+		return nil, errOutOfBounds
+	}
+	start, err := t.readOffset(n)
+	if err != nil {
+		return nil, err
+	}
+	end, err := t.readOffset(n + 1)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	if t.compress {
+		return snappy.Decode(nil, blob)
+	}
+	return blob, nil
+}
+
+// Truncate discards all but the first items items of the table.
+func (t *freezerTable) Truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errTableClosed
+	}
+	if items >= t.items {
+		return nil
+	}
+	offset, err := t.readOffset(items)
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64((items + 1) * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(offset)); err != nil {
+		return err
+	}
+	t.items, t.size = items, offset
+	return nil
+}
+
+// Size returns the size of the table files.
+func (t *freezerTable) Size() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.size + (t.items+1)*indexEntrySize
+}
+
+// Sync flushes the table files to disk, the data before the index so an index
+// entry never refers to missing contents.
+func (t *freezerTable) Sync() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return errTableClosed
+	}
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+// Close closes the table files.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	for _, f := range []*os.File{t.index, t.data} {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.index, t.data = nil, nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("table %s: %v", t.name, errs)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package datxdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/metrics"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// testAncientItem returns the item of a kind stored for a test block.
+func testAncientItem(kind string, number uint64) []byte {
+	return bytes.Repeat([]byte(fmt.Sprintf("%s-%d ", kind, number)), int(number%8)+1)
+}
+
+// appendTestAncients appends test blocks to the freezer up to the given number.
+func appendTestAncients(t *testing.T, f *freezer, limit uint64) {
+	frozen, _ := f.Ancients()
+	for number := frozen; number < limit; number++ {
+		err := f.AppendAncient(number,
+			testAncientItem(FreezerHashTable, number),
+			testAncientItem(FreezerHeaderTable, number),
+			testAncientItem(FreezerBodiesTable, number),
+			testAncientItem(FreezerReceiptTable, number),
+			testAncientItem(FreezerDifficultyTable, number),
+		)
+		if err != nil {
+			t.Fatalf("failed to append block %d: %v", number, err)
+		}
+	}
+}
+
+// checkTestAncients checks that the freezer holds exactly the given number of
+// test blocks.
+func checkTestAncients(t *testing.T, f *freezer, count uint64) {
+	if frozen, _ := f.Ancients(); frozen != count {
+		t.Fatalf("frozen blocks mismatch: have %d, want %d", frozen, count)
+	}
+	for kind := range FreezerTables {
+		for number := uint64(0); number < count; number++ {
+			item, err := f.Ancient(kind, number)
+			if err != nil {
+				t.Fatalf("failed to retrieve %s #%d: %v", kind, number, err)
+			}
+			if want := testAncientItem(kind, number); !bytes.Equal(item, want) {
+				t.Fatalf("%s #%d mismatch: have %q, want %q", kind, number, item, want)
+			}
+		}
+		if _, err := f.Ancient(kind, count); err != errOutOfBounds {
+			t.Fatalf("%s #%d past the end: have error %v, want %v", kind, count, err, errOutOfBounds)
+		}
+	}
+}
+
+// Tests that blocks appended to the freezer can be retrieved, also after it is
+// reopened, and that blocks can't be appended out of order.
+func TestFreezerAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	appendTestAncients(t, f, 32)
+	checkTestAncients(t, f, 32)
+
+	if err := f.AppendAncient(40, nil, nil, nil, nil, nil); err != errOrderViolation {
+		t.Fatalf("gapped append error mismatch: have %v, want %v", err, errOrderViolation)
+	}
+	if _, err := f.Ancient("unknown", 0); err != errUnknownTable {
+		t.Fatalf("unknown table error mismatch: have %v, want %v", err, errUnknownTable)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatalf("failed to sync freezer: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed to close freezer: %v", err)
+	}
+	if f, err = newFreezer(dir); err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer f.Close()
+
+	checkTestAncients(t, f, 32)
+	appendTestAncients(t, f, 48)
+	checkTestAncients(t, f, 48)
+}
+
+// Tests that truncating the freezer discards the blocks above the limit.
+func TestFreezerTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	defer f.Close()
+
+	appendTestAncients(t, f, 32)
+	size, _ := f.AncientSize(FreezerBodiesTable)
+
+	if err := f.TruncateAncients(16); err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	checkTestAncients(t, f, 16)
+	if truncated, _ := f.AncientSize(FreezerBodiesTable); truncated >= size {
+		t.Fatalf("table not shrunk: have %d bytes, had %d", truncated, size)
+	}
+	// Truncating above the frozen blocks is a noop
+	if err := f.TruncateAncients(64); err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	checkTestAncients(t, f, 16)
+
+	// The truncated blocks can be appended again
+	appendTestAncients(t, f, 32)
+	checkTestAncients(t, f, 32)
+}
+
+// Tests that a freezer whose tables were left out of step by a crash is
+// repaired to the blocks all tables hold completely.
+func TestFreezerRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	appendTestAncients(t, f, 32)
+	f.Close()
+
+	// Cut off the end of the last body and a partial index entry of the headers
+	bodies := filepath.Join(dir, FreezerBodiesTable+".cdat")
+	stat, err := os.Stat(bodies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(bodies, stat.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.OpenFile(filepath.Join(dir, FreezerHeaderTable+".cidx"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Write([]byte{0x00, 0x01, 0x02})
+	index.Close()
+
+	if f, err = newFreezer(dir); err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer f.Close()
+
+	checkTestAncients(t, f, 31)
+	appendTestAncients(t, f, 40)
+	checkTestAncients(t, f, 40)
+}
+
+// Tests that metering a database with an ancient store meters its key-value
+// store, whichever engine that is.
+func TestFreezerMeter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	kvdb, err := NewBoltDatabase(filepath.Join(dir, "chaindata"), 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db, err := NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"))
+	if err != nil {
+		t.Fatalf("failed to open ancient store: %v", err)
+	}
+	defer db.Close()
+
+	m, ok := db.(Meterer)
+	if !ok {
+		t.Fatalf("database with ancient store not metered")
+	}
+	m.Meter("datxdb/test/freezer/")
+	if err := db.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	timer, ok := gometrics.DefaultRegistry.Get("datxdb/test/freezer/user/puts").(gometrics.Timer)
+	if !ok {
+		t.Fatalf("put timer not registered")
+	}
+	if count := timer.Count(); count != 1 {
+		t.Errorf("put count mismatch: have %d, want 1", count)
+	}
+}
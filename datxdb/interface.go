@@ -25,20 +25,97 @@ type Putter interface {
 	Put(key []byte, value []byte) error
 }
 
+// Deleter wraps the database delete operation supported by both batches and regular databases.
+type Deleter interface {
+	Delete(key []byte) error
+}
+
 // Database wraps all database operations. All methods are safe for concurrent use.
 type Database interface {
 	Putter
+	Deleter
+	Iteratee
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
-	Delete(key []byte) error
 	Close()
 	NewBatch() Batch
+
+	// Compact flattens the underlying data store for the given key range, a nil
+	// start and limit meaning before and after all keys. It's only a hint to
+	// reclaim the space of deleted data, engines without compaction ignore it.
+	Compact(start []byte, limit []byte) error
+
+	// Stat returns a particular internal stat of the database.
+	Stat(property string) (string, error)
+}
+
+// Meterer wraps the method of databases reporting their request and internal
+// statistics to the metrics system.
+type Meterer interface {
+	// Meter starts collecting the metrics of the database under the given
+	// prefix. It's a noop if the metrics system is disabled.
+	Meter(prefix string)
 }
 
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
 	Putter
+	Deleter
 	ValueSize() int // amount of data in the batch
 	Write() error
+	// Reset resets the batch for reuse
+	Reset()
+}
+
+// Iterator iterates over the key/value pairs of a database in ascending key
+// order. The key and value returned are only valid until the next call to Next.
+// Iterators must be released after use.
+type Iterator interface {
+	Next() bool
+	Error() error
+	Key() []byte
+	Value() []byte
+	Release()
+}
+
+// Iteratee wraps the iteration method of databases.
+type Iteratee interface {
+	// NewIteratorWithPrefix creates an iterator over the entries whose keys start
+	// with the given prefix, beginning at the first key not smaller than prefix
+	// followed by start. Both may be empty to iterate the whole database.
+	NewIteratorWithPrefix(prefix []byte, start []byte) Iterator
+}
+
+// AncientReader wraps the read methods of an ancient store, which keeps the
+// immutable chain data of old blocks in append-only tables, one per kind.
+type AncientReader interface {
+	// Ancient retrieves the item of a kind stored for the given block number.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks in the ancient store, all blocks
+	// below it being frozen.
+	Ancients() (uint64, error)
+
+	// AncientSize returns the size of the table of a kind on disk.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientWriter wraps the write methods of an ancient store.
+type AncientWriter interface {
+	// AppendAncient stores the items of the next block, which must be the block
+	// number Ancients returns.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first items blocks of the store.
+	TruncateAncients(items uint64) error
+
+	// Sync flushes the appended items to disk.
+	Sync() error
+}
+
+// AncientStore is an ancient store both readable and writable.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
 }
@@ -18,6 +18,9 @@ package datxdb
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/DATxChain-Protocol/DATx/common"
@@ -92,11 +95,59 @@ func (db *MemDatabase) Delete(key []byte) error {
 
 func (db *MemDatabase) Close() {}
 
+// NewIteratorWithPrefix implements Iteratee, iterating over a snapshot of the
+// entries whose keys start with prefix, from prefix followed by start.
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte, start []byte) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var (
+		keys  []string
+		first = string(prefix) + string(start)
+	)
+	for key := range db.db {
+		if strings.HasPrefix(key, string(prefix)) && key >= first {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = db.db[key]
+	}
+	return &memIterator{keys: keys, values: values, index: -1}
+}
+
+// Compact is a noop, there is nothing to compact in memory.
+func (db *MemDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// Stat returns a particular internal stat of the database. The only supported
+// property is "memory.stats".
+func (db *MemDatabase) Stat(property string) (string, error) {
+	if property != "memory.stats" {
+		return "", errors.New("unknown property")
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var size common.StorageSize
+	for key, value := range db.db {
+		size += common.StorageSize(len(key) + len(value))
+	}
+	return fmt.Sprintf("Entries: %d\nSize: %v\n", len(db.db), size), nil
+}
+
 func (db *MemDatabase) NewBatch() Batch {
 	return &memBatch{db: db}
 }
 
-type kv struct{ k, v []byte }
+type kv struct {
+	k, v []byte
+	del  bool
+}
 
 type memBatch struct {
 	db     *MemDatabase
@@ -105,16 +156,26 @@ type memBatch struct {
 }
 
 func (b *memBatch) Put(key, value []byte) error {
-	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value)})
+	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value), false})
 	b.size += len(value)
 	return nil
 }
 
+func (b *memBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), nil, true})
+	b.size += len(key)
+	return nil
+}
+
 func (b *memBatch) Write() error {
 	b.db.lock.Lock()
 	defer b.db.lock.Unlock()
 
 	for _, kv := range b.writes {
+		if kv.del {
+			delete(b.db.db, string(kv.k))
+			continue
+		}
 		b.db.db[string(kv.k)] = kv.v
 	}
 	return nil
@@ -123,3 +184,45 @@ func (b *memBatch) Write() error {
 func (b *memBatch) ValueSize() int {
 	return b.size
 }
+
+func (b *memBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// memIterator iterates over a sorted snapshot of a memory database.
+type memIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+func (it *memIterator) Next() bool {
+	if it.index >= len(it.keys) {
+		return false
+	}
+	it.index++
+	return it.index < len(it.keys)
+}
+
+func (it *memIterator) Error() error {
+	return nil
+}
+
+func (it *memIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return []byte(it.keys[it.index])
+}
+
+func (it *memIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *memIterator) Release() {
+	it.keys, it.values = nil, nil
+}
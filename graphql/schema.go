@@ -0,0 +1,743 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/datx/filters"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// schema is the chain schema served by the service.
+const schema = `
+    # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes32
+    # Address is a 20 byte account address, represented as 0x-prefixed hexadecimal.
+    scalar Address
+    # Bytes is an arbitrary length binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes
+    # BigInt is a large integer, represented as 0x-prefixed hexadecimal.
+    scalar BigInt
+    # Long is a 64 bit unsigned integer, given as a number or 0x-prefixed hexadecimal.
+    scalar Long
+    # BlockNumber is a Long, or one of the "latest", "pending" and "earliest" tags.
+    scalar BlockNumber
+
+    schema {
+        query: Query
+    }
+
+    type Query {
+        # Block by number or hash, defaulting to the latest one.
+        block(number: Long, hash: Bytes32): Block
+        # Canonical blocks from a number on, up to an optional last number.
+        blocks(from: Long!, to: Long, first: Int): [Block!]!
+        # Included transaction by hash.
+        transaction(hash: Bytes32!): Transaction
+        # Logs matching a filter, within a limited range of blocks.
+        logs(filter: FilterCriteria!, first: Int): [Log!]!
+        # Account at the state of a block, defaulting to the latest one.
+        account(address: Address!, block: BlockNumber): Account!
+    }
+
+    input FilterCriteria {
+        fromBlock: Long
+        toBlock: Long
+        addresses: [Address!]
+        # Alternatives of every topic position, null matching any topic.
+        topics: [[Bytes32!]]
+    }
+
+    enum TransactionType {
+        BINARY
+        LOGIN_CANDIDATE
+        LOGOUT_CANDIDATE
+        DELEGATE
+        UNDELEGATE
+    }
+
+    type Block {
+        number: Long!
+        hash: Bytes32!
+        parentHash: Bytes32!
+        nonce: Bytes!
+        stateRoot: Bytes32!
+        transactionsRoot: Bytes32!
+        receiptsRoot: Bytes32!
+        miner: Address!
+        validator: Address!
+        difficulty: BigInt!
+        extraData: Bytes!
+        gasLimit: BigInt!
+        gasUsed: BigInt!
+        timestamp: BigInt!
+        logsBloom: Bytes!
+        mixHash: Bytes32!
+        transactionCount: Long!
+        dposContext: DposContext
+        transactions(first: Int, skip: Int, type: TransactionType): [Transaction!]!
+        account(address: Address!): Account!
+        # Validators of the epoch of the block.
+        validators: [Address!]!
+        candidates(first: Int, skip: Int): [Candidate!]!
+        # Votes of the delegators, optionally only the ones for a single candidate.
+        votes(candidate: Address, first: Int, skip: Int): [Vote!]!
+    }
+
+    # DposContext are the roots of the DPoS context tries of a block.
+    type DposContext {
+        epochRoot: Bytes32!
+        delegateRoot: Bytes32!
+        candidateRoot: Bytes32!
+        voteRoot: Bytes32!
+        mintCntRoot: Bytes32!
+    }
+
+    type Transaction {
+        hash: Bytes32!
+        type: TransactionType!
+        nonce: Long!
+        to: Address
+        value: BigInt!
+        gas: BigInt!
+        gasPrice: BigInt!
+        input: Bytes!
+        index: Long!
+        from: Address!
+        block: Block!
+        status: Long!
+        gasUsed: BigInt!
+        cumulativeGasUsed: BigInt!
+        contractAddress: Address
+        logs: [Log!]!
+    }
+
+    type Log {
+        index: Long!
+        address: Address!
+        topics: [Bytes32!]!
+        data: Bytes!
+        blockNumber: Long!
+        blockHash: Bytes32!
+        transactionHash: Bytes32!
+        transaction: Transaction
+    }
+
+    type Account {
+        address: Address!
+        balance: BigInt!
+        nonce: Long!
+        code: Bytes!
+        storage(slot: Bytes32!): Bytes32!
+    }
+
+    type Candidate {
+        address: Address!
+        delegators(first: Int, skip: Int): [Address!]!
+    }
+
+    type Vote {
+        delegator: Address!
+        candidate: Address!
+    }
+`
+
+// txTypeNames are the names of the transaction types in queries and results.
+var txTypeNames = map[types.TxType]string{
+	types.Binary:          "BINARY",
+	types.LoginCandidate:  "LOGIN_CANDIDATE",
+	types.LogoutCandidate: "LOGOUT_CANDIDATE",
+	types.Delegate:        "DELEGATE",
+	types.UnDelegate:      "UNDELEGATE",
+}
+
+// blockNumber is a block number argument, accepting the tags of the RPC block
+// numbers along with numbers.
+type blockNumber rpc.BlockNumber
+
+// ImplementsGraphQLType returns true if blockNumber implements the specified
+// GraphQL type.
+func (blockNumber) ImplementsGraphQLType(name string) bool { return name == "BlockNumber" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (n *blockNumber) UnmarshalGraphQL(input interface{}) error {
+	if s, ok := input.(string); ok {
+		var number rpc.BlockNumber
+		tag, _ := json.Marshal(s)
+		if err := number.UnmarshalJSON(tag); err != nil {
+			return err
+		}
+		*n = blockNumber(number)
+		return nil
+	}
+	var number hexutil.Uint64
+	if err := number.UnmarshalGraphQL(input); err != nil {
+		return err
+	}
+	*n = blockNumber(number)
+	return nil
+}
+
+// resolver resolves the queries of the chain schema from the backend of a node.
+type resolver struct {
+	backend Backend
+	config  *Config
+}
+
+// page returns the number of items to skip and the maximum number of items of a
+// page of a list, defaulting and clamping the requested page size.
+func (r *resolver) page(first, skip *int32) (int, int, error) {
+	size, offset := r.config.DefaultPageSize, 0
+	if first != nil {
+		if *first < 0 {
+			return 0, 0, fmt.Errorf("invalid first %d", *first)
+		}
+		size = int(*first)
+	}
+	if size > r.config.MaxPageSize {
+		size = r.config.MaxPageSize
+	}
+	if skip != nil {
+		if *skip < 0 {
+			return 0, 0, fmt.Errorf("invalid skip %d", *skip)
+		}
+		offset = int(*skip)
+	}
+	return offset, size, nil
+}
+
+// Block resolves a block by hash or number, defaulting to the latest one.
+func (r *resolver) Block(ctx context.Context, args struct {
+	Number *hexutil.Uint64
+	Hash   *common.Hash
+}) (*block, error) {
+	var (
+		b   *types.Block
+		err error
+	)
+	switch {
+	case args.Hash != nil && args.Number != nil:
+		return nil, errors.New("only one of number and hash may be given")
+	case args.Hash != nil:
+		b, err = r.backend.GetBlock(ctx, *args.Hash)
+	case args.Number != nil:
+		b, err = r.backend.BlockByNumber(ctx, rpc.BlockNumber(*args.Number))
+	default:
+		b, err = r.backend.BlockByNumber(ctx, rpc.LatestBlockNumber)
+	}
+	if b == nil || err != nil {
+		return nil, err
+	}
+	return &block{r: r, b: b}, nil
+}
+
+// Blocks resolves the canonical blocks from a number on, up to an optional last
+// number, limited to a page.
+func (r *resolver) Blocks(ctx context.Context, args struct {
+	From  hexutil.Uint64
+	To    *hexutil.Uint64
+	First *int32
+}) ([]*block, error) {
+	_, size, err := r.page(args.First, nil)
+	if err != nil {
+		return nil, err
+	}
+	to := r.backend.CurrentBlock().NumberU64()
+	if args.To != nil && uint64(*args.To) < to {
+		to = uint64(*args.To)
+	}
+	blocks := []*block{}
+	for n := uint64(args.From); n <= to && len(blocks) < size; n++ {
+		b, err := r.backend.BlockByNumber(ctx, rpc.BlockNumber(n))
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			break
+		}
+		blocks = append(blocks, &block{r: r, b: b})
+	}
+	return blocks, nil
+}
+
+// Transaction resolves an included transaction by hash.
+func (r *resolver) Transaction(ctx context.Context, args struct{ Hash common.Hash }) (*transaction, error) {
+	tx, blockHash, _, index := core.GetTransaction(r.backend.ChainDb(), args.Hash)
+	if tx == nil {
+		return nil, nil
+	}
+	b, err := r.backend.GetBlock(ctx, blockHash)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	return &transaction{r: r, tx: tx, block: b, index: index}, nil
+}
+
+// filterCriteria is the filter of the logs query.
+type filterCriteria struct {
+	FromBlock *hexutil.Uint64
+	ToBlock   *hexutil.Uint64
+	Addresses *[]common.Address
+	Topics    *[]*[]common.Hash
+}
+
+// Logs resolves the logs matching a filter within a limited range of blocks.
+func (r *resolver) Logs(ctx context.Context, args struct {
+	Filter filterCriteria
+	First  *int32
+}) ([]*logEntry, error) {
+	_, size, err := r.page(args.First, nil)
+	if err != nil {
+		return nil, err
+	}
+	head := r.backend.CurrentBlock().NumberU64()
+	begin, end := head, head
+	if args.Filter.FromBlock != nil {
+		begin = uint64(*args.Filter.FromBlock)
+	}
+	if args.Filter.ToBlock != nil {
+		end = uint64(*args.Filter.ToBlock)
+	}
+	if begin > end {
+		return nil, fmt.Errorf("invalid range: fromBlock %d after toBlock %d", begin, end)
+	}
+	if end-begin >= r.config.MaxBlockRange {
+		return nil, fmt.Errorf("block range too large: %d blocks, limit %d", end-begin+1, r.config.MaxBlockRange)
+	}
+	var addresses []common.Address
+	if args.Filter.Addresses != nil {
+		addresses = *args.Filter.Addresses
+	}
+	var topics [][]common.Hash
+	if args.Filter.Topics != nil {
+		for _, alternatives := range *args.Filter.Topics {
+			if alternatives == nil {
+				topics = append(topics, nil)
+				continue
+			}
+			topics = append(topics, *alternatives)
+		}
+	}
+	logs, err := filters.New(r.backend, int64(begin), int64(end), addresses, topics).Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []*logEntry{}
+	for _, log := range logs {
+		if len(result) >= size {
+			break
+		}
+		result = append(result, &logEntry{r: r, log: log})
+	}
+	return result, nil
+}
+
+// Account resolves an account at the state of a block, defaulting to the latest.
+func (r *resolver) Account(ctx context.Context, args struct {
+	Address common.Address
+	Block   *blockNumber
+}) *account {
+	number := rpc.LatestBlockNumber
+	if args.Block != nil {
+		number = rpc.BlockNumber(*args.Block)
+	}
+	return &account{r: r, address: args.Address, number: number}
+}
+
+// block is a block of the chain.
+type block struct {
+	r *resolver
+	b *types.Block
+}
+
+func (b *block) Number() hexutil.Uint64        { return hexutil.Uint64(b.b.NumberU64()) }
+func (b *block) Hash() common.Hash             { return b.b.Hash() }
+func (b *block) ParentHash() common.Hash       { return b.b.ParentHash() }
+func (b *block) StateRoot() common.Hash        { return b.b.Root() }
+func (b *block) TransactionsRoot() common.Hash { return b.b.TxHash() }
+func (b *block) ReceiptsRoot() common.Hash     { return b.b.ReceiptHash() }
+func (b *block) Miner() common.Address         { return b.b.Coinbase() }
+func (b *block) Validator() common.Address     { return b.b.Validator() }
+func (b *block) Difficulty() hexutil.Big       { return hexutil.Big(*b.b.Difficulty()) }
+func (b *block) ExtraData() hexutil.Bytes      { return hexutil.Bytes(b.b.Extra()) }
+func (b *block) GasLimit() hexutil.Big         { return hexutil.Big(*b.b.GasLimit()) }
+func (b *block) GasUsed() hexutil.Big          { return hexutil.Big(*b.b.GasUsed()) }
+func (b *block) Timestamp() hexutil.Big        { return hexutil.Big(*b.b.Time()) }
+func (b *block) LogsBloom() hexutil.Bytes      { return hexutil.Bytes(b.b.Bloom().Bytes()) }
+func (b *block) MixHash() common.Hash          { return b.b.MixDigest() }
+
+func (b *block) Nonce() hexutil.Bytes {
+	nonce := b.b.Header().Nonce
+	return hexutil.Bytes(nonce[:])
+}
+
+func (b *block) TransactionCount() hexutil.Uint64 {
+	return hexutil.Uint64(len(b.b.Transactions()))
+}
+
+// DposContext resolves the roots of the DPoS context tries of the block.
+func (b *block) DposContext() *dposRoots {
+	proto := b.b.Header().DposContext
+	if proto == nil {
+		return nil
+	}
+	return &dposRoots{proto}
+}
+
+// Transactions resolves a page of the transactions of the block, optionally of a
+// single type.
+func (b *block) Transactions(args struct {
+	First *int32
+	Skip  *int32
+	Type  *string
+}) ([]*transaction, error) {
+	skip, size, err := b.r.page(args.First, args.Skip)
+	if err != nil {
+		return nil, err
+	}
+	txs := []*transaction{}
+	for i, tx := range b.b.Transactions() {
+		if len(txs) >= size {
+			break
+		}
+		if args.Type != nil && txTypeNames[tx.Type()] != *args.Type {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		txs = append(txs, &transaction{r: b.r, tx: tx, block: b.b, index: uint64(i)})
+	}
+	return txs, nil
+}
+
+// Account resolves an account at the state of the block.
+func (b *block) Account(args struct{ Address common.Address }) *account {
+	return &account{r: b.r, address: args.Address, number: rpc.BlockNumber(b.b.NumberU64())}
+}
+
+// dposContext loads the DPoS context of the block from the trie database. The
+// context is loaded afresh for every query, so iterating it never touches the one
+// the chain shares between its users.
+func (b *block) dposContext() (*types.DposContext, error) {
+	proto := b.b.Header().DposContext
+	if proto == nil {
+		return nil, errors.New("block has no DPoS context")
+	}
+	return types.NewDposContextFromProto(b.r.backend.TrieDB(), proto)
+}
+
+// Validators resolves the validators of the epoch of the block.
+func (b *block) Validators() ([]common.Address, error) {
+	dpos, err := b.dposContext()
+	if err != nil {
+		return nil, err
+	}
+	return dpos.GetValidators()
+}
+
+// Candidates resolves a page of the candidates in the DPoS context of the block.
+func (b *block) Candidates(args struct {
+	First *int32
+	Skip  *int32
+}) ([]*candidate, error) {
+	skip, size, err := b.r.page(args.First, args.Skip)
+	if err != nil {
+		return nil, err
+	}
+	dpos, err := b.dposContext()
+	if err != nil {
+		return nil, err
+	}
+	candidates := []*candidate{}
+	err = pageTrie(dpos.CandidateTrie().NodeIterator(nil), skip, size, func(key, value []byte) {
+		candidates = append(candidates, &candidate{r: b.r, address: common.BytesToAddress(value), dpos: dpos})
+	})
+	return candidates, err
+}
+
+// Votes resolves a page of the votes in the DPoS context of the block, optionally
+// only the ones for a single candidate.
+func (b *block) Votes(args struct {
+	Candidate *common.Address
+	First     *int32
+	Skip      *int32
+}) ([]*vote, error) {
+	skip, size, err := b.r.page(args.First, args.Skip)
+	if err != nil {
+		return nil, err
+	}
+	dpos, err := b.dposContext()
+	if err != nil {
+		return nil, err
+	}
+	votes := []*vote{}
+	if args.Candidate != nil {
+		address := *args.Candidate
+		err = pageTrie(dpos.DelegateTrie().PrefixIterator(address.Bytes()), skip, size, func(key, value []byte) {
+			votes = append(votes, &vote{delegator: common.BytesToAddress(value), candidate: address})
+		})
+		return votes, err
+	}
+	err = pageTrie(dpos.VoteTrie().NodeIterator(nil), skip, size, func(key, value []byte) {
+		votes = append(votes, &vote{delegator: common.BytesToAddress(key), candidate: common.BytesToAddress(value)})
+	})
+	return votes, err
+}
+
+// pageTrie iterates the entries of a trie, skipping the requested number of them
+// and passing at most a page of them to the given function.
+func pageTrie(it trie.NodeIterator, skip, size int, item func(key, value []byte)) error {
+	iter := trie.NewIterator(it)
+	for n := 0; n < size && iter.Next(); {
+		if skip > 0 {
+			skip--
+			continue
+		}
+		item(iter.Key, iter.Value)
+		n++
+	}
+	return iter.Err
+}
+
+// dposRoots are the roots of the DPoS context tries of a block.
+type dposRoots struct {
+	proto *types.DposContextProto
+}
+
+func (d *dposRoots) EpochRoot() common.Hash     { return d.proto.EpochHash }
+func (d *dposRoots) DelegateRoot() common.Hash  { return d.proto.DelegateHash }
+func (d *dposRoots) CandidateRoot() common.Hash { return d.proto.CandidateHash }
+func (d *dposRoots) VoteRoot() common.Hash      { return d.proto.VoteHash }
+func (d *dposRoots) MintCntRoot() common.Hash   { return d.proto.MintCntHash }
+
+// candidate is a DPoS candidate in the context of a block.
+type candidate struct {
+	r       *resolver
+	address common.Address
+	dpos    *types.DposContext
+}
+
+func (c *candidate) Address() common.Address { return c.address }
+
+// Delegators resolves a page of the delegators of the candidate.
+func (c *candidate) Delegators(args struct {
+	First *int32
+	Skip  *int32
+}) ([]common.Address, error) {
+	skip, size, err := c.r.page(args.First, args.Skip)
+	if err != nil {
+		return nil, err
+	}
+	delegators := []common.Address{}
+	err = pageTrie(c.dpos.DelegateTrie().PrefixIterator(c.address.Bytes()), skip, size, func(key, value []byte) {
+		delegators = append(delegators, common.BytesToAddress(value))
+	})
+	return delegators, err
+}
+
+// vote is the delegation of a delegator to a candidate.
+type vote struct {
+	delegator common.Address
+	candidate common.Address
+}
+
+func (v *vote) Delegator() common.Address { return v.delegator }
+func (v *vote) Candidate() common.Address { return v.candidate }
+
+// transaction is a transaction included in a block.
+type transaction struct {
+	r     *resolver
+	tx    *types.Transaction
+	block *types.Block
+	index uint64
+
+	receipt *types.Receipt // Loaded on first use
+	lock    sync.Mutex     // Protects the receipt, as fields resolve concurrently
+}
+
+func (t *transaction) Hash() common.Hash     { return t.tx.Hash() }
+func (t *transaction) Type() string          { return txTypeNames[t.tx.Type()] }
+func (t *transaction) Nonce() hexutil.Uint64 { return hexutil.Uint64(t.tx.Nonce()) }
+func (t *transaction) To() *common.Address   { return t.tx.To() }
+func (t *transaction) Value() hexutil.Big    { return hexutil.Big(*t.tx.Value()) }
+func (t *transaction) Gas() hexutil.Big      { return hexutil.Big(*t.tx.Gas()) }
+func (t *transaction) GasPrice() hexutil.Big { return hexutil.Big(*t.tx.GasPrice()) }
+func (t *transaction) Input() hexutil.Bytes  { return hexutil.Bytes(t.tx.Data()) }
+func (t *transaction) Index() hexutil.Uint64 { return hexutil.Uint64(t.index) }
+func (t *transaction) Block() *block         { return &block{r: t.r, b: t.block} }
+
+// From resolves the sender of the transaction.
+func (t *transaction) From() (common.Address, error) {
+	signer := types.MakeSigner(t.r.backend.ChainConfig(), t.block.Number())
+	return types.Sender(signer, t.tx)
+}
+
+// getReceipt returns the receipt of the transaction, loading it once.
+func (t *transaction) getReceipt(ctx context.Context) (*types.Receipt, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.receipt != nil {
+		return t.receipt, nil
+	}
+	receipts, err := t.r.backend.GetReceipts(ctx, t.block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if t.index >= uint64(len(receipts)) {
+		return nil, fmt.Errorf("receipt of transaction %x not found", t.tx.Hash())
+	}
+	t.receipt = receipts[t.index]
+	return t.receipt, nil
+}
+
+func (t *transaction) Status(ctx context.Context) (hexutil.Uint64, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(receipt.Status), nil
+}
+
+func (t *transaction) GasUsed(ctx context.Context) (hexutil.Big, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*receipt.GasUsed), nil
+}
+
+func (t *transaction) CumulativeGasUsed(ctx context.Context) (hexutil.Big, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*receipt.CumulativeGasUsed), nil
+}
+
+func (t *transaction) ContractAddress(ctx context.Context) (*common.Address, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt.ContractAddress == (common.Address{}) {
+		return nil, err
+	}
+	return &receipt.ContractAddress, nil
+}
+
+// Logs resolves the logs emitted by the transaction.
+func (t *transaction) Logs(ctx context.Context) ([]*logEntry, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]*logEntry, len(receipt.Logs))
+	for i, log := range receipt.Logs {
+		logs[i] = &logEntry{r: t.r, log: log}
+	}
+	return logs, nil
+}
+
+// logEntry is a log emitted by a transaction.
+type logEntry struct {
+	r   *resolver
+	log *types.Log
+}
+
+func (l *logEntry) Index() hexutil.Uint64        { return hexutil.Uint64(l.log.Index) }
+func (l *logEntry) Address() common.Address      { return l.log.Address }
+func (l *logEntry) Topics() []common.Hash        { return l.log.Topics }
+func (l *logEntry) Data() hexutil.Bytes          { return hexutil.Bytes(l.log.Data) }
+func (l *logEntry) BlockNumber() hexutil.Uint64  { return hexutil.Uint64(l.log.BlockNumber) }
+func (l *logEntry) BlockHash() common.Hash       { return l.log.BlockHash }
+func (l *logEntry) TransactionHash() common.Hash { return l.log.TxHash }
+
+// Transaction resolves the transaction that emitted the log.
+func (l *logEntry) Transaction(ctx context.Context) (*transaction, error) {
+	b, err := l.r.backend.GetBlock(ctx, l.log.BlockHash)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	if l.log.TxIndex >= uint(len(b.Transactions())) {
+		return nil, fmt.Errorf("transaction %x not found", l.log.TxHash)
+	}
+	return &transaction{r: l.r, tx: b.Transactions()[l.log.TxIndex], block: b, index: uint64(l.log.TxIndex)}, nil
+}
+
+// account is an account at the state of a block.
+type account struct {
+	r       *resolver
+	address common.Address
+	number  rpc.BlockNumber
+
+	statedb *state.StateDB // Loaded on first use
+	lock    sync.Mutex     // Serializes the reads of the state
+}
+
+func (a *account) Address() common.Address { return a.address }
+
+// readState reads the account from its state, loading the state once. The reads
+// are serialized as the fields of the account resolve concurrently.
+func (a *account) readState(ctx context.Context, read func(statedb *state.StateDB)) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.statedb == nil {
+		statedb, _, err := a.r.backend.StateAndHeaderByNumber(ctx, a.number)
+		if err != nil {
+			return err
+		}
+		if statedb == nil {
+			return fmt.Errorf("block #%d not found", a.number)
+		}
+		a.statedb = statedb
+	}
+	read(a.statedb)
+	return nil
+}
+
+func (a *account) Balance(ctx context.Context) (balance hexutil.Big, err error) {
+	err = a.readState(ctx, func(statedb *state.StateDB) { balance = hexutil.Big(*statedb.GetBalance(a.address)) })
+	return balance, err
+}
+
+func (a *account) Nonce(ctx context.Context) (nonce hexutil.Uint64, err error) {
+	err = a.readState(ctx, func(statedb *state.StateDB) { nonce = hexutil.Uint64(statedb.GetNonce(a.address)) })
+	return nonce, err
+}
+
+func (a *account) Code(ctx context.Context) (code hexutil.Bytes, err error) {
+	err = a.readState(ctx, func(statedb *state.StateDB) { code = hexutil.Bytes(statedb.GetCode(a.address)) })
+	return code, err
+}
+
+// Storage resolves a storage slot of the account.
+func (a *account) Storage(ctx context.Context, args struct{ Slot common.Hash }) (value common.Hash, err error) {
+	err = a.readState(ctx, func(statedb *state.StateDB) { value = statedb.GetState(a.address, args.Slot) })
+	return value, err
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package graphql serves the chain, its receipts and logs, the accounts and the
+// DPoS context of the blocks over GraphQL queries.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/datx/filters"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/p2p"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace"
+)
+
+// maxRequestSize is the maximum size of a query request, unless the RPC policy
+// of the node limits it further.
+const maxRequestSize = 1024 * 128
+
+// Backend is the chain access the queries are resolved with.
+type Backend interface {
+	filters.Backend
+
+	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
+	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
+	ChainConfig() *params.ChainConfig
+	CurrentBlock() *types.Block
+	TrieDB() trie.Database
+}
+
+// Config are the limits imposed on the queries.
+type Config struct {
+	MaxCost         int    // Maximum number of fields resolved by a query
+	MaxDepth        int    // Maximum nesting of the selections of a query
+	DefaultPageSize int    // Number of items of the lists without a "first" argument
+	MaxPageSize     int    // Maximum number of items of a list
+	MaxBlockRange   uint64 // Maximum number of blocks searched for logs
+}
+
+// DefaultConfig contains the default limits of the queries.
+var DefaultConfig = Config{
+	MaxCost:         10000,
+	MaxDepth:        8,
+	DefaultPageSize: 20,
+	MaxPageSize:     100,
+	MaxBlockRange:   1000,
+}
+
+// Service serves GraphQL queries over the HTTP endpoint of a node.
+type Service struct {
+	schema *graphql.Schema
+	config Config
+}
+
+// New creates a GraphQL service resolving queries from the given backend.
+func New(backend Backend, config Config) (*Service, error) {
+	s := &Service{config: config}
+	schema, err := graphql.ParseSchema(schema, &resolver{backend: backend, config: &s.config},
+		graphql.MaxDepth(config.MaxDepth),
+		graphql.Tracer(costTracer{limit: int64(config.MaxCost)}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.schema = schema
+	return s, nil
+}
+
+// Protocols implements node.Service, returning no P2P protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, the queries are served by the node.
+func (s *Service) Start(server *p2p.Server) error {
+	log.Info("GraphQL endpoint enabled", "path", "/graphql")
+	return nil
+}
+
+// Stop implements node.Service.
+func (s *Service) Stop() error { return nil }
+
+// HTTPHandlers implements node.HTTPService, serving the queries under /graphql.
+func (s *Service) HTTPHandlers() map[string]http.Handler {
+	return map[string]http.Handler{"/graphql": s}
+}
+
+// query is a GraphQL request.
+type query struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ServeHTTP serves a query posted as JSON, or passed in the URL of a GET request.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var q query
+	switch r.Method {
+	case "GET":
+		values := r.URL.Query()
+		q.Query, q.OperationName = values.Get("query"), values.Get("operationName")
+		if vars := values.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &q.Variables); err != nil {
+				http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case "POST":
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&q); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := s.schema.Exec(r.Context(), q.Query, q.OperationName, q.Variables)
+
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debug("Failed to write GraphQL response", "err", err)
+	}
+}
+
+// costKey is the context key of the number of fields a query resolved.
+type costKey struct{}
+
+// costTracer limits the number of fields resolved by every query. Once a query
+// reaches the limit, the resolution of its remaining fields fails.
+type costTracer struct {
+	limit int64
+}
+
+// TraceQuery implements trace.Tracer, counting the fields of a query from zero.
+func (t costTracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, trace.TraceQueryFinishFunc) {
+	return context.WithValue(ctx, costKey{}, new(int64)), func([]*gqlerrors.QueryError) {}
+}
+
+// TraceField implements trace.Tracer, counting a field and cancelling its
+// resolution if the query exceeds the limit.
+func (t costTracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	if cost, ok := ctx.Value(costKey{}).(*int64); ok && atomic.AddInt64(cost, 1) > t.limit {
+		ctx = &exhaustedContext{ctx, fmt.Errorf("query too expensive: limit %d fields", t.limit)}
+	}
+	return ctx, func(*gqlerrors.QueryError) {}
+}
+
+// exhaustedContext is the context of a field beyond the cost limit of its query,
+// reporting why it isn't resolved.
+type exhaustedContext struct {
+	context.Context
+	err error
+}
+
+func (c *exhaustedContext) Err() error { return c.err }
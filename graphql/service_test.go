@@ -0,0 +1,196 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/datx/filters"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+var (
+	testValidator = common.HexToAddress("0x01")
+	testCandidate = common.HexToAddress("0xc1")
+	testDelegator = common.HexToAddress("0xd1")
+	testAccount   = common.HexToAddress("0xa1")
+)
+
+// testBackend serves a single block, whose state and DPoS context are committed
+// to a memory database.
+type testBackend struct {
+	filters.Backend // Not used by the tested queries
+
+	db    datxdb.Database
+	block *types.Block
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	db, _ := datxdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.AddBalance(testAccount, big.NewInt(1000))
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	dpos, err := types.NewDposContext(db)
+	if err != nil {
+		t.Fatalf("failed to create DPoS context: %v", err)
+	}
+	if err := dpos.SetValidators([]common.Address{testValidator}); err != nil {
+		t.Fatalf("failed to set validators: %v", err)
+	}
+	if err := dpos.BecomeCandidate(testCandidate); err != nil {
+		t.Fatalf("failed to add candidate: %v", err)
+	}
+	if err := dpos.Delegate(testDelegator, testCandidate); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+	proto, err := dpos.CommitTo(db)
+	if err != nil {
+		t.Fatalf("failed to commit DPoS context: %v", err)
+	}
+	block := types.NewBlockWithHeader(&types.Header{
+		Number:      big.NewInt(1),
+		Root:        root,
+		Time:        big.NewInt(1000),
+		GasLimit:    big.NewInt(8000000),
+		GasUsed:     new(big.Int),
+		Difficulty:  big.NewInt(1),
+		Validator:   testValidator,
+		DposContext: proto,
+	})
+	// Attach a context shared with the chain that queries must not read
+	block.DposContext, _ = types.NewDposContext(db)
+	return &testBackend{db: db, block: block}
+}
+
+func (b *testBackend) ChainDb() datxdb.Database         { return b.db }
+func (b *testBackend) TrieDB() trie.Database            { return b.db }
+func (b *testBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (b *testBackend) CurrentBlock() *types.Block       { return b.block }
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return nil, nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber || uint64(number) == b.block.NumberU64() {
+		return b.block, nil
+	}
+	return nil, nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if hash == b.block.Hash() {
+		return b.block, nil
+	}
+	return nil, nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	block, _ := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, nil, nil
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(b.db))
+	return statedb, block.Header(), err
+}
+
+// runQuery runs a query through the HTTP handler of a service and returns the
+// response.
+func runQuery(service *Service, q string) string {
+	rec := httptest.NewRecorder()
+	service.ServeHTTP(rec, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(q), nil))
+	return strings.TrimSpace(rec.Body.String())
+}
+
+func TestQueries(t *testing.T) {
+	config := DefaultConfig
+	config.MaxCost, config.MaxDepth, config.DefaultPageSize, config.MaxPageSize = 12, 3, 1, 2
+
+	service, err := New(newTestBackend(t), config)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// Blocks, accounts and the DPoS context loaded from the trie database
+		{`{ block { number validator } }`, `{"data":{"block":{"number":"0x1","validator":"0x0000000000000000000000000000000000000001"}}}`},
+		{`{ block(number: 2) { number } }`, `{"data":{"block":null}}`},
+		{`{ account(address: "0x00000000000000000000000000000000000000a1", block: "latest") { balance nonce } }`, `{"data":{"account":{"balance":"0x3e8","nonce":"0x0"}}}`},
+		{`{ block { validators } }`, `{"data":{"block":{"validators":["0x0000000000000000000000000000000000000001"]}}}`},
+		{`{ block { candidates { address delegators } } }`, `{"data":{"block":{"candidates":[{"address":"0x00000000000000000000000000000000000000c1","delegators":["0x00000000000000000000000000000000000000d1"]}]}}}`},
+		{`{ block { votes(candidate: "0x00000000000000000000000000000000000000c1") { delegator } } }`, `{"data":{"block":{"votes":[{"delegator":"0x00000000000000000000000000000000000000d1"}]}}}`},
+		// Limits on depth and the number of fields resolved
+		{`{ block { transactions { block { number } } } }`, `{"errors":[{"message":"Field \"number\" has depth 4 that exceeds max depth 3","locations":[{"line":1,"column":34}]}]}`},
+	}
+	for i, tt := range tests {
+		if have := runQuery(service, tt.query); have != tt.want {
+			t.Errorf("test %d: response mismatch:\nhave %s\nwant %s", i, have, tt.want)
+		}
+	}
+	// Fields beyond the cost limit aren't resolved, in whatever order the fields
+	// resolve concurrently
+	var resp struct {
+		Data   map[string]*struct{ Number string }
+		Errors []struct{ Message string }
+	}
+	have := runQuery(service, `{ a: block { number } b: block { number } c: block { number } d: block { number } e: block { number } f: block { number } g: block { number } }`)
+	if err := json.Unmarshal([]byte(have), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", have, err)
+	}
+	resolved := 0
+	for _, block := range resp.Data {
+		if block != nil {
+			resolved++
+		}
+	}
+	if resolved != 6 || len(resp.Errors) != 1 || resp.Errors[0].Message != "query too expensive: limit 12 fields" {
+		t.Errorf("expensive query response mismatch: %s", have)
+	}
+}
+
+func TestQueryVariables(t *testing.T) {
+	service, err := New(newTestBackend(t), DefaultConfig)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":     `query Q($number: Long) { block(number: $number) { number } }`,
+		"variables": map[string]interface{}{"number": 1},
+	})
+	rec := httptest.NewRecorder()
+	service.ServeHTTP(rec, httptest.NewRequest("POST", "/graphql", bytes.NewReader(body)))
+	if have, want := strings.TrimSpace(rec.Body.String()), `{"data":{"block":{"number":"0x1"}}}`; have != want {
+		t.Errorf("response mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
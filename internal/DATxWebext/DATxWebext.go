@@ -80,6 +80,11 @@ DATxWeb._extend({
 			params: 0,
 			outputFormatter: DATxWeb._extend.utils.toBigNumber
 		}),
+		new DATxWeb._extend.Method({
+			name: 'getClockOffset',
+			call: 'dpos_getClockOffset',
+			params: 0
+		}),
 	]
 });
 `
@@ -155,6 +160,15 @@ DATxWeb._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new DATxWeb._extend.Method({
+			name: 'txPoolPolicy',
+			call: 'admin_txPoolPolicy'
+		}),
+		new DATxWeb._extend.Method({
+			name: 'setTxPoolPolicy',
+			call: 'admin_setTxPoolPolicy',
+			params: 1
+		}),
 		new DATxWeb._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -230,12 +244,14 @@ DATxWeb._extend({
 		new DATxWeb._extend.Method({
 			name: 'traceBlockByNumber',
 			call: 'debug_traceBlockByNumber',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new DATxWeb._extend.Method({
 			name: 'traceBlockByHash',
 			call: 'debug_traceBlockByHash',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new DATxWeb._extend.Method({
 			name: 'seedHash',
@@ -433,6 +449,23 @@ DATxWeb._extend({
 			params: 2,
 			inputFormatter: [DATxWeb._extend.formatters.inputBlockNumberFormatter, DATxWeb._extend.utils.toHex]
 		}),
+		new DATxWeb._extend.Method({
+			name: 'simulate',
+			call: 'datx_simulate',
+			params: 2,
+			inputFormatter: [null, DATxWeb._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new DATxWeb._extend.Method({
+			name: 'getTransactionsByAddress',
+			call: 'datx_getTransactionsByAddress',
+			params: 4,
+			inputFormatter: [DATxWeb._extend.formatters.inputAddressFormatter, DATxWeb._extend.formatters.inputBlockNumberFormatter, DATxWeb._extend.formatters.inputBlockNumberFormatter, DATxWeb._extend.utils.toHex]
+		}),
+		new DATxWeb._extend.Method({
+			name: 'stateAvailability',
+			call: 'datx_stateAvailability',
+			params: 0
+		}),
 	],
 	properties: [
 		new DATxWeb._extend.Property({
@@ -610,7 +643,13 @@ DATxWeb._extend({
 const TxPool_JS = `
 DATxWeb._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new DATxWeb._extend.Method({
+			name: 'getReplacement',
+			call: 'txpool_getReplacement',
+			params: 1
+		}),
+	],
 	properties:
 	[
 		new DATxWeb._extend.Property({
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/DATxChain-Protocol/DATx/accounts"
+	"github.com/DATxChain-Protocol/DATx/accounts/abi"
 	"github.com/DATxChain-Protocol/DATx/accounts/keystore"
 	"github.com/DATxChain-Protocol/DATx/common"
 	"github.com/DATxChain-Protocol/DATx/common/hexutil"
@@ -173,6 +174,44 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// RPCTxReplacement describes how a transaction was replaced in the pool by one
+// of the same sender and nonce.
+type RPCTxReplacement struct {
+	ReplacedBy common.Hash     `json:"replacedBy"` // Transaction that directly replaced it
+	Latest     *RPCTransaction `json:"latest"`     // Last transaction of the replacement chain
+	Cancelled  bool            `json:"cancelled"`  // Whether the latest one is a cancellation
+	Time       hexutil.Uint64  `json:"time"`       // Time of the direct replacement
+}
+
+// GetReplacement returns how a transaction was replaced in the pool, following
+// the replacements of the replacing transactions too. Cancellations are plain
+// transfers of no value to the sender itself. Nil is returned if the transaction
+// wasn't replaced recently.
+func (s *PublicTxPoolAPI) GetReplacement(hash common.Hash) *RPCTxReplacement {
+	replacement := s.b.GetPoolReplacement(hash)
+	if replacement == nil {
+		return nil
+	}
+	latest := replacement.By
+	for seen := map[common.Hash]bool{hash: true}; !seen[latest.Hash()]; {
+		seen[latest.Hash()] = true
+		next := s.b.GetPoolReplacement(latest.Hash())
+		if next == nil {
+			break
+		}
+		latest = next.By
+	}
+	result := &RPCTxReplacement{
+		ReplacedBy: replacement.By.Hash(),
+		Latest:     newRPCPendingTransaction(latest),
+		Time:       hexutil.Uint64(replacement.Time.Unix()),
+	}
+	if to := latest.To(); to != nil && *to == result.Latest.From {
+		result.Cancelled = latest.Type() == types.Binary && latest.Value().Sign() == 0 && len(latest.Data()) == 0
+	}
+	return result
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -577,24 +616,31 @@ type CallArgs struct {
 	GasPrice hexutil.Big     `json:"gasPrice"`
 	Value    hexutil.Big     `json:"value"`
 	Data     hexutil.Bytes   `json:"data"`
+	Type     types.TxType    `json:"type"`
+}
+
+// callSender returns the sender of a call, defaulting to the first account of
+// the node if none is specified.
+func (s *PublicBlockChainAPI) callSender(from common.Address) common.Address {
+	if from == (common.Address{}) {
+		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
+			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
+				return accounts[0].Address
+			}
+		}
+	}
+	return from
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) ([]byte, *big.Int, bool, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
-		return nil, common.Big0, false, err
+		return nil, err
 	}
 	// Set sender address or use a default if none specified
-	addr := args.From
-	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
-			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
-				addr = accounts[0].Address
-			}
-		}
-	}
+	addr := s.callSender(args.From)
 	// Set default gas & gas price if none were set
 	gas, gasPrice := args.Gas.ToInt(), args.GasPrice.ToInt()
 	if gas.Sign() == 0 {
@@ -622,7 +668,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Get a new instance of the EVM.
 	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
-		return nil, common.Big0, false, err
+		return nil, err
 	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -634,66 +680,27 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Setup the gas pool (also for unmetered requests)
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxBig256)
-	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
+	result, err := core.ApplyMessageResult(evm, msg, gp)
 	if err := vmError(); err != nil {
-		return nil, common.Big0, false, err
+		return nil, err
 	}
-	return res, gas, failed, err
+	return result, err
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
+// Reverted calls fail with the revert reason, the data they returned being passed
+// as the data of the error.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
-	return (hexutil.Bytes)(result), err
-}
-
-// EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*hexutil.Big, error) {
-	// Determine the lowest and highest possible gas limits to binary search in between
-	var (
-		lo  uint64 = params.TxGas - 1
-		hi  uint64
-		cap uint64
-	)
-	if (*big.Int)(&args.Gas).Uint64() >= params.TxGas {
-		hi = (*big.Int)(&args.Gas).Uint64()
-	} else {
-		// Retrieve the current pending block to act as the gas ceiling
-		block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
-		if err != nil {
-			return nil, err
-		}
-		hi = block.GasLimit().Uint64()
-	}
-	cap = hi
-
-	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
-		(*big.Int)(&args.Gas).SetUint64(gas)
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
-		if err != nil || failed {
-			return false
-		}
-		return true
-	}
-	// Execute the binary search and hone in on an executable gas limit
-	for lo+1 < hi {
-		mid := (hi + lo) / 2
-		if !executable(mid) {
-			lo = mid
-		} else {
-			hi = mid
-		}
+	result, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
+	if err != nil {
+		return nil, err
 	}
-	// Reject the transaction as invalid if it still fails at the highest allowance
-	if hi == cap {
-		if !executable(hi) {
-			return nil, fmt.Errorf("gas required exceeds allowance or always failing transaction")
-		}
+	// Only reverts carry a reason, other failures return their (empty) data as before
+	if result.Err == vm.ErrExecutionReverted && len(result.ReturnData) > 0 {
+		return nil, newRevertError(result.ReturnData)
 	}
-	return (*hexutil.Big)(new(big.Int).SetUint64(hi)), nil
+	return (hexutil.Bytes)(result.ReturnData), nil
 }
 
 // ExecutionResult groups all structured logs emitted by the EVM
@@ -833,6 +840,7 @@ type RPCTransaction struct {
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+	ReplacedBy       *common.Hash    `json:"replacedBy,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -982,6 +990,12 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
 		return newRPCPendingTransaction(tx)
 	}
+	// Not pooled either, report it if it was replaced recently
+	if replacement := s.b.GetPoolReplacement(hash); replacement != nil {
+		tx, by := newRPCPendingTransaction(replacement.Tx), replacement.By.Hash()
+		tx.ReplacedBy = &by
+		return tx
+	}
 	// Transaction unknown, return as such
 	return nil
 }
@@ -1043,6 +1057,13 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Include the revert reason if the node recorded it
+	if len(receipt.RevertData) > 0 {
+		fields["revertData"] = hexutil.Bytes(receipt.RevertData)
+		if reason, err := abi.UnpackRevert(receipt.RevertData); err == nil {
+			fields["revertReason"] = reason
+		}
+	}
 	return fields, nil
 }
 
@@ -0,0 +1,230 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/common/math"
+	"github.com/DATxChain-Protocol/DATx/consensus"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// EstimateGas returns an estimate of the amount of gas needed to execute the
+// given transaction against the current pending block.
+//
+// The transaction is applied the way it would be mined, DPoS transactions
+// changing the DPoS context too, and is paid for from the sender's balance if
+// it has a gas price. Transactions failing with any allowance are rejected with
+// the reason they fail, DPoS transactions also if the DPoS context would ignore
+// them.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*hexutil.Big, error) {
+	defer func(start time.Time) { log.Debug("Estimating gas finished", "runtime", time.Since(start)) }(time.Now())
+
+	if args.Type != types.Binary && args.To == nil {
+		return nil, types.ErrInvalidType
+	}
+	block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("pending block unavailable")
+	}
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	est := &estimator{
+		config:  s.b.ChainConfig(),
+		chain:   &chainContext{ctx: ctx, b: s.b},
+		header:  header,
+		statedb: statedb,
+		from:    s.callSender(args.From),
+		args:    args,
+	}
+	if args.Type != types.Binary {
+		// The pending block carries a context of its own, its tries aren't committed
+		if dpos := block.DposCtx(); dpos != nil {
+			est.dpos = dpos.Copy()
+		} else if est.dpos, err = loadDposContext(ctx, s.b, block.Header()); err != nil {
+			return nil, err
+		}
+		if err := dposFailure(est.dpos, est.message(0)); err != nil {
+			return nil, fmt.Errorf("DPoS transaction ignored: %v", err)
+		}
+	}
+	// Determine the lowest and highest possible gas limits to binary search in between
+	var (
+		lo  uint64 = params.TxGas - 1
+		hi  uint64
+		cap uint64
+	)
+	if args.Gas.ToInt().Uint64() >= params.TxGas {
+		hi = args.Gas.ToInt().Uint64()
+	} else {
+		// Use the gas limit of the pending block as the gas ceiling
+		hi = block.GasLimit().Uint64()
+	}
+	// Cap the allowance to what the sender can pay for
+	if gasPrice := args.GasPrice.ToInt(); gasPrice.Sign() > 0 {
+		available := new(big.Int).Sub(statedb.GetBalance(est.from), args.Value.ToInt())
+		if available.Sign() < 0 {
+			return nil, vm.ErrInsufficientBalance
+		}
+		if allowance := available.Div(available, gasPrice); allowance.IsUint64() && allowance.Uint64() < hi {
+			hi = allowance.Uint64()
+		}
+	}
+	cap = hi
+
+	// Reject the transaction outright if it fails at the highest allowance
+	result, err := est.execute(ctx, hi)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil || result.Failed() {
+		return nil, estimateFailure(result, cap)
+	}
+	// The gas used, net of refunds, is a lower bound of the gas needed
+	if used := result.UsedGas.Uint64(); used > lo+1 {
+		lo = used - 1
+	}
+	// Execute the binary search and hone in on an executable gas limit
+	for lo+1 < hi {
+		mid := (hi + lo) / 2
+		result, err := est.execute(ctx, mid)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil || result.Failed() {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (*hexutil.Big)(new(big.Int).SetUint64(hi)), nil
+}
+
+// estimateFailure returns the reason a transaction fails with the highest gas
+// allowance, its outcome being nil if the allowance doesn't cover its intrinsic
+// gas.
+func estimateFailure(result *core.ExecutionResult, cap uint64) error {
+	if result == nil {
+		return fmt.Errorf("intrinsic gas exceeds allowance (%d)", cap)
+	}
+	switch result.Err {
+	case vm.ErrOutOfGas, vm.ErrCodeStoreOutOfGas:
+		return fmt.Errorf("gas required exceeds allowance (%d)", cap)
+	case vm.ErrExecutionReverted:
+		if len(result.ReturnData) > 0 {
+			return newRevertError(result.ReturnData)
+		}
+		return errors.New("execution reverted")
+	}
+	return fmt.Errorf("always failing transaction: %v", result.Err)
+}
+
+// dposFailure returns why the DPoS context would ignore the change a typed
+// message makes. Such transactions are still mined, ApplyDposMessage leaving the
+// context unchanged.
+func dposFailure(dpos *types.DposContext, msg types.Message) error {
+	dpos = dpos.Copy()
+	switch msg.Type() {
+	case types.LoginCandidate:
+		return dpos.BecomeCandidate(msg.From())
+	case types.LogoutCandidate:
+		return dpos.KickoutCandidate(msg.From())
+	case types.Delegate:
+		return dpos.Delegate(msg.From(), *msg.To())
+	case types.UnDelegate:
+		return dpos.UnDelegate(msg.From(), *msg.To())
+	}
+	return types.ErrInvalidType
+}
+
+// estimator applies a transaction with varying gas allowances, each time on
+// fresh copies of the pending state and DPoS context.
+type estimator struct {
+	config  *params.ChainConfig
+	chain   core.ChainContext
+	header  *types.Header
+	statedb *state.StateDB
+	dpos    *types.DposContext // DPoS context of the pending block, nil for binary transactions
+	from    common.Address
+	args    CallArgs
+}
+
+// message creates the message of the transaction with the given gas allowance.
+func (est *estimator) message(gas uint64) types.Message {
+	args := est.args
+	nonce := est.statedb.GetNonce(est.from)
+	return types.NewMessage(est.from, args.To, nonce, args.Value.ToInt(), new(big.Int).SetUint64(gas), args.GasPrice.ToInt(), args.Data, false).WithType(args.Type)
+}
+
+// execute applies the transaction with the given gas allowance. The outcome is
+// nil if the allowance doesn't cover the intrinsic gas, an error is only
+// returned if the transaction can't be applied with any allowance.
+func (est *estimator) execute(ctx context.Context, gas uint64) (*core.ExecutionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var dpos *types.DposContext
+	if est.dpos != nil {
+		dpos = est.dpos.Copy()
+	}
+	gp := new(core.GasPool).AddGas(math.MaxBig256)
+	_, result, err := core.ApplyMessageTransaction(est.config, dpos, est.chain, &est.header.Coinbase, gp, est.statedb.Copy(), est.header, est.message(gas), common.Hash{}, new(big.Int), vm.Config{})
+	if err == vm.ErrOutOfGas {
+		return nil, nil
+	}
+	return result, err
+}
+
+// chainContext retrieves the headers of the chain for the EVM of a call. The
+// coinbase of the calls is taken from their header, so the consensus engine is
+// never needed.
+type chainContext struct {
+	ctx context.Context
+	b   Backend
+}
+
+// Engine implements core.ChainContext.
+func (c *chainContext) Engine() consensus.Engine {
+	return nil
+}
+
+// GetHeader implements core.ChainContext.
+func (c *chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header, _ := c.b.HeaderByNumber(c.ctx, rpc.BlockNumber(number))
+	if header == nil || header.Hash() != hash {
+		return nil
+	}
+	return header
+}
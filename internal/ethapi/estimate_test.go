@@ -0,0 +1,139 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+func TestEstimateGas(t *testing.T) {
+	var (
+		alice     = common.HexToAddress("0xa1")
+		bob       = common.HexToAddress("0xb0")
+		carol     = common.HexToAddress("0xca")
+		candidate = common.HexToAddress("0xcc")
+		store     = common.HexToAddress("0xc1")
+		revert    = common.HexToAddress("0xc2")
+		reason    = common.HexToAddress("0xc3")
+	)
+	backend := newSimulateBackend(t)
+	api := NewPublicBlockChainAPI(backend)
+
+	backend.statedb.SetBalance(alice, new(big.Int).SetUint64(params.DATx))
+	backend.statedb.SetBalance(carol, big.NewInt(30000))
+	backend.statedb.SetCode(store, []byte{0x60, 0x01, 0x60, 0x00, 0x55})                                // SSTORE(0, 1)
+	backend.statedb.SetCode(revert, []byte{0x60, 0x00, 0x60, 0x00, 0xfd})                               // REVERT(0, 0)
+	backend.statedb.SetCode(reason, []byte{0x60, 0x2a, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xfd}) // MSTORE(0, 42) REVERT(0, 32)
+
+	dpos := backend.block.DposCtx()
+	if err := dpos.BecomeCandidate(candidate); err != nil {
+		t.Fatalf("failed to add candidate: %v", err)
+	}
+	if err := dpos.Delegate(carol, candidate); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+	tests := []struct {
+		args CallArgs
+		gas  uint64
+		err  string
+	}{
+		// Binary transactions, transfers and contract calls
+		{args: CallArgs{From: alice, To: &bob, Value: hexutil.Big(*big.NewInt(1))}, gas: params.TxGas},
+		{args: CallArgs{From: alice, To: &store}, gas: params.TxGas + 6 + params.SstoreSetGas},
+		{args: CallArgs{From: alice, To: &revert}, err: "execution reverted"},
+		{args: CallArgs{From: alice, To: &reason}, err: "execution reverted"},
+		{args: CallArgs{From: carol, To: &store, GasPrice: hexutil.Big(*big.NewInt(1))}, err: "gas required exceeds allowance (30000)"},
+		{args: CallArgs{From: bob, To: &alice, Value: hexutil.Big(*big.NewInt(1)), GasPrice: hexutil.Big(*big.NewInt(1))}, err: "insufficient balance"},
+
+		// DPoS transactions, changing the DPoS context of the pending block
+		{args: CallArgs{From: bob, To: &bob, Type: types.LoginCandidate}, gas: params.TxGas},
+		{args: CallArgs{From: candidate, To: &candidate, Type: types.LogoutCandidate}, gas: params.TxGas},
+		{args: CallArgs{From: alice, To: &candidate, Type: types.Delegate}, gas: params.TxGas},
+		{args: CallArgs{From: alice, To: &bob, Type: types.Delegate}, err: "invalid candidate to delegate"},
+		{args: CallArgs{From: carol, To: &candidate, Type: types.UnDelegate}, gas: params.TxGas},
+		{args: CallArgs{From: alice, To: &candidate, Type: types.UnDelegate}, err: "mismatch candidate to undelegate"},
+		{args: CallArgs{From: alice, Type: types.Delegate}, err: types.ErrInvalidType.Error()},
+		{args: CallArgs{From: alice, To: &bob, Type: types.TxType(42)}, err: types.ErrInvalidType.Error()},
+	}
+	for i, tt := range tests {
+		gas, err := api.EstimateGas(context.Background(), tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to estimate: %v", i, err)
+			continue
+		}
+		if gas.ToInt().Uint64() != tt.gas {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas.ToInt().Uint64(), tt.gas)
+		}
+	}
+	// The estimations must not leak into the chain
+	if vote, _ := dpos.VoteTrie().TryGet(alice.Bytes()); vote != nil {
+		t.Errorf("chain DPoS context modified by the estimation")
+	}
+}
+
+// Tests that DPoS transactions are estimated against the DPoS context loaded from
+// the trie database if the pending block doesn't carry one, like on nodes that
+// aren't mining.
+func TestEstimateGasLoadedDpos(t *testing.T) {
+	var (
+		alice     = common.HexToAddress("0xa1")
+		candidate = common.HexToAddress("0xcc")
+	)
+	backend := newSimulateBackend(t)
+	api := NewPublicBlockChainAPI(backend)
+
+	dpos := backend.block.DposCtx()
+	if err := dpos.BecomeCandidate(candidate); err != nil {
+		t.Fatalf("failed to add candidate: %v", err)
+	}
+	proto, err := dpos.CommitTo(backend.db)
+	if err != nil {
+		t.Fatalf("failed to commit DPoS context: %v", err)
+	}
+	header := backend.block.Header()
+	header.DposContext = proto
+	backend.block = types.NewBlockWithHeader(header)
+
+	if gas, err := api.EstimateGas(context.Background(), CallArgs{From: alice, To: &candidate, Type: types.Delegate}); err != nil {
+		t.Errorf("failed to estimate delegation: %v", err)
+	} else if gas.ToInt().Uint64() != params.TxGas {
+		t.Errorf("gas mismatch: have %d, want %d", gas.ToInt().Uint64(), params.TxGas)
+	}
+	if _, err := api.EstimateGas(context.Background(), CallArgs{From: alice, To: &alice, Type: types.Delegate}); err == nil || !strings.Contains(err.Error(), "invalid candidate to delegate") {
+		t.Errorf("error mismatch: have %v, want invalid candidate", err)
+	}
+	// A pending block without any DPoS context can't be estimated against
+	header.DposContext = nil
+	backend.block = types.NewBlockWithHeader(header)
+	if _, err := api.EstimateGas(context.Background(), CallArgs{From: alice, To: &candidate, Type: types.Delegate}); err == nil {
+		t.Errorf("estimated without a DPoS context")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"github.com/DATxChain-Protocol/DATx/accounts/abi"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+)
+
+// revertErrorCode is the JSON-RPC error code of reverted executions.
+const revertErrorCode = 3
+
+// revertError is the error of an execution reverted with data. The message holds
+// the revert reason if the data is an Error(string), while the data itself is
+// passed to RPC clients as the data of the error.
+type revertError struct {
+	message string
+	reason  string // Reason the data resolves to, empty if it isn't an Error(string)
+	data    []byte // Data returned by the reverted execution
+}
+
+// newRevertError creates the error of an execution reverted with the given data.
+func newRevertError(data []byte) *revertError {
+	err := &revertError{message: "execution reverted", data: data}
+	if reason, unpackErr := abi.UnpackRevert(data); unpackErr == nil {
+		err.reason = reason
+		err.message += ": " + reason
+	}
+	return err
+}
+
+// Error implements error.
+func (e *revertError) Error() string { return e.message }
+
+// ErrorCode implements rpc.Error, returning the code of reverted executions.
+func (e *revertError) ErrorCode() int { return revertErrorCode }
+
+// ErrorData implements rpc.DataError, returning the hex encoded revert data.
+func (e *revertError) ErrorData() interface{} { return hexutil.Encode(e.data) }
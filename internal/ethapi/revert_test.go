@@ -0,0 +1,92 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// Tests that reverted calls and estimations fail with the revert reason, passing
+// the revert data along to RPC clients.
+func TestRevertError(t *testing.T) {
+	var (
+		alice    = common.HexToAddress("0xa1")
+		reason   = common.HexToAddress("0xc1")
+		noReason = common.HexToAddress("0xc2")
+		stop     = common.HexToAddress("0xc3")
+		invalid  = common.HexToAddress("0xc4")
+	)
+	// Error("not enough"), returned by copying it from the code
+	data := common.Hex2Bytes("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000a6e6f7420656e6f75676800000000000000000000000000000000000000000000")
+
+	backend := newSimulateBackend(t)
+	backend.statedb.SetCode(reason, append(common.Hex2Bytes("6064600c60003960646000fd"), data...))        // CODECOPY(0, 12, 100) REVERT(0, 100)
+	backend.statedb.SetCode(noReason, []byte{0x60, 0x2a, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xfd}) // MSTORE(0, 42) REVERT(0, 32)
+	backend.statedb.SetCode(stop, []byte{0x60, 0x2a, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3})     // MSTORE(0, 42) RETURN(0, 32)
+	backend.statedb.SetCode(invalid, []byte{0xfe})                                                        // INVALID
+	api := NewPublicBlockChainAPI(backend)
+
+	tests := []struct {
+		to      common.Address
+		message string
+		data    []byte
+	}{
+		{reason, "execution reverted: not enough", data},
+		{noReason, "execution reverted", common.LeftPadBytes([]byte{42}, 32)},
+	}
+	for i, tt := range tests {
+		args := CallArgs{From: alice, To: &tt.to}
+		_, callErr := api.Call(context.Background(), args, rpc.LatestBlockNumber)
+		_, estimateErr := api.EstimateGas(context.Background(), args)
+
+		for name, err := range map[string]error{"call": callErr, "estimate": estimateErr} {
+			revertErr, ok := err.(*revertError)
+			if !ok {
+				t.Errorf("test %d, %s: error mismatch: have %v, want revert error", i, name, err)
+				continue
+			}
+			if revertErr.Error() != tt.message {
+				t.Errorf("test %d, %s: message mismatch: have %q, want %q", i, name, revertErr.Error(), tt.message)
+			}
+			if revertErr.ErrorCode() != revertErrorCode {
+				t.Errorf("test %d, %s: code mismatch: have %d, want %d", i, name, revertErr.ErrorCode(), revertErrorCode)
+			}
+			if have, want := revertErr.ErrorData(), hexutil.Encode(tt.data); have != want {
+				t.Errorf("test %d, %s: data mismatch: have %v, want %s", i, name, have, want)
+			}
+		}
+	}
+	// Successful calls return their data
+	ret, err := api.Call(context.Background(), CallArgs{From: alice, To: &stop}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	if want := common.LeftPadBytes([]byte{42}, 32); !bytes.Equal(ret, want) {
+		t.Errorf("return data mismatch: have %x, want %x", ret, want)
+	}
+	// Other failures return no data, without an error
+	ret, err = api.Call(context.Background(), CallArgs{From: alice, To: &invalid}, rpc.LatestBlockNumber)
+	if err != nil || len(ret) != 0 {
+		t.Errorf("failed call result mismatch: have %x, %v, want empty result", ret, err)
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/common/math"
+	"github.com/DATxChain-Protocol/DATx/consensus"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+const (
+	maxSimulateBlocks     = 256             // Maximum number of blocks simulated at once
+	maxSimulateCalls      = 1024            // Maximum number of calls over all the simulated blocks
+	simulateTimeout       = 5 * time.Second // Maximum time spent simulating the blocks
+	simulateBlockInterval = 10              // Seconds between the simulated blocks, as minted by DPoS
+)
+
+// AccountOverride replaces fields of an account before the calls of a simulated
+// block are made.
+type AccountOverride struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   *hexutil.Uint64             `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// StateOverride is the set of accounts overridden before a simulated block.
+type StateOverride map[common.Address]AccountOverride
+
+// apply overrides the accounts in the given state.
+func (overrides StateOverride) apply(statedb *state.StateDB) {
+	for addr, account := range overrides {
+		if account.Balance != nil {
+			statedb.SetBalance(addr, account.Balance.ToInt())
+		}
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+}
+
+// BlockOverrides replaces fields of the header of a simulated block, which are
+// otherwise derived from its parent. The fees of a block with an overridden
+// validator are paid to the validator, as its coinbase is overridden too.
+type BlockOverrides struct {
+	Number    *hexutil.Big    `json:"number"`
+	Time      *hexutil.Big    `json:"time"`
+	Validator *common.Address `json:"validator"`
+}
+
+// SimulateBlock is a block of calls to simulate, along with the overrides applied
+// before they are made.
+type SimulateBlock struct {
+	BlockOverrides *BlockOverrides `json:"blockOverrides"`
+	StateOverrides StateOverride   `json:"stateOverrides"`
+	Calls          []*CallArgs     `json:"calls"`
+}
+
+// SimulateCallResult is the outcome of a simulated call. Calls that couldn't be
+// made at all, like the ones without the funds to pay for their gas, report why
+// and don't change the state.
+type SimulateCallResult struct {
+	ReturnData      hexutil.Bytes   `json:"returnData"`
+	Logs            []*types.Log    `json:"logs"`
+	GasUsed         *hexutil.Big    `json:"gasUsed"`
+	Status          hexutil.Uint    `json:"status"`
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// SimulateBlockResult is the outcome of the calls of a simulated block.
+type SimulateBlockResult struct {
+	Number    *hexutil.Big          `json:"number"`
+	Hash      common.Hash           `json:"hash"`
+	Time      *hexutil.Big          `json:"time"`
+	Validator common.Address        `json:"validator"`
+	GasUsed   *hexutil.Big          `json:"gasUsed"`
+	Calls     []*SimulateCallResult `json:"calls"`
+}
+
+// Simulate makes the calls of a sequence of simulated blocks on top of the state
+// of the given block. The calls share their state, each one seeing the changes of
+// the previous ones, including the changes DPoS transactions make to the DPoS
+// context. Calls without a gas price don't pay for the gas they use.
+//
+// The simulated blocks aren't finalized, so no epoch is elected while simulating
+// and the validators of the simulated blocks are the ones of the given block.
+func (s *PublicBlockChainAPI) Simulate(ctx context.Context, blocks []*SimulateBlock, blockNr rpc.BlockNumber) ([]*SimulateBlockResult, error) {
+	if len(blocks) == 0 {
+		return nil, errors.New("no blocks to simulate")
+	}
+	if len(blocks) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks to simulate: %d, limit %d", len(blocks), maxSimulateBlocks)
+	}
+	calls := 0
+	for i, block := range blocks {
+		if block == nil {
+			return nil, fmt.Errorf("block %d missing", i)
+		}
+		for j, call := range block.Calls {
+			if call == nil {
+				return nil, fmt.Errorf("block %d, call %d missing", i, j)
+			}
+		}
+		calls += len(block.Calls)
+	}
+	if calls > maxSimulateCalls {
+		return nil, fmt.Errorf("too many calls to simulate: %d, limit %d", calls, maxSimulateCalls)
+	}
+	statedb, parent, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, simulateTimeout)
+	defer cancel()
+
+	sim := &simulator{
+		ctx:     ctx,
+		b:       s.b,
+		base:    parent,
+		statedb: statedb,
+		headers: make(map[common.Hash]*types.Header),
+		sender:  s.callSender,
+	}
+	results := make([]*SimulateBlockResult, 0, len(blocks))
+	for i, block := range blocks {
+		header, err := sim.header(parent, block.BlockOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", i, err)
+		}
+		block.StateOverrides.apply(statedb)
+
+		result := &SimulateBlockResult{
+			Number:    (*hexutil.Big)(header.Number),
+			Hash:      header.Hash(),
+			Time:      (*hexutil.Big)(header.Time),
+			Validator: header.Validator,
+			Calls:     make([]*SimulateCallResult, 0, len(block.Calls)),
+		}
+		gasUsed := new(big.Int)
+		for j, args := range block.Calls {
+			call, err := sim.call(header, j, args)
+			if err != nil {
+				return nil, fmt.Errorf("block %d, call %d: %v", i, j, err)
+			}
+			gasUsed.Add(gasUsed, call.GasUsed.ToInt())
+			result.Calls = append(result.Calls, call)
+		}
+		result.GasUsed = (*hexutil.Big)(gasUsed)
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// simulator makes the calls of simulated blocks on a shared state.
+type simulator struct {
+	ctx     context.Context
+	b       Backend
+	base    *types.Header                 // Header of the block the simulation starts from
+	statedb *state.StateDB                // State shared by the calls
+	dpos    *types.DposContext            // DPoS context shared by the calls, loaded on first use
+	headers map[common.Hash]*types.Header // Headers of the simulated blocks
+
+	sender func(from common.Address) common.Address // Defaults the sender of the calls omitting it
+}
+
+// header derives the header of a simulated block from its parent and overrides.
+func (sim *simulator) header(parent *types.Header, overrides *BlockOverrides) (*types.Header, error) {
+	header := types.CopyHeader(parent)
+	header.ParentHash = parent.Hash()
+	header.Number = new(big.Int).Add(parent.Number, common.Big1)
+	header.Time = new(big.Int).Add(parent.Time, big.NewInt(simulateBlockInterval))
+	header.GasUsed = new(big.Int)
+
+	if overrides != nil {
+		if overrides.Number != nil {
+			if overrides.Number.ToInt().Cmp(parent.Number) <= 0 {
+				return nil, fmt.Errorf("number %v not after parent %v", overrides.Number.ToInt(), parent.Number)
+			}
+			header.Number = new(big.Int).Set(overrides.Number.ToInt())
+		}
+		if overrides.Time != nil {
+			if overrides.Time.ToInt().Cmp(parent.Time) < 0 {
+				return nil, fmt.Errorf("time %v before parent %v", overrides.Time.ToInt(), parent.Time)
+			}
+			header.Time = new(big.Int).Set(overrides.Time.ToInt())
+		}
+		if overrides.Validator != nil {
+			header.Validator = *overrides.Validator
+			header.Coinbase = *overrides.Validator
+		}
+	}
+	sim.headers[header.Hash()] = header
+	return header, nil
+}
+
+// dposContext returns the DPoS context shared by the calls, loading the one of
+// the block the simulation starts from on first use.
+func (sim *simulator) dposContext() (*types.DposContext, error) {
+	if sim.dpos != nil {
+		return sim.dpos, nil
+	}
+	dpos, err := loadDposContext(sim.ctx, sim.b, sim.base)
+	if err != nil {
+		return nil, err
+	}
+	sim.dpos = dpos
+	return dpos, nil
+}
+
+// loadDposContext loads the DPoS context of a chain block afresh to make changes
+// to. The tries are loaded through the trie database, which holds the nodes of
+// the recent blocks not flushed to disk yet.
+func loadDposContext(ctx context.Context, b Backend, header *types.Header) (*types.DposContext, error) {
+	if header.DposContext == nil {
+		return nil, errors.New("block has no DPoS context")
+	}
+	dpos, err := types.NewDposContextFromProto(b.TrieDB(), header.DposContext)
+	if err != nil {
+		return nil, dposUnavailable(ctx, b, header.Number.Uint64(), err)
+	}
+	return dpos, nil
+}
+
+// call makes a call of a simulated block. Calls that are rejected are reported in
+// their result, only failing to simulate at all is returned as an error.
+func (sim *simulator) call(header *types.Header, index int, args *CallArgs) (*SimulateCallResult, error) {
+	if args.Type != types.Binary && args.To == nil {
+		return &SimulateCallResult{GasUsed: new(hexutil.Big), Error: types.ErrInvalidType.Error()}, nil
+	}
+	var (
+		from     = sim.sender(args.From)
+		nonce    = sim.statedb.GetNonce(from)
+		gas      = args.Gas.ToInt()
+		gasPrice = args.GasPrice.ToInt()
+	)
+	if gas.Sign() == 0 {
+		gas = big.NewInt(50000000)
+	}
+	// Identify the call by the hash of the unsigned transaction making it
+	var tx *types.Transaction
+	if args.To == nil {
+		tx = types.NewContractCreation(nonce, args.Value.ToInt(), gas, gasPrice, args.Data)
+	} else {
+		tx = types.NewTransaction(args.Type, nonce, *args.To, args.Value.ToInt(), gas, gasPrice, args.Data)
+	}
+	msg := types.NewMessage(from, args.To, nonce, args.Value.ToInt(), gas, gasPrice, args.Data, false).WithType(args.Type)
+
+	var dpos *types.DposContext
+	if msg.Type() != types.Binary {
+		var err error
+		if dpos, err = sim.dposContext(); err != nil {
+			return nil, err
+		}
+	}
+	sim.statedb.Prepare(tx.Hash(), header.Hash(), index)
+
+	evm := vm.NewEVM(core.NewEVMContext(msg, header, sim, &header.Coinbase), sim.statedb, sim.b.ChainConfig(), vm.Config{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-sim.ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+	ret, gasUsed, failed, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxBig256))
+	if sim.ctx.Err() != nil {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", simulateTimeout)
+	}
+	if err != nil {
+		return &SimulateCallResult{GasUsed: new(hexutil.Big), Error: err.Error()}, nil
+	}
+	if dpos != nil {
+		if err := core.ApplyDposMessage(dpos, msg); err != nil {
+			return nil, err
+		}
+	}
+	sim.statedb.Finalise(sim.b.ChainConfig().IsEIP158(header.Number))
+
+	result := &SimulateCallResult{
+		ReturnData: ret,
+		Logs:       sim.statedb.GetLogs(tx.Hash()),
+		GasUsed:    (*hexutil.Big)(gasUsed),
+	}
+	if result.Logs == nil {
+		result.Logs = []*types.Log{}
+	}
+	if !failed {
+		result.Status = hexutil.Uint(types.ReceiptStatusSuccessful)
+		if args.To == nil {
+			address := crypto.CreateAddress(from, nonce)
+			result.ContractAddress = &address
+		}
+	}
+	return result, nil
+}
+
+// Engine implements core.ChainContext. The coinbase of the simulated blocks is
+// taken from their header, so the consensus engine is never needed.
+func (sim *simulator) Engine() consensus.Engine {
+	return nil
+}
+
+// GetHeader implements core.ChainContext, retrieving the headers of both the
+// simulated blocks and the chain they're simulated on.
+func (sim *simulator) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := sim.headers[hash]; ok {
+		return header
+	}
+	if hash == sim.base.Hash() {
+		return sim.base
+	}
+	return (&chainContext{ctx: sim.ctx, b: sim.b}).GetHeader(hash, number)
+}
@@ -0,0 +1,250 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/accounts"
+	"github.com/DATxChain-Protocol/DATx/accounts/keystore"
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/common/math"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/state"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/params"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// simulateBackend serves a single block and its state to simulate calls on.
+type simulateBackend struct {
+	Backend
+	db      datxdb.Database
+	block   *types.Block
+	statedb *state.StateDB
+	manager *accounts.Manager
+}
+
+func (b *simulateBackend) AccountManager() *accounts.Manager {
+	return b.manager
+}
+
+func (b *simulateBackend) TrieDB() trie.Database {
+	return b.db
+}
+
+func (b *simulateBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	return b.statedb.Copy(), b.block.Header(), nil
+}
+
+func (b *simulateBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	return b.block, nil
+}
+
+func (b *simulateBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	if uint64(blockNr) == b.block.NumberU64() {
+		return b.block.Header(), nil
+	}
+	return nil, nil
+}
+
+func (b *simulateBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if hash == b.block.Hash() {
+		return b.block, nil
+	}
+	return nil, nil
+}
+
+func (b *simulateBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, &chainContext{ctx: ctx, b: b}, &header.Coinbase)
+	return vm.NewEVM(context, state, b.ChainConfig(), vmCfg), func() error { return nil }, nil
+}
+
+func (b *simulateBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+// newSimulateBackend creates a backend of a block with an empty state and a DPoS
+// context without candidates.
+func newSimulateBackend(t *testing.T) *simulateBackend {
+	db, _ := datxdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(db)
+	if err != nil {
+		t.Fatalf("failed to create DPoS context: %v", err)
+	}
+	header := &types.Header{
+		Number:      big.NewInt(10),
+		Time:        big.NewInt(1000),
+		GasLimit:    big.NewInt(8000000),
+		GasUsed:     new(big.Int),
+		Difficulty:  big.NewInt(1),
+		Validator:   common.HexToAddress("0x01"),
+		DposContext: dposContext.ToProto(),
+	}
+	block := types.NewBlock(header, nil, nil, nil)
+	block.DposContext = dposContext
+	return &simulateBackend{db: db, block: block, statedb: statedb}
+}
+
+func TestSimulate(t *testing.T) {
+	var (
+		alice     = common.HexToAddress("0xa1")
+		bob       = common.HexToAddress("0xb0")
+		contract  = common.HexToAddress("0xc0")
+		datx      = new(big.Int).SetUint64(params.DATx)
+		validator = common.HexToAddress("0xee")
+	)
+	backend := newSimulateBackend(t)
+	api := NewPublicBlockChainAPI(backend)
+
+	blocks := []*SimulateBlock{
+		{
+			// Fund alice and deploy a contract emitting a log, then spend the funds twice
+			StateOverrides: StateOverride{
+				alice:    {Balance: (*hexutil.Big)(datx)},
+				contract: {Code: &hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0xa0, 0x00}}, // LOG0(0, 0)
+			},
+			Calls: []*CallArgs{
+				{From: alice, To: &bob, Value: hexutil.Big(*big.NewInt(6e17))},
+				{From: alice, To: &bob, Value: hexutil.Big(*big.NewInt(6e17))},
+				{From: alice, To: &contract},
+			},
+		},
+		{
+			// Move to a later time and validator, let bob pay with alice's funds and
+			// the validator with the fees it was paid
+			BlockOverrides: &BlockOverrides{
+				Time:      (*hexutil.Big)(big.NewInt(5000)),
+				Validator: &validator,
+			},
+			Calls: []*CallArgs{
+				{From: bob, To: &alice, Value: hexutil.Big(*big.NewInt(1e17))},
+				{From: bob, To: &alice, GasPrice: hexutil.Big(*datx)},
+				{From: bob, To: &bob, Type: types.LoginCandidate},
+				{From: alice, To: &bob, Type: types.Delegate},
+				{From: alice, Type: types.Delegate},
+				{From: alice, To: &bob, GasPrice: hexutil.Big(*big.NewInt(1e9))},
+				{From: validator, To: &bob, Value: hexutil.Big(*big.NewInt(21000 * 1e9))},
+			},
+		},
+	}
+	results, err := api.Simulate(context.Background(), blocks, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("block count mismatch: have %d, want 2", len(results))
+	}
+	// Check the headers derived from the base block and the overrides
+	if n := results[0].Number.ToInt().Uint64(); n != 11 {
+		t.Errorf("block 0: number mismatch: have %d, want 11", n)
+	}
+	if time := results[0].Time.ToInt().Uint64(); time != 1000+simulateBlockInterval {
+		t.Errorf("block 0: time mismatch: have %d, want %d", time, 1000+simulateBlockInterval)
+	}
+	if n, time := results[1].Number.ToInt().Uint64(), results[1].Time.ToInt().Uint64(); n != 12 || time != 5000 {
+		t.Errorf("block 1: number/time mismatch: have %d/%d, want 12/5000", n, time)
+	}
+	if results[1].Validator != validator {
+		t.Errorf("block 1: validator mismatch: have %x, want %x", results[1].Validator, validator)
+	}
+	// Check the calls see the changes of the previous ones
+	tests := []struct {
+		block, call int
+		status      hexutil.Uint
+		logs        int
+		err         bool
+	}{
+		{0, 0, 1, 0, false}, // transfer funded by the override
+		{0, 1, 0, 0, true},  // transfer rejected for lack of funds
+		{0, 2, 1, 1, false}, // call of the overridden code
+		{1, 0, 1, 0, false}, // transfer of the funds received in the previous block
+		{1, 1, 0, 0, true},  // call without the funds to pay for its gas
+		{1, 2, 1, 0, false}, // DPoS candidate login
+		{1, 3, 1, 0, false}, // DPoS delegation to the candidate
+		{1, 4, 0, 0, true},  // DPoS delegation without a candidate
+		{1, 5, 1, 0, false}, // transfer paying fees to the overridden validator
+		{1, 6, 1, 0, false}, // transfer of the fees by the validator
+	}
+	for _, tt := range tests {
+		call := results[tt.block].Calls[tt.call]
+		if call.Status != tt.status {
+			t.Errorf("block %d, call %d: status mismatch: have %d, want %d", tt.block, tt.call, call.Status, tt.status)
+		}
+		if len(call.Logs) != tt.logs {
+			t.Errorf("block %d, call %d: log count mismatch: have %d, want %d", tt.block, tt.call, len(call.Logs), tt.logs)
+		}
+		if (call.Error != "") != tt.err {
+			t.Errorf("block %d, call %d: error mismatch: have %q, want error %v", tt.block, tt.call, call.Error, tt.err)
+		}
+	}
+	if log := results[0].Calls[2].Logs[0]; log.Address != contract || log.BlockHash != results[0].Hash {
+		t.Errorf("log mismatch: have address %x in block %x, want %x in block %x", log.Address, log.BlockHash, contract, results[0].Hash)
+	}
+	if gas := results[0].GasUsed.ToInt().Uint64(); gas <= 2*params.TxGas {
+		t.Errorf("block 0: gas used too low: have %d, want above %d", gas, 2*params.TxGas)
+	}
+	// The simulation must not leak into the chain
+	if candidates := backend.block.DposCtx().CandidateTrie().Hash(); candidates != (common.Hash{}) && candidates != types.EmptyRootHash {
+		t.Errorf("chain DPoS context modified by the simulation")
+	}
+}
+
+// Tests that calls omitting their sender are made from the first local account,
+// as eth_call makes them.
+func TestSimulateDefaultSender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simulate-keystore")
+	if err != nil {
+		t.Fatalf("failed to create keystore directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	backend := newSimulateBackend(t)
+	backend.manager = accounts.NewManager(ks)
+	api := NewPublicBlockChainAPI(backend)
+
+	// Only the local account is funded, a call from anyone else would fail
+	bob := common.HexToAddress("0xb0")
+	blocks := []*SimulateBlock{{
+		StateOverrides: StateOverride{
+			account.Address: {Balance: (*hexutil.Big)(new(big.Int).SetUint64(params.DATx))},
+		},
+		Calls: []*CallArgs{{To: &bob, Value: hexutil.Big(*big.NewInt(1e17))}},
+	}}
+	results, err := api.Simulate(context.Background(), blocks, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if call := results[0].Calls[0]; call.Status != 1 || call.Error != "" {
+		t.Errorf("call without sender failed: status %d, error %q", call.Status, call.Error)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// stateUnavailableErrorCode is the JSON-RPC error code of requests for state the
+// node doesn't retain, the resource unavailable code of EIP-1474.
+const stateUnavailableErrorCode = -32002
+
+// StateUnavailableError is returned for requests of a block whose state the node
+// has garbage collected or never synced, instead of the missing trie node the
+// lookup fails with.
+type StateUnavailableError struct {
+	Number uint64 // Block whose state was requested
+	Oldest uint64 // Oldest block the node retains the state of
+}
+
+// Error implements error.
+func (e *StateUnavailableError) Error() string {
+	return fmt.Sprintf("state of block %d unavailable, oldest available is %d", e.Number, e.Oldest)
+}
+
+// ErrorCode implements rpc.Error, returning the resource unavailable code.
+func (e *StateUnavailableError) ErrorCode() int { return stateUnavailableErrorCode }
+
+// ErrorData implements rpc.DataError, returning the oldest block with state for
+// clients to retry from.
+func (e *StateUnavailableError) ErrorData() interface{} {
+	return map[string]interface{}{
+		"number": hexutil.Uint64(e.Number),
+		"oldest": hexutil.Uint64(e.Oldest),
+	}
+}
+
+// StateAvailability is the range of blocks whose account and DPoS state a node
+// serves. An oldest block past the head means no state of the kind is retained.
+type StateAvailability struct {
+	Head     hexutil.Uint64 `json:"head"`
+	Accounts hexutil.Uint64 `json:"accounts"`
+	Dpos     hexutil.Uint64 `json:"dpos"`
+}
+
+// StateAvailability returns the oldest blocks whose account trie and DPoS tries
+// the node retains. Older states may be served still if the node flushed them to
+// disk, requests for those it lacks failing with a StateUnavailableError.
+func (s *PublicBlockChainAPI) StateAvailability(ctx context.Context) (*StateAvailability, error) {
+	avail, err := s.b.StateAvailability(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &StateAvailability{
+		Head:     hexutil.Uint64(avail.Head),
+		Accounts: hexutil.Uint64(avail.Accounts),
+		Dpos:     hexutil.Uint64(avail.Dpos),
+	}, nil
+}
+
+// dposUnavailable converts the error of loading the DPoS tries of a block into a
+// StateUnavailableError if the tries are missing.
+func dposUnavailable(ctx context.Context, b Backend, number uint64, err error) error {
+	if _, missing := err.(*trie.MissingNodeError); !missing {
+		return err
+	}
+	avail, availErr := b.StateAvailability(ctx)
+	if availErr != nil {
+		return err
+	}
+	return &StateUnavailableError{Number: number, Oldest: avail.Dpos}
+}
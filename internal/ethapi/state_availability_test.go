@@ -0,0 +1,78 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
+)
+
+// availabilityBackend reports a fixed state availability over an empty database.
+type availabilityBackend struct {
+	Backend
+	db    datxdb.Database
+	avail core.StateAvailability
+}
+
+func (b *availabilityBackend) TrieDB() trie.Database {
+	return b.db
+}
+
+func (b *availabilityBackend) StateAvailability(ctx context.Context) (*core.StateAvailability, error) {
+	return &b.avail, nil
+}
+
+func TestStateAvailability(t *testing.T) {
+	db, _ := datxdb.NewMemDatabase()
+	backend := &availabilityBackend{db: db, avail: core.StateAvailability{Head: 200, Accounts: 73, Dpos: 81}}
+
+	avail, err := NewPublicBlockChainAPI(backend).StateAvailability(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve availability: %v", err)
+	}
+	if avail.Head != 200 || avail.Accounts != 73 || avail.Dpos != 81 {
+		t.Errorf("availability mismatch: have %+v", avail)
+	}
+	// Loading pruned DPoS tries should report the oldest block retaining them
+	header := &types.Header{
+		Number:      big.NewInt(10),
+		DposContext: &types.DposContextProto{EpochHash: common.HexToHash("0xdead")},
+	}
+	_, err = loadDposContext(context.Background(), backend, header)
+	unavailable, ok := err.(*StateUnavailableError)
+	if !ok {
+		t.Fatalf("error type mismatch: have %T (%v), want *StateUnavailableError", err, err)
+	}
+	if unavailable.Number != 10 || unavailable.Oldest != 81 {
+		t.Errorf("error mismatch: have %+v, want number 10, oldest 81", unavailable)
+	}
+	var rpcErr rpc.DataError = unavailable
+	if code := unavailable.ErrorCode(); code != stateUnavailableErrorCode {
+		t.Errorf("error code mismatch: have %d, want %d", code, stateUnavailableErrorCode)
+	}
+	if data := rpcErr.ErrorData().(map[string]interface{}); data["oldest"] != avail.Dpos {
+		t.Errorf("error data mismatch: have %v", data)
+	}
+}
@@ -18,6 +18,7 @@ package les
 
 import (
 	"context"
+	"errors"
 	"math/big"
 
 	"github.com/DATxChain-Protocol/DATx/accounts"
@@ -35,8 +36,13 @@ import (
 	"github.com/DATxChain-Protocol/DATx/light"
 	"github.com/DATxChain-Protocol/DATx/params"
 	"github.com/DATxChain-Protocol/DATx/rpc"
+	"github.com/DATxChain-Protocol/DATx/trie"
 )
 
+// errLightStateAvailability is returned for the state availability of light
+// clients, which retrieve any state on demand from their servers.
+var errLightStateAvailability = errors.New("light clients retrieve state on demand")
+
 type LesApiBackend struct {
 	datx *LightEthereum
 	gpo *gasprice.Oracle
@@ -79,6 +85,10 @@ func (b *LesApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	return light.NewState(ctx, header, b.datx.odr), header, nil
 }
 
+func (b *LesApiBackend) StateAvailability(ctx context.Context) (*core.StateAvailability, error) {
+	return nil, errLightStateAvailability
+}
+
 func (b *LesApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
 	return b.datx.blockchain.GetBlockByHash(ctx, blockHash)
 }
@@ -113,6 +123,12 @@ func (b *LesApiBackend) GetPoolTransaction(txHash common.Hash) *types.Transactio
 	return b.datx.txPool.GetTransaction(txHash)
 }
 
+// GetPoolReplacement always returns nil, the light transaction pool doesn't
+// replace transactions.
+func (b *LesApiBackend) GetPoolReplacement(txHash common.Hash) *core.TxReplacement {
+	return nil
+}
+
 func (b *LesApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.datx.txPool.GetNonce(ctx, addr)
 }
@@ -129,6 +145,15 @@ func (b *LesApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Sub
 	return b.datx.txPool.SubscribeTxPreEvent(ch)
 }
 
+// SubscribeTxReplacedEvent returns a subscription that never fires, the light
+// transaction pool doesn't replace transactions.
+func (b *LesApiBackend) SubscribeTxReplacedEvent(ch chan<- core.TxReplacedEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.datx.blockchain.SubscribeChainEvent(ch)
 }
@@ -161,6 +186,10 @@ func (b *LesApiBackend) ChainDb() datxdb.Database {
 	return b.datx.chainDb
 }
 
+func (b *LesApiBackend) TrieDB() trie.Database {
+	return b.datx.chainDb
+}
+
 func (b *LesApiBackend) EventMux() *event.TypeMux {
 	return b.datx.eventMux
 }
@@ -64,6 +64,12 @@ func (db *odrDatabase) CopyTrie(t state.Trie) state.Trie {
 	}
 }
 
+// TrieDB returns nil, as light clients retrieve the tries on demand and never
+// keep them in a trie database.
+func (db *odrDatabase) TrieDB() *trie.NodeDatabase {
+	return nil
+}
+
 func (db *odrDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
 	if codeHash == sha3_nil {
 		return nil, nil
@@ -114,10 +120,14 @@ func (t *odrTrie) TryDelete(key []byte) error {
 }
 
 func (t *odrTrie) CommitTo(db trie.DatabaseWriter) (common.Hash, error) {
+	return t.CommitToWithCallback(db, nil)
+}
+
+func (t *odrTrie) CommitToWithCallback(db trie.DatabaseWriter, onleaf trie.LeafCallback) (common.Hash, error) {
 	if t.trie == nil {
 		return t.id.Root, nil
 	}
-	return t.trie.CommitTo(db)
+	return t.trie.CommitToWithCallback(db, onleaf)
 }
 
 func (t *odrTrie) Hash() common.Hash {
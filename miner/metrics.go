@@ -0,0 +1,30 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the block producer.
+
+package miner
+
+import (
+	"github.com/DATxChain-Protocol/DATx/metrics"
+)
+
+var (
+	assemblyTimer   = metrics.NewTimer("miner/assembly")          // Time spent assembling a block from the pool
+	slotUnusedTimer = metrics.NewTimer("miner/slot/unused")       // Slot time left after the block was sealed
+	refreshMeter    = metrics.NewMeter("miner/prepared/txs")      // Transactions added to prepared blocks
+	rebuildMeter    = metrics.NewMeter("miner/prepared/rebuilds") // Prepared blocks rebuilt on a new chain head
+)
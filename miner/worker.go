@@ -108,6 +108,7 @@ type worker struct {
 
 	currentMu sync.Mutex
 	current   *Work
+	prepared  *Work // Unfinalized block assembled ahead of the next local slot
 
 	uncleMu        sync.Mutex
 	possibleUncles map[common.Hash]*types.Block
@@ -118,7 +119,8 @@ type worker struct {
 	mining int32
 	atWork int32
 
-	quitCh  chan struct{}
+	quitMu  sync.Mutex    // Protects quitCh, replaced once closed
+	quitCh  chan struct{} // Closed to abort the block being sealed
 	stopper chan struct{}
 }
 
@@ -147,7 +149,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 
 	go worker.update()
 	go worker.wait()
-	worker.createNewWork()
+	worker.createNewWork(worker.now().Unix())
 
 	return worker
 }
@@ -168,30 +170,36 @@ func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
 
-	if atomic.LoadInt32(&self.mining) == 0 {
-		return types.NewBlock(
-			self.current.header,
-			self.current.txs,
-			nil,
-			self.current.receipts,
-		), self.current.state.Copy()
-	}
-	return self.current.Block, self.current.state.Copy()
+	return self.pendingCopy(), self.current.state.Copy()
 }
 
 func (self *worker) pendingBlock() *types.Block {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
 
+	return self.pendingCopy()
+}
+
+// pendingCopy returns the pending block with a copy of its DPoS context, whose
+// tries aren't committed to the database to be loaded from. It assumes currentMu
+// is held.
+func (self *worker) pendingCopy() *types.Block {
+	var block *types.Block
 	if atomic.LoadInt32(&self.mining) == 0 {
-		return types.NewBlock(
+		block = types.NewBlock(
 			self.current.header,
 			self.current.txs,
 			nil,
 			self.current.receipts,
 		)
+	} else {
+		if self.current.Block == nil {
+			return nil
+		}
+		block = self.current.Block.WithBody(self.current.Block.Transactions(), self.current.Block.Uncles())
 	}
-	return self.current.Block
+	block.DposContext = self.current.dposContext.Copy()
+	return block
 }
 
 func (self *worker) start() {
@@ -202,6 +210,8 @@ func (self *worker) start() {
 	go self.mintLoop()
 }
 
+// mintBlock seals a block in the slot starting at now, using the block prepared
+// ahead of time if there's one, or assembling a new one otherwise.
 func (self *worker) mintBlock(now int64) {
 	engine, ok := self.engine.(*dpos.Dpos)
 	if !ok {
@@ -221,35 +231,135 @@ func (self *worker) mintBlock(now int64) {
 		}
 		return
 	}
-	work, err := self.createNewWork()
+	// Grab the channel before assembling the block, so a chain head arriving in
+	// the meantime aborts sealing it
+	self.quitMu.Lock()
+	quit := self.quitCh
+	self.quitMu.Unlock()
+
+	work, err := self.finalizePrepared(now)
 	if err != nil {
-		log.Error("Failed to create the new work", "err", err)
+		log.Error("Failed to finalize the prepared work", "err", err)
 		return
 	}
-
-	result, err := self.engine.Seal(self.chain, work.Block, self.quitCh)
+	if work == nil {
+		if work, err = self.createNewWork(now); err != nil {
+			log.Error("Failed to create the new work", "err", err)
+			return
+		}
+	}
+	result, err := self.engine.Seal(self.chain, work.Block, quit)
 	if err != nil {
 		log.Error("Failed to seal the block", "err", err)
 		return
 	}
+	slotUnusedTimer.Update(time.Unix(dpos.NextSlot(now+1), 0).Sub(self.now()))
 	self.recv <- &Result{work, result}
 }
 
+// mintLoop keeps a block prepared for the next slot of the local validator and
+// seals it once the slot starts.
 func (self *worker) mintLoop() {
-	ticker := time.NewTicker(time.Second).C
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var (
+		slot int64            // Slot the prepared block is due at
+		seal <-chan time.Time // Fires at the start of the slot
+	)
 	for {
 		select {
-		case now := <-ticker:
-			self.mintBlock(now.Unix())
+		case <-ticker.C:
+			if next := self.prepareSlot(self.now()); next != slot {
+				slot, seal = next, nil
+				if slot != 0 {
+					seal = time.After(time.Unix(slot, 0).Sub(self.now()))
+				}
+			}
+		case <-seal:
+			self.mintBlock(slot)
+			slot, seal = 0, nil
+
 		case <-self.stopper:
-			close(self.quitCh)
-			self.quitCh = make(chan struct{}, 1)
+			self.currentMu.Lock()
+			self.prepared = nil
+			self.currentMu.Unlock()
+
+			self.abortSealing()
 			self.stopper = make(chan struct{}, 1)
 			return
 		}
 	}
 }
 
+// nextMintSlot returns the first slot at or after now that's still available
+// on top of a chain head stamped with headTime.
+func nextMintSlot(now, headTime int64) int64 {
+	slot := dpos.NextSlot(now)
+	if headTime >= slot {
+		slot = dpos.NextSlot(headTime + 1)
+	}
+	return slot
+}
+
+// prepareSlot assembles the block for the next slot in the background if the
+// slot belongs to the local validator, rebuilding it if the chain head changed
+// since. It returns the slot the prepared block is due at, or zero if the next
+// slot belongs to someone else.
+func (self *worker) prepareSlot(now time.Time) int64 {
+	engine, ok := self.engine.(*dpos.Dpos)
+	if !ok {
+		return 0
+	}
+	head := self.chain.CurrentBlock()
+	slot := nextMintSlot(now.Unix(), head.Time().Int64())
+
+	if err := engine.CheckValidator(head, slot); err != nil {
+		switch err {
+		case dpos.ErrWaitForPrevBlock,
+			dpos.ErrMintFutureBlock,
+			dpos.ErrInvalidBlockValidator,
+			dpos.ErrInvalidMintBlockTime:
+		default:
+			log.Error("Failed to look up the next slot", "err", err)
+		}
+		return 0
+	}
+	self.currentMu.Lock()
+	prepared := self.prepared
+	self.currentMu.Unlock()
+
+	if prepared != nil && prepared.header.ParentHash == head.Hash() && prepared.header.Time.Int64() == slot {
+		return slot
+	}
+	if prepared != nil {
+		rebuildMeter.Mark(1)
+	}
+	if _, err := self.prepareWork(slot); err != nil {
+		log.Error("Failed to prepare the new work", "err", err)
+		return 0
+	}
+	return slot
+}
+
+// now returns the current time as seen by the consensus engine, so that blocks
+// are minted against the same clock their slots are verified with.
+func (self *worker) now() time.Time {
+	if engine, ok := self.engine.(*dpos.Dpos); ok {
+		return engine.Now()
+	}
+	return time.Now()
+}
+
+// abortSealing aborts sealing the current block, if any.
+func (self *worker) abortSealing() {
+	self.quitMu.Lock()
+	defer self.quitMu.Unlock()
+
+	close(self.quitCh)
+	self.quitCh = make(chan struct{}, 1)
+}
+
 func (self *worker) stop() {
 	if atomic.LoadInt32(&self.mining) == 0 {
 		return
@@ -274,8 +384,7 @@ func (self *worker) update() {
 		select {
 		// Handle ChainHeadEvent
 		case <-self.chainHeadCh:
-			close(self.quitCh)
-			self.quitCh = make(chan struct{}, 1)
+			self.abortSealing()
 
 		// Handle TxPreEvent
 		case ev := <-self.txCh:
@@ -288,6 +397,18 @@ func (self *worker) update() {
 
 				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
 				self.currentMu.Unlock()
+			} else {
+				// Mining, refresh the block prepared for the next slot
+				self.currentMu.Lock()
+				if self.prepared != nil {
+					acc, _ := types.Sender(self.prepared.signer, ev.Tx)
+					txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
+					txset := types.NewTransactionsByPriceAndNonce(self.prepared.signer, txs)
+
+					self.prepared.commitTransactions(self.mux, txset, self.chain, self.coinbase)
+					refreshMeter.Mark(1)
+				}
+				self.currentMu.Unlock()
 			}
 		// System stopped
 		case <-self.txSub.Err():
@@ -347,15 +468,15 @@ func (self *worker) wait() {
 	}
 }
 
-// makeCurrent creates a new environment for the current cycle.
-func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error {
+// makeWork creates a new environment for a block on top of parent.
+func (self *worker) makeWork(parent *types.Block, header *types.Header) (*Work, error) {
 	state, err := self.chain.StateAt(parent.Root())
 	if err != nil {
-		return err
+		return nil, err
 	}
-	dposContext, err := types.NewDposContextFromProto(self.chainDb, parent.Header().DposContext)
+	dposContext, err := types.NewDposContextFromProto(self.chain.StateCache().TrieDB(), parent.Header().DposContext)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	work := &Work{
 		config:      self.config,
@@ -380,11 +501,12 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 
 	// Keep track of transactions which return errors so they can be removed
 	work.tcount = 0
-	self.current = work
-	return nil
+	return work, nil
 }
 
-func (self *worker) createNewWork() (*Work, error) {
+// createNewWork assembles and finalizes a new block to be sealed at the given
+// timestamp, making it the current pending block.
+func (self *worker) createNewWork(tstamp int64) (*Work, error) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 	self.uncleMu.Lock()
@@ -392,20 +514,75 @@ func (self *worker) createNewWork() (*Work, error) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
 
-	tstart := time.Now()
-	parent := self.chain.CurrentBlock()
-
-	tstamp := tstart.Unix()
-	if parent.Time().Cmp(new(big.Int).SetInt64(tstamp)) >= 0 {
-		tstamp = parent.Time().Int64() + 1
+	work, err := self.assembleWork(tstamp)
+	if err != nil {
+		return nil, err
 	}
 	// this will ensure we're not going off too far in the future
-	if now := time.Now().Unix(); tstamp > now+1 {
+	if tstamp, now := work.header.Time.Int64(), self.now().Unix(); tstamp > now+1 {
 		wait := time.Duration(tstamp-now) * time.Second
 		log.Info("Mining too far in the future", "wait", common.PrettyDuration(wait))
 		time.Sleep(wait)
 	}
+	if err := self.finalizeWork(work); err != nil {
+		return nil, err
+	}
+	self.current = work
+	return work, nil
+}
+
+// prepareWork assembles the block to be sealed in an upcoming slot, without
+// finalizing it yet so transactions arriving in the meantime can be added.
+func (self *worker) prepareWork(slot int64) (*Work, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	work, err := self.assembleWork(slot)
+	if err != nil {
+		return nil, err
+	}
+	self.currentMu.Lock()
+	self.prepared = work
+	self.currentMu.Unlock()
+
+	return work, nil
+}
 
+// finalizePrepared finalizes the block prepared for the slot starting at now,
+// making it the current pending block. It returns nil if no block was prepared
+// for the slot on top of the current chain head.
+func (self *worker) finalizePrepared(now int64) (*Work, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.uncleMu.Lock()
+	defer self.uncleMu.Unlock()
+	self.currentMu.Lock()
+	defer self.currentMu.Unlock()
+
+	work := self.prepared
+	self.prepared = nil
+
+	if work == nil || work.header.Time.Int64() != now || work.header.ParentHash != self.chain.CurrentBlock().Hash() {
+		return nil, nil
+	}
+	if err := self.finalizeWork(work); err != nil {
+		return nil, err
+	}
+	self.current = work
+	return work, nil
+}
+
+// assembleWork creates the environment for a new block on top of the current
+// chain head and fills it with the pending transactions. It assumes mu is held.
+func (self *worker) assembleWork(tstamp int64) (*Work, error) {
+	tstart := time.Now()
+	defer func() { assemblyTimer.UpdateSince(tstart) }()
+
+	parent := self.chain.CurrentBlock()
+
+	if parent.Time().Cmp(new(big.Int).SetInt64(tstamp)) >= 0 {
+		tstamp = parent.Time().Int64() + 1
+	}
 	num := parent.Number()
 	header := &types.Header{
 		ParentHash: parent.Hash(),
@@ -437,12 +614,11 @@ func (self *worker) createNewWork() (*Work, error) {
 	}
 
 	// Could potentially happen if starting to mine in an odd state.
-	err := self.makeCurrent(parent, header)
+	work, err := self.makeWork(parent, header)
 	if err != nil {
 		return nil, fmt.Errorf("got error when create mining context, err: %s", err)
 	}
-	// Create the current work task and check any fork transitions needed
-	work := self.current
+	// Check any fork transitions needed
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
@@ -450,9 +626,17 @@ func (self *worker) createNewWork() (*Work, error) {
 	if err != nil {
 		return nil, fmt.Errorf("got error when fetch pending transactions, err: %s", err)
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	txs := types.NewTransactionsByPriceAndNonce(work.signer, pending)
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
+	log.Debug("Assembled new mining work", "number", header.Number, "txs", work.tcount, "elapsed", common.PrettyDuration(time.Since(tstart)))
+	return work, nil
+}
+
+// finalizeWork picks the uncles of an assembled block and finalizes it with the
+// consensus engine, making it ready for sealing. It assumes mu and uncleMu are
+// held.
+func (self *worker) finalizeWork(work *Work) error {
 	// compute uncles for the new block.
 	var (
 		uncles    []*types.Header
@@ -476,18 +660,19 @@ func (self *worker) createNewWork() (*Work, error) {
 		delete(self.possibleUncles, hash)
 	}
 	// Create the new block to seal with the consensus engine
-	if work.Block, err = self.engine.Finalize(self.chain, header, work.state, work.txs, uncles, work.receipts, work.dposContext); err != nil {
-		return nil, fmt.Errorf("got error when finalize block for sealing, err: %s", err)
+	var err error
+	if work.Block, err = self.engine.Finalize(self.chain, work.header, work.state, work.txs, uncles, work.receipts, work.dposContext); err != nil {
+		return fmt.Errorf("got error when finalize block for sealing, err: %s", err)
 	}
 	work.Block.DposContext = work.dposContext
 
 	// update the count for the miner of new block
 	// We only care about logging if we're actually mining.
 	if atomic.LoadInt32(&self.mining) == 1 {
-		log.Info("Commit new mining work", "number", work.Block.Number(), "txs", work.tcount, "uncles", len(uncles), "elapsed", common.PrettyDuration(time.Since(tstart)))
+		log.Info("Commit new mining work", "number", work.Block.Number(), "txs", work.tcount, "elapsed", common.PrettyDuration(time.Since(work.createdAt)))
 		self.unconfirmed.Shift(work.Block.NumberU64() - 1)
 	}
-	return work, nil
+	return nil
 }
 
 func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
@@ -587,7 +772,8 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, coinbase common.Address, gp *core.GasPool) (error, []*types.Log) {
 	snap := env.state.Snapshot()
 	dposSnap := env.dposContext.Snapshot()
-	receipt, _, err := core.ApplyTransaction(env.config, env.dposContext, bc, &coinbase, gp, env.state, env.header, tx, env.header.GasUsed, vm.Config{})
+	vmConfig := vm.Config{RecordRevertData: bc.GetVMConfig().RecordRevertData}
+	receipt, _, err := core.ApplyTransaction(env.config, env.dposContext, bc, &coinbase, gp, env.state, env.header, tx, env.header.GasUsed, vmConfig)
 	if err != nil {
 		env.state.RevertToSnapshot(snap)
 		env.dposContext.RevertToSnapShot(dposSnap)
@@ -0,0 +1,217 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/accounts"
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/core/vm"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/event"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+var (
+	testKey, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testValidator = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+// testBackend is a single validator chain with a transaction pool, minting with
+// a manually set clock.
+type testBackend struct {
+	db     datxdb.Database
+	engine *dpos.Dpos
+	chain  *core.BlockChain
+	pool   *core.TxPool
+	now    int64 // Unix time of the engine clock, atomically accessed
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	config := *params.DposChainConfig
+	config.Dpos = &params.DposConfig{Validators: []common.Address{testValidator}}
+
+	db, _ := datxdb.NewMemDatabase()
+	genesis := &core.Genesis{
+		Config:     &config,
+		ExtraData:  make([]byte, 32),
+		GasLimit:   4712388,
+		Difficulty: big.NewInt(1),
+		Alloc:      core.GenesisAlloc{testValidator: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	genesis.MustCommit(db)
+
+	b := &testBackend{db: db, engine: dpos.New(config.Dpos, db)}
+	b.engine.SetTimeSource(func() time.Time { return time.Unix(atomic.LoadInt64(&b.now), 0) })
+	b.engine.Authorize(testValidator, func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, testKey)
+	})
+	chain, err := core.NewBlockChain(db, &config, b.engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	b.chain, b.pool = chain, core.NewTxPool(poolConfig, &config, chain)
+	return b
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager { return nil }
+func (b *testBackend) BlockChain() *core.BlockChain      { return b.chain }
+func (b *testBackend) TxPool() *core.TxPool              { return b.pool }
+func (b *testBackend) ChainDb() datxdb.Database          { return b.db }
+
+func (b *testBackend) close() {
+	b.pool.Stop()
+	b.chain.Stop()
+}
+
+// preparedTxs returns the number of transactions in the block prepared for the
+// next slot, or -1 if there's none.
+func preparedTxs(w *worker) int {
+	w.currentMu.Lock()
+	defer w.currentMu.Unlock()
+
+	if w.prepared == nil {
+		return -1
+	}
+	return w.prepared.tcount
+}
+
+// waitHead waits for the chain head to reach the given number.
+func waitHead(t *testing.T, chain *core.BlockChain, number uint64) *types.Block {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if head := chain.CurrentBlock(); head.NumberU64() >= number {
+			return head
+		}
+	}
+	t.Fatalf("chain head #%d not reached, have #%d", number, chain.CurrentBlock().NumberU64())
+	return nil
+}
+
+// Tests that blocks are prepared for the first slot still free on top of the
+// chain head, never for one the head already occupies.
+func TestNextMintSlot(t *testing.T) {
+	tests := []struct {
+		now, head int64
+		slot      int64
+	}{
+		{now: 1000, head: 990, slot: 1000},  // Slot starting right now
+		{now: 1001, head: 990, slot: 1010},  // Slot in progress, prepare the next
+		{now: 1009, head: 1000, slot: 1010}, // Head sealed in the current slot
+		{now: 1000, head: 1000, slot: 1010}, // Head already occupies the slot
+		{now: 1000, head: 1010, slot: 1020}, // Head from a slightly fast validator
+	}
+	for i, tt := range tests {
+		if slot := nextMintSlot(tt.now, tt.head); slot != tt.slot {
+			t.Errorf("test %d: slot mismatch: have %d, want %d", i, slot, tt.slot)
+		}
+	}
+}
+
+// Tests that the block of an upcoming local slot is prepared right away, kept
+// while the chain head doesn't change and rebuilt once it does.
+func TestPrepareSlot(t *testing.T) {
+	b := newTestBackend(t)
+	defer b.close()
+
+	atomic.StoreInt64(&b.now, 1005)
+	w := newWorker(b.chain.Config(), b.engine, testValidator, b, new(event.TypeMux))
+
+	start := time.Now()
+	if slot := w.prepareSlot(time.Unix(1005, 0)); slot != 1010 {
+		t.Fatalf("prepared slot mismatch: have %d, want 1010", slot)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("preparing the block waited for its slot: %v", elapsed)
+	}
+	w.currentMu.Lock()
+	prepared := w.prepared
+	w.currentMu.Unlock()
+
+	if prepared == nil || prepared.header.Time.Int64() != 1010 || prepared.header.ParentHash != b.chain.Genesis().Hash() {
+		t.Fatalf("block not prepared for slot 1010 on top of the genesis")
+	}
+	// Preparing again on the same head keeps the block
+	if slot := w.prepareSlot(time.Unix(1006, 0)); slot != 1010 {
+		t.Fatalf("prepared slot mismatch: have %d, want 1010", slot)
+	}
+	w.currentMu.Lock()
+	kept := w.prepared == prepared
+	w.currentMu.Unlock()
+
+	if !kept {
+		t.Errorf("prepared block rebuilt on the same head")
+	}
+	// A block for another slot isn't sealed
+	if work, err := w.finalizePrepared(1020); work != nil || err != nil {
+		t.Errorf("block prepared for slot 1010 finalized for slot 1020: %v", err)
+	}
+}
+
+// Tests that blocks are minted from the prepared ones, including transactions
+// arriving after the preparation, and assembled on the spot if none is.
+func TestMintPrepared(t *testing.T) {
+	b := newTestBackend(t)
+	defer b.close()
+
+	atomic.StoreInt64(&b.now, 1005)
+	w := newWorker(b.chain.Config(), b.engine, testValidator, b, new(event.TypeMux))
+	atomic.StoreInt32(&w.mining, 1)
+
+	if slot := w.prepareSlot(time.Unix(1005, 0)); slot != 1010 {
+		t.Fatalf("prepared slot mismatch: have %d, want 1010", slot)
+	}
+	// Transactions arriving before the slot are added to the prepared block
+	signer := types.NewEIP155Signer(b.chain.Config().ChainId)
+	tx, _ := types.SignTx(types.NewTransaction(types.Binary, 0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil), signer, testKey)
+	if err := b.pool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); preparedTxs(w) != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("transaction not added to the prepared block")
+		}
+	}
+	// The prepared block is sealed once its slot starts
+	atomic.StoreInt64(&b.now, 1010)
+	w.mintBlock(1010)
+
+	head := waitHead(t, b.chain, 1)
+	if head.Time().Int64() != 1010 || len(head.Transactions()) != 1 || head.Transactions()[0].Hash() != tx.Hash() {
+		t.Fatalf("minted block mismatch: time %d, %d txs", head.Time().Int64(), len(head.Transactions()))
+	}
+	if preparedTxs(w) != -1 {
+		t.Errorf("sealed block still prepared")
+	}
+	// Without a prepared block, one is assembled on the spot
+	atomic.StoreInt64(&b.now, 1020)
+	w.mintBlock(1020)
+
+	if head := waitHead(t, b.chain, 2); head.Time().Int64() != 1020 || head.ParentHash() != b.chain.GetBlockByNumber(1).Hash() {
+		t.Fatalf("minted block mismatch: time %d, parent %x", head.Time().Int64(), head.ParentHash())
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/DATxChain-Protocol/DATx/log"
 	"github.com/DATxChain-Protocol/DATx/p2p"
 	"github.com/DATxChain-Protocol/DATx/p2p/enode"
+	"github.com/DATxChain-Protocol/DATx/rpc"
 )
 
 const (
@@ -66,6 +67,17 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the name of the datxdb engine used to create new databases in
+	// the data directory. Existing databases are always opened with their own.
+	// If empty, datxdb.DefaultEngine is used.
+	DBEngine string `toml:",omitempty"`
+
+	// AncientDir is the directory of the ancient store holding the chain data
+	// of old blocks, which may be on a cheaper disk than the data directory.
+	// Relative paths are resolved against the chain database directory. If
+	// empty, the ancient store is kept in the chain database directory.
+	AncientDir string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -135,6 +147,11 @@ type Config struct {
 	// *WARNING* Only set this if the node is running in a trusted network, exposing
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
+
+	// RPCPolicy restricts the calls accepted by the HTTP and websocket RPC servers
+	// with credentials, allow lists and limits. The IPC and in-process endpoints
+	// are left unrestricted.
+	RPCPolicy *rpc.Policy `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -273,6 +290,19 @@ func (c *Config) resolvePath(path string) string {
 	return filepath.Join(c.instanceDir(), path)
 }
 
+// resolveAncient returns the directory of the ancient store of the database
+// with the given name.
+func (c *Config) resolveAncient(name string) string {
+	switch {
+	case c.AncientDir == "":
+		return filepath.Join(c.resolvePath(name), "ancient")
+	case filepath.IsAbs(c.AncientDir):
+		return c.AncientDir
+	default:
+		return filepath.Join(c.resolvePath(name), c.AncientDir)
+	}
+}
+
 func (c *Config) instanceDir() string {
 	if c.DataDir == "" {
 		return ""
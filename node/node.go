@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -58,10 +59,11 @@ type Node struct {
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
 	ipcHandler  *rpc.Server  // IPC RPC request handler to process the API requests
 
-	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
-	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
-	httpListener  net.Listener // HTTP RPC listener socket to server API requests
-	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
+	httpEndpoint  string                  // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
+	httpWhitelist []string                // HTTP RPC modules to allow through this endpoint
+	httpListener  net.Listener            // HTTP RPC listener socket to server API requests
+	httpHandler   *rpc.Server             // HTTP RPC request handler to process the API requests
+	httpServices  map[string]http.Handler // HTTP handlers of the services, served along the RPC endpoint
 
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
@@ -245,9 +247,17 @@ func (n *Node) openDataDir() error {
 func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	// Gather all the possible APIs to surface
 	apis := n.apis()
+	handlers := make(map[string]http.Handler)
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
+		if service, ok := service.(HTTPService); ok {
+			for path, handler := range service.HTTPHandlers() {
+				handlers[path] = handler
+			}
+		}
 	}
+	n.httpServices = handlers
+
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -370,6 +380,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetPolicy(n.config.RPCPolicy)
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -386,7 +397,17 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
+	var mux http.Handler = handler
+	if len(n.httpServices) > 0 {
+		serveMux := http.NewServeMux()
+		serveMux.Handle("/", handler)
+		for path, service := range n.httpServices {
+			serveMux.Handle(path, handler.Guard(strings.Trim(path, "/"), service))
+			log.Info(fmt.Sprintf("HTTP service opened: http://%s%s", endpoint, path))
+		}
+		mux = serveMux
+	}
+	go (&http.Server{Handler: rpc.NewCorsHandler(mux, cors)}).Serve(listener)
 	log.Info(fmt.Sprintf("HTTP endpoint opened: http://%s", endpoint))
 
 	// All listeners booted successfully
@@ -424,6 +445,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	}
 	// Register all the APIs exposed by the services
 	handler := rpc.NewServer()
+	handler.SetPolicy(n.config.RPCPolicy)
 	for _, api := range apis {
 		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -481,6 +503,7 @@ func (n *Node) Stop() error {
 	n.stopHTTP()
 	n.stopIPC()
 	n.rpcAPIs = nil
+	n.httpServices = nil
 	failure := &StopError{
 		Services: make(map[reflect.Type]error),
 	}
@@ -639,7 +662,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (datxdb.Database, e
 	if n.config.DataDir == "" {
 		return datxdb.NewMemDatabase()
 	}
-	return datxdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	return datxdb.Open(n.config.DBEngine, n.config.resolvePath(name), cache, handles)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
@@ -647,6 +670,12 @@ func (n *Node) ResolvePath(x string) string {
 	return n.config.resolvePath(x)
 }
 
+// ResolveAncient returns the directory of the ancient store of the database
+// with the given name.
+func (n *Node) ResolveAncient(name string) string {
+	return n.config.resolveAncient(name)
+}
+
 // apis returns the collection of RPC descriptors this node offers.
 func (n *Node) apis() []rpc.API {
 	return []rpc.API{
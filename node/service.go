@@ -17,6 +17,7 @@
 package node
 
 import (
+	"net/http"
 	"reflect"
 
 	"github.com/DATxChain-Protocol/DATx/accounts"
@@ -43,13 +44,35 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (da
 	if ctx.config.DataDir == "" {
 		return datxdb.NewMemDatabase()
 	}
-	db, err := datxdb.NewLDBDatabase(ctx.config.resolvePath(name), cache, handles)
+	db, err := datxdb.Open(ctx.config.DBEngine, ctx.config.resolvePath(name), cache, handles)
 	if err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
+// OpenDatabaseWithFreezer opens an existing database like OpenDatabase, with
+// the ancient chain data of the database kept in the configured ancient store.
+// If namespace is not empty, the key-value store is metered under it.
+func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handles int, namespace string) (datxdb.Database, error) {
+	if ctx.config.DataDir == "" {
+		return datxdb.NewMemDatabase()
+	}
+	db, err := datxdb.Open(ctx.config.DBEngine, ctx.config.resolvePath(name), cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := db.(datxdb.Meterer); ok && namespace != "" {
+		m.Meter(namespace)
+	}
+	frdb, err := datxdb.NewDatabaseWithFreezer(db, ctx.config.resolveAncient(name))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 // ResolvePath resolves a user path into the data directory if that was relative
 // and if the user actually uses persistent storage. It will return an empty string
 // for emphemeral storage and the user's own input for absolute paths.
@@ -96,3 +119,12 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// HTTPService is a service also serving requests over the HTTP RPC endpoint of the
+// node, under paths of its own.
+type HTTPService interface {
+	Service
+
+	// HTTPHandlers retrieves the handlers of the paths the service serves.
+	HTTPHandlers() map[string]http.Handler
+}
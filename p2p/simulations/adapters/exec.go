@@ -422,7 +422,6 @@ func startExecNodeStack() (*node.Node, error) {
 	}
 	conf.Node.initEnode(nodeTcpConn.IP, nodeTcpConn.Port, nodeTcpConn.Port)
 	conf.Stack.P2P.PrivateKey = conf.Node.PrivateKey
-	conf.Stack.Logger = log.New("node.id", conf.Node.ID.String())
 
 	// initialize the devp2p stack
 	stack, err := node.New(&conf.Stack)
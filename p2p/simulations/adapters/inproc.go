@@ -24,7 +24,6 @@ import (
 	"sync"
 
 	"github.com/DATxChain-Protocol/DATx/event"
-	"github.com/DATxChain-Protocol/DATx/log"
 	"github.com/DATxChain-Protocol/DATx/node"
 	"github.com/DATxChain-Protocol/DATx/p2p"
 	"github.com/DATxChain-Protocol/DATx/p2p/enode"
@@ -105,8 +104,7 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 			Dialer:          s,
 			EnableMsgEvents: config.EnableMsgEvents,
 		},
-		NoUSB:  true,
-		Logger: log.New("node.id", id.String()),
+		NoUSB: true,
 	})
 	if err != nil {
 		return nil, err
@@ -182,10 +180,13 @@ type SimNode struct {
 	registerOnce sync.Once
 }
 
-// Close closes the underlaying node.Node to release
+// Close stops the underlaying node.Node if still running, which releases its
 // acquired resources.
 func (sn *SimNode) Close() error {
-	return sn.node.Close()
+	if err := sn.node.Stop(); err != nil && err != node.ErrNodeStopped {
+		return err
+	}
+	return nil
 }
 
 // Addr returns the node's discovery address
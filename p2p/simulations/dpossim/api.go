@@ -0,0 +1,65 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpossim
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/log"
+)
+
+// API is the fault injection API of a simulated validator. It's only ever
+// registered by simulation nodes, never by a real gdatx.
+type API struct {
+	s *Service
+}
+
+// Validator returns the address the node seals blocks with.
+func (api *API) Validator() common.Address {
+	return api.s.validator
+}
+
+// SetClockSkew shifts the wall clock of the validator by the given number of
+// milliseconds, positive values moving it into the future.
+func (api *API) SetClockSkew(millis int64) {
+	skew := time.Duration(millis) * time.Millisecond
+	atomic.StoreInt64(&api.s.skew, int64(skew))
+	log.Warn("Simulated clock skew changed", "skew", skew)
+}
+
+// ClockSkew returns the current clock skew of the validator in milliseconds.
+func (api *API) ClockSkew() int64 {
+	return int64(time.Duration(atomic.LoadInt64(&api.s.skew)) / time.Millisecond)
+}
+
+// SetDoubleSign toggles whether the validator equivocates on every slot it
+// seals by broadcasting a second, conflicting block.
+func (api *API) SetDoubleSign(enabled bool) {
+	value := int32(0)
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&api.s.doubleSign, value)
+	log.Warn("Simulated double signing changed", "enabled", enabled)
+}
+
+// Forged returns the number of conflicting blocks the validator broadcast.
+func (api *API) Forged() int64 {
+	return atomic.LoadInt64(&api.s.forged)
+}
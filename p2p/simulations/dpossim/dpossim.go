@@ -0,0 +1,132 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dpossim runs networks of DPoS validators on top of the devp2p
+// simulation framework, injects faults into them and checks that the consensus
+// invariants keep holding.
+//
+// Every simulated node runs a full datx.Ethereum service sealing with its devp2p
+// node key, so the validator set of the shared genesis is simply the set of node
+// addresses in the network.
+//
+// Scenarios are JSON scripts of timed faults and expectations, e.g.
+//
+//	{
+//	  "duration": "20m",
+//	  "steps": [
+//	    {"at": "1m",  "action": "offline", "nodes": ["validator03"]},
+//	    {"at": "2m",  "action": "partition", "groups": [["validator00", "validator01"], ["validator02"]]},
+//	    {"at": "4m",  "action": "heal"},
+//	    {"at": "5m",  "action": "skew", "nodes": ["validator04"], "skew": "4s"},
+//	    {"at": "6m",  "action": "doublesign", "nodes": ["validator05"]},
+//	    {"at": "19m", "action": "expect", "epochs": 3, "kickout": ["validator03"]}
+//	  ]
+//	}
+package dpossim
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/params"
+)
+
+const (
+	// ServiceName is the name the validator service is registered with in the
+	// simulation adapters.
+	ServiceName = "dpos"
+
+	// DefaultEpoch is the epoch length used by simulated networks, short enough
+	// for a few elections to happen within a single run.
+	DefaultEpoch = 420
+
+	// DefaultNetworkId is the network id simulated validators handshake with.
+	DefaultNetworkId = 4096
+)
+
+// Config is the configuration shared by all validators of a simulated network.
+type Config struct {
+	Genesis   *core.Genesis `json:"genesis"`
+	NetworkId uint64        `json:"networkId"`
+}
+
+// NewConfig creates a network configuration whose genesis authorizes the given
+// validator keys and elects every epoch seconds.
+func NewConfig(keys []*ecdsa.PrivateKey, epoch uint64) *Config {
+	validators := make([]common.Address, len(keys))
+	alloc := make(core.GenesisAlloc, len(keys))
+	for i, key := range keys {
+		validators[i] = crypto.PubkeyToAddress(key.PublicKey)
+		alloc[validators[i]] = core.GenesisAccount{Balance: new(big.Int).Lsh(big.NewInt(1), 100)}
+	}
+	chainConfig := *params.DposChainConfig
+	chainConfig.ChainId = big.NewInt(DefaultNetworkId)
+	chainConfig.Dpos = &params.DposConfig{
+		Validators: validators,
+		Epoch:      epoch,
+	}
+	return &Config{
+		Genesis: &core.Genesis{
+			Config:     &chainConfig,
+			Timestamp:  0,
+			ExtraData:  make([]byte, 32),
+			GasLimit:   4712388,
+			Difficulty: big.NewInt(1),
+			Alloc:      alloc,
+		},
+		NetworkId: DefaultNetworkId,
+	}
+}
+
+// LoadConfig reads a network configuration from a JSON file, as written by
+// Config.Save. Nodes started by the exec adapter use it to share the genesis.
+func LoadConfig(file string) (*Config, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := new(Config)
+	if err := json.Unmarshal(blob, config); err != nil {
+		return nil, fmt.Errorf("invalid simulation config %s: %v", file, err)
+	}
+	if config.Genesis == nil || config.Genesis.Config == nil || config.Genesis.Config.Dpos == nil {
+		return nil, fmt.Errorf("simulation config %s has no dpos genesis", file)
+	}
+	return config, nil
+}
+
+// Save writes the network configuration into a JSON file.
+func (c *Config) Save(file string) error {
+	blob, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, blob, 0644)
+}
+
+// Epoch returns the epoch length of the simulated network in seconds.
+func (c *Config) Epoch() uint64 {
+	if epoch := c.Genesis.Config.Dpos.Epoch; epoch != 0 {
+		return epoch
+	}
+	return 86400
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpossim
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/common/hexutil"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// Head is the part of a block header the invariant checker looks at.
+type Head struct {
+	Number uint64
+	Hash   common.Hash
+	Time   uint64
+}
+
+// ChainView is the read only view on a validator's chain needed to check the
+// consensus invariants.
+type ChainView interface {
+	// Header retrieves the canonical header at the given height, or the head
+	// header if number is nil.
+	Header(ctx context.Context, number *uint64) (*Head, error)
+
+	// Confirmed retrieves the number of the latest irreversible block.
+	Confirmed(ctx context.Context) (uint64, error)
+
+	// Validators retrieves the validator set of the current epoch.
+	Validators(ctx context.Context) ([]common.Address, error)
+}
+
+// rpcChainView is a ChainView on top of the public RPC API of a node.
+type rpcChainView struct {
+	client *rpc.Client
+}
+
+// NewRPCChainView creates a chain view querying a node over RPC.
+func NewRPCChainView(client *rpc.Client) ChainView {
+	return &rpcChainView{client}
+}
+
+func (v *rpcChainView) Header(ctx context.Context, number *uint64) (*Head, error) {
+	arg := "latest"
+	if number != nil {
+		arg = hexutil.EncodeUint64(*number)
+	}
+	var head *struct {
+		Number hexutil.Uint64 `json:"number"`
+		Hash   common.Hash    `json:"hash"`
+		Time   *hexutil.Big   `json:"timestamp"`
+	}
+	if err := v.client.CallContext(ctx, &head, "eth_getBlockByNumber", arg, false); err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, fmt.Errorf("block %s not found", arg)
+	}
+	return &Head{
+		Number: uint64(head.Number),
+		Hash:   head.Hash,
+		Time:   head.Time.ToInt().Uint64(),
+	}, nil
+}
+
+func (v *rpcChainView) Confirmed(ctx context.Context) (uint64, error) {
+	var number *big.Int
+	if err := v.client.CallContext(ctx, &number, "dpos_getConfirmedBlockNumber"); err != nil {
+		return 0, err
+	}
+	return number.Uint64(), nil
+}
+
+func (v *rpcChainView) Validators(ctx context.Context) ([]common.Address, error) {
+	var validators []common.Address
+	err := v.client.CallContext(ctx, &validators, "dpos_getValidators", nil)
+	return validators, err
+}
+
+// Violation is a broken consensus invariant observed on a node.
+type Violation struct {
+	Node   string `json:"node"`
+	Number uint64 `json:"number"`
+	Reason string `json:"reason"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s: block #%d: %s", v.Node, v.Number, v.Reason)
+}
+
+// Checker tracks the chains of a simulated network and verifies that
+//
+//   - no node ever reverts a block it has confirmed as irreversible,
+//   - all nodes agree on every block confirmed by any of them,
+//   - new epochs keep being entered and elect a validator set.
+//
+// The checker is fed by calling Check periodically while the simulation runs.
+type Checker struct {
+	epoch uint64 // Epoch length in seconds
+
+	confirmed  map[string]uint64           // Latest confirmed number per node
+	finalized  map[uint64]common.Hash      // Confirmed hashes agreed upon by the network
+	epochs     map[uint64]bool             // Epochs the network produced blocks in
+	validators map[uint64][]common.Address // Validator set observed in each epoch
+
+	violations []*Violation
+	lock       sync.Mutex
+}
+
+// NewChecker creates an invariant checker for a network electing every epoch
+// seconds.
+func NewChecker(epoch uint64) *Checker {
+	return &Checker{
+		epoch:      epoch,
+		confirmed:  make(map[string]uint64),
+		finalized:  make(map[uint64]common.Hash),
+		epochs:     make(map[uint64]bool),
+		validators: make(map[uint64][]common.Address),
+	}
+}
+
+// Check runs one round of invariant checks against the given nodes. Nodes that
+// cannot be reached (e.g. because they were taken offline) are skipped.
+func (c *Checker) Check(ctx context.Context, views map[string]ChainView) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.checkNode(ctx, name, views[name]); err != nil {
+			log.Debug("Skipping unreachable simulation node", "node", name, "err", err)
+		}
+	}
+}
+
+// checkNode verifies the invariants on a single node.
+func (c *Checker) checkNode(ctx context.Context, name string, view ChainView) error {
+	confirmed, err := view.Confirmed(ctx)
+	if err != nil {
+		return err
+	}
+	if prev := c.confirmed[name]; confirmed < prev {
+		c.violate(name, confirmed, fmt.Sprintf("confirmed block moved back from #%d", prev))
+	} else {
+		c.confirmed[name] = confirmed
+	}
+	// Every block ever confirmed by anyone must be in this node's chain, as long
+	// as the node itself considers it irreversible already. Checking the highest
+	// one is enough, its ancestors are implied by the hash chain.
+	var (
+		highest uint64
+		found   bool
+	)
+	for number := range c.finalized {
+		if number <= confirmed && (!found || number > highest) {
+			highest, found = number, true
+		}
+	}
+	if found {
+		head, err := view.Header(ctx, &highest)
+		if err != nil {
+			return err
+		}
+		if hash := c.finalized[highest]; head.Hash != hash {
+			c.violate(name, highest, fmt.Sprintf("fork below confirmed block: have %x, network confirmed %x", head.Hash, hash))
+		}
+	}
+	if _, known := c.finalized[confirmed]; !known && confirmed > 0 {
+		head, err := view.Header(ctx, &confirmed)
+		if err != nil {
+			return err
+		}
+		c.finalized[confirmed] = head.Hash
+	}
+	// Track the epochs and validator sets the network goes through
+	head, err := view.Header(ctx, nil)
+	if err != nil {
+		return err
+	}
+	epoch := head.Time / c.epoch
+	c.epochs[epoch] = true
+	if _, known := c.validators[epoch]; !known {
+		validators, err := view.Validators(ctx)
+		if err != nil {
+			return err
+		}
+		c.validators[epoch] = validators
+	}
+	return nil
+}
+
+// violate records a broken invariant.
+func (c *Checker) violate(node string, number uint64, reason string) {
+	v := &Violation{Node: node, Number: number, Reason: reason}
+	log.Error("DPoS invariant violated", "node", node, "number", number, "reason", reason)
+	c.violations = append(c.violations, v)
+}
+
+// Violations returns all the broken invariants observed so far.
+func (c *Checker) Violations() []*Violation {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]*Violation{}, c.violations...)
+}
+
+// Epochs returns the number of distinct epochs the network produced blocks in.
+func (c *Checker) Epochs() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.epochs)
+}
+
+// Kicked reports whether validator was part of an observed validator set and
+// missing from the set of a later epoch.
+func (c *Checker) Kicked(validator common.Address) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	epochs := make([]uint64, 0, len(c.validators))
+	for epoch := range c.validators {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+
+	member := false
+	for _, epoch := range epochs {
+		found := false
+		for _, addr := range c.validators[epoch] {
+			if addr == validator {
+				found = true
+				break
+			}
+		}
+		if member && !found {
+			return true
+		}
+		member = member || found
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpossim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+)
+
+// testChainView is a ChainView backed by a static list of block hashes.
+type testChainView struct {
+	hashes     []common.Hash
+	times      []uint64
+	confirmed  uint64
+	validators []common.Address
+	offline    bool
+}
+
+func newTestChainView(n int, seed byte) *testChainView {
+	view := &testChainView{}
+	for i := 0; i < n; i++ {
+		view.hashes = append(view.hashes, common.Hash{seed, byte(i)})
+		view.times = append(view.times, uint64(i)*10)
+	}
+	return view
+}
+
+func (v *testChainView) Header(ctx context.Context, number *uint64) (*Head, error) {
+	if v.offline {
+		return nil, errors.New("offline")
+	}
+	n := uint64(len(v.hashes) - 1)
+	if number != nil {
+		n = *number
+	}
+	return &Head{Number: n, Hash: v.hashes[n], Time: v.times[n]}, nil
+}
+
+func (v *testChainView) Confirmed(ctx context.Context) (uint64, error) {
+	if v.offline {
+		return 0, errors.New("offline")
+	}
+	return v.confirmed, nil
+}
+
+func (v *testChainView) Validators(ctx context.Context) ([]common.Address, error) {
+	return v.validators, nil
+}
+
+// Tests that nodes agreeing on their chains don't trip the checker, but a node
+// reorganising below a confirmed block does.
+func TestCheckerForkBelowConfirmed(t *testing.T) {
+	checker := NewChecker(100)
+
+	one, two := newTestChainView(10, 1), newTestChainView(10, 1)
+	one.confirmed, two.confirmed = 5, 3
+
+	views := map[string]ChainView{"one": one, "two": two}
+	checker.Check(context.Background(), views)
+	if violations := checker.Violations(); len(violations) != 0 {
+		t.Fatalf("unexpected violations on agreeing chains: %v", violations)
+	}
+	// Fork node two above its confirmed block, but below the confirmed block of
+	// node one; it's fine until two considers the forked block confirmed too
+	for i := 4; i < len(two.hashes); i++ {
+		two.hashes[i] = common.Hash{2, byte(i)}
+	}
+	checker.Check(context.Background(), views)
+	if violations := checker.Violations(); len(violations) != 0 {
+		t.Fatalf("unexpected violations above confirmed block: %v", violations)
+	}
+	two.confirmed = 6
+	checker.Check(context.Background(), views)
+	if violations := checker.Violations(); len(violations) != 1 || violations[0].Node != "two" {
+		t.Fatalf("violation mismatch: have %v, want fork on node two", violations)
+	}
+}
+
+// Tests that a confirmed block moving backwards is detected, and that offline
+// nodes are skipped.
+func TestCheckerConfirmedRevert(t *testing.T) {
+	checker := NewChecker(100)
+
+	view := newTestChainView(10, 1)
+	view.confirmed = 5
+
+	views := map[string]ChainView{"node": view}
+	checker.Check(context.Background(), views)
+
+	view.offline = true
+	checker.Check(context.Background(), views)
+	if violations := checker.Violations(); len(violations) != 0 {
+		t.Fatalf("unexpected violations for offline node: %v", violations)
+	}
+	view.offline, view.confirmed = false, 4
+	checker.Check(context.Background(), views)
+	if violations := checker.Violations(); len(violations) != 1 {
+		t.Fatalf("violation count mismatch: have %d, want 1", len(violations))
+	}
+}
+
+// Tests that epoch rollovers and validator kickouts are tracked.
+func TestCheckerEpochsAndKickouts(t *testing.T) {
+	var (
+		checker = NewChecker(50)
+		view    = newTestChainView(3, 1)
+		first   = common.Address{1}
+		second  = common.Address{2}
+	)
+	view.validators = []common.Address{first, second}
+	views := map[string]ChainView{"node": view}
+	checker.Check(context.Background(), views)
+
+	// Advance the chain into the next epoch with the second validator removed
+	for i := 3; i < 8; i++ {
+		view.hashes = append(view.hashes, common.Hash{1, byte(i)})
+		view.times = append(view.times, uint64(i)*10)
+	}
+	view.validators = []common.Address{first}
+	checker.Check(context.Background(), views)
+
+	if epochs := checker.Epochs(); epochs != 2 {
+		t.Errorf("epoch count mismatch: have %d, want 2", epochs)
+	}
+	if checker.Kicked(first) {
+		t.Errorf("validator %x reported as kicked out", first)
+	}
+	if !checker.Kicked(second) {
+		t.Errorf("validator %x not reported as kicked out", second)
+	}
+}
+
+// Tests that scenarios are decoded, validated and ordered.
+func TestScenarioValidation(t *testing.T) {
+	var scenario Scenario
+	blob := `{"duration": "10m", "steps": [
+		{"at": "5m", "action": "expect", "epochs": 1},
+		{"at": "1m", "action": "offline", "nodes": ["node01"]}
+	]}`
+	if err := json.Unmarshal([]byte(blob), &scenario); err != nil {
+		t.Fatalf("failed to decode scenario: %v", err)
+	}
+	if err := scenario.validate(); err != nil {
+		t.Fatalf("failed to validate scenario: %v", err)
+	}
+	if scenario.Steps[0].Action != ActionOffline || time.Duration(scenario.Steps[0].At) != time.Minute {
+		t.Errorf("steps not ordered: first step %+v", scenario.Steps[0])
+	}
+	if time.Duration(scenario.Interval) != 10*time.Second {
+		t.Errorf("default interval mismatch: have %v", time.Duration(scenario.Interval))
+	}
+	invalid := []string{
+		`{"steps": []}`,
+		`{"duration": "1m", "steps": [{"at": "2m", "action": "heal"}]}`,
+		`{"duration": "1m", "steps": [{"at": "10s", "action": "offline"}]}`,
+		`{"duration": "1m", "steps": [{"at": "10s", "action": "partition", "groups": [["a"]]}]}`,
+		`{"duration": "1m", "steps": [{"at": "10s", "action": "explode"}]}`,
+	}
+	for i, blob := range invalid {
+		var scenario Scenario
+		if err := json.Unmarshal([]byte(blob), &scenario); err != nil {
+			t.Fatalf("invalid scenario %d: failed to decode: %v", i, err)
+		}
+		if err := scenario.validate(); err == nil {
+			t.Errorf("invalid scenario %d: validation passed", i)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpossim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// Network is the control surface of a running simulation the scenario runner
+// drives. Nodes are identified by their human readable names.
+type Network interface {
+	Nodes() ([]string, error)
+	Start(node string) error
+	Stop(node string) error
+	Connect(one, other string) error
+	Disconnect(one, other string) error
+	Client(node string) (*rpc.Client, error)
+}
+
+// Fault injection and assertion actions a scenario step may take.
+const (
+	ActionOffline    = "offline"    // Stop the given nodes
+	ActionOnline     = "online"     // Restart the given nodes
+	ActionPartition  = "partition"  // Disconnect the given groups from each other
+	ActionHeal       = "heal"       // Reconnect all previously partitioned nodes
+	ActionSkew       = "skew"       // Shift the clocks of the given nodes
+	ActionDoubleSign = "doublesign" // Make the given nodes equivocate
+	ActionHonest     = "honest"     // Stop the given nodes from equivocating
+	ActionExpect     = "expect"     // Assert epochs and kickouts observed so far
+)
+
+// Duration is a time.Duration encoded as a string (e.g. "1m30s") in JSON.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(input []byte) error {
+	var str string
+	if err := json.Unmarshal(input, &str); err != nil {
+		return err
+	}
+	dur, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// Step is a single timed action of a scenario.
+type Step struct {
+	At     Duration   `json:"at"`               // Offset from the scenario start
+	Action string     `json:"action"`           // One of the Action* constants
+	Nodes  []string   `json:"nodes,omitempty"`  // Nodes the action applies to
+	Groups [][]string `json:"groups,omitempty"` // Sides of a partition
+	Skew   Duration   `json:"skew,omitempty"`   // Clock skew to apply
+
+	Epochs  int      `json:"epochs,omitempty"`  // Minimum number of epochs to have been seen
+	Kickout []string `json:"kickout,omitempty"` // Nodes expected to have been voted out
+}
+
+// Scenario is a scripted simulation run: a list of faults injected at given
+// times while the invariants are continuously checked.
+type Scenario struct {
+	Duration Duration `json:"duration"` // Total running time of the scenario
+	Interval Duration `json:"interval"` // Time between two invariant checks
+	Steps    []Step   `json:"steps"`
+}
+
+// LoadScenario reads and validates a scenario from a JSON file.
+func LoadScenario(file string) (*Scenario, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(Scenario)
+	if err := json.Unmarshal(blob, scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", file, err)
+	}
+	if err := scenario.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", file, err)
+	}
+	return scenario, nil
+}
+
+// validate checks the scenario for structural errors and orders its steps.
+func (s *Scenario) validate() error {
+	if s.Duration <= 0 {
+		return errors.New("missing duration")
+	}
+	if s.Interval <= 0 {
+		s.Interval = Duration(10 * time.Second)
+	}
+	for i, step := range s.Steps {
+		if step.At > s.Duration {
+			return fmt.Errorf("step %d: at %v past the end of the scenario", i, time.Duration(step.At))
+		}
+		switch step.Action {
+		case ActionOffline, ActionOnline, ActionDoubleSign, ActionHonest, ActionSkew:
+			if len(step.Nodes) == 0 {
+				return fmt.Errorf("step %d: %s without nodes", i, step.Action)
+			}
+		case ActionPartition:
+			if len(step.Groups) < 2 {
+				return fmt.Errorf("step %d: partition needs at least two groups", i)
+			}
+		case ActionHeal, ActionExpect:
+		default:
+			return fmt.Errorf("step %d: unknown action %q", i, step.Action)
+		}
+	}
+	sort.SliceStable(s.Steps, func(i, j int) bool { return s.Steps[i].At < s.Steps[j].At })
+	return nil
+}
+
+// Report is the outcome of a scenario run.
+type Report struct {
+	Epochs     int          `json:"epochs"`
+	Violations []*Violation `json:"violations"`
+	Failures   []string     `json:"failures"`
+}
+
+// Failed reports whether any invariant or expectation failed during the run.
+func (r *Report) Failed() bool {
+	return len(r.Violations) > 0 || len(r.Failures) > 0
+}
+
+// Runner executes scenarios against a simulated network.
+type Runner struct {
+	network Network
+	checker *Checker
+
+	validators  map[string]common.Address // Validator address of every node
+	partitioned [][2]string               // Connections cut by partitions
+}
+
+// NewRunner creates a scenario runner on a network electing every epoch seconds.
+func NewRunner(network Network, epoch uint64) *Runner {
+	return &Runner{
+		network:    network,
+		checker:    NewChecker(epoch),
+		validators: make(map[string]common.Address),
+	}
+}
+
+// Run executes the scenario, blocking until it finishes or ctx is cancelled.
+func (r *Runner) Run(ctx context.Context, scenario *Scenario) (*Report, error) {
+	if err := scenario.validate(); err != nil {
+		return nil, err
+	}
+	nodes, err := r.network.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		client, err := r.network.Client(node)
+		if err != nil {
+			return nil, err
+		}
+		var validator common.Address
+		if err := client.CallContext(ctx, &validator, "dpossim_validator"); err != nil {
+			return nil, fmt.Errorf("node %s is not a dpos validator: %v", node, err)
+		}
+		r.validators[node] = validator
+	}
+	report := new(Report)
+
+	start := time.Now()
+	ticker := time.NewTicker(time.Duration(scenario.Interval))
+	defer ticker.Stop()
+
+	end := time.NewTimer(time.Duration(scenario.Duration))
+	defer end.Stop()
+
+	steps := scenario.Steps
+	for {
+		// Execute all the steps that are due
+		for len(steps) > 0 && time.Since(start) >= time.Duration(steps[0].At) {
+			log.Info("Executing scenario step", "at", time.Duration(steps[0].At), "action", steps[0].Action)
+			if err := r.execute(ctx, &steps[0], report); err != nil {
+				return report, fmt.Errorf("step %s at %v: %v", steps[0].Action, time.Duration(steps[0].At), err)
+			}
+			steps = steps[1:]
+		}
+		var next <-chan time.Time
+		if len(steps) > 0 {
+			next = time.After(time.Duration(steps[0].At) - time.Since(start))
+		}
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-next:
+		case <-ticker.C:
+			r.check(ctx)
+		case <-end.C:
+			r.check(ctx)
+			report.Epochs = r.checker.Epochs()
+			report.Violations = r.checker.Violations()
+			return report, nil
+		}
+	}
+}
+
+// check runs a round of invariant checks against all reachable nodes.
+func (r *Runner) check(ctx context.Context) {
+	views := make(map[string]ChainView)
+	for node := range r.validators {
+		client, err := r.network.Client(node)
+		if err != nil {
+			continue
+		}
+		views[node] = NewRPCChainView(client)
+	}
+	r.checker.Check(ctx, views)
+}
+
+// execute applies a single scenario step to the network.
+func (r *Runner) execute(ctx context.Context, step *Step, report *Report) error {
+	switch step.Action {
+	case ActionOffline:
+		for _, node := range step.Nodes {
+			if err := r.network.Stop(node); err != nil {
+				return err
+			}
+		}
+	case ActionOnline:
+		for _, node := range step.Nodes {
+			if err := r.network.Start(node); err != nil {
+				return err
+			}
+		}
+	case ActionPartition:
+		for i, group := range step.Groups {
+			for _, other := range step.Groups[i+1:] {
+				for _, one := range group {
+					for _, two := range other {
+						if err := r.network.Disconnect(one, two); err != nil {
+							log.Debug("Failed to cut simulated connection", "one", one, "other", two, "err", err)
+							continue
+						}
+						r.partitioned = append(r.partitioned, [2]string{one, two})
+					}
+				}
+			}
+		}
+	case ActionHeal:
+		for _, conn := range r.partitioned {
+			if err := r.network.Connect(conn[0], conn[1]); err != nil {
+				return err
+			}
+		}
+		r.partitioned = nil
+
+	case ActionSkew:
+		millis := int64(time.Duration(step.Skew) / time.Millisecond)
+		return r.call(ctx, step.Nodes, "dpossim_setClockSkew", millis)
+
+	case ActionDoubleSign:
+		return r.call(ctx, step.Nodes, "dpossim_setDoubleSign", true)
+
+	case ActionHonest:
+		return r.call(ctx, step.Nodes, "dpossim_setDoubleSign", false)
+
+	case ActionExpect:
+		r.check(ctx)
+		if epochs := r.checker.Epochs(); epochs < step.Epochs {
+			report.Failures = append(report.Failures, fmt.Sprintf("at %v: saw %d epochs, want at least %d", time.Duration(step.At), epochs, step.Epochs))
+		}
+		for _, node := range step.Kickout {
+			validator, ok := r.validators[node]
+			if !ok {
+				return fmt.Errorf("unknown node %s", node)
+			}
+			if !r.checker.Kicked(validator) {
+				report.Failures = append(report.Failures, fmt.Sprintf("at %v: %s (%x) was not kicked out", time.Duration(step.At), node, validator))
+			}
+		}
+	}
+	return nil
+}
+
+// call invokes a fault injection method on each of the given nodes.
+func (r *Runner) call(ctx context.Context, nodes []string, method string, args ...interface{}) error {
+	for _, node := range nodes {
+		client, err := r.network.Client(node)
+		if err != nil {
+			return err
+		}
+		if err := client.CallContext(ctx, nil, method, args...); err != nil {
+			return fmt.Errorf("%s on %s: %v", method, node, err)
+		}
+	}
+	return nil
+}
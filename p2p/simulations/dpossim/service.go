@@ -0,0 +1,178 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpossim
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/accounts"
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/consensus/dpos"
+	"github.com/DATxChain-Protocol/DATx/core"
+	"github.com/DATxChain-Protocol/DATx/core/types"
+	"github.com/DATxChain-Protocol/DATx/crypto"
+	"github.com/DATxChain-Protocol/DATx/datx"
+	"github.com/DATxChain-Protocol/DATx/event"
+	"github.com/DATxChain-Protocol/DATx/log"
+	"github.com/DATxChain-Protocol/DATx/node"
+	"github.com/DATxChain-Protocol/DATx/p2p"
+	"github.com/DATxChain-Protocol/DATx/rpc"
+)
+
+// Service is a DPoS validator running in a simulated network. It wraps a full
+// datx.Ethereum node sealing with the validator key and lets the simulation
+// skew its clock or make it equivocate.
+type Service struct {
+	datx      *datx.Ethereum
+	engine    *dpos.Dpos
+	key       *ecdsa.PrivateKey
+	validator common.Address
+
+	skew       int64 // Clock skew in nanoseconds (atomic)
+	doubleSign int32 // Whether every sealed block is equivocated (atomic)
+	forged     int64 // Number of conflicting blocks broadcast (atomic)
+
+	minedSub *event.TypeMuxSubscription
+}
+
+// NewService creates a validator service sealing with key on the network
+// described by config.
+func NewService(ctx *node.ServiceContext, key *ecdsa.PrivateKey, config *Config) (*Service, error) {
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+
+	datxConfig := datx.DefaultConfig
+	datxConfig.Genesis = config.Genesis
+	datxConfig.NetworkId = config.NetworkId
+	datxConfig.Validator = validator
+	datxConfig.Coinbase = validator
+
+	backend, err := datx.New(ctx, &datxConfig)
+	if err != nil {
+		return nil, err
+	}
+	engine, ok := backend.Engine().(*dpos.Dpos)
+	if !ok {
+		return nil, errors.New("simulated validator requires the dpos engine")
+	}
+	s := &Service{
+		datx:      backend,
+		engine:    engine,
+		key:       key,
+		validator: validator,
+	}
+	engine.SetTimeSource(s.now)
+	engine.Authorize(validator, s.signHash)
+	return s, nil
+}
+
+// Protocols implements node.Service.
+func (s *Service) Protocols() []p2p.Protocol {
+	return s.datx.Protocols()
+}
+
+// APIs implements node.Service, exposing the fault injection API next to the
+// regular datx ones.
+func (s *Service) APIs() []rpc.API {
+	return append(s.datx.APIs(), rpc.API{
+		Namespace: "dpossim",
+		Version:   "1.0",
+		Service:   &API{s},
+		Public:    true,
+	})
+}
+
+// Start implements node.Service, starting the node and its validator.
+func (s *Service) Start(srvr *p2p.Server) error {
+	if err := s.datx.Start(srvr); err != nil {
+		return err
+	}
+	s.minedSub = s.datx.EventMux().Subscribe(core.NewMinedBlockEvent{})
+	go s.equivocateLoop(s.minedSub)
+
+	s.datx.Miner().Start(s.validator)
+	return nil
+}
+
+// Stop implements node.Service.
+func (s *Service) Stop() error {
+	s.minedSub.Unsubscribe()
+	return s.datx.Stop()
+}
+
+// Validator returns the address the service seals blocks with.
+func (s *Service) Validator() common.Address {
+	return s.validator
+}
+
+// now is the skewed wall clock of the simulated validator.
+func (s *Service) now() time.Time {
+	return time.Now().Add(time.Duration(atomic.LoadInt64(&s.skew)))
+}
+
+// signHash implements dpos.SignerFn using the validator key directly.
+func (s *Service) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	if account.Address != s.validator {
+		return nil, accounts.ErrUnknownAccount
+	}
+	return crypto.Sign(hash, s.key)
+}
+
+// equivocateLoop broadcasts a second, conflicting block for every block the
+// validator seals while double signing is enabled.
+func (s *Service) equivocateLoop(sub *event.TypeMuxSubscription) {
+	forged := make(map[common.Hash]bool)
+	for obj := range sub.Chan() {
+		ev, ok := obj.Data.(core.NewMinedBlockEvent)
+		if !ok {
+			continue
+		}
+		if forged[ev.Block.Hash()] {
+			delete(forged, ev.Block.Hash())
+			continue
+		}
+		if atomic.LoadInt32(&s.doubleSign) == 0 || ev.Block.Header().Validator != s.validator {
+			continue
+		}
+		block, err := s.forge(ev.Block)
+		if err != nil {
+			log.Warn("Failed to forge conflicting block", "number", ev.Block.Number(), "err", err)
+			continue
+		}
+		forged[block.Hash()] = true
+		atomic.AddInt64(&s.forged, 1)
+
+		log.Info("Double signing slot", "number", block.Number(), "sealed", ev.Block.Hash(), "forged", block.Hash())
+		go s.datx.EventMux().Post(core.NewMinedBlockEvent{Block: block})
+	}
+}
+
+// forge creates a block for the same slot and parent as the given one, differing
+// only in its extra-data vanity, and seals it with the validator key.
+func (s *Service) forge(block *types.Block) (*types.Block, error) {
+	header := block.Header()
+	header.Extra[0] ^= 0xff
+
+	sighash, err := crypto.Sign(dpos.SigHash(header).Bytes(), s.key)
+	if err != nil {
+		return nil, err
+	}
+	copy(header.Extra[len(header.Extra)-len(sighash):], sighash)
+	return block.WithSeal(header), nil
+}
@@ -84,7 +84,8 @@ func (c *CliqueConfig) String() string {
 
 // DposConfig is the consensus engine configs for delegated proof-of-stake based sealing.
 type DposConfig struct {
-	Validators []common.Address `json:"validators"` // Genesis validator list
+	Validators []common.Address `json:"validators"`      // Genesis validator list
+	Epoch      uint64           `json:"epoch,omitempty"` // Epoch length in seconds to re-elect validators (0 = 86400)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -58,6 +58,22 @@ func (e *callbackError) ErrorCode() int { return -32000 }
 
 func (e *callbackError) Error() string { return e.message }
 
+// issued when the client isn't allowed to call a method
+type unauthorizedError struct{ method string }
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string {
+	return fmt.Sprintf("unauthorized to call %s", e.method)
+}
+
+// issued when a request exceeds a limit of the server
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
+
 // issued when a request is received after the server is issued to stop.
 type shutdownError struct{}
 
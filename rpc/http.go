@@ -143,7 +143,7 @@ func (t *httpReadWriteNopCloser) Close() error {
 //
 // Deprecated: Server implements http.Handler
 func NewHTTPServer(cors []string, srv *Server) *http.Server {
-	return &http.Server{Handler: newCorsHandler(srv, cors)}
+	return &http.Server{Handler: NewCorsHandler(srv, cors)}
 }
 
 // ServeHTTP serves JSON-RPC requests over HTTP.
@@ -152,28 +152,36 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" && r.ContentLength == 0 && r.URL.RawQuery == "" {
 		return
 	}
-	if code, err := validateRequest(r); err != nil {
+	maxSize := srv.policy.maxRequestSize()
+	if code, err := validateRequest(r, maxSize); err != nil {
 		http.Error(w, err.Error(), code)
 		return
 	}
+	client, err := srv.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	// All checks passed, create a codec that reads direct from the request body
 	// untilEOF and writes the response to w and order the server to process a
 	// single request.
-	codec := NewJSONCodec(&httpReadWriteNopCloser{r.Body, w})
+	body := http.MaxBytesReader(w, r.Body, maxSize)
+	codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
 	defer codec.Close()
 
 	w.Header().Set("content-type", contentType)
-	srv.ServeSingleRequest(codec, OptionMethodInvocation)
+	srv.serveRequest(context.WithValue(context.Background(), clientKey{}, client), codec, true, OptionMethodInvocation)
 }
 
 // validateRequest returns a non-zero response code and error message if the
 // request is invalid.
-func validateRequest(r *http.Request) (int, error) {
+func validateRequest(r *http.Request, maxSize int64) (int, error) {
 	if r.Method == "PUT" || r.Method == "DELETE" {
 		return http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
-	if r.ContentLength > maxHTTPRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxHTTPRequestContentLength)
+	if r.ContentLength > maxSize {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxSize)
 		return http.StatusRequestEntityTooLarge, err
 	}
 	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
@@ -184,10 +192,12 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+// NewCorsHandler wraps an HTTP handler, allowing the cross-origin requests of the
+// given origins.
+func NewCorsHandler(h http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
-		return srv
+		return h
 	}
 
 	c := cors.New(cors.Options{
@@ -196,5 +206,5 @@ func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
 	})
-	return c.Handler(srv)
+	return c.Handler(h)
 }
@@ -0,0 +1,336 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/log"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// maxRateBuckets is the number of clients the rate limiter tracks before it drops
+// the ones that are idle.
+const maxRateBuckets = 4096
+
+// jwtIssueWindow is how far from the current time a JSON web token without an
+// expiry may have been issued.
+const jwtIssueWindow = time.Minute
+
+// DefaultAuditedModules are the namespaces whose calls are logged when a policy
+// doesn't list any.
+var DefaultAuditedModules = []string{"admin", "debug", "miner", "personal"}
+
+var errUnauthenticated = errors.New("invalid or missing credentials")
+
+// Credential is a client of the RPC server, authenticated by a static bearer token
+// or by JSON web tokens signed with a shared secret.
+type Credential struct {
+	Name      string   // Name of the client, reported in the audit log
+	Token     string   `toml:",omitempty"` // Static bearer token
+	JWTSecret string   `toml:",omitempty"` // Secret of the HS256 JSON web tokens
+	Allow     []string // Namespaces ("admin") or methods ("admin_peers") the client may call
+}
+
+// Policy restricts the calls a server accepts over HTTP and websocket connections.
+//
+// Clients without credentials may only call the namespaces and methods listed in
+// Anonymous. If neither credentials nor anonymous calls are configured, all the
+// registered methods are served to anyone, as without a policy.
+//
+// The handlers served next to the RPC endpoint are guarded as methods named after
+// their path, e.g. "graphql" for the GraphQL queries served under /graphql.
+type Policy struct {
+	Credentials []*Credential `toml:",omitempty"`
+	Anonymous   []string      `toml:",omitempty"`
+
+	MaxRequestSize int64   `toml:",omitempty"` // Maximum size of a request in bytes, 0 for the default
+	MaxBatchSize   int     `toml:",omitempty"` // Maximum number of calls in a batch, 0 for no limit
+	RateLimit      float64 `toml:",omitempty"` // Calls per second allowed to a client, 0 for no limit
+	RateBurst      int     `toml:",omitempty"` // Calls a client may make at once, at least 1
+
+	Audit []string `toml:",omitempty"` // Namespaces or methods whose calls are logged
+}
+
+// clientKey is the context key of the client of a connection.
+type clientKey struct{}
+
+// client is the identity of the other end of a connection.
+type client struct {
+	name string      // Name of the credential, or remote host of anonymous clients
+	cred *Credential // Credential the client authenticated with, nil if anonymous
+}
+
+// authenticate resolves the client of an HTTP request from its bearer token.
+func (p *Policy) authenticate(r *http.Request) (*client, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return &client{name: host}, nil
+	}
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, errUnauthenticated
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	for _, cred := range p.Credentials {
+		if cred.Token != "" && subtle.ConstantTimeCompare([]byte(cred.Token), []byte(token)) == 1 {
+			return &client{name: cred.Name, cred: cred}, nil
+		}
+	}
+	for _, cred := range p.Credentials {
+		if cred.JWTSecret != "" && validJWT(token, cred.JWTSecret) {
+			return &client{name: cred.Name, cred: cred}, nil
+		}
+	}
+	return nil, errUnauthenticated
+}
+
+// validJWT reports whether a JSON web token is signed with the given secret and is
+// currently valid. Tokens must expire, or else be issued within jwtIssueWindow of
+// the current time, so a leaked one can't be replayed forever.
+func validJWT(token, secret string) bool {
+	var claims jwt.StandardClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil || !parsed.Valid {
+		return false
+	}
+	if claims.ExpiresAt != 0 {
+		return true
+	}
+	if claims.IssuedAt == 0 {
+		return false
+	}
+	issued := time.Since(time.Unix(claims.IssuedAt, 0))
+	return issued > -jwtIssueWindow && issued < jwtIssueWindow
+}
+
+// allowed reports whether the client may call the given method.
+func (p *Policy) allowed(c *client, method string) bool {
+	if c.cred != nil {
+		return matchMethod(c.cred.Allow, method)
+	}
+	if len(p.Credentials) == 0 && len(p.Anonymous) == 0 {
+		return true
+	}
+	return matchMethod(p.Anonymous, method)
+}
+
+// audited reports whether calls of the given method are logged.
+func (p *Policy) audited(method string) bool {
+	if len(p.Audit) == 0 {
+		return matchMethod(DefaultAuditedModules, method)
+	}
+	return matchMethod(p.Audit, method)
+}
+
+// matchMethod reports whether a method is in a list of namespaces and methods.
+func matchMethod(list []string, method string) bool {
+	namespace := method
+	if i := strings.Index(method, serviceMethodSeparator); i >= 0 {
+		namespace = method[:i]
+	}
+	for _, entry := range list {
+		if entry == "*" || entry == method || entry == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// maxRequestSize returns the maximum size of a request under the policy.
+func (p *Policy) maxRequestSize() int64 {
+	if p == nil || p.MaxRequestSize <= 0 {
+		return maxHTTPRequestContentLength
+	}
+	return p.MaxRequestSize
+}
+
+// rateLimiter is a token bucket rate limiter of the calls of every client.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	buckets map[string]*rateBucket
+	lock    sync.Mutex
+}
+
+// rateBucket is the allowance of a single client.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing every client the given calls per
+// second, with bursts up to the given size.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// allow takes a call from the allowance of a client, returning false if it has
+// none left.
+func (l *rateLimiter) allow(name string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[name]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.prune(now)
+		}
+		bucket = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[name] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// prune drops the clients whose allowance refilled, as they'd start over with a
+// full one anyway.
+func (l *rateLimiter) prune(now time.Time) {
+	for name, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, name)
+		}
+	}
+}
+
+// SetPolicy restricts the calls the server accepts over HTTP and websocket
+// connections. It must be called before the server starts serving them.
+func (s *Server) SetPolicy(policy *Policy) {
+	s.policy = policy
+	s.limiter = nil
+	if policy != nil && policy.RateLimit > 0 {
+		s.limiter = newRateLimiter(policy.RateLimit, policy.RateBurst)
+	}
+}
+
+// authenticate resolves the client of an HTTP request under the policy of the
+// server, returning nil if the server has none.
+func (s *Server) authenticate(r *http.Request) (*client, error) {
+	if s.policy == nil {
+		return nil, nil
+	}
+	return s.policy.authenticate(r)
+}
+
+// enforce applies the policy of the server to the requests read from the client
+// of a connection, failing the ones the client isn't allowed to make.
+func (s *Server) enforce(ctx context.Context, reqs []*serverRequest, batch bool) {
+	if s.policy == nil {
+		return
+	}
+	c, _ := ctx.Value(clientKey{}).(*client)
+	if c == nil {
+		return
+	}
+	if batch && s.policy.MaxBatchSize > 0 && len(reqs) > s.policy.MaxBatchSize {
+		err := &limitExceededError{fmt.Sprintf("batch too large (%d>%d)", len(reqs), s.policy.MaxBatchSize)}
+		for _, req := range reqs {
+			req.err = err
+		}
+		return
+	}
+	for _, req := range reqs {
+		if req.err != nil || req.isUnsubscribe {
+			continue
+		}
+		if !s.policy.allowed(c, req.method) {
+			log.Warn("Unauthorized RPC call", "client", c.name, "method", req.method)
+			req.err = &unauthorizedError{req.method}
+			continue
+		}
+		if s.limiter != nil && !s.limiter.allow(c.name) {
+			req.err = &limitExceededError{"rate limit exceeded"}
+			continue
+		}
+		if s.policy.audited(req.method) {
+			log.Info("Privileged RPC call", "client", c.name, "method", req.method)
+		}
+	}
+}
+
+// Guard wraps a handler served over the HTTP endpoint next to the server, applying
+// the policy of the server to its requests as if they were calls of the given
+// method: the client must be allowed to call it, is rate limited and audited, and
+// the size of its requests is limited.
+func (s *Server) Guard(method string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.policy == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		maxSize := s.policy.maxRequestSize()
+		if r.ContentLength > maxSize {
+			err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxSize)
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		c, err := s.policy.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !s.policy.allowed(c, method) {
+			log.Warn("Unauthorized HTTP request", "client", c.name, "method", method)
+			http.Error(w, (&unauthorizedError{method}).Error(), http.StatusForbidden)
+			return
+		}
+		if s.limiter != nil && !s.limiter.allow(c.name) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		if s.policy.audited(method) {
+			log.Info("Privileged HTTP request", "client", c.name, "method", method)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		h.ServeHTTP(w, r)
+	})
+}
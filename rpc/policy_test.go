@@ -0,0 +1,208 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// newPolicyTestServer starts an HTTP server of the test service under a policy.
+func newPolicyTestServer(t *testing.T, policy *Policy) *httptest.Server {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := server.RegisterName("admin", new(Service)); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	server.SetPolicy(policy)
+	return httptest.NewServer(server)
+}
+
+// postPolicyRequest sends a request with the given bearer token and returns the
+// HTTP status along with the JSON-RPC error codes of the responses.
+func postPolicyRequest(t *testing.T, url, token, body string) (int, []int) {
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("content-type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	var msgs []*jsonrpcMessage
+	if strings.HasPrefix(body, "[") {
+		if err := json.NewDecoder(resp.Body).Decode(&msgs); err != nil {
+			t.Fatalf("invalid batch response: %v", err)
+		}
+	} else {
+		msg := new(jsonrpcMessage)
+		if err := json.NewDecoder(resp.Body).Decode(msg); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	codes := make([]int, len(msgs))
+	for i, msg := range msgs {
+		if msg.Error != nil {
+			codes[i] = msg.Error.Code
+		}
+	}
+	return resp.StatusCode, codes
+}
+
+const (
+	policyTestCall  = `{"jsonrpc":"2.0","id":1,"method":"test_rets","params":[]}`
+	policyAdminCall = `{"jsonrpc":"2.0","id":1,"method":"admin_rets","params":[]}`
+)
+
+func TestPolicyAuthentication(t *testing.T) {
+	jwtSecret := "secret"
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(jwtSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(jwtSecret))
+	issued, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		IssuedAt: time.Now().Unix(),
+	}).SignedString([]byte(jwtSecret))
+	stale, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		IssuedAt: time.Now().Add(-2 * jwtIssueWindow).Unix(),
+	}).SignedString([]byte(jwtSecret))
+	eternal, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject: "monitor",
+	}).SignedString([]byte(jwtSecret))
+
+	server := newPolicyTestServer(t, &Policy{
+		Credentials: []*Credential{
+			{Name: "operator", Token: "token", Allow: []string{"admin", "test"}},
+			{Name: "monitor", JWTSecret: jwtSecret, Allow: []string{"admin_rets"}},
+		},
+		Anonymous: []string{"test"},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		token  string
+		body   string
+		status int
+		code   int
+	}{
+		{"", policyTestCall, http.StatusOK, 0},
+		{"", policyAdminCall, http.StatusOK, -32001},
+		{"token", policyAdminCall, http.StatusOK, 0},
+		{"token", policyTestCall, http.StatusOK, 0},
+		{signed, policyAdminCall, http.StatusOK, 0},
+		{signed, policyTestCall, http.StatusOK, -32001},
+		{expired, policyAdminCall, http.StatusUnauthorized, 0},
+		{issued, policyAdminCall, http.StatusOK, 0},
+		{stale, policyAdminCall, http.StatusUnauthorized, 0},
+		{eternal, policyAdminCall, http.StatusUnauthorized, 0},
+		{"wrong", policyTestCall, http.StatusUnauthorized, 0},
+	}
+	for i, tt := range tests {
+		status, codes := postPolicyRequest(t, server.URL, tt.token, tt.body)
+		if status != tt.status {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, status, tt.status)
+			continue
+		}
+		if status == http.StatusOK && codes[0] != tt.code {
+			t.Errorf("test %d: error code mismatch: have %d, want %d", i, codes[0], tt.code)
+		}
+	}
+}
+
+func TestPolicyLimits(t *testing.T) {
+	server := newPolicyTestServer(t, &Policy{
+		MaxRequestSize: 256,
+		MaxBatchSize:   2,
+		RateLimit:      0.001,
+		RateBurst:      3,
+	})
+	defer server.Close()
+
+	// Oversized requests are rejected before being read
+	if status, _ := postPolicyRequest(t, server.URL, "", `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["`+strings.Repeat("x", 256)+`"]}`); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized request: status mismatch: have %d, want %d", status, http.StatusRequestEntityTooLarge)
+	}
+	// Oversized batches are failed without being executed
+	batch := "[" + strings.Join([]string{policyTestCall, policyTestCall, policyTestCall}, ",") + "]"
+	if _, codes := postPolicyRequest(t, server.URL, "", batch); len(codes) != 3 || codes[0] != -32005 {
+		t.Errorf("oversized batch: error codes mismatch: have %v, want -32005", codes)
+	}
+	// Calls beyond the burst are rate limited
+	for i := 0; i < 3; i++ {
+		if _, codes := postPolicyRequest(t, server.URL, "", policyTestCall); codes[0] != 0 {
+			t.Fatalf("call %d: unexpected error code %d", i, codes[0])
+		}
+	}
+	if _, codes := postPolicyRequest(t, server.URL, "", policyTestCall); codes[0] != -32005 {
+		t.Errorf("rate limited call: error code mismatch: have %d, want -32005", codes[0])
+	}
+}
+
+func TestPolicyGuard(t *testing.T) {
+	server := NewServer()
+	server.SetPolicy(&Policy{
+		Credentials:    []*Credential{{Name: "operator", Token: "token", Allow: []string{"graphql"}}},
+		Anonymous:      []string{"test"},
+		MaxRequestSize: 256,
+	})
+	handler := server.Guard("graphql", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}))
+	tests := []struct {
+		token  string
+		size   int
+		status int
+	}{
+		{"", 0, http.StatusForbidden},
+		{"wrong", 0, http.StatusUnauthorized},
+		{"token", 0, http.StatusOK},
+		{"token", 512, http.StatusRequestEntityTooLarge},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(strings.Repeat("x", tt.size)))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, rec.Code, tt.status)
+		}
+	}
+}
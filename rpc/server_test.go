@@ -160,3 +160,39 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+type dataError struct{}
+
+func (e *dataError) Error() string          { return "failed" }
+func (e *dataError) ErrorCode() int         { return 3 }
+func (e *dataError) ErrorData() interface{} { return "0x2a" }
+
+type FailingService struct{}
+
+func (s *FailingService) Fail() error {
+	return &dataError{}
+}
+
+func TestServerErrorData(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(FailingService)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	request := map[string]interface{}{"id": 1, "method": "test_fail", "version": "2.0"}
+	if err := json.NewEncoder(clientConn).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var response jsonErrResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	want := jsonError{Code: 3, Message: "failed", Data: "0x2a"}
+	if !reflect.DeepEqual(response.Error, want) {
+		t.Errorf("error mismatch: have %+v, want %+v", response.Error, want)
+	}
+}
@@ -57,6 +57,7 @@ type service struct {
 type serverRequest struct {
 	id            interface{}
 	svcname       string
+	method        string // Name of the called method or subscription, with its namespace
 	callb         *callback
 	args          []reflect.Value
 	isUnsubscribe bool
@@ -71,6 +72,9 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 type Server struct {
 	services serviceRegistry
 
+	policy  *Policy      // Restrictions of HTTP and websocket calls, if any
+	limiter *rateLimiter // Rate limiter of the calls of every client, if any
+
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
@@ -92,6 +96,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError wraps RPC errors carrying additional data, which is returned to the
+// client as the data member of the error object.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -37,10 +37,27 @@ import (
 // allowedOrigins should be a comma-separated list of allowed origin URLs.
 // To allow connections with any origin, pass "*".
 func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
+	validateOrigin := wsHandshakeValidator(allowedOrigins)
 	return websocket.Server{
-		Handshake: wsHandshakeValidator(allowedOrigins),
+		Handshake: func(cfg *websocket.Config, req *http.Request) error {
+			if err := validateOrigin(cfg, req); err != nil {
+				return err
+			}
+			_, err := srv.authenticate(req)
+			return err
+		},
 		Handler: func(conn *websocket.Conn) {
-			srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
+			client, err := srv.authenticate(conn.Request())
+			if err != nil {
+				conn.Close()
+				return
+			}
+			if srv.policy != nil && srv.policy.MaxRequestSize > 0 {
+				conn.MaxPayloadBytes = int(srv.policy.MaxRequestSize)
+			}
+			codec := NewJSONCodec(conn)
+			defer codec.Close()
+			srv.serveRequest(context.WithValue(context.Background(), clientKey{}, client), codec, false, OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
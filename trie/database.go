@@ -0,0 +1,376 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+	"github.com/DATxChain-Protocol/DATx/log"
+)
+
+// NodeDatabase is an intermediate write layer between the trie data structures
+// and the disk database. Tries commit their nodes into it, where they're kept in
+// memory along with reference counts, until they're either flushed to disk as
+// part of a committed root, or garbage collected once no root references them.
+//
+// Besides trie nodes, which are keyed by their hash, the database also buffers
+// any other entry committed alongside the tries (e.g. secure key preimages) and
+// writes those out on the next flush.
+type NodeDatabase struct {
+	diskdb datxdb.Database // Persistent storage for matured trie nodes
+
+	nodes     map[common.Hash]*cachedNode // Data and references relationships of a node
+	preimages map[string][]byte           // Non-node entries committed along with the tries
+
+	gcnodes uint64             // Nodes garbage collected since the last flush
+	gcsize  common.StorageSize // Data storage garbage collected since the last flush
+	gctime  time.Duration      // Time spent on garbage collection since the last flush
+
+	nodesSize     common.StorageSize // Storage size of the nodes cache
+	preimagesSize common.StorageSize // Storage size of the non-node entries
+
+	lock sync.RWMutex
+}
+
+// cachedNode is a trie node cached in memory, along with its references.
+type cachedNode struct {
+	blob     []byte              // Cached data block of the trie node
+	parents  int                 // Number of live nodes referencing this one
+	children map[common.Hash]int // Children referenced by this node
+}
+
+// NewNodeDatabase creates a new trie node database on top of a persistent store.
+// The root references are tracked under the zero hash, so it has no parents.
+func NewNodeDatabase(diskdb datxdb.Database) *NodeDatabase {
+	return &NodeDatabase{
+		diskdb: diskdb,
+		nodes: map[common.Hash]*cachedNode{
+			{}: {children: make(map[common.Hash]int)},
+		},
+		preimages: make(map[string][]byte),
+	}
+}
+
+// DiskDB retrieves the persistent storage backing the trie database.
+func (db *NodeDatabase) DiskDB() datxdb.Database {
+	return db.diskdb
+}
+
+// Put implements DatabaseWriter, inserting a node committed by a trie into the
+// memory cache. The references of the node to its cached children are tracked
+// so that they are kept alive along with it.
+func (db *NodeDatabase) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(key) != common.HashLength {
+		if _, ok := db.preimages[string(key)]; !ok {
+			db.preimages[string(key)] = common.CopyBytes(value)
+			db.preimagesSize += common.StorageSize(len(key) + len(value))
+		}
+		return nil
+	}
+	db.insert(common.BytesToHash(key), value)
+	return nil
+}
+
+// insert inserts a node into the memory cache, referencing its cached children.
+// It assumes the lock is held.
+func (db *NodeDatabase) insert(hash common.Hash, blob []byte) {
+	// If the node's already cached, skip
+	if _, ok := db.nodes[hash]; ok {
+		return
+	}
+	entry := &cachedNode{
+		blob:     common.CopyBytes(blob),
+		children: make(map[common.Hash]int),
+	}
+	// Track all the direct parent->child references. Blobs which are not trie
+	// nodes (e.g. contract code) have no children.
+	if n, err := decodeNode(hash[:], blob, 0); err == nil {
+		for _, child := range nodeChildren(n, nil) {
+			if c, ok := db.nodes[child]; ok {
+				c.parents++
+				entry.children[child]++
+			}
+		}
+	}
+	db.nodes[hash] = entry
+	db.nodesSize += common.StorageSize(common.HashLength + len(entry.blob))
+}
+
+// nodeChildren appends the hashes of the children referenced by a node to the
+// given list, descending into the children embedded in their parent.
+func nodeChildren(n node, children []common.Hash) []common.Hash {
+	switch n := n.(type) {
+	case *shortNode:
+		return nodeChildren(n.Val, children)
+	case *fullNode:
+		for i := 0; i < 16; i++ {
+			children = nodeChildren(n.Children[i], children)
+		}
+	case hashNode:
+		children = append(children, common.BytesToHash(n))
+	}
+	return children
+}
+
+// Get implements DatabaseReader, retrieving a node from the memory cache, or
+// from the persistent database if it's not cached.
+func (db *NodeDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil {
+			db.lock.RUnlock()
+			return node.blob, nil
+		}
+	} else if preimage, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return preimage, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Get(key)
+}
+
+// Has implements DatabaseReader, checking the memory cache and then the
+// persistent database for the given key.
+func (db *NodeDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil {
+			db.lock.RUnlock()
+			return true, nil
+		}
+	} else if _, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return true, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Has(key)
+}
+
+// Reference adds a new reference from a parent node to a child node. A zero
+// parent hash references the child as a root, keeping it alive until it's
+// dereferenced. References to nodes that are not cached are ignored, as those
+// are already persisted.
+func (db *NodeDatabase) Reference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.reference(child, parent)
+}
+
+// reference is the private locked version of Reference.
+func (db *NodeDatabase) reference(child common.Hash, parent common.Hash) {
+	// If the node does not exist, it's a node pulled from disk, skip
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	// If the parent is not cached, there is nothing keeping the child alive
+	if _, ok := db.nodes[parent]; !ok {
+		return
+	}
+	// If the reference already exists, only duplicate for roots
+	if _, ok = db.nodes[parent].children[child]; ok && parent != (common.Hash{}) {
+		return
+	}
+	node.parents++
+	db.nodes[parent].children[child]++
+}
+
+// Dereference removes a root reference added by Reference, and deletes the
+// nodes of the trie which are not referenced by anything else any more.
+func (db *NodeDatabase) Dereference(root common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	nodes, storage, start := len(db.nodes), db.nodesSize, time.Now()
+	db.dereference(root, common.Hash{})
+
+	db.gcnodes += uint64(nodes - len(db.nodes))
+	db.gcsize += storage - db.nodesSize
+	db.gctime += time.Since(start)
+
+	log.Trace("Dereferenced trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+}
+
+// dereference is the private locked version of Dereference.
+func (db *NodeDatabase) dereference(child common.Hash, parent common.Hash) {
+	// Dereference the parent-child
+	node := db.nodes[parent]
+
+	if node.children[child] > 0 {
+		node.children[child]--
+		if node.children[child] == 0 {
+			delete(node.children, child)
+		}
+	} else {
+		// The reference was dropped by a flush in the meantime
+		return
+	}
+	// If the child does not exist, it's a previously committed node
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	// If there are no more references to the child, delete it and cascade
+	if node.parents > 0 {
+		node.parents--
+	}
+	if node.parents == 0 {
+		for hash := range node.children {
+			db.dereference(hash, child)
+		}
+		delete(db.nodes, child)
+		db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+	}
+}
+
+// Commit iterates over all the children of a particular node, writes them out
+// to disk along with the buffered non-node entries, and removes them from the
+// memory cache. Root references to the flushed nodes are dropped too, as the
+// persisted data is never garbage collected.
+func (db *NodeDatabase) Commit(node common.Hash) error {
+	// Create a database batch to flush persistent data out. It is important that
+	// outside code doesn't see an inconsistent state (referenced data removed from
+	// memory cache during commit but not yet in persistent storage). This is ensured
+	// by only uncaching existing data when the database write finalizes.
+	db.lock.RLock()
+
+	start := time.Now()
+	batch := db.diskdb.NewBatch()
+
+	for key, preimage := range db.preimages {
+		if err := batch.Put([]byte(key), preimage); err != nil {
+			db.lock.RUnlock()
+			return err
+		}
+		if batch.ValueSize() > datxdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				db.lock.RUnlock()
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	// Move the trie itself into the batch, flushing if enough data is accumulated
+	nodes, storage := len(db.nodes), db.nodesSize
+	if err := db.commit(node, batch); err != nil {
+		log.Error("Failed to commit trie from trie database", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	// Write batch ready, unlock for readers during persistence
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to write trie to disk", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	// Write successful, clear out the flushed data
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.preimages = make(map[string][]byte)
+	db.preimagesSize = 0
+
+	db.uncache(node)
+
+	log.Debug("Persisted trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+
+	// Reset the garbage collection statistics
+	db.gcnodes, db.gcsize, db.gctime = 0, 0, 0
+
+	return nil
+}
+
+// commit is the private locked version of Commit.
+func (db *NodeDatabase) commit(hash common.Hash, batch datxdb.Batch) error {
+	// If the node does not exist, it's a previously committed node
+	node, ok := db.nodes[hash]
+	if !ok {
+		return nil
+	}
+	for child := range node.children {
+		if err := db.commit(child, batch); err != nil {
+			return err
+		}
+	}
+	if err := batch.Put(hash[:], node.blob); err != nil {
+		return err
+	}
+	// If we've reached an optimal match size, commit and start over
+	if batch.ValueSize() >= datxdb.IdealBatchSize {
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+	}
+	return nil
+}
+
+// uncache is the post-processing step of a commit operation where the already
+// persisted trie is removed from the cache. The reason behind the two-phase
+// commit is to ensure consistent data availability while moving from memory
+// to disk.
+func (db *NodeDatabase) uncache(hash common.Hash) {
+	// If the node does not exist, we're done on this path
+	node, ok := db.nodes[hash]
+	if !ok {
+		return
+	}
+	// Otherwise uncache the node's subtries and remove the node itself too
+	for child := range node.children {
+		db.uncache(child)
+	}
+	delete(db.nodes, hash)
+	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+}
+
+// Size returns the current storage size of the memory cache in front of the
+// persistent database layer.
+func (db *NodeDatabase) Size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.nodesSize + db.preimagesSize
+}
+
+// Nodes retrieves the hashes of all the nodes cached within the memory database.
+// This method is extremely expensive and should only be used to validate internal
+// states in test code.
+func (db *NodeDatabase) Nodes() []common.Hash {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var hashes = make([]common.Hash, 0, len(db.nodes))
+	for hash := range db.nodes {
+		if hash != (common.Hash{}) { // Special case for "root" references/nodes
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The go-DATx Authors
+// This file is part of the go-DATx library.
+//
+// The go-DATx library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-DATx library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-DATx library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/DATxChain-Protocol/DATx/common"
+	"github.com/DATxChain-Protocol/DATx/datxdb"
+)
+
+// makeNodeDatabaseTries commits two tries sharing most of their nodes into a
+// node database, returning their roots.
+func makeNodeDatabaseTries(t *testing.T, db *NodeDatabase) (common.Hash, common.Hash) {
+	trie, _ := New(common.Hash{}, db)
+	for i := 0; i < 256; i++ {
+		updateString(trie, fmt.Sprintf("key-%03d", i), fmt.Sprintf("value-%03d", i))
+	}
+	first, err := trie.CommitTo(db)
+	if err != nil {
+		t.Fatalf("failed to commit first trie: %v", err)
+	}
+	updateString(trie, "key-000", "changed")
+	second, err := trie.CommitTo(db)
+	if err != nil {
+		t.Fatalf("failed to commit second trie: %v", err)
+	}
+	db.Reference(first, common.Hash{})
+	db.Reference(second, common.Hash{})
+	return first, second
+}
+
+// checkNodeDatabaseTrie verifies that all the values of a trie created by
+// makeNodeDatabaseTries are retrievable from the given database.
+func checkNodeDatabaseTrie(t *testing.T, db Database, root common.Hash, first string) {
+	trie, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	for i := 0; i < 256; i++ {
+		want := fmt.Sprintf("value-%03d", i)
+		if i == 0 {
+			want = first
+		}
+		if have, err := trie.TryGet([]byte(fmt.Sprintf("key-%03d", i))); err != nil || !bytes.Equal(have, []byte(want)) {
+			t.Fatalf("trie %x: key %d: value mismatch: have %q, want %q (err %v)", root, i, have, want, err)
+		}
+	}
+}
+
+// Tests that dereferencing a trie only garbage collects the nodes not shared
+// with other live tries, and that nothing reaches the disk until committed.
+func TestNodeDatabaseDereference(t *testing.T) {
+	diskdb, _ := datxdb.NewMemDatabase()
+	db := NewNodeDatabase(diskdb)
+
+	first, second := makeNodeDatabaseTries(t, db)
+	if n := len(diskdb.Keys()); n != 0 {
+		t.Fatalf("disk database not empty before commit: %d entries", n)
+	}
+	nodes := len(db.Nodes())
+
+	db.Dereference(first)
+	if len(db.Nodes()) >= nodes {
+		t.Fatalf("no nodes garbage collected: have %d, had %d", len(db.Nodes()), nodes)
+	}
+	if _, err := db.Get(first[:]); err == nil {
+		t.Fatalf("dereferenced root still available")
+	}
+	checkNodeDatabaseTrie(t, db, second, "changed")
+
+	db.Dereference(second)
+	if n := len(db.Nodes()); n != 0 {
+		t.Fatalf("nodes left after dereferencing all roots: %d", n)
+	}
+	if db.Size() != 0 {
+		t.Fatalf("cache size left after dereferencing all roots: %v", db.Size())
+	}
+}
+
+// Tests that committing a trie flushes all its nodes to disk and drops them from
+// the cache, leaving the nodes of other tries untouched.
+func TestNodeDatabaseCommit(t *testing.T) {
+	diskdb, _ := datxdb.NewMemDatabase()
+	db := NewNodeDatabase(diskdb)
+
+	first, second := makeNodeDatabaseTries(t, db)
+	if err := db.Commit(first); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	checkNodeDatabaseTrie(t, diskdb, first, "value-000")
+	checkNodeDatabaseTrie(t, db, second, "changed")
+
+	if _, err := diskdb.Get(second[:]); err == nil {
+		t.Fatalf("uncommitted root persisted")
+	}
+	// Garbage collecting the second trie must not touch the persisted one
+	db.Dereference(second)
+	if n := len(db.Nodes()); n != 0 {
+		t.Fatalf("nodes left after dereferencing all roots: %d", n)
+	}
+	checkNodeDatabaseTrie(t, diskdb, first, "value-000")
+}
@@ -30,6 +30,7 @@ type hasher struct {
 	tmp                  *bytes.Buffer
 	sha                  hash.Hash
 	cachegen, cachelimit uint16
+	onleaf               LeafCallback
 }
 
 // hashers live in a global pool.
@@ -39,9 +40,9 @@ var hasherPool = sync.Pool{
 	},
 }
 
-func newHasher(cachegen, cachelimit uint16) *hasher {
+func newHasher(cachegen, cachelimit uint16, onleaf LeafCallback) *hasher {
 	h := hasherPool.Get().(*hasher)
-	h.cachegen, h.cachelimit = cachegen, cachelimit
+	h.cachegen, h.cachelimit, h.onleaf = cachegen, cachelimit, onleaf
 	return h
 }
 
@@ -167,7 +168,28 @@ func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 		hash = hashNode(h.sha.Sum(nil))
 	}
 	if db != nil {
-		return hash, db.Put(hash, h.tmp.Bytes())
+		if err := db.Put(hash, h.tmp.Bytes()); err != nil {
+			return hash, err
+		}
+		// Track external references from the leaves of the stored node
+		if h.onleaf != nil {
+			switch n := n.(type) {
+			case *shortNode:
+				if child, ok := n.Val.(valueNode); ok {
+					if err := h.onleaf(child, common.BytesToHash(hash)); err != nil {
+						return hash, err
+					}
+				}
+			case *fullNode:
+				for i := 0; i < 17; i++ {
+					if child, ok := n.Children[i].(valueNode); ok && len(child) > 0 {
+						if err := h.onleaf(child, common.BytesToHash(hash)); err != nil {
+							return hash, err
+						}
+					}
+				}
+			}
+		}
 	}
 	return hash, nil
 }
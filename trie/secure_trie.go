@@ -174,6 +174,13 @@ func (t *SecureTrie) NodeIterator(start []byte) NodeIterator {
 // the trie's database. Calling code must ensure that the changes made to db are
 // written back to the trie's attached database before using the trie.
 func (t *SecureTrie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
+	return t.CommitToWithCallback(db, nil)
+}
+
+// CommitToWithCallback writes all nodes and the secure hash pre-images to the
+// given database like CommitTo, invoking onleaf for every leaf value contained
+// in a stored node.
+func (t *SecureTrie) CommitToWithCallback(db DatabaseWriter, onleaf LeafCallback) (root common.Hash, err error) {
 	if len(t.getSecKeyCache()) > 0 {
 		for hk, key := range t.secKeyCache {
 			if err := db.Put(t.secKey([]byte(hk)), key); err != nil {
@@ -182,7 +189,7 @@ func (t *SecureTrie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
 		}
 		t.secKeyCache = make(map[string][]byte)
 	}
-	return t.trie.CommitTo(db)
+	return t.trie.CommitToWithCallback(db, onleaf)
 }
 
 // secKey returns the database key for the preimage of key, as an ephemeral buffer.
@@ -198,7 +205,7 @@ func (t *SecureTrie) secKey(key []byte) []byte {
 // The caller must not hold onto the return value because it will become
 // invalid on the next call to hashKey or secKey.
 func (t *SecureTrie) hashKey(key []byte) []byte {
-	h := newHasher(0, 0)
+	h := newHasher(0, 0, nil)
 	h.sha.Reset()
 	h.sha.Write(key)
 	buf := h.sha.Sum(t.hashKeyBuf[:0])
@@ -69,6 +69,11 @@ type DatabaseReader interface {
 	Has(key []byte) (bool, error)
 }
 
+// LeafCallback is a callback type invoked when a trie commit stores a node
+// holding a leaf value. It's used by the state to reference the storage tries
+// and code of accounts from the account trie node holding them.
+type LeafCallback func(leaf []byte, parent common.Hash) error
+
 // DatabaseWriter wraps the Put method of a backing store for the trie.
 type DatabaseWriter interface {
 	// Put stores the mapping key->value in the database.
@@ -493,7 +498,7 @@ func (t *Trie) Root() []byte { return t.Hash().Bytes() }
 // Hash returns the root hash of the trie. It does not write to the
 // database and can be used even if the trie doesn't have one.
 func (t *Trie) Hash() common.Hash {
-	hash, cached, _ := t.hashRoot(nil)
+	hash, cached, _ := t.hashRoot(nil, nil)
 	t.root = cached
 	return common.BytesToHash(hash.(hashNode))
 }
@@ -518,7 +523,13 @@ func (t *Trie) Commit() (root common.Hash, err error) {
 // the changes made to db are written back to the trie's attached
 // database before using the trie.
 func (t *Trie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
-	hash, cached, err := t.hashRoot(db)
+	return t.CommitToWithCallback(db, nil)
+}
+
+// CommitToWithCallback writes all nodes to the given database like CommitTo,
+// invoking onleaf for every leaf value contained in a stored node.
+func (t *Trie) CommitToWithCallback(db DatabaseWriter, onleaf LeafCallback) (root common.Hash, err error) {
+	hash, cached, err := t.hashRoot(db, onleaf)
 	if err != nil {
 		return (common.Hash{}), err
 	}
@@ -527,11 +538,11 @@ func (t *Trie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
 	return common.BytesToHash(hash.(hashNode)), nil
 }
 
-func (t *Trie) hashRoot(db DatabaseWriter) (node, node, error) {
+func (t *Trie) hashRoot(db DatabaseWriter, onleaf LeafCallback) (node, node, error) {
 	if t.root == nil {
 		return hashNode(emptyRoot.Bytes()), nil, nil
 	}
-	h := newHasher(t.cachegen, t.cachelimit)
+	h := newHasher(t.cachegen, t.cachelimit, onleaf)
 	defer returnHasherToPool(h)
 	return h.hash(t.root, db, true)
 }